@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"moirai/internal/app"
+	"moirai/internal/auto"
+	"moirai/internal/backup"
+	"moirai/internal/link"
+	"moirai/internal/lock"
+)
+
+const autoUsage = "moirai auto [--dir <path>] [--quiet] | moirai auto hook bash|zsh|fish"
+
+type autoResult struct {
+	Dir      string      `json:"dir"`
+	Match    *auto.Match `json:"match"`
+	Previous string      `json:"previous,omitempty"`
+	Applied  bool        `json:"applied"`
+	quiet    bool
+}
+
+func (r autoResult) writeText(w io.Writer) {
+	if r.quiet {
+		if r.Applied {
+			fmt.Fprintf(w, "moirai: switched to profile %s\n", r.Match.Profile)
+		}
+		return
+	}
+	if r.Match == nil {
+		fmt.Fprintf(w, "No profile configured for %s\n", r.Dir)
+		return
+	}
+	if r.Applied {
+		fmt.Fprintf(w, "Applied: %s\n", r.Match.Profile)
+	} else {
+		fmt.Fprintf(w, "Already active: %s\n", r.Match.Profile)
+	}
+	fmt.Fprintf(w, "Matched: %s (%s)\n", r.Match.Rule, r.Match.Source)
+}
+
+type hookResult struct {
+	Shell  string `json:"shell"`
+	Script string `json:"script"`
+}
+
+func (r hookResult) writeText(w io.Writer) {
+	fmt.Fprint(w, r.Script)
+}
+
+func runAuto(config app.AppConfig, args []string) (textResult, error) {
+	if len(args) > 0 && args[0] == "hook" {
+		if len(args) != 2 {
+			return nil, usageError(autoUsage)
+		}
+		script, err := auto.Hook(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return hookResult{Shell: args[1], Script: script}, nil
+	}
+
+	autoFlags := flag.NewFlagSet("auto", flag.ContinueOnError)
+	autoFlags.SetOutput(io.Discard)
+	dir := autoFlags.String("dir", "", "directory to resolve (default: current directory)")
+	quiet := autoFlags.Bool("quiet", false, "only print when the profile changes")
+	if err := autoFlags.Parse(args); err != nil {
+		return nil, err
+	}
+	if autoFlags.NArg() != 0 {
+		return nil, usageError(autoUsage)
+	}
+	if *dir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		*dir = wd
+	}
+
+	res := autoResult{Dir: *dir, quiet: *quiet}
+	match, ok, err := auto.Resolve(config, *dir)
+	if err != nil || !ok {
+		return res, err
+	}
+	res.Match = &match
+
+	active, hasActive, err := link.ActiveProfile(config.ConfigDir)
+	if err != nil {
+		return res, err
+	}
+	if hasActive {
+		res.Previous = active
+		if active == match.Profile {
+			return res, nil
+		}
+	}
+
+	// Only take the lock when switching so prompt hooks stay cheap.
+	held, err := lock.Acquire(config.ConfigDir, config.LockTimeout)
+	if err != nil {
+		return res, err
+	}
+	defer held.Release()
+	applied, err := link.ApplyProfileWith(config.ConfigDir, match.Profile, link.ApplyOptions{
+		Strict:    config.StrictReferences,
+		Backups:   backup.NewStore(config),
+		Retention: config.BackupRetention,
+	})
+	if err != nil {
+		return res, err
+	}
+	res.Applied = true
+	return res, commitOperation(config, applied.Entry)
+}
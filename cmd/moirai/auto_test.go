@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"moirai/internal/link"
+)
+
+func TestRunAutoAppliesMarkerProfileOnce(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	project := filepath.Join(t.TempDir(), "project")
+	if err := os.MkdirAll(filepath.Join(project, "src"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(project, ".moirai"), []byte("alpha\n"), 0o644); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	args := []string{"moirai", "auto", "--quiet", "--dir", filepath.Join(project, "src")}
+	if exitCode := run(args, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	if stdout.String() != "moirai: switched to profile alpha\n" {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+	active, ok, err := link.ActiveProfile(configDir)
+	if err != nil || !ok || active != "alpha" {
+		t.Fatalf("expected alpha active, got %q %v %v", active, ok, err)
+	}
+
+	stdout.Reset()
+	if exitCode := run(args, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected quiet no-op, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if exitCode := run([]string{"moirai", "auto", "--dir", project}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d", exitCode)
+	}
+	if !strings.Contains(stdout.String(), "Already active: alpha") {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+}
+
+func TestRunAutoUsesDirectoryMapping(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	work := t.TempDir()
+	config := `{"directoryProfiles":[{"path":"` + filepath.Join(work, "*") + `","profile":"alpha"}]}`
+	if err := os.WriteFile(filepath.Join(configDir, "moirai.json"), []byte(config), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	repo := filepath.Join(work, "client")
+	if err := os.MkdirAll(repo, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "auto", "--dir", repo}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Applied: alpha") {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if exitCode := run([]string{"moirai", "auto", "--dir", work}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d", exitCode)
+	}
+	if !strings.Contains(stdout.String(), "No profile configured for") {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+}
+
+func TestRunAutoHook(t *testing.T) {
+	setupOutputConfig(t)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "auto", "hook", "zsh"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d", exitCode)
+	}
+	if !strings.Contains(stdout.String(), "add-zsh-hook chpwd _moirai_auto") {
+		t.Fatalf("unexpected hook %q", stdout.String())
+	}
+}
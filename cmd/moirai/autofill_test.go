@@ -1,12 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"moirai/internal/app"
 	"moirai/internal/backup"
+	"moirai/internal/journal"
+	"moirai/internal/models"
+	"moirai/internal/profile"
 )
 
 func TestAutofillRefusedWhenDisabled(t *testing.T) {
@@ -18,15 +24,32 @@ func TestAutofillRefusedWhenDisabled(t *testing.T) {
 	}
 
 	config := app.AppConfig{ConfigDir: configDir, EnableAutofill: false}
-	exitCode, err := runAutofill(config, profileName, "openai")
-	if err != nil {
-		t.Fatalf("expected no error, got %v", err)
+	_, exitCode, err := runAutofill(config, profileName, "openai", profile.AutofillOptions{}, false)
+	if !errors.Is(err, errAutofillDisabled) {
+		t.Fatalf("expected errAutofillDisabled, got %v", err)
 	}
 	if exitCode != 3 {
 		t.Fatalf("expected exit code 3, got %d", exitCode)
 	}
 }
 
+func TestAutofillRejectsPathInProfileName(t *testing.T) {
+	dir := t.TempDir()
+	configDir := filepath.Join(dir, "opencode")
+	if err := os.MkdirAll(configDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	outside := filepath.Join(dir, "x")
+	if err := os.WriteFile(outside, []byte(`{"agents":{"sisyphus":{"model":""}}}`), 0o600); err != nil {
+		t.Fatalf("write outside profile: %v", err)
+	}
+
+	config := app.AppConfig{ConfigDir: configDir, EnableAutofill: true}
+	if _, _, err := runAutofill(config, "x/../../x", "openai", profile.AutofillOptions{}, true); err == nil {
+		t.Fatal("expected autofill to reject the name")
+	}
+}
+
 func TestAutofillCreatesBackup(t *testing.T) {
 	configDir := t.TempDir()
 	profileName := "alpha"
@@ -37,7 +60,7 @@ func TestAutofillCreatesBackup(t *testing.T) {
 	}
 
 	config := app.AppConfig{ConfigDir: configDir, EnableAutofill: true}
-	exitCode, err := runAutofill(config, profileName, "openai")
+	res, exitCode, err := runAutofill(config, profileName, "openai", profile.AutofillOptions{}, false)
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -45,14 +68,20 @@ func TestAutofillCreatesBackup(t *testing.T) {
 		t.Fatalf("expected exit code 0, got %d", exitCode)
 	}
 
-	backups, err := backup.ListProfileBackups(configDir, profileName)
+	backups, err := backup.ListProfileBackups(backup.NewStore(config), profileName)
 	if err != nil {
 		t.Fatalf("ListProfileBackups: %v", err)
 	}
 	if len(backups) != 1 {
 		t.Fatalf("expected 1 backup, got %d", len(backups))
 	}
-	backupPath := filepath.Join(configDir, backups[0])
+	backupPath, err := backup.Locate(backup.NewStore(config), backups[0])
+	if err != nil {
+		t.Fatalf("Locate: %v", err)
+	}
+	if res.Backup != backupPath {
+		t.Fatalf("expected result backup %q, got %q", backupPath, res.Backup)
+	}
 	backupData, err := os.ReadFile(backupPath)
 	if err != nil {
 		t.Fatalf("read backup: %v", err)
@@ -61,3 +90,80 @@ func TestAutofillCreatesBackup(t *testing.T) {
 		t.Fatalf("backup does not match original content")
 	}
 }
+
+func TestAutofillWarnsWhenItCannotBeJournaled(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	profilePath := filepath.Join(configDir, "oh-my-opencode.json.alpha")
+	if err := os.WriteFile(profilePath, []byte(`{"agents":{"sisyphus":{"model":""}}}`), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	// A directory where the journal belongs makes every append fail.
+	if err := os.MkdirAll(journal.Path(configDir), 0o700); err != nil {
+		t.Fatalf("mkdir journal: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	args := []string{"moirai", "--enable-autofill", "autofill", "alpha", "--preset", "openai"}
+	if exitCode := run(args, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected autofill to succeed, got %d: %s", exitCode, stderr.String())
+	}
+	if !strings.HasPrefix(stderr.String(), "Warning: journal autofill:") {
+		t.Fatalf("expected a journal warning, got %q", stderr.String())
+	}
+	if data, _ := os.ReadFile(profilePath); strings.Contains(string(data), `"model":""`) {
+		t.Fatalf("expected the profile autofilled, got %s", data)
+	}
+}
+
+func TestRunAutofillModesAndDryRun(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	profilePath := filepath.Join(configDir, "oh-my-opencode.json.alpha")
+	original := []byte(`{"agents":{"oracle":{"model":"retired"},"explore":{"model":"gpt-4o-mini"}}}`)
+	if err := os.WriteFile(profilePath, original, 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	if err := models.SaveCachedModelsAtomic(filepath.Dir(configDir), []string{"gpt-4o-mini"}); err != nil {
+		t.Fatalf("save models cache: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	args := []string{"moirai", "--enable-autofill", "autofill", "alpha", "--preset", "openai", "--mode", "upgrade", "--dry-run"}
+	if exitCode := run(args, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	output := stdout.String()
+	if !strings.Contains(output, "Plan for alpha (preset openai, mode upgrade):") || !strings.Contains(output, " - oracle: retired -> gpt-4o-mini") || !strings.Contains(output, "Dry run") {
+		t.Fatalf("unexpected output:\n%s", output)
+	}
+	if strings.Contains(output, "sisyphus") {
+		t.Fatalf("upgrade should not fill missing agents:\n%s", output)
+	}
+	data, err := os.ReadFile(profilePath)
+	if err != nil || string(data) != string(original) {
+		t.Fatalf("dry run modified the profile: %s %v", data, err)
+	}
+
+	stdout.Reset()
+	args = []string{"moirai", "--enable-autofill", "autofill", "alpha", "--preset", "openai", "--mode", "only=oracle"}
+	if exitCode := run(args, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	cfg, err := profile.LoadProfile(profilePath)
+	if err != nil {
+		t.Fatalf("LoadProfile: %v", err)
+	}
+	if cfg.Agents["oracle"].Model != "gpt-4o-mini" {
+		t.Fatalf("expected oracle reset, got %#v", cfg.Agents)
+	}
+	if _, ok := cfg.Agents["sisyphus"]; ok {
+		t.Fatalf("only= should leave other agents alone: %#v", cfg.Agents)
+	}
+
+	stderr.Reset()
+	args = []string{"moirai", "--enable-autofill", "autofill", "alpha", "--preset", "openai", "--mode", "replace"}
+	if exitCode := run(args, noTUI, stdout, stderr); exitCode != 1 || !strings.Contains(stderr.String(), "invalid autofill mode") {
+		t.Fatalf("expected invalid mode error, got %d %s", exitCode, stderr.String())
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+)
+
+func TestBackupNoteAndBackupsColumns(t *testing.T) {
+	configDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(configDir, "oh-my-opencode.json.alpha"), []byte("{}"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	config := app.AppConfig{ConfigDir: configDir}
+
+	first, err := runBackup(config, []string{"alpha", "-m", "before experiment"})
+	if err != nil {
+		t.Fatalf("runBackup: %v", err)
+	}
+	if first.Reused || first.Reason != backup.ReasonManual || first.Note != "before experiment" {
+		t.Fatalf("unexpected backup result: %+v", first)
+	}
+	second, err := runBackup(config, []string{"alpha"})
+	if err != nil {
+		t.Fatalf("runBackup: %v", err)
+	}
+	if !second.Reused || second.Backup != first.Backup {
+		t.Fatalf("expected identical content to reuse %q, got %+v", first.Backup, second)
+	}
+
+	res, err := runBackups(config, "alpha")
+	if err != nil {
+		t.Fatalf("runBackups: %v", err)
+	}
+	if len(res.Backups) != 1 || res.Backups[0].SHA256 != first.SHA256 || res.Backups[0].Version != app.Version {
+		t.Fatalf("unexpected backups: %+v", res.Backups)
+	}
+	var out bytes.Buffer
+	res.writeText(&out)
+	want := "manual    " + app.Version
+	if !strings.Contains(out.String(), want) || !strings.Contains(out.String(), first.SHA256[:12]) || !strings.Contains(out.String(), "before experiment") {
+		t.Fatalf("expected reason, version, hash and note columns, got %q", out.String())
+	}
+
+	if _, err := runBackup(config, []string{"alpha", "extra"}); err == nil {
+		t.Fatal("expected usage error for extra arguments")
+	}
+}
+
+func TestMigrateBackupsMovesLegacyBackups(t *testing.T) {
+	configDir := t.TempDir()
+	legacy := "oh-my-opencode.json.alpha.bak.20240101-000000"
+	for _, name := range []string{"oh-my-opencode.json.alpha", legacy} {
+		if err := os.WriteFile(filepath.Join(configDir, name), []byte("{}"), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	config := app.AppConfig{ConfigDir: configDir}
+
+	res, err := runMigrateBackups(config, []string{"--dry-run"})
+	if err != nil || !res.DryRun || len(res.Moved) != 1 {
+		t.Fatalf("unexpected dry run result: %+v %v", res, err)
+	}
+	res, err = runMigrateBackups(config, nil)
+	if err != nil || len(res.Moved) != 1 {
+		t.Fatalf("unexpected result: %+v %v", res, err)
+	}
+	want := filepath.Join(app.BackupsDir(configDir), "alpha", legacy)
+	if res.Moved[0].To != want {
+		t.Fatalf("expected %s, got %+v", want, res.Moved[0])
+	}
+
+	diff, exitCode, err := runDiffAgainstLastBackup(config, "alpha")
+	if err != nil || exitCode != 0 || !diff.Found {
+		t.Fatalf("expected diff against the migrated backup, got %+v %d %v", diff, exitCode, err)
+	}
+}
+
+func TestActiveBackupsRestoreAndAdopt(t *testing.T) {
+	configDir := t.TempDir()
+	activePath := filepath.Join(configDir, "oh-my-opencode.json")
+	if err := os.WriteFile(activePath, []byte(`{"hand":"written"}`), 0o600); err != nil {
+		t.Fatalf("write active: %v", err)
+	}
+	saved, err := backup.BackupActive(backup.NewStore(app.AppConfig{ConfigDir: configDir}))
+	if err != nil {
+		t.Fatalf("BackupActive: %v", err)
+	}
+	if err := os.WriteFile(activePath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("overwrite active: %v", err)
+	}
+	config := app.AppConfig{ConfigDir: configDir}
+
+	list, err := runActiveBackups(config)
+	if err != nil || !list.Active || len(list.Backups) != 1 || list.Backups[0].Reason != backup.ReasonApply {
+		t.Fatalf("unexpected active backups: %+v %v", list, err)
+	}
+	name := list.Backups[0].Name
+
+	restored, err := runRestoreActive(config, name)
+	if err != nil || !restored.Active || restored.PreBackup == "" {
+		t.Fatalf("unexpected restore result: %+v %v", restored, err)
+	}
+	if data, _ := os.ReadFile(activePath); string(data) != `{"hand":"written"}` {
+		t.Fatalf("expected the backup content, got %q", data)
+	}
+
+	adopted, err := runAdopt(config, []string{"legacy", "--from-backup", saved})
+	if err != nil {
+		t.Fatalf("runAdopt: %v", err)
+	}
+	if data, _ := os.ReadFile(adopted.Path); string(data) != `{"hand":"written"}` {
+		t.Fatalf("expected adopted content, got %q", data)
+	}
+	if _, err := runAdopt(config, []string{"other", "extra"}); err == nil {
+		t.Fatal("expected usage error for extra arguments")
+	}
+}
+
+func TestAdoptActiveConfig(t *testing.T) {
+	configDir := t.TempDir()
+	activePath := filepath.Join(configDir, "oh-my-opencode.json")
+	if err := os.WriteFile(activePath, []byte(`{"hand":"written"}`), 0o600); err != nil {
+		t.Fatalf("write active: %v", err)
+	}
+	config := app.AppConfig{ConfigDir: configDir}
+
+	res, err := runAdopt(config, []string{"mine"})
+	if err != nil {
+		t.Fatalf("runAdopt: %v", err)
+	}
+	if !res.Active || res.Backup == "" || res.Path != filepath.Join(configDir, "oh-my-opencode.json.mine") {
+		t.Fatalf("unexpected adopt result: %+v", res)
+	}
+	if target, err := os.Readlink(activePath); err != nil || target != "oh-my-opencode.json.mine" {
+		t.Fatalf("expected the active config to link to mine, got %q %v", target, err)
+	}
+	var out bytes.Buffer
+	res.writeText(&out)
+	if !strings.Contains(out.String(), "Active: mine") {
+		t.Fatalf("unexpected text output %q", out.String())
+	}
+}
+
+func TestBackupCommandsRejectPathInProfileName(t *testing.T) {
+	config := app.AppConfig{ConfigDir: t.TempDir()}
+	name := "x/../../foo"
+	if _, err := runBackup(config, []string{name}); err == nil || !strings.Contains(err.Error(), "invalid profile name") {
+		t.Fatalf("runBackup: expected invalid name, got %v", err)
+	}
+	if _, err := runBackups(config, name); err == nil {
+		t.Fatal("runBackups: expected invalid name")
+	}
+	if _, err := runRestore(config, name, "backup"); err == nil {
+		t.Fatal("runRestore: expected invalid name")
+	}
+	if _, exitCode, err := runDiffAgainstLastBackup(config, name); err == nil || exitCode != 1 {
+		t.Fatalf("runDiffAgainstLastBackup: expected invalid name, got %d %v", exitCode, err)
+	}
+}
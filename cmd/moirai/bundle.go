@@ -0,0 +1,181 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+	"moirai/internal/bundle"
+	"moirai/internal/secrets"
+	"moirai/internal/util"
+)
+
+const (
+	exportUsage = "moirai export <profile>... -o <bundle.tar.gz> [--with-backups] [--include-secrets]"
+	importUsage = "moirai import <bundle.tar.gz> [--as <name>] [--on-conflict rename|overwrite|skip]"
+)
+
+type exportResult struct {
+	Bundle        string    `json:"bundle"`
+	Profiles      []string  `json:"profiles"`
+	Backups       int       `json:"backups"`
+	Redacted      int       `json:"redacted"`
+	MoiraiVersion string    `json:"moiraiVersion"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+func (r exportResult) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Exported: %s\n", strings.Join(r.Profiles, ", "))
+	if r.Backups > 0 {
+		fmt.Fprintf(w, "Backups: %d\n", r.Backups)
+	}
+	if r.Redacted > 0 {
+		fmt.Fprintf(w, "Redacted secrets: %d (use --include-secrets to keep them)\n", r.Redacted)
+	}
+	fmt.Fprintf(w, "Bundle: %s\n", r.Bundle)
+}
+
+// splitLeadingArgs returns the positional arguments before the first flag
+// and the rest, so flags may follow a list of names.
+func splitLeadingArgs(args []string) ([]string, []string) {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			return args[:i], args[i:]
+		}
+	}
+	return args, nil
+}
+
+func runExport(config app.AppConfig, args []string) (exportResult, error) {
+	names, flagArgs := splitLeadingArgs(args)
+	exportFlags := flag.NewFlagSet("export", flag.ContinueOnError)
+	exportFlags.SetOutput(io.Discard)
+	output := exportFlags.String("o", "", "bundle file to write")
+	withBackups := exportFlags.Bool("with-backups", false, "include profile backups")
+	includeSecrets := exportFlags.Bool("include-secrets", false, "keep secret values instead of redacting them")
+	if err := exportFlags.Parse(flagArgs); err != nil {
+		return exportResult{}, err
+	}
+	names = append(names, exportFlags.Args()...)
+	if len(names) == 0 || *output == "" {
+		return exportResult{}, usageError(exportUsage)
+	}
+	outputPath, err := util.ExpandUser(*output)
+	if err != nil {
+		return exportResult{}, err
+	}
+
+	// Write next to the destination and rename so a failed export never
+	// leaves a truncated bundle behind.
+	tempFile, err := os.CreateTemp(filepath.Dir(outputPath), ".tmp-bundle-")
+	if err != nil {
+		return exportResult{}, err
+	}
+	tempName := tempFile.Name()
+	defer os.Remove(tempName)
+	manifest, err := bundle.Export(tempFile, config.ConfigDir, names, bundle.ExportOptions{
+		IncludeBackups: *withBackups,
+		IncludeSecrets: *includeSecrets,
+		Backups:        backup.NewStore(config),
+	})
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return exportResult{}, err
+	}
+	if err := os.Rename(tempName, outputPath); err != nil {
+		return exportResult{}, err
+	}
+
+	res := exportResult{
+		Bundle:        outputPath,
+		MoiraiVersion: manifest.MoiraiVersion,
+		CreatedAt:     manifest.CreatedAt,
+	}
+	for _, entry := range manifest.Profiles {
+		res.Profiles = append(res.Profiles, entry.Name)
+		res.Backups += len(entry.Backups)
+		res.Redacted += entry.Redacted
+	}
+	return res, nil
+}
+
+type importResult struct {
+	Bundle        string                `json:"bundle"`
+	MoiraiVersion string                `json:"moiraiVersion"`
+	CreatedAt     time.Time             `json:"createdAt"`
+	Profiles      []bundle.ImportResult `json:"profiles"`
+}
+
+func (r importResult) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Bundle: %s (moirai %s, %s)\n", r.Bundle, r.MoiraiVersion, r.CreatedAt.Format(time.RFC3339))
+	for _, entry := range r.Profiles {
+		switch entry.Action {
+		case bundle.ActionSkipped:
+			fmt.Fprintf(w, " - %s: skipped (already exists)\n", entry.Profile)
+		case bundle.ActionOverwritten:
+			fmt.Fprintf(w, " - %s -> %s: overwritten, %d changes, backup: %s\n", entry.Profile, entry.Target, len(entry.Changes), entry.Backup)
+			for _, change := range entry.Changes {
+				fmt.Fprintf(w, "   %s %s\n", change.Kind, change.Path)
+			}
+		default:
+			fmt.Fprintf(w, " - %s -> %s: %s\n", entry.Profile, entry.Target, entry.Action)
+		}
+		if len(entry.Backups) > 0 {
+			fmt.Fprintf(w, "   restored %d backups\n", len(entry.Backups))
+		}
+	}
+}
+
+func runImport(config app.AppConfig, args []string) (importResult, error) {
+	bundlePath, flagArgs := splitPositional(args)
+	importFlags := flag.NewFlagSet("import", flag.ContinueOnError)
+	importFlags.SetOutput(io.Discard)
+	as := importFlags.String("as", "", "import a single profile under this name")
+	onConflict := importFlags.String("on-conflict", "", "rename, overwrite or skip")
+	if err := importFlags.Parse(flagArgs); err != nil {
+		return importResult{}, err
+	}
+	if bundlePath == "" || importFlags.NArg() != 0 {
+		return importResult{}, usageError(importUsage)
+	}
+	conflict, err := bundle.ParseConflict(*onConflict)
+	if err != nil {
+		return importResult{}, err
+	}
+
+	file, err := os.Open(bundlePath)
+	if err != nil {
+		return importResult{}, err
+	}
+	defer file.Close()
+	b, err := bundle.Read(file)
+	if err != nil {
+		return importResult{}, err
+	}
+	results, err := bundle.Import(config.ConfigDir, b, bundle.ImportOptions{
+		As:         *as,
+		OnConflict: conflict,
+		Backups:    backup.NewStore(config),
+		Retention:  config.BackupRetention,
+	})
+	if err != nil {
+		return importResult{}, err
+	}
+	for i := range results {
+		results[i].Changes = secrets.RedactChanges(results[i].Changes)
+	}
+	return importResult{
+		Bundle:        bundlePath,
+		MoiraiVersion: b.Manifest.MoiraiVersion,
+		CreatedAt:     b.Manifest.CreatedAt,
+		Profiles:      results,
+	}, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunExportImport(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	bundlePath := filepath.Join(t.TempDir(), "team.tar.gz")
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "export", "alpha", "beta", "-o", bundlePath}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("export failed with %d: %s", exitCode, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Exported: alpha, beta") {
+		t.Fatalf("unexpected export output %q", stdout.String())
+	}
+
+	if err := os.WriteFile(filepath.Join(configDir, "oh-my-opencode.json.alpha"), []byte(`{"agents":{"oracle":{"model":"local"}}}`), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	stdout.Reset()
+	stderr.Reset()
+	if exitCode := run([]string{"moirai", "import", bundlePath}, noTUI, stdout, stderr); exitCode != 1 {
+		t.Fatalf("expected conflict failure, got %d", exitCode)
+	}
+	if !strings.Contains(stderr.String(), "--on-conflict") {
+		t.Fatalf("unexpected stderr %q", stderr.String())
+	}
+
+	stdout.Reset()
+	args := []string{"moirai", "--output", "json", "import", bundlePath, "--on-conflict", "overwrite"}
+	if exitCode := run(args, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("import failed with %d: %s", exitCode, stderr.String())
+	}
+	var envelope struct {
+		Result importResult `json:"result"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &envelope); err != nil {
+		t.Fatalf("decode: %v\n%s", err, stdout.String())
+	}
+	profiles := envelope.Result.Profiles
+	if len(profiles) != 2 || profiles[0].Backup == "" || len(profiles[0].Changes) == 0 {
+		t.Fatalf("unexpected import result %#v", profiles)
+	}
+	data, err := os.ReadFile(filepath.Join(configDir, "oh-my-opencode.json.alpha"))
+	if err != nil || strings.Contains(string(data), "local") {
+		t.Fatalf("expected alpha overwritten, got %q %v", data, err)
+	}
+}
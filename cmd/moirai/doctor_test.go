@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunDoctorFixRemovesStrayTempFiles(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	tempPath := filepath.Join(configDir, ".tmp-999")
+	if err := os.WriteFile(tempPath, []byte("partial"), 0o600); err != nil {
+		t.Fatalf("write temp: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exitCode := run([]string{"moirai", "doctor"}, noTUI, stdout, stderr)
+	if exitCode != 2 {
+		t.Fatalf("expected exit code 2, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "[warning] stray-temp-files "+tempPath) || !strings.Contains(stdout.String(), "(fixable with --fix)") {
+		t.Fatalf("unexpected output:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	exitCode = run([]string{"moirai", "--output", "json", "doctor", "--fix"}, noTUI, stdout, stderr)
+	if exitCode != 2 {
+		// Missing agent models remain and have no automatic fix.
+		t.Fatalf("expected exit code 2, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	var envelope struct {
+		Result doctorResult `json:"result"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &envelope); err != nil {
+		t.Fatalf("decode: %v\n%s", err, stdout.String())
+	}
+	fixed := false
+	for _, finding := range envelope.Result.Findings {
+		if finding.Check == "stray-temp-files" {
+			fixed = finding.Fixed
+		}
+		if finding.Check == "agent-missing-model" && finding.Fixable {
+			t.Fatalf("missing models should not be fixable: %#v", finding)
+		}
+	}
+	if !fixed {
+		t.Fatalf("expected stray temp finding fixed:\n%s", stdout.String())
+	}
+	if _, err := os.Stat(tempPath); !os.IsNotExist(err) {
+		t.Fatalf("expected temp file removed, got %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"moirai/internal/app"
+	"moirai/internal/ephemeral"
+)
+
+const execUsage = "moirai exec --profile <name> [--model <agent>=<model>]... -- <command> [args...]"
+
+// forwardedSignals are relayed to the child so it can shut down cleanly
+// before the temp config home is removed.
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// runExec starts a command with a private config home in which the profile
+// is the active config. It returns the child's exit code; err is set only
+// when the command could not be started.
+func runExec(config app.AppConfig, args []string, stdout, stderr io.Writer) (int, error) {
+	execFlags := flag.NewFlagSet("exec", flag.ContinueOnError)
+	execFlags.SetOutput(io.Discard)
+	profileName := execFlags.String("profile", "", "profile to run with")
+	overrides := map[string]string{}
+	execFlags.Func("model", "override an agent model as <agent>=<model>", func(value string) error {
+		agent, model, ok := strings.Cut(value, "=")
+		if !ok || agent == "" || model == "" {
+			return fmt.Errorf("invalid --model %q (want <agent>=<model>)", value)
+		}
+		overrides[agent] = model
+		return nil
+	})
+	if err := execFlags.Parse(args); err != nil {
+		return 1, err
+	}
+	command := execFlags.Args()
+	if *profileName == "" || len(command) == 0 {
+		return 1, usageError(execUsage)
+	}
+
+	env, err := ephemeral.Prepare(config.ConfigDir, *profileName, overrides)
+	if err != nil {
+		return 1, err
+	}
+	defer env.Cleanup()
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = env.Environ(os.Environ())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+	if err := cmd.Start(); err != nil {
+		return 127, err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				_ = cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			return 128 + int(status.Signal()), nil
+		}
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestRunExecUsesPrivateConfigAndExitCode(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	setupOutputConfig(t)
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	script := `echo "$XDG_CONFIG_HOME"; cat "$XDG_CONFIG_HOME/opencode/oh-my-opencode.json"; exit 7`
+	args := []string{"moirai", "exec", "--profile", "alpha", "--model", "oracle=o3", "--", "sh", "-c", script}
+	exitCode := run(args, noTUI, stdout, stderr)
+	if exitCode != 7 {
+		t.Fatalf("expected child exit code 7, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	home, config, _ := strings.Cut(stdout.String(), "\n")
+	if !strings.Contains(config, `"model": "o3"`) {
+		t.Fatalf("unexpected child config:\n%s", config)
+	}
+	if _, err := os.Stat(home); !os.IsNotExist(err) {
+		t.Fatalf("expected temp config home %q removed, got %v", home, err)
+	}
+}
+
+func TestRunExecRequiresCommand(t *testing.T) {
+	setupOutputConfig(t)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "exec", "--profile", "alpha"}, noTUI, stdout, stderr); exitCode != 1 {
+		t.Fatalf("expected exit code 1, got %d", exitCode)
+	}
+	if !strings.Contains(stderr.String(), "Usage: moirai exec") {
+		t.Fatalf("unexpected stderr %q", stderr.String())
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"moirai/internal/app"
+	"moirai/internal/gitstore"
+	"moirai/internal/journal"
+	"moirai/internal/profile"
+)
+
+// commitOperation commits the journaled operation entry to the git store
+// when config enables it. The operation already happened, so a failed
+// commit is returned as a warning.
+func commitOperation(config app.AppConfig, entry journal.Entry) error {
+	if !config.Git.Enabled || entry.Op == "" {
+		return nil
+	}
+	change := gitstore.Change{Op: entry.Op, Profile: entry.Profile, Journal: entry.ID}
+	if _, err := gitstore.Commit(config.ConfigDir, config.Git, change); err != nil {
+		return warning{fmt.Errorf("git store commit failed: %w", err)}
+	}
+	return nil
+}
+
+const (
+	logUsage  = "moirai log [<profile>] [--limit <n>]"
+	syncUsage = "moirai sync"
+)
+
+type logResult struct {
+	Profile string              `json:"profile,omitempty"`
+	Commits []gitstore.LogEntry `json:"commits"`
+}
+
+func (r logResult) writeText(w io.Writer) {
+	fmt.Fprintln(w, "Log:")
+	if len(r.Commits) == 0 {
+		fmt.Fprintln(w, " (none)")
+		return
+	}
+	for _, commit := range r.Commits {
+		fmt.Fprintf(w, " - %s %s %s\n", shortHash(commit.Hash), commit.Time.Local().Format("2006-01-02 15:04:05"), commit.Subject)
+	}
+}
+
+func runLog(config app.AppConfig, args []string) (logResult, error) {
+	profileName, flagArgs := splitPositional(args)
+	logFlags := flag.NewFlagSet("log", flag.ContinueOnError)
+	logFlags.SetOutput(io.Discard)
+	limit := logFlags.Int("limit", 20, "number of commits to show; 0 shows all")
+	if err := logFlags.Parse(flagArgs); err != nil || logFlags.NArg() != 0 || *limit < 0 {
+		return logResult{}, usageError(logUsage)
+	}
+	if profileName != "" {
+		if err := profile.ValidateName(profileName); err != nil {
+			return logResult{}, err
+		}
+	}
+	store, err := gitstore.Open(config.ConfigDir, config.Git)
+	if err != nil {
+		return logResult{}, err
+	}
+	commits, err := store.Log(profileName, *limit)
+	if err != nil {
+		return logResult{}, err
+	}
+	return logResult{Profile: profileName, Commits: commits}, nil
+}
+
+type syncResult struct {
+	gitstore.SyncResult
+}
+
+func (r syncResult) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Synced with %s (%s): pulled %d, pushed %d\n", r.Remote, r.Branch, r.Pulled, r.Pushed)
+	if r.Snapshot != "" {
+		fmt.Fprintf(w, "Committed pending changes: %s\n", shortHash(r.Snapshot))
+	}
+	if len(r.Updated) > 0 {
+		fmt.Fprintf(w, "Updated: %s\n", strings.Join(r.Updated, ", "))
+		fmt.Fprintln(w, "Re-apply the active profile to use the updated content.")
+	}
+}
+
+func runSync(config app.AppConfig, args []string) (syncResult, error) {
+	if len(args) != 0 {
+		return syncResult{}, usageError(syncUsage)
+	}
+	store, err := gitstore.Open(config.ConfigDir, config.Git)
+	if err != nil {
+		return syncResult{}, err
+	}
+	res, err := store.Sync()
+	if err != nil {
+		return syncResult{}, err
+	}
+	return syncResult{SyncResult: res}, nil
+}
+
+// runDiffAgainstRevision compares a profile with its content at a revision
+// of the git store.
+func runDiffAgainstRevision(config app.AppConfig, profileName, rev string) (diffResult, int, error) {
+	if err := profile.ValidateName(profileName); err != nil {
+		return diffResult{}, 1, err
+	}
+	profilePath := profile.ProfilePath(config.ConfigDir, profileName)
+	if _, err := os.Stat(profilePath); err != nil {
+		return diffResult{}, 1, err
+	}
+	store, err := gitstore.Open(config.ConfigDir, config.Git)
+	if err != nil {
+		return diffResult{}, 1, err
+	}
+	data, err := store.Show(profileName, rev)
+	if err != nil {
+		return diffResult{}, 1, err
+	}
+	old, err := profile.ParseProfile(data)
+	if err != nil {
+		return diffResult{}, 1, fmt.Errorf("profile %q at %s: %w", profileName, rev, err)
+	}
+	current, err := profile.LoadProfile(profilePath)
+	if err != nil {
+		return diffResult{}, 1, err
+	}
+	changes, err := profile.DiffConfigs(old, current)
+	if err != nil {
+		return diffResult{}, 1, err
+	}
+	return diffResult{Profile: profileName, Against: rev, Found: true, Changes: changes}, 0, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"moirai/internal/app"
+)
+
+func TestGitStoreLogDiffAndSync(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	remote := filepath.Join(t.TempDir(), "profiles.git")
+	if out, err := exec.Command("git", "init", "--quiet", "--bare", remote).CombinedOutput(); err != nil {
+		t.Fatalf("git init --bare: %v %s", err, out)
+	}
+
+	configDir := setupOutputConfig(t)
+	settings := `{"git": {"enabled": true, "remote": "` + filepath.ToSlash(remote) + `"}}`
+	if err := os.WriteFile(filepath.Join(configDir, app.ConfigFileName), []byte(settings), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "apply", "alpha"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("apply failed with %d: %s", exitCode, stderr.String())
+	}
+	profilePath := filepath.Join(configDir, "oh-my-opencode.json.alpha")
+	if err := os.WriteFile(profilePath, []byte(`{"agents":{"oracle":{"model":"o3"}}}`), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+
+	stdout.Reset()
+	if exitCode := run([]string{"moirai", "--output", "json", "log", "alpha"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("log failed with %d: %s", exitCode, stderr.String())
+	}
+	var envelope struct {
+		Result logResult `json:"result"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &envelope); err != nil {
+		t.Fatalf("parse output: %v\n%s", err, stdout.String())
+	}
+	commits := envelope.Result.Commits
+	if len(commits) != 2 || commits[0].Subject != "apply alpha" || commits[1].Op != "init" {
+		t.Fatalf("unexpected log %+v", commits)
+	}
+
+	stdout.Reset()
+	if exitCode := run([]string{"moirai", "diff", "alpha", "--against", "HEAD", "--no-color"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("diff failed with %d: %s", exitCode, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "agents.oracle") {
+		t.Fatalf("expected the uncommitted edit in the diff, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if exitCode := run([]string{"moirai", "sync"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("sync failed with %d: %s", exitCode, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "pulled 0, pushed 3") || !strings.Contains(stdout.String(), "Committed pending changes") {
+		t.Fatalf("unexpected sync output %q", stdout.String())
+	}
+	if out, err := exec.Command("git", "--git-dir="+remote, "show", "main:oh-my-opencode.json.alpha").CombinedOutput(); err != nil || !strings.Contains(string(out), `"o3"`) {
+		t.Fatalf("expected the edit on the remote, got %q %v", out, err)
+	}
+}
+
+func TestGitCommandsRequireEnabledStore(t *testing.T) {
+	setupOutputConfig(t)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "log"}, noTUI, stdout, stderr); exitCode == 0 || !strings.Contains(stderr.String(), "not enabled") {
+		t.Fatalf("expected log to fail without the git store, got %d %q", exitCode, stderr.String())
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+	"moirai/internal/journal"
+	"moirai/internal/lifecycle"
+	"moirai/internal/profile"
+	"moirai/internal/util"
+)
+
+const (
+	historyUsage = "moirai history [<profile>] [--limit <n>]"
+	undoUsage    = "moirai undo [<op-id>] [--force]"
+)
+
+type historyResult struct {
+	Profile string         `json:"profile,omitempty"`
+	Entries []historyEntry `json:"entries"`
+}
+
+type historyEntry struct {
+	journal.Entry
+	Undone bool `json:"undone"`
+	// BackupMissing is set when the backup the entry restores from no
+	// longer exists, so undo cannot revert it.
+	BackupMissing bool `json:"backupMissing,omitempty"`
+}
+
+func (r historyResult) writeText(w io.Writer) {
+	fmt.Fprintln(w, "History:")
+	if len(r.Entries) == 0 {
+		fmt.Fprintln(w, " (none)")
+		return
+	}
+	for _, entry := range r.Entries {
+		suffix := ""
+		if entry.Undone {
+			suffix = " (undone)"
+		} else if entry.BackupMissing {
+			suffix = " (backup missing, cannot be undone)"
+		}
+		fmt.Fprintf(w, " - %s %s %s%s%s\n", entry.ID, entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Op, describeEntry(entry.Entry), suffix)
+	}
+}
+
+// describeEntry summarizes what an operation changed for text output.
+func describeEntry(entry journal.Entry) string {
+	parts := make([]string, 0, 3)
+	if entry.Profile != "" {
+		parts = append(parts, entry.Profile)
+	}
+	if entry.Undoes != "" {
+		parts = append(parts, "reverts "+entry.Undoes)
+	}
+	if entry.SwitchesActive() {
+		parts = append(parts, fmt.Sprintf("active %s -> %s", activeLabel(entry.PreviousActive), activeLabel(entry.Active)))
+	}
+	if entry.Backup != "" {
+		parts = append(parts, "backup "+filepath.Base(entry.Backup))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " " + strings.Join(parts, ", ")
+}
+
+func activeLabel(target string) string {
+	if target == "" {
+		return "(none)"
+	}
+	return strings.TrimPrefix(filepath.Base(target), "oh-my-opencode.json.")
+}
+
+func runHistory(config app.AppConfig, args []string) (historyResult, error) {
+	profileName, flagArgs := splitPositional(args)
+	historyFlags := flag.NewFlagSet("history", flag.ContinueOnError)
+	historyFlags.SetOutput(io.Discard)
+	limit := historyFlags.Int("limit", 20, "number of operations to show; 0 shows all")
+	if err := historyFlags.Parse(flagArgs); err != nil || historyFlags.NArg() != 0 || *limit < 0 {
+		return historyResult{}, usageError(historyUsage)
+	}
+	if profileName != "" {
+		if err := profile.ValidateName(profileName); err != nil {
+			return historyResult{}, err
+		}
+	}
+
+	entries, err := journal.Read(config.ConfigDir)
+	if err != nil {
+		return historyResult{}, err
+	}
+	undone := journal.Undone(entries)
+	store := backup.NewStore(config)
+	res := historyResult{Profile: profileName, Entries: []historyEntry{}}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if *limit > 0 && len(res.Entries) == *limit {
+			break
+		}
+		entry := entries[i]
+		if profileName != "" && entry.Profile != profileName {
+			continue
+		}
+		item := historyEntry{Entry: entry, Undone: undone[entry.ID]}
+		if !item.Undone && entry.Backup != "" {
+			item.BackupMissing = !backupExists(store, entry.Backup)
+		}
+		res.Entries = append(res.Entries, item)
+	}
+	return res, nil
+}
+
+// backupExists reports whether the backup at path, or one of the same name
+// moved into the backup store, is still there for undo to restore.
+func backupExists(store backup.Store, path string) bool {
+	if util.FileExists(path) {
+		return true
+	}
+	_, err := backup.Locate(store, filepath.Base(path))
+	return err == nil
+}
+
+type undoResult struct {
+	Undone journal.Entry `json:"undone"`
+	Entry  journal.Entry `json:"entry"`
+}
+
+func (r undoResult) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Undone: %s %s%s\n", r.Undone.ID, r.Undone.Op, describeEntry(r.Undone))
+	if r.Entry.Backup != "" {
+		fmt.Fprintf(w, "PreBackup: %s\n", r.Entry.Backup)
+	}
+	fmt.Fprintf(w, "Recorded as: %s\n", r.Entry.ID)
+}
+
+func runUndo(config app.AppConfig, args []string) (undoResult, error) {
+	id, flagArgs := splitPositional(args)
+	undoFlags := flag.NewFlagSet("undo", flag.ContinueOnError)
+	undoFlags.SetOutput(io.Discard)
+	force := undoFlags.Bool("force", false, "undo even if the files changed since")
+	if err := undoFlags.Parse(flagArgs); err != nil || undoFlags.NArg() != 0 {
+		return undoResult{}, usageError(undoUsage)
+	}
+	res, err := lifecycle.Undo(config, id, *force)
+	if err != nil {
+		return undoResult{}, err
+	}
+	return undoResult{Undone: res.Undone, Entry: res.Entry}, commitOperation(config, res.Entry)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+	"moirai/internal/journal"
+	"moirai/internal/link"
+)
+
+func TestRunHistoryAndUndo(t *testing.T) {
+	configDir := setupOutputConfig(t)
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "apply", "alpha"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("apply failed with %d: %s", exitCode, stderr.String())
+	}
+
+	stdout.Reset()
+	if exitCode := run([]string{"moirai", "--output", "json", "history"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("history failed with %d: %s", exitCode, stderr.String())
+	}
+	var envelope struct {
+		Result historyResult `json:"result"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &envelope); err != nil {
+		t.Fatalf("parse output: %v\n%s", err, stdout.String())
+	}
+	if len(envelope.Result.Entries) != 1 || envelope.Result.Entries[0].Op != "apply" || envelope.Result.Entries[0].PreviousActive != "oh-my-opencode.json.beta" {
+		t.Fatalf("unexpected history %+v", envelope.Result)
+	}
+	id := envelope.Result.Entries[0].ID
+
+	stdout.Reset()
+	if exitCode := run([]string{"moirai", "undo", id}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("undo failed with %d: %s", exitCode, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Undone: "+id+" apply alpha, active beta -> alpha") {
+		t.Fatalf("unexpected undo output %q", stdout.String())
+	}
+	if active, _, _ := link.ActiveProfile(configDir); active != "beta" {
+		t.Fatalf("expected beta active again, got %q", active)
+	}
+
+	stdout.Reset()
+	if exitCode := run([]string{"moirai", "history", "alpha"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("history failed with %d: %s", exitCode, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "apply alpha, active beta -> alpha (undone)") || !strings.Contains(out, "undo alpha, reverts "+id) {
+		t.Fatalf("unexpected history output %q", out)
+	}
+}
+
+func TestHistoryFlagsEntriesWhoseBackupIsGone(t *testing.T) {
+	configDir := t.TempDir()
+	config := app.AppConfig{ConfigDir: configDir}
+	if err := os.WriteFile(filepath.Join(configDir, "oh-my-opencode.json.alpha"), []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	saved, err := backup.BackupProfile(backup.NewStore(config), "alpha")
+	if err != nil {
+		t.Fatalf("BackupProfile: %v", err)
+	}
+	if _, err := journal.Record(configDir, journal.Entry{Op: journal.OpRestore, Profile: "alpha", Backup: saved}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	res, err := runHistory(config, nil)
+	if err != nil || len(res.Entries) != 1 || res.Entries[0].BackupMissing {
+		t.Fatalf("expected the backup to be found, got %+v %v", res, err)
+	}
+	if err := os.Remove(saved); err != nil {
+		t.Fatalf("remove backup: %v", err)
+	}
+	res, err = runHistory(config, nil)
+	if err != nil || len(res.Entries) != 1 || !res.Entries[0].BackupMissing {
+		t.Fatalf("expected the missing backup to be flagged, got %+v %v", res, err)
+	}
+	out := &bytes.Buffer{}
+	res.writeText(out)
+	if !strings.Contains(out.String(), "(backup missing, cannot be undone)") {
+		t.Fatalf("unexpected history output %q", out.String())
+	}
+}
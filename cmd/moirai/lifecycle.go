@@ -0,0 +1,206 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"moirai/internal/app"
+	"moirai/internal/catalog"
+	"moirai/internal/lifecycle"
+)
+
+type newResult struct {
+	Profile string `json:"profile"`
+	Path    string `json:"path"`
+	From    string `json:"from,omitempty"`
+	Preset  string `json:"preset,omitempty"`
+}
+
+func (r newResult) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Created: %s\n", r.Profile)
+	fmt.Fprintf(w, "Path: %s\n", r.Path)
+}
+
+const newUsage = "moirai new <name> [--from <profile>|--empty|--preset <preset>]"
+
+func runNew(config app.AppConfig, args []string) (newResult, int, error) {
+	if len(args) < 1 {
+		return newResult{}, 1, usageError(newUsage)
+	}
+	newFlags := flag.NewFlagSet("new", flag.ContinueOnError)
+	newFlags.SetOutput(io.Discard)
+	from := newFlags.String("from", "", "profile to copy")
+	empty := newFlags.Bool("empty", false, "create an empty profile")
+	presetName := newFlags.String("preset", "", "autofill preset")
+	if err := newFlags.Parse(args[1:]); err != nil {
+		return newResult{}, 1, err
+	}
+	if newFlags.NArg() != 0 {
+		return newResult{}, 1, usageError(newUsage)
+	}
+	selected := 0
+	for _, set := range []bool{*from != "", *empty, *presetName != ""} {
+		if set {
+			selected++
+		}
+	}
+	if selected > 1 {
+		return newResult{}, 1, fmt.Errorf("--from, --empty and --preset are mutually exclusive")
+	}
+
+	name := args[0]
+	opts := lifecycle.CreateOptions{From: *from}
+	if *presetName != "" {
+		if !config.EnableAutofill {
+			return newResult{}, 3, errAutofillDisabled
+		}
+		preset, err := resolvePreset(config, *presetName)
+		if err != nil {
+			return newResult{}, 1, err
+		}
+		opts.Preset = &preset
+		opts.Agents = catalog.Load(config).Required()
+	}
+	path, err := lifecycle.CreateProfile(config.ConfigDir, name, opts)
+	if err != nil {
+		return newResult{}, 1, err
+	}
+	return newResult{Profile: name, Path: path, From: *from, Preset: *presetName}, 0, nil
+}
+
+func runClone(config app.AppConfig, args []string) (newResult, error) {
+	if len(args) != 2 {
+		return newResult{}, usageError("moirai clone <source> <name>")
+	}
+	path, err := lifecycle.CloneProfile(config.ConfigDir, args[0], args[1])
+	if err != nil {
+		return newResult{}, err
+	}
+	return newResult{Profile: args[1], Path: path, From: args[0]}, nil
+}
+
+type renameResult struct {
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+	Path       string   `json:"path"`
+	Retargeted bool     `json:"retargeted"`
+	Backups    []string `json:"backups"`
+}
+
+func (r renameResult) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Renamed: %s -> %s\n", r.From, r.To)
+	if r.Retargeted {
+		fmt.Fprintf(w, "Active: %s\n", r.To)
+	}
+	fmt.Fprintf(w, "Backups moved: %d\n", len(r.Backups))
+}
+
+func runRename(config app.AppConfig, args []string) (renameResult, error) {
+	if len(args) != 2 {
+		return renameResult{}, usageError("moirai rename <old> <new>")
+	}
+	res, err := lifecycle.RenameProfile(config, args[0], args[1])
+	if err != nil {
+		return renameResult{}, err
+	}
+	backups := res.Backups
+	if backups == nil {
+		backups = []string{}
+	}
+	return renameResult{
+		From:       args[0],
+		To:         args[1],
+		Path:       res.Path,
+		Retargeted: res.Retargeted,
+		Backups:    backups,
+	}, commitOperation(config, res.Entry)
+}
+
+type deleteResult struct {
+	Profile       string `json:"profile"`
+	Backup        string `json:"backup"`
+	ActiveRemoved bool   `json:"activeRemoved"`
+}
+
+func (r deleteResult) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Deleted: %s\n", r.Profile)
+	fmt.Fprintf(w, "Backup: %s\n", r.Backup)
+	if r.ActiveRemoved {
+		fmt.Fprintln(w, "Active: (none)")
+	}
+}
+
+const deleteUsage = "moirai delete <name> [--force]"
+
+func runDelete(config app.AppConfig, args []string) (deleteResult, error) {
+	if len(args) < 1 {
+		return deleteResult{}, usageError(deleteUsage)
+	}
+	deleteFlags := flag.NewFlagSet("delete", flag.ContinueOnError)
+	deleteFlags.SetOutput(io.Discard)
+	force := deleteFlags.Bool("force", false, "delete even if active")
+	if err := deleteFlags.Parse(args[1:]); err != nil {
+		return deleteResult{}, err
+	}
+	if deleteFlags.NArg() != 0 {
+		return deleteResult{}, usageError(deleteUsage)
+	}
+	res, err := lifecycle.DeleteProfile(config, args[0], *force)
+	if err != nil {
+		return deleteResult{}, err
+	}
+	return deleteResult{Profile: args[0], Backup: res.Backup, ActiveRemoved: res.ActiveRemoved}, commitOperation(config, res.Entry)
+}
+
+type adoptResult struct {
+	Profile string `json:"profile"`
+	Path    string `json:"path"`
+	// FromBackup is set when the profile was made from an active config
+	// backup; otherwise the active config was adopted and Backup holds its
+	// backup.
+	FromBackup string `json:"fromBackup,omitempty"`
+	Active     bool   `json:"active"`
+	Backup     string `json:"backup,omitempty"`
+}
+
+func (r adoptResult) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Adopted: %s\n", r.Profile)
+	fmt.Fprintf(w, "Path: %s\n", r.Path)
+	if r.FromBackup != "" {
+		fmt.Fprintf(w, "From: %s\n", r.FromBackup)
+	}
+	if r.Active {
+		fmt.Fprintf(w, "Active: %s\n", r.Profile)
+		fmt.Fprintf(w, "Backup: %s\n", r.Backup)
+	}
+}
+
+const adoptUsage = "moirai adopt <name> [--from-backup <backup>]"
+
+func runAdopt(config app.AppConfig, args []string) (adoptResult, error) {
+	if len(args) < 1 {
+		return adoptResult{}, usageError(adoptUsage)
+	}
+	adoptFlags := flag.NewFlagSet("adopt", flag.ContinueOnError)
+	adoptFlags.SetOutput(io.Discard)
+	fromBackup := adoptFlags.String("from-backup", "", "active config backup path or filename")
+	if err := adoptFlags.Parse(args[1:]); err != nil {
+		return adoptResult{}, err
+	}
+	if adoptFlags.NArg() != 0 {
+		return adoptResult{}, usageError(adoptUsage)
+	}
+	if *fromBackup != "" {
+		path, err := lifecycle.AdoptBackup(config, args[0], *fromBackup)
+		if err != nil {
+			return adoptResult{}, err
+		}
+		return adoptResult{Profile: args[0], Path: path, FromBackup: *fromBackup}, nil
+	}
+	res, err := lifecycle.AdoptActive(config, args[0])
+	if err != nil {
+		return adoptResult{}, err
+	}
+	return adoptResult{Profile: args[0], Path: res.Path, Active: true, Backup: res.Backup}, commitOperation(config, res.Entry)
+}
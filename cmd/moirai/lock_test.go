@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"moirai/internal/lock"
+)
+
+func TestRunApplyFailsWhileConfigDirLocked(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	if err := os.WriteFile(filepath.Join(configDir, "moirai.json"), []byte(`{"lockTimeoutMs": 50}`), 0o600); err != nil {
+		t.Fatalf("write moirai.json: %v", err)
+	}
+	held, err := lock.Acquire(configDir, 0)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exitCode := run([]string{"moirai", "apply", "alpha"}, noTUI, stdout, stderr)
+	if exitCode != 1 {
+		t.Fatalf("expected exit code 1, got %d", exitCode)
+	}
+	if !strings.Contains(stderr.String(), fmt.Sprintf("locked by pid %d", os.Getpid())) {
+		t.Fatalf("expected lock error, got %q", stderr.String())
+	}
+	target, err := os.Readlink(filepath.Join(configDir, "oh-my-opencode.json"))
+	if err != nil || target != "oh-my-opencode.json.beta" {
+		t.Fatalf("expected active profile untouched, got %q %v", target, err)
+	}
+
+	// Read-only commands do not wait for the lock.
+	stderr.Reset()
+	if exitCode := run([]string{"moirai", "list"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected list to succeed, got %d (%s)", exitCode, stderr.String())
+	}
+
+	if err := held.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	stderr.Reset()
+	if exitCode := run([]string{"moirai", "apply", "alpha"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected apply after release to succeed, got %d (%s)", exitCode, stderr.String())
+	}
+}
@@ -9,11 +9,19 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"moirai/internal/app"
 	"moirai/internal/backup"
+	"moirai/internal/catalog"
+	"moirai/internal/doctor"
+	"moirai/internal/journal"
 	"moirai/internal/link"
+	"moirai/internal/lock"
+	"moirai/internal/models"
 	"moirai/internal/profile"
+	"moirai/internal/schema"
+	"moirai/internal/secrets"
 	"moirai/internal/tui"
 	"moirai/internal/util"
 )
@@ -33,9 +41,9 @@ func run(args []string, tuiRunner func(app.AppConfig) error, stdout, stderr io.W
 		fmt.Fprintln(stderr, err)
 		return 1
 	}
+	out := newPrinter(globalFlags.Output, stdout, stderr)
 	if shouldPrintVersion(remaining, globalFlags) {
-		printVersion(stdout)
-		return 0
+		return out.finish("version", versionResult{Version: app.Version}, 0, nil)
 	}
 	if len(remaining) == 0 {
 		configDir, err := resolveConfigDir()
@@ -63,10 +71,10 @@ func run(args []string, tuiRunner func(app.AppConfig) error, stdout, stderr io.W
 		return 0
 	}
 
+	command := remaining[0]
 	configDir, err := resolveConfigDir()
 	if err != nil {
-		fmt.Fprintln(stderr, err)
-		return 1
+		return out.finish(command, nil, 1, err)
 	}
 	var enableAutofillOverride *bool
 	if globalFlags.EnableAutofillSet {
@@ -74,108 +82,151 @@ func run(args []string, tuiRunner func(app.AppConfig) error, stdout, stderr io.W
 	}
 	appConfig, err := app.LoadConfig(configDir, enableAutofillOverride)
 	if err != nil {
-		fmt.Fprintln(stderr, err)
-		return 1
+		return out.finish(command, nil, 1, err)
+	}
+	if lockedCommands[command] {
+		held, err := lock.Acquire(appConfig.ConfigDir, appConfig.LockTimeout)
+		if err != nil {
+			return out.finish(command, nil, 1, err)
+		}
+		defer held.Release()
 	}
 
-	switch remaining[0] {
+	switch command {
 	case "list":
-		if err := runList(appConfig); err != nil {
-			fmt.Fprintln(stderr, err)
-			return 1
-		}
+		res, err := runList(appConfig)
+		return out.finish(command, res, 0, err)
 	case "apply":
-		if len(remaining) != 2 {
-			fmt.Fprintln(stderr, "Usage: moirai apply <profile>")
-			return 1
-		}
-		if err := runApply(appConfig, remaining[1]); err != nil {
-			fmt.Fprintln(stderr, err)
-			return 1
-		}
+		res, err := runApply(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
 	case "doctor":
-		if len(remaining) != 2 {
-			fmt.Fprintln(stderr, "Usage: moirai doctor <profile>")
-			return 1
-		}
-		exitCode, err := runDoctor(appConfig, remaining[1])
-		if err != nil {
-			fmt.Fprintln(stderr, err)
-			return 1
-		}
-		if exitCode != 0 {
-			return exitCode
-		}
+		res, exitCode, err := runDoctor(appConfig, remaining[1:])
+		return out.finish(command, res, exitCode, err)
 	case "backup":
-		if len(remaining) != 2 {
-			fmt.Fprintln(stderr, "Usage: moirai backup <profile>")
-			return 1
-		}
-		if err := runBackup(appConfig, remaining[1]); err != nil {
-			fmt.Fprintln(stderr, err)
-			return 1
-		}
+		res, err := runBackup(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
 	case "backups":
 		if len(remaining) != 2 {
-			fmt.Fprintln(stderr, "Usage: moirai backups <profile>")
-			return 1
+			return out.finish(command, nil, 1, usageError(backupsUsage))
 		}
-		if err := runBackups(appConfig, remaining[1]); err != nil {
-			fmt.Fprintln(stderr, err)
-			return 1
+		if remaining[1] == "--active" {
+			res, err := runActiveBackups(appConfig)
+			return out.finish(command, res, 0, err)
 		}
+		res, err := runBackups(appConfig, remaining[1])
+		return out.finish(command, res, 0, err)
 	case "restore":
 		if len(remaining) < 2 {
-			fmt.Fprintln(stderr, "Usage: moirai restore <profile> --from <backupPathOrFilename>")
-			return 1
+			return out.finish(command, nil, 1, usageError(restoreUsage))
 		}
 		restoreFlags := flag.NewFlagSet("restore", flag.ContinueOnError)
+		restoreFlags.SetOutput(io.Discard)
 		from := restoreFlags.String("from", "", "backup path or filename")
 		if err := restoreFlags.Parse(remaining[2:]); err != nil {
-			fmt.Fprintln(stderr, err)
-			return 1
+			return out.finish(command, nil, 1, err)
 		}
-		if err := runRestore(appConfig, remaining[1], *from); err != nil {
-			fmt.Fprintln(stderr, err)
-			return 1
+		if remaining[1] == "--active" {
+			res, err := runRestoreActive(appConfig, *from)
+			return out.finish(command, res, 0, err)
 		}
+		res, err := runRestore(appConfig, remaining[1], *from)
+		return out.finish(command, res, 0, err)
 	case "diff":
-		exitCode, err := runDiff(appConfig, remaining[1:])
-		if err != nil {
-			fmt.Fprintln(stderr, err)
-			return 1
-		}
-		if exitCode != 0 {
-			return exitCode
-		}
+		res, exitCode, err := runDiff(appConfig, remaining[1:])
+		return out.finish(command, res, exitCode, err)
 	case "autofill":
-		if len(remaining) < 2 {
-			fmt.Fprintln(stderr, "Usage: moirai autofill <profile> --preset <preset>")
-			return 1
-		}
-		autofillFlags := flag.NewFlagSet("autofill", flag.ContinueOnError)
-		preset := autofillFlags.String("preset", "", "autofill preset")
-		if err := autofillFlags.Parse(remaining[2:]); err != nil {
-			fmt.Fprintln(stderr, err)
-			return 1
-		}
-		if *preset == "" {
-			fmt.Fprintln(stderr, "Usage: moirai autofill <profile> --preset <preset>")
-			return 1
-		}
-		exitCode, err := runAutofill(appConfig, remaining[1], *preset)
+		res, exitCode, err := runAutofillCommand(appConfig, remaining[1:])
+		return out.finish(command, res, exitCode, err)
+	case "new":
+		res, exitCode, err := runNew(appConfig, remaining[1:])
+		return out.finish(command, res, exitCode, err)
+	case "clone":
+		res, err := runClone(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
+	case "rename":
+		res, err := runRename(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
+	case "adopt":
+		res, err := runAdopt(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
+	case "delete":
+		res, err := runDelete(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
+	case "resolve":
+		res, err := runResolve(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
+	case "prune":
+		res, err := runPrune(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
+	case "migrate-backups":
+		res, err := runMigrateBackups(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
+	case "export":
+		res, err := runExport(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
+	case "import":
+		res, err := runImport(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
+	case "exec":
+		exitCode, err := runExec(appConfig, remaining[1:], stdout, stderr)
 		if err != nil {
-			fmt.Fprintln(stderr, err)
-			return 1
-		}
-		if exitCode != 0 {
-			return exitCode
+			return out.finish(command, nil, exitCode, err)
 		}
+		return exitCode
+	case "auto":
+		res, err := runAuto(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
+	case "presets":
+		res, err := runPresets(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
+	case "secrets":
+		res, exitCode, err := runSecrets(appConfig, remaining[1:])
+		return out.finish(command, res, exitCode, err)
+	case "validate":
+		res, exitCode, err := runValidate(appConfig, remaining[1:])
+		return out.finish(command, res, exitCode, err)
+	case "history":
+		res, err := runHistory(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
+	case "undo":
+		res, err := runUndo(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
+	case "log":
+		res, err := runLog(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
+	case "sync":
+		res, err := runSync(appConfig, remaining[1:])
+		return out.finish(command, res, 0, err)
 	default:
+		if out.format != outputText {
+			return out.finish(command, nil, 1, fmt.Errorf("unknown command: %s", command))
+		}
 		printHelp(stdout)
 		return 1
 	}
-	return 0
+}
+
+// lockedCommands modify the config dir and hold its lock while they run, so
+// concurrent moirai processes cannot interleave backups, saves and applies.
+var lockedCommands = map[string]bool{
+	"apply":           true,
+	"backup":          true,
+	"restore":         true,
+	"autofill":        true,
+	"new":             true,
+	"clone":           true,
+	"rename":          true,
+	"delete":          true,
+	"adopt":           true,
+	"prune":           true,
+	"import":          true,
+	"undo":            true,
+	"migrate-backups": true,
+	"sync":            true,
+}
+
+func usageError(usage string) error {
+	return fmt.Errorf("Usage: %s", usage)
 }
 
 func resolveConfigDir() (string, error) {
@@ -193,12 +244,35 @@ type globalFlags struct {
 	EnableAutofill    bool
 	EnableAutofillSet bool
 	ShowVersion       bool
+	Output            outputFormat
 }
 
 func parseGlobalFlags(args []string) ([]string, globalFlags, error) {
-	var flags globalFlags
+	flags := globalFlags{Output: outputText}
 	remaining := make([]string, 0, len(args))
-	for _, arg := range args {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			// Everything after "--" belongs to the subcommand (e.g. exec).
+			remaining = append(remaining, args[i:]...)
+			break
+		}
+		if arg == "--output" || strings.HasPrefix(arg, "--output=") {
+			value := strings.TrimPrefix(arg, "--output=")
+			if arg == "--output" {
+				if i+1 >= len(args) {
+					return nil, flags, fmt.Errorf("--output requires a value (text, json or yaml)")
+				}
+				i++
+				value = args[i]
+			}
+			format, err := parseOutputFormat(value)
+			if err != nil {
+				return nil, flags, err
+			}
+			flags.Output = format
+			continue
+		}
 		if arg == "--enable-autofill" {
 			flags.EnableAutofill = true
 			flags.EnableAutofillSet = true
@@ -225,17 +299,39 @@ func parseGlobalFlags(args []string) ([]string, globalFlags, error) {
 
 func printHelp(w io.Writer) {
 	fmt.Fprintln(w, "Usage: moirai list")
-	fmt.Fprintln(w, "       moirai apply <profile>")
-	fmt.Fprintln(w, "       moirai doctor <profile>")
-	fmt.Fprintln(w, "       moirai backup <profile>")
-	fmt.Fprintln(w, "       moirai backups <profile>")
-	fmt.Fprintln(w, "       moirai restore <profile> --from <backupPathOrFilename>")
-	fmt.Fprintln(w, "       moirai diff <profile> --against last-backup")
-	fmt.Fprintln(w, "       moirai diff --between <profileA> <profileB>")
-	fmt.Fprintln(w, "       moirai autofill <profile> --preset <preset>")
+	fmt.Fprintln(w, "       "+applyUsage)
+	fmt.Fprintln(w, "       moirai doctor [<profile>] [--fix]")
+	fmt.Fprintln(w, "       "+exportUsage)
+	fmt.Fprintln(w, "       "+importUsage)
+	fmt.Fprintln(w, "       "+execUsage)
+	fmt.Fprintln(w, "       moirai auto [--dir <path>] [--quiet]")
+	fmt.Fprintln(w, "       moirai auto hook bash|zsh|fish")
+	fmt.Fprintln(w, "       "+backupUsage)
+	fmt.Fprintln(w, "       "+backupsUsage)
+	fmt.Fprintln(w, "       "+restoreUsage)
+	fmt.Fprintln(w, "       "+resolveUsage)
+	fmt.Fprintln(w, "       moirai prune [<profile>] [--dry-run]")
+	fmt.Fprintln(w, "       "+migrateBackupsUsage)
+	fmt.Fprintln(w, "       "+historyUsage)
+	fmt.Fprintln(w, "       "+undoUsage)
+	fmt.Fprintln(w, "       "+logUsage)
+	fmt.Fprintln(w, "       "+syncUsage)
+	fmt.Fprintln(w, "       moirai diff <profile> --against last-backup|<rev> [--no-color] [--show-secrets]")
+	fmt.Fprintln(w, "       moirai diff --between <profileA> <profileB> [--no-color] [--show-secrets]")
+	fmt.Fprintln(w, "       "+secretsUsage)
+	fmt.Fprintln(w, "       "+validateUsage)
+	fmt.Fprintln(w, "       "+autofillUsage)
+	fmt.Fprintln(w, "       moirai presets list")
+	fmt.Fprintln(w, "       moirai presets show <preset>")
+	fmt.Fprintln(w, "       moirai new <name> [--from <profile>|--empty|--preset <preset>]")
+	fmt.Fprintln(w, "       moirai clone <source> <name>")
+	fmt.Fprintln(w, "       moirai rename <old> <new>")
+	fmt.Fprintln(w, "       moirai delete <name> [--force]")
+	fmt.Fprintln(w, "       "+adoptUsage)
 	fmt.Fprintln(w, "       moirai version")
 	fmt.Fprintln(w, "Global options:")
 	fmt.Fprintln(w, "       --enable-autofill")
+	fmt.Fprintln(w, "       --output text|json|yaml")
 	fmt.Fprintln(w, "       --version")
 	fmt.Fprintln(w, "       moirai help")
 }
@@ -247,216 +343,660 @@ func shouldPrintVersion(args []string, flags globalFlags) bool {
 	return len(args) > 0 && args[0] == "version"
 }
 
-func printVersion(w io.Writer) {
-	fmt.Fprintln(w, app.Version)
+type versionResult struct {
+	Version string `json:"version"`
+}
+
+func (r versionResult) writeText(w io.Writer) {
+	fmt.Fprintln(w, r.Version)
 }
 
-func runList(config app.AppConfig) error {
+type listResult struct {
+	ConfigDir string        `json:"configDir"`
+	Active    *string       `json:"active"`
+	Profiles  []listProfile `json:"profiles"`
+}
+
+type listProfile struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Active bool   `json:"active"`
+}
+
+func (r listResult) writeText(w io.Writer) {
+	fmt.Fprintf(w, "ConfigDir: %s\n", r.ConfigDir)
+	if r.Active != nil {
+		fmt.Fprintf(w, "Active: %s\n", *r.Active)
+	} else {
+		fmt.Fprintln(w, "Active: (none)")
+	}
+	fmt.Fprintln(w, "Profiles:")
+	for _, info := range r.Profiles {
+		suffix := ""
+		if info.Active {
+			suffix = " *"
+		}
+		fmt.Fprintf(w, " - %s%s\n", info.Name, suffix)
+	}
+}
+
+func runList(config app.AppConfig) (listResult, error) {
 	profiles, err := profile.DiscoverProfiles(config.ConfigDir)
 	if err != nil {
-		return err
+		return listResult{}, err
 	}
 
 	activeName, ok, err := link.ActiveProfile(config.ConfigDir)
 	if err != nil {
-		return err
+		return listResult{}, err
 	}
 
-	fmt.Printf("ConfigDir: %s\n", config.ConfigDir)
+	res := listResult{
+		ConfigDir: config.ConfigDir,
+		Profiles:  make([]listProfile, 0, len(profiles)),
+	}
 	if ok {
-		fmt.Printf("Active: %s\n", activeName)
-	} else {
-		fmt.Println("Active: (none)")
+		res.Active = &activeName
 	}
-	fmt.Println("Profiles:")
 	for _, info := range profiles {
-		suffix := ""
-		if ok && info.Name == activeName {
-			suffix = " *"
+		res.Profiles = append(res.Profiles, listProfile{
+			Name:   info.Name,
+			Path:   info.Path,
+			Active: ok && info.Name == activeName,
+		})
+	}
+	return res, nil
+}
+
+const applyUsage = "moirai apply <profile> [--strict] [--no-validate]"
+
+type applyResult struct {
+	Profile    string              `json:"profile"`
+	Unresolved []profile.Reference `json:"unresolved,omitempty"`
+}
+
+func (r applyResult) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Applied: %s\n", r.Profile)
+	writeUnresolved(w, r.Unresolved)
+}
+
+// writeUnresolved warns about placeholders left in the active config.
+func writeUnresolved(w io.Writer, refs []profile.Reference) {
+	for _, ref := range refs {
+		fmt.Fprintf(w, "Warning: %s at %s is unresolved: %s\n", ref.Placeholder, ref.Path, ref.Error)
+	}
+}
+
+func runApply(config app.AppConfig, args []string) (applyResult, error) {
+	profileName, flagArgs := splitPositional(args)
+	applyFlags := flag.NewFlagSet("apply", flag.ContinueOnError)
+	applyFlags.SetOutput(io.Discard)
+	strict := applyFlags.Bool("strict", config.StrictReferences, "fail on unresolved references")
+	noValidate := applyFlags.Bool("no-validate", false, "skip schema validation")
+	if err := applyFlags.Parse(flagArgs); err != nil {
+		return applyResult{}, err
+	}
+	if profileName == "" || applyFlags.NArg() != 0 {
+		return applyResult{}, usageError(applyUsage)
+	}
+	if !*noValidate {
+		result, err := schema.ValidateProfile(config.ConfigDir, profileName, schema.Options{Path: config.SchemaPath})
+		if err != nil {
+			return applyResult{}, err
+		}
+		if err := result.Err(); err != nil {
+			return applyResult{}, fmt.Errorf("%w (use --no-validate to apply anyway)", err)
 		}
-		fmt.Printf(" - %s%s\n", info.Name, suffix)
 	}
-	return nil
+	applied, err := link.ApplyProfileWith(config.ConfigDir, profileName, link.ApplyOptions{
+		Strict:    *strict,
+		Backups:   backup.NewStore(config),
+		Retention: config.BackupRetention,
+	})
+	if err != nil {
+		return applyResult{}, err
+	}
+	return applyResult{Profile: profileName, Unresolved: applied.Unresolved}, commitOperation(config, applied.Entry)
 }
 
-func runApply(config app.AppConfig, profileName string) error {
-	if err := link.ApplyProfile(config.ConfigDir, profileName); err != nil {
-		return err
+type doctorResult struct {
+	Profile  string           `json:"profile,omitempty"`
+	Missing  []string         `json:"missing"`
+	Findings []doctor.Finding `json:"findings"`
+}
+
+func (r doctorResult) writeText(w io.Writer) {
+	if r.Profile != "" {
+		fmt.Fprintf(w, "Profile: %s\n", r.Profile)
+	}
+	fmt.Fprintln(w, "Findings:")
+	if len(r.Findings) == 0 {
+		fmt.Fprintln(w, " (none)")
+		return
+	}
+	for _, finding := range r.Findings {
+		subject := finding.Path
+		if finding.Profile != "" {
+			subject = finding.Profile
+		}
+		suffix := ""
+		switch {
+		case finding.Fixed && finding.Backup != "":
+			suffix = fmt.Sprintf(" (fixed, backup: %s)", finding.Backup)
+		case finding.Fixed:
+			suffix = " (fixed)"
+		case finding.FixError != "":
+			suffix = fmt.Sprintf(" (fix failed: %s)", finding.FixError)
+		case finding.Fixable:
+			suffix = " (fixable with --fix)"
+		}
+		fmt.Fprintf(w, " - [%s] %s %s: %s%s\n", finding.Severity, finding.Check, subject, finding.Message, suffix)
 	}
-	fmt.Printf("Applied: %s\n", profileName)
-	return nil
 }
 
-func runDoctor(config app.AppConfig, profileName string) (int, error) {
-	profilePath := filepath.Join(config.ConfigDir, fmt.Sprintf("oh-my-opencode.json.%s", profileName))
-	cfg, err := profile.LoadProfile(profilePath)
+func runDoctor(config app.AppConfig, args []string) (doctorResult, int, error) {
+	doctorFlags := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	doctorFlags.SetOutput(io.Discard)
+	fix := doctorFlags.Bool("fix", false, "apply automatic fixes")
+	profileName, flagArgs := splitPositional(args)
+	if err := doctorFlags.Parse(flagArgs); err != nil || doctorFlags.NArg() > 0 {
+		return doctorResult{}, 1, usageError("moirai doctor [<profile>] [--fix]")
+	}
+
+	if *fix {
+		held, err := lock.Acquire(config.ConfigDir, config.LockTimeout)
+		if err != nil {
+			return doctorResult{}, 1, err
+		}
+		defer held.Release()
+	}
+
+	agents := catalog.Load(config)
+	env := doctor.Env{
+		ConfigDir:  config.ConfigDir,
+		Profile:    profileName,
+		Catalog:    agents,
+		SchemaPath: config.SchemaPath,
+		Backups:    backup.NewStore(config),
+		Retention:  config.BackupRetention,
+	}
+	if cached, ok, err := models.LoadCachedModels(filepath.Dir(config.ConfigDir)); err == nil && ok {
+		env.Models = cached
+	}
+	findings, err := doctor.Run(env, doctor.DefaultChecks())
 	if err != nil {
-		return 1, err
+		return doctorResult{}, 1, err
+	}
+	if *fix {
+		doctor.Fix(findings)
 	}
 
-	missing := profile.MissingAgents(cfg, profile.KnownAgents())
+	res := doctorResult{Profile: profileName, Missing: []string{}, Findings: findings}
+	if profileName != "" {
+		if resolved, err := profile.ResolveProfile(config.ConfigDir, profileName); err == nil {
+			res.Missing = profile.MissingAgents(resolved.Config, agents.Required())
+		}
+	}
+	if doctor.Failed(findings) {
+		return res, 2, nil
+	}
+	return res, 0, nil
+}
 
-	fmt.Printf("Profile: %s\n", profileName)
-	fmt.Println("Missing:")
-	if len(missing) == 0 {
-		fmt.Println(" (none)")
-		return 0, nil
+// splitPositional returns the leading positional argument, if any, and the
+// remaining arguments for flag parsing.
+func splitPositional(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
 	}
-	for _, agent := range missing {
-		fmt.Printf(" - %s\n", agent)
+	return "", args
+}
+
+type backupResult struct {
+	Profile string `json:"profile"`
+	Backup  string `json:"backup"`
+	Reason  string `json:"reason"`
+	SHA256  string `json:"sha256"`
+	Note    string `json:"note,omitempty"`
+	// Reused is set when the profile matched its newest backup, so no new
+	// file was written.
+	Reused bool `json:"reused"`
+}
+
+func (r backupResult) writeText(w io.Writer) {
+	if r.Reused {
+		fmt.Fprintf(w, "Backup: %s (unchanged since this backup)\n", r.Backup)
+		return
 	}
-	return 2, nil
+	fmt.Fprintf(w, "Backup: %s\n", r.Backup)
 }
 
-func runBackup(config app.AppConfig, profileName string) error {
-	backupPath, err := backup.BackupProfile(config.ConfigDir, profileName)
+const backupUsage = "moirai backup <profile> [-m <note>]"
+
+func runBackup(config app.AppConfig, args []string) (backupResult, error) {
+	profileName, flagArgs := splitPositional(args)
+	backupFlags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	backupFlags.SetOutput(io.Discard)
+	note := backupFlags.String("m", "", "note stored with the backup")
+	if err := backupFlags.Parse(flagArgs); err != nil {
+		return backupResult{}, err
+	}
+	if profileName == "" || backupFlags.NArg() != 0 {
+		return backupResult{}, usageError(backupUsage)
+	}
+	if err := profile.ValidateName(profileName); err != nil {
+		return backupResult{}, err
+	}
+	entry, err := backup.BackupProfileWith(backup.NewStore(config), profileName, backup.Options{Reason: backup.ReasonManual, Note: *note, Retention: config.BackupRetention})
 	if err != nil {
-		return err
+		return backupResult{}, err
+	}
+	return backupResult{
+		Profile: profileName,
+		Backup:  entry.Path,
+		Reason:  entry.Reason,
+		SHA256:  entry.SHA256,
+		Note:    entry.Note,
+		Reused:  entry.Reused,
+	}, nil
+}
+
+const backupsUsage = "moirai backups <profile>|--active"
+
+type backupsResult struct {
+	Profile string        `json:"profile,omitempty"`
+	Active  bool          `json:"active,omitempty"`
+	Backups []backupEntry `json:"backups"`
+}
+
+type backupEntry struct {
+	Name      string     `json:"name"`
+	Path      string     `json:"path"`
+	CreatedAt *time.Time `json:"createdAt"`
+	// Reason and Version are empty for backups taken before moirai kept
+	// backup metadata.
+	Reason  string `json:"reason"`
+	Version string `json:"version"`
+	SHA256  string `json:"sha256"`
+	Note    string `json:"note,omitempty"`
+}
+
+func (r backupsResult) writeText(w io.Writer) {
+	fmt.Fprintln(w, "Backups:")
+	if len(r.Backups) == 0 {
+		fmt.Fprintln(w, " (none)")
+		return
+	}
+	for _, entry := range r.Backups {
+		reason, version := entry.Reason, entry.Version
+		if reason == "" {
+			reason = "-"
+		}
+		if version == "" {
+			version = "-"
+		}
+		line := fmt.Sprintf(" - %s  %-8s  %-8s  %s", entry.Name, reason, version, shortHash(entry.SHA256))
+		if entry.Note != "" {
+			line += "  " + entry.Note
+		}
+		fmt.Fprintln(w, line)
 	}
-	fmt.Printf("Backup: %s\n", backupPath)
-	return nil
 }
 
-func runBackups(config app.AppConfig, profileName string) error {
-	backups, err := backup.ListProfileBackups(config.ConfigDir, profileName)
+// shortHash abbreviates a SHA-256 hex digest for display.
+func shortHash(sum string) string {
+	if len(sum) > 12 {
+		return sum[:12]
+	}
+	return sum
+}
+
+func runBackups(config app.AppConfig, profileName string) (backupsResult, error) {
+	if err := profile.ValidateName(profileName); err != nil {
+		return backupsResult{}, err
+	}
+	backups, err := backup.ListProfileBackups(backup.NewStore(config), profileName)
 	if err != nil {
-		return err
+		return backupsResult{}, err
 	}
+	entries, err := describeBackups(config, backups)
+	if err != nil {
+		return backupsResult{}, err
+	}
+	return backupsResult{Profile: profileName, Backups: entries}, nil
+}
 
-	fmt.Println("Backups:")
-	if len(backups) == 0 {
-		fmt.Println(" (none)")
-		return nil
+// runActiveBackups lists the backups of the active config taken when an
+// apply replaced a regular file.
+func runActiveBackups(config app.AppConfig) (backupsResult, error) {
+	backups, err := backup.ListActiveBackups(backup.NewStore(config))
+	if err != nil {
+		return backupsResult{}, err
 	}
-	for _, name := range backups {
-		fmt.Printf(" - %s\n", name)
+	entries, err := describeBackups(config, backups)
+	if err != nil {
+		return backupsResult{}, err
 	}
-	return nil
+	return backupsResult{Active: true, Backups: entries}, nil
 }
 
-func runRestore(config app.AppConfig, profileName, from string) error {
-	preBackupPath, err := backup.RestoreProfileFromBackup(config.ConfigDir, profileName, from)
+func describeBackups(config app.AppConfig, backups []string) ([]backupEntry, error) {
+	described, err := backup.Describe(backup.NewStore(config), backups)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	entries := make([]backupEntry, 0, len(described))
+	for _, info := range described {
+		entry := backupEntry{
+			Name:    info.Name,
+			Path:    info.Path,
+			Reason:  info.Reason,
+			Version: info.Version,
+			SHA256:  info.SHA256,
+			Note:    info.Note,
+		}
+		if createdAt, ok := backup.BackupTime(info.Name); ok {
+			entry.CreatedAt = &createdAt
+		}
+		entries = append(entries, entry)
 	}
-	fmt.Printf("Restored: %s\n", profileName)
-	fmt.Printf("PreBackup: %s\n", preBackupPath)
-	return nil
+	return entries, nil
+}
+
+const restoreUsage = "moirai restore <profile>|--active --from <backupPathOrFilename>"
+
+type restoreResult struct {
+	Profile   string `json:"profile,omitempty"`
+	Active    bool   `json:"active,omitempty"`
+	PreBackup string `json:"preBackup"`
 }
 
-func runDiff(config app.AppConfig, args []string) (int, error) {
-	if len(args) == 0 {
-		printDiffHelp()
-		return 1, fmt.Errorf("missing diff arguments")
+func (r restoreResult) writeText(w io.Writer) {
+	if r.Active {
+		fmt.Fprintln(w, "Restored: active config")
+	} else {
+		fmt.Fprintf(w, "Restored: %s\n", r.Profile)
 	}
-	if args[0] == "--between" {
-		if len(args) != 3 {
-			printDiffHelp()
-			return 1, fmt.Errorf("Usage: moirai diff --between <profileA> <profileB>")
-		}
-		return runDiffBetween(config, args[1], args[2])
+	if r.PreBackup == "" {
+		// A symlinked active config needs no backup: its profile is intact.
+		fmt.Fprintln(w, "PreBackup: (none)")
+		return
+	}
+	fmt.Fprintf(w, "PreBackup: %s\n", r.PreBackup)
+}
+
+func runRestore(config app.AppConfig, profileName, from string) (restoreResult, error) {
+	if err := profile.ValidateName(profileName); err != nil {
+		return restoreResult{}, err
+	}
+	restored, err := backup.RestoreProfileFromBackup(backup.NewStore(config), profileName, from, backup.Options{Retention: config.BackupRetention})
+	if err != nil {
+		return restoreResult{}, err
 	}
+	return restoreResult{Profile: profileName, PreBackup: restored.PreBackup}, commitOperation(config, restored.Entry)
+}
+
+func runRestoreActive(config app.AppConfig, from string) (restoreResult, error) {
+	restored, err := backup.RestoreActiveFromBackup(backup.NewStore(config), from, backup.Options{Retention: config.BackupRetention})
+	if err != nil {
+		return restoreResult{}, err
+	}
+	return restoreResult{Active: true, PreBackup: restored.PreBackup}, commitOperation(config, restored.Entry)
+}
+
+const diffUsage = "Usage: moirai diff <profile> --against last-backup|<rev> [--no-color] [--show-secrets]\n       moirai diff --between <profileA> <profileB> [--no-color] [--show-secrets]"
 
-	if len(args) < 2 {
-		printDiffHelp()
-		return 1, fmt.Errorf("Usage: moirai diff <profile> --against last-backup")
+type diffResult struct {
+	Profile string           `json:"profile"`
+	Against string           `json:"against"`
+	Found   bool             `json:"found"`
+	Changes []profile.Change `json:"changes"`
+
+	color bool
+}
+
+func (r diffResult) writeText(w io.Writer) {
+	if !r.Found {
+		fmt.Fprintf(w, "No backups found for profile: %s\n", r.Profile)
+		return
 	}
-	if args[1] != "--against" || len(args) != 3 || args[2] != "last-backup" {
-		printDiffHelp()
-		return 1, fmt.Errorf("Usage: moirai diff <profile> --against last-backup")
+	if len(r.Changes) == 0 {
+		fmt.Fprintln(w, "No differences.")
+		return
 	}
-	return runDiffAgainstLastBackup(config, args[0])
+	fmt.Fprint(w, profile.FormatDiff(r.Against, r.Profile, r.Changes, r.color))
 }
 
-func printDiffHelp() {
-	fmt.Println("Usage: moirai diff <profile> --against last-backup")
-	fmt.Println("       moirai diff --between <profileA> <profileB>")
+func runDiff(config app.AppConfig, args []string) (diffResult, int, error) {
+	noColor := false
+	showSecrets := false
+	positional := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch arg {
+		case "--no-color":
+			noColor = true
+			continue
+		case "--show-secrets":
+			showSecrets = true
+			continue
+		}
+		positional = append(positional, arg)
+	}
+	args = positional
+
+	var (
+		res      diffResult
+		exitCode int
+		err      error
+	)
+	switch {
+	case len(args) == 3 && args[0] == "--between":
+		res, exitCode, err = runDiffBetween(config, args[1], args[2])
+	case len(args) == 3 && args[1] == "--against" && args[2] == "last-backup":
+		res, exitCode, err = runDiffAgainstLastBackup(config, args[0])
+	case len(args) == 3 && args[1] == "--against" && args[2] != "":
+		res, exitCode, err = runDiffAgainstRevision(config, args[0], args[2])
+	default:
+		return diffResult{}, 1, errors.New(diffUsage)
+	}
+	if !showSecrets {
+		res.Changes = secrets.RedactChanges(res.Changes)
+	}
+	res.color = !noColor && colorEnabled(os.Stdout)
+	return res, exitCode, err
 }
 
-func runDiffAgainstLastBackup(config app.AppConfig, profileName string) (int, error) {
+func runDiffAgainstLastBackup(config app.AppConfig, profileName string) (diffResult, int, error) {
+	if err := profile.ValidateName(profileName); err != nil {
+		return diffResult{}, 1, err
+	}
 	profilePath := filepath.Join(config.ConfigDir, fmt.Sprintf("oh-my-opencode.json.%s", profileName))
 	if _, err := os.Stat(profilePath); err != nil {
-		return 1, err
+		return diffResult{}, 1, err
 	}
 
-	backupName, ok, err := backup.LatestProfileBackup(config.ConfigDir, profileName)
+	res := diffResult{Profile: profileName, Against: "last-backup"}
+	store := backup.NewStore(config)
+	backupName, ok, err := backup.LatestProfileBackup(store, profileName)
 	if err != nil {
-		return 1, err
+		return diffResult{}, 1, err
 	}
 	if !ok {
-		fmt.Printf("No backups found for profile: %s\n", profileName)
-		return 2, nil
+		return res, 2, nil
 	}
-	backupPath := filepath.Join(config.ConfigDir, backupName)
 
-	diff, err := util.GitDiffNoIndex(backupPath, profilePath)
+	backupPath, err := backup.Locate(store, backupName)
 	if err != nil {
-		if errors.Is(err, util.ErrGitNotAvailable) {
-			return 1, fmt.Errorf("git is required for diff: %w", err)
+		return diffResult{}, 1, err
+	}
+	changes, err := profile.DiffProfileAgainstFile(config.ConfigDir, profileName, backupPath)
+	if err != nil {
+		return diffResult{}, 1, err
+	}
+	res.Against = backupName
+	res.Found = true
+	res.Changes = changes
+	return res, 0, nil
+}
+
+func runDiffBetween(config app.AppConfig, profileA, profileB string) (diffResult, int, error) {
+	for _, name := range []string{profileA, profileB} {
+		if err := profile.ValidateName(name); err != nil {
+			return diffResult{}, 1, err
 		}
-		return 1, err
 	}
-	fmt.Print(diff)
-	return 0, nil
+	changes, err := profile.DiffProfiles(config.ConfigDir, profileA, profileB)
+	if err != nil {
+		return diffResult{}, 1, err
+	}
+	return diffResult{Profile: profileB, Against: profileA, Found: true, Changes: changes}, 0, nil
+}
+
+// colorEnabled reports whether ANSI colors should be written to f.
+func colorEnabled(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
 }
 
-func runDiffBetween(config app.AppConfig, profileA, profileB string) (int, error) {
-	pathA := filepath.Join(config.ConfigDir, fmt.Sprintf("oh-my-opencode.json.%s", profileA))
-	pathB := filepath.Join(config.ConfigDir, fmt.Sprintf("oh-my-opencode.json.%s", profileB))
+var errAutofillDisabled = errors.New("Autofill is disabled. Enable with --enable-autofill or moirai.json.")
 
-	if _, err := os.Stat(pathA); err != nil {
-		return 1, err
+type autofillResult struct {
+	Profile string                `json:"profile"`
+	Preset  string                `json:"preset"`
+	Mode    string                `json:"mode"`
+	DryRun  bool                  `json:"dryRun"`
+	Changes []profile.AgentChange `json:"changes"`
+	Changed bool                  `json:"changed"`
+	Backup  string                `json:"backup,omitempty"`
+}
+
+func (r autofillResult) writeText(w io.Writer) {
+	if len(r.Changes) == 0 {
+		fmt.Fprintf(w, "No changes needed for profile: %s\n", r.Profile)
+		return
+	}
+	fmt.Fprintf(w, "Plan for %s (preset %s, mode %s):\n", r.Profile, r.Preset, r.Mode)
+	for _, change := range r.Changes {
+		from := change.From
+		if from == "" {
+			from = "(unset)"
+		}
+		fmt.Fprintf(w, " - %s: %s -> %s\n", change.Agent, from, change.To)
 	}
-	if _, err := os.Stat(pathB); err != nil {
-		return 1, err
+	if r.DryRun {
+		fmt.Fprintln(w, "Dry run: no changes written.")
+		return
 	}
+	fmt.Fprintf(w, "Autofilled: %s\n", r.Profile)
+	fmt.Fprintf(w, "Backup: %s\n", r.Backup)
+}
+
+const autofillUsage = "moirai autofill <profile> --preset <preset> [--mode missing|overwrite|upgrade|only=<agents>] [--dry-run]"
 
-	diff, err := util.GitDiffNoIndex(pathA, pathB)
+func runAutofillCommand(config app.AppConfig, args []string) (autofillResult, int, error) {
+	profileName, flagArgs := splitPositional(args)
+	if profileName == "" {
+		return autofillResult{}, 1, usageError(autofillUsage)
+	}
+	autofillFlags := flag.NewFlagSet("autofill", flag.ContinueOnError)
+	autofillFlags.SetOutput(io.Discard)
+	preset := autofillFlags.String("preset", "", "autofill preset")
+	mode := autofillFlags.String("mode", "", "missing, overwrite, upgrade or only=<agents>")
+	dryRun := autofillFlags.Bool("dry-run", false, "print the plan without writing")
+	if err := autofillFlags.Parse(flagArgs); err != nil {
+		return autofillResult{}, 1, err
+	}
+	if *preset == "" || autofillFlags.NArg() != 0 {
+		return autofillResult{}, 1, usageError(autofillUsage)
+	}
+	opts, err := profile.ParseAutofillMode(*mode)
 	if err != nil {
-		if errors.Is(err, util.ErrGitNotAvailable) {
-			return 1, fmt.Errorf("git is required for diff: %w", err)
+		return autofillResult{}, 1, err
+	}
+	if opts.Mode == profile.AutofillUpgrade {
+		if cached, ok, err := models.LoadCachedModels(filepath.Dir(config.ConfigDir)); err == nil && ok {
+			opts.Models = cached
 		}
-		return 1, err
 	}
-	fmt.Print(diff)
-	return 0, nil
+	return runAutofill(config, profileName, *preset, opts, *dryRun)
 }
 
-func runAutofill(config app.AppConfig, profileName, presetName string) (int, error) {
+func runAutofill(config app.AppConfig, profileName, presetName string, opts profile.AutofillOptions, dryRun bool) (autofillResult, int, error) {
 	if !config.EnableAutofill {
-		fmt.Fprintln(os.Stderr, "Autofill is disabled. Enable with --enable-autofill or moirai.json.")
-		return 3, nil
+		return autofillResult{}, 3, errAutofillDisabled
 	}
 	if profileName == "" {
-		return 1, fmt.Errorf("profile name is required")
+		return autofillResult{}, 1, fmt.Errorf("profile name is required")
+	}
+	if err := profile.ValidateName(profileName); err != nil {
+		return autofillResult{}, 1, err
 	}
 
-	preset, ok := profile.PresetByName(presetName)
-	if !ok {
-		return 1, fmt.Errorf("unknown preset: %s", presetName)
+	preset, err := resolvePreset(config, presetName)
+	if err != nil {
+		return autofillResult{}, 1, err
 	}
 
 	profilePath := filepath.Join(config.ConfigDir, fmt.Sprintf("oh-my-opencode.json.%s", profileName))
 	cfg, err := profile.LoadProfile(profilePath)
 	if err != nil {
-		return 1, err
+		return autofillResult{}, 1, err
 	}
 
-	changed := profile.ApplyAutofill(cfg, profile.KnownAgents(), preset)
-	if !changed {
-		fmt.Printf("No changes needed for profile: %s\n", profileName)
-		return 0, nil
+	changes, err := profile.PlanAutofill(cfg, catalog.Load(config).Required(), preset, opts)
+	if err != nil {
+		return autofillResult{}, 1, err
+	}
+	res := autofillResult{
+		Profile: profileName,
+		Preset:  preset.Name,
+		Mode:    opts.String(),
+		DryRun:  dryRun,
+		Changes: changes,
+	}
+	if res.Changes == nil {
+		res.Changes = []profile.AgentChange{}
+	}
+	if dryRun || !profile.ApplyAgentChanges(cfg, changes) {
+		return res, 0, nil
 	}
 
-	backupPath, err := backup.BackupProfile(config.ConfigDir, profileName)
+	beforeHash, err := journal.HashFile(profilePath)
 	if err != nil {
-		return 1, err
+		return autofillResult{}, 1, err
 	}
+	backupEntry, err := backup.BackupProfileWith(backup.NewStore(config), profileName, backup.Options{Reason: backup.ReasonAutofill, Retention: config.BackupRetention})
+	if err != nil {
+		return autofillResult{}, 1, err
+	}
+	backupPath := backupEntry.Path
 	if err := profile.SaveProfileAtomic(profilePath, cfg); err != nil {
-		return 1, err
+		return autofillResult{}, 1, err
 	}
-
-	fmt.Printf("Autofilled: %s\n", profileName)
-	fmt.Printf("Backup: %s\n", backupPath)
-	return 0, nil
+	afterHash, err := journal.HashFile(profilePath)
+	if err != nil {
+		return autofillResult{}, 1, err
+	}
+	recorded, err := journal.Record(config.ConfigDir, journal.Entry{
+		Op:         journal.OpAutofill,
+		Profile:    profileName,
+		File:       filepath.Base(profilePath),
+		BeforeHash: beforeHash,
+		AfterHash:  afterHash,
+		Backup:     backupPath,
+	})
+
+	res.Changed = true
+	res.Backup = backupPath
+	if err != nil {
+		// The profile is saved; only undo of this autofill is lost.
+		return res, 0, warning{fmt.Errorf("journal autofill: %w", err)}
+	}
+	return res, 0, commitOperation(config, recorded)
 }
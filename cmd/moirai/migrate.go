@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+)
+
+type migrateBackupsResult struct {
+	DryRun bool          `json:"dryRun"`
+	Store  string        `json:"store"`
+	Moved  []backup.Move `json:"moved"`
+}
+
+func (r migrateBackupsResult) writeText(w io.Writer) {
+	if r.DryRun {
+		fmt.Fprintf(w, "Migrate backups to %s (dry run):\n", r.Store)
+	} else {
+		fmt.Fprintf(w, "Migrate backups to %s:\n", r.Store)
+	}
+	if len(r.Moved) == 0 {
+		fmt.Fprintln(w, " (no backups in the config dir)")
+		return
+	}
+	for _, move := range r.Moved {
+		fmt.Fprintf(w, " - %s -> %s\n", move.From, move.To)
+	}
+}
+
+const migrateBackupsUsage = "moirai migrate-backups [--dry-run]"
+
+func runMigrateBackups(config app.AppConfig, args []string) (migrateBackupsResult, error) {
+	migrateFlags := flag.NewFlagSet("migrate-backups", flag.ContinueOnError)
+	migrateFlags.SetOutput(io.Discard)
+	dryRun := migrateFlags.Bool("dry-run", false, "report without moving")
+	if err := migrateFlags.Parse(args); err != nil {
+		return migrateBackupsResult{}, err
+	}
+	if migrateFlags.NArg() != 0 {
+		return migrateBackupsResult{}, usageError(migrateBackupsUsage)
+	}
+	store := backup.NewStore(config)
+	moved, err := backup.Migrate(store, *dryRun)
+	if err != nil {
+		return migrateBackupsResult{}, err
+	}
+	return migrateBackupsResult{DryRun: *dryRun, Store: store.Dir, Moved: moved}, nil
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// outputSchemaVersion is bumped whenever a machine-readable result changes
+// in a backwards-incompatible way.
+const outputSchemaVersion = 1
+
+type outputFormat string
+
+const (
+	outputText outputFormat = "text"
+	outputJSON outputFormat = "json"
+	outputYAML outputFormat = "yaml"
+)
+
+func parseOutputFormat(value string) (outputFormat, error) {
+	switch outputFormat(value) {
+	case outputText, outputJSON, outputYAML:
+		return outputFormat(value), nil
+	default:
+		return "", fmt.Errorf("invalid value for --output: %q (expected text, json or yaml)", value)
+	}
+}
+
+// textResult is implemented by every command result so it can be rendered
+// for humans in addition to the structured formats.
+type textResult interface {
+	writeText(w io.Writer)
+}
+
+type resultEnvelope struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	Command       string       `json:"command"`
+	Result        any          `json:"result,omitempty"`
+	Warnings      []string     `json:"warnings,omitempty"`
+	Error         *errorObject `json:"error,omitempty"`
+}
+
+// warning is an error reported alongside a command's result instead of
+// failing it, for a follow-up step that failed after the change was made.
+type warning struct{ err error }
+
+func (w warning) Error() string { return w.err.Error() }
+func (w warning) Unwrap() error { return w.err }
+
+type errorObject struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type printer struct {
+	format outputFormat
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func newPrinter(format outputFormat, stdout, stderr io.Writer) printer {
+	if format == "" {
+		format = outputText
+	}
+	return printer{format: format, stdout: stdout, stderr: stderr}
+}
+
+// finish renders the outcome of a command and returns its exit code.
+// A non-nil err is reported as an error object with exitCode (or 1 when
+// exitCode is zero), except that a warning is reported alongside the result.
+func (p printer) finish(command string, res textResult, exitCode int, err error) int {
+	var warn warning
+	if res != nil && errors.As(err, &warn) {
+		p.writeResult(command, res, err.Error())
+		return exitCode
+	}
+	if err != nil {
+		if exitCode == 0 {
+			exitCode = 1
+		}
+		p.writeError(command, exitCode, err)
+		return exitCode
+	}
+	if res != nil {
+		p.writeResult(command, res)
+	}
+	return exitCode
+}
+
+func (p printer) writeResult(command string, res textResult, warnings ...string) {
+	if p.format == outputText {
+		res.writeText(p.stdout)
+		for _, warning := range warnings {
+			fmt.Fprintf(p.stderr, "Warning: %s\n", warning)
+		}
+		return
+	}
+	p.encode(p.stdout, resultEnvelope{
+		SchemaVersion: outputSchemaVersion,
+		Command:       command,
+		Result:        res,
+		Warnings:      warnings,
+	})
+}
+
+func (p printer) writeError(command string, exitCode int, err error) {
+	if p.format == outputText {
+		fmt.Fprintln(p.stderr, err)
+		return
+	}
+	p.encode(p.stderr, resultEnvelope{
+		SchemaVersion: outputSchemaVersion,
+		Command:       command,
+		Error:         &errorObject{Code: exitCode, Message: err.Error()},
+	})
+}
+
+func (p printer) encode(w io.Writer, value any) {
+	// Values such as "<redacted>" are shown as is rather than HTML-escaped.
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		fmt.Fprintln(p.stderr, err)
+		return
+	}
+	if p.format == outputYAML {
+		data, err := jsonToYAML(buf.Bytes())
+		if err != nil {
+			fmt.Fprintln(p.stderr, err)
+			return
+		}
+		_, _ = w.Write(data)
+		return
+	}
+	_, _ = w.Write(buf.Bytes())
+}
+
+// yamlNode is an order-preserving view of a decoded JSON value.
+type yamlNode struct {
+	kind   byte // 'o' object, 'a' array, 's' scalar
+	keys   []string
+	values []*yamlNode
+	scalar string
+}
+
+// jsonToYAML converts a JSON document to block-style YAML, keeping key order.
+func jsonToYAML(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := decodeYAMLNode(dec)
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	writeYAMLNode(&b, root, 0)
+	return []byte(b.String()), nil
+}
+
+func decodeYAMLNode(dec *json.Decoder) (*yamlNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch value := tok.(type) {
+	case json.Delim:
+		switch value {
+		case '{':
+			node := &yamlNode{kind: 'o'}
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, ok := keyTok.(string)
+				if !ok {
+					return nil, fmt.Errorf("unexpected object key %v", keyTok)
+				}
+				child, err := decodeYAMLNode(dec)
+				if err != nil {
+					return nil, err
+				}
+				node.keys = append(node.keys, key)
+				node.values = append(node.values, child)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return node, nil
+		case '[':
+			node := &yamlNode{kind: 'a'}
+			for dec.More() {
+				child, err := decodeYAMLNode(dec)
+				if err != nil {
+					return nil, err
+				}
+				node.values = append(node.values, child)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return node, nil
+		}
+		return nil, fmt.Errorf("unexpected delimiter %v", value)
+	case string:
+		return &yamlNode{kind: 's', scalar: yamlString(value)}, nil
+	case json.Number:
+		return &yamlNode{kind: 's', scalar: value.String()}, nil
+	case bool:
+		if value {
+			return &yamlNode{kind: 's', scalar: "true"}, nil
+		}
+		return &yamlNode{kind: 's', scalar: "false"}, nil
+	case nil:
+		return &yamlNode{kind: 's', scalar: "null"}, nil
+	}
+	return nil, fmt.Errorf("unexpected token %v", tok)
+}
+
+func writeYAMLNode(b *strings.Builder, node *yamlNode, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch node.kind {
+	case 'o':
+		if len(node.keys) == 0 {
+			b.WriteString(pad + "{}\n")
+			return
+		}
+		for i, key := range node.keys {
+			b.WriteString(pad + yamlString(key) + ":")
+			writeYAMLChild(b, node.values[i], indent+1)
+		}
+	case 'a':
+		if len(node.values) == 0 {
+			b.WriteString(pad + "[]\n")
+			return
+		}
+		for _, child := range node.values {
+			b.WriteString(pad + "-")
+			writeYAMLChild(b, child, indent+1)
+		}
+	default:
+		b.WriteString(pad + node.scalar + "\n")
+	}
+}
+
+func writeYAMLChild(b *strings.Builder, node *yamlNode, indent int) {
+	switch {
+	case node.kind == 's':
+		b.WriteString(" " + node.scalar + "\n")
+	case node.kind == 'o' && len(node.keys) == 0:
+		b.WriteString(" {}\n")
+	case node.kind == 'a' && len(node.values) == 0:
+		b.WriteString(" []\n")
+	default:
+		b.WriteString("\n")
+		writeYAMLNode(b, node, indent)
+	}
+}
+
+// yamlString returns s as a plain scalar when that is unambiguous and as a
+// double-quoted scalar otherwise.
+func yamlString(s string) string {
+	if yamlPlainSafe(s) {
+		return s
+	}
+	encoded, _ := json.Marshal(s)
+	return string(encoded)
+}
+
+func yamlPlainSafe(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return false
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~", "y", "n":
+		return false
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`0123456789.+") {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
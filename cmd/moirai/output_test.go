@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"moirai/internal/app"
+)
+
+func setupOutputConfig(t *testing.T) string {
+	t.Helper()
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", xdg)
+	configDir := filepath.Join(xdg, "opencode")
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		t.Fatalf("mkdir config: %v", err)
+	}
+	for _, name := range []string{"alpha", "beta"} {
+		path := filepath.Join(configDir, "oh-my-opencode.json."+name)
+		if err := os.WriteFile(path, []byte(`{"agents":{}}`), 0o600); err != nil {
+			t.Fatalf("write profile: %v", err)
+		}
+	}
+	if err := os.Symlink("oh-my-opencode.json.beta", filepath.Join(configDir, "oh-my-opencode.json")); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+	return configDir
+}
+
+func noTUI(_ app.AppConfig) error { return nil }
+
+func TestRunListJSONOutput(t *testing.T) {
+	configDir := setupOutputConfig(t)
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exitCode := run([]string{"moirai", "--output", "json", "list"}, noTUI, stdout, stderr)
+	if exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+
+	var envelope struct {
+		SchemaVersion int        `json:"schemaVersion"`
+		Command       string     `json:"command"`
+		Result        listResult `json:"result"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &envelope); err != nil {
+		t.Fatalf("parse output: %v\n%s", err, stdout.String())
+	}
+	if envelope.SchemaVersion != outputSchemaVersion || envelope.Command != "list" {
+		t.Fatalf("unexpected envelope: %+v", envelope)
+	}
+	if envelope.Result.ConfigDir != configDir {
+		t.Fatalf("expected config dir %q, got %q", configDir, envelope.Result.ConfigDir)
+	}
+	if envelope.Result.Active == nil || *envelope.Result.Active != "beta" {
+		t.Fatalf("expected active beta, got %v", envelope.Result.Active)
+	}
+	if len(envelope.Result.Profiles) != 2 {
+		t.Fatalf("expected 2 profiles, got %d", len(envelope.Result.Profiles))
+	}
+	beta := envelope.Result.Profiles[1]
+	if beta.Name != "beta" || !beta.Active || beta.Path != filepath.Join(configDir, "oh-my-opencode.json.beta") {
+		t.Fatalf("unexpected beta entry: %+v", beta)
+	}
+}
+
+func TestRunErrorJSONKeepsExitCode(t *testing.T) {
+	setupOutputConfig(t)
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exitCode := run([]string{"moirai", "--output=json", "autofill", "alpha", "--preset", "openai"}, noTUI, stdout, stderr)
+	if exitCode != 3 {
+		t.Fatalf("expected exit code 3, got %d", exitCode)
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected empty stdout, got %q", stdout.String())
+	}
+
+	var envelope resultEnvelope
+	if err := json.Unmarshal(stderr.Bytes(), &envelope); err != nil {
+		t.Fatalf("parse error output: %v\n%s", err, stderr.String())
+	}
+	if envelope.Command != "autofill" || envelope.Error == nil {
+		t.Fatalf("expected autofill error envelope, got %+v", envelope)
+	}
+	if envelope.Error.Code != 3 {
+		t.Fatalf("expected error code 3, got %d", envelope.Error.Code)
+	}
+}
+
+func TestRunDoctorYAMLOutput(t *testing.T) {
+	setupOutputConfig(t)
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exitCode := run([]string{"moirai", "--output", "yaml", "doctor", "alpha"}, noTUI, stdout, stderr)
+	if exitCode != 2 {
+		t.Fatalf("expected exit code 2, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"schemaVersion: 1\n", "command: doctor\n", "  profile: alpha\n", "  missing:\n    - atlas\n"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunRejectsUnknownOutputFormat(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exitCode := run([]string{"moirai", "--output", "xml", "list"}, noTUI, stdout, stderr)
+	if exitCode != 1 {
+		t.Fatalf("expected exit code 1, got %d", exitCode)
+	}
+	if !strings.Contains(stderr.String(), "--output") {
+		t.Fatalf("expected output flag error, got %q", stderr.String())
+	}
+}
+
+func TestJSONToYAMLQuotesAmbiguousScalars(t *testing.T) {
+	out, err := jsonToYAML([]byte(`{"a":"true","b":"","c":"plain","d":[],"e":{"f":["x: y",1]}}`))
+	if err != nil {
+		t.Fatalf("jsonToYAML: %v", err)
+	}
+	want := "a: \"true\"\nb: \"\"\nc: plain\nd: []\ne:\n  f:\n    - \"x: y\"\n    - 1\n"
+	if string(out) != want {
+		t.Fatalf("unexpected yaml:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestRunDiffBetweenJSONOutput(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	if err := os.WriteFile(filepath.Join(configDir, "oh-my-opencode.json.beta"), []byte(`{"agents":{"oracle":{"model":"gpt-4.1"}}}`), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exitCode := run([]string{"moirai", "--output", "json", "diff", "--between", "alpha", "beta"}, noTUI, stdout, stderr)
+	if exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	var envelope struct {
+		Result diffResult `json:"result"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &envelope); err != nil {
+		t.Fatalf("parse output: %v\n%s", err, stdout.String())
+	}
+	changes := envelope.Result.Changes
+	if len(changes) != 1 || changes[0].Path != "agents.oracle" || changes[0].Kind != "added" {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+}
+
+func TestRunDiffBetweenIdenticalProfiles(t *testing.T) {
+	setupOutputConfig(t)
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "diff", "--between", "alpha", "beta"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	if stdout.String() != "No differences.\n" {
+		t.Fatalf("unexpected output %q", stdout.String())
+	}
+
+	stdout.Reset()
+	if exitCode := run([]string{"moirai", "diff", "--between", "../x", "beta"}, noTUI, stdout, stderr); exitCode != 1 {
+		t.Fatalf("expected a path in the name to be rejected, got %d", exitCode)
+	}
+}
+
+func TestFailedGitCommitIsAWarning(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	if err := os.WriteFile(filepath.Join(configDir, app.ConfigFileName), []byte(`{"git": {"enabled": true}}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	// A file where the store's repository belongs makes every commit fail.
+	if err := os.MkdirAll(app.StateDir(configDir), 0o700); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(app.StateDir(configDir), "git"), nil, 0o600); err != nil {
+		t.Fatalf("write git: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "apply", "alpha"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected apply to succeed, got %d: %s", exitCode, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "alpha") || !strings.HasPrefix(stderr.String(), "Warning: git store commit failed") {
+		t.Fatalf("unexpected output %q, stderr %q", stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if exitCode := run([]string{"moirai", "--output", "json", "apply", "beta"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected apply to succeed, got %d: %s", exitCode, stderr.String())
+	}
+	var envelope struct {
+		Result   applyResult `json:"result"`
+		Warnings []string    `json:"warnings"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &envelope); err != nil {
+		t.Fatalf("parse output: %v\n%s", err, stdout.String())
+	}
+	if envelope.Result.Profile != "beta" || len(envelope.Warnings) != 1 {
+		t.Fatalf("unexpected envelope %+v", envelope)
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"moirai/internal/app"
+	"moirai/internal/catalog"
+	"moirai/internal/presets"
+	"moirai/internal/profile"
+)
+
+const presetsUsage = "moirai presets list | moirai presets show <preset>"
+
+type presetsListResult struct {
+	Default string          `json:"default"`
+	Presets []presetSummary `json:"presets"`
+}
+
+type presetSummary struct {
+	Name        string         `json:"name"`
+	Source      presets.Source `json:"source"`
+	Extends     string         `json:"extends,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Default     bool           `json:"default"`
+}
+
+func (r presetsListResult) writeText(w io.Writer) {
+	fmt.Fprintln(w, "Presets:")
+	for _, entry := range r.Presets {
+		marker := " "
+		if entry.Default {
+			marker = "*"
+		}
+		origin := string(entry.Source)
+		if entry.Extends != "" {
+			origin += ", extends " + entry.Extends
+		}
+		line := fmt.Sprintf(" %s %s (%s)", marker, entry.Name, origin)
+		if entry.Description != "" {
+			line += ": " + entry.Description
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+type presetShowResult struct {
+	Name        string         `json:"name"`
+	Source      presets.Source `json:"source"`
+	Path        string         `json:"path,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Chain       []string       `json:"chain"`
+	Fallback    string         `json:"fallback,omitempty"`
+	Agents      []presetAgent  `json:"agents"`
+}
+
+type presetAgent struct {
+	Agent string `json:"agent"`
+	Model string `json:"model,omitempty"`
+	// Via is "mapping" for per-agent models and "fallback" for the default.
+	Via string `json:"via,omitempty"`
+}
+
+func (r presetShowResult) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Preset: %s (%s)\n", r.Name, r.Source)
+	if r.Path != "" {
+		fmt.Fprintf(w, "Path: %s\n", r.Path)
+	}
+	if r.Description != "" {
+		fmt.Fprintf(w, "Description: %s\n", r.Description)
+	}
+	if len(r.Chain) > 1 {
+		fmt.Fprintf(w, "Extends: %s\n", strings.Join(r.Chain[1:], " -> "))
+	}
+	if r.Fallback != "" {
+		fmt.Fprintf(w, "Fallback: %s\n", r.Fallback)
+	} else {
+		fmt.Fprintln(w, "Fallback: (none)")
+	}
+	fmt.Fprintln(w, "Agents:")
+	for _, agent := range r.Agents {
+		switch agent.Via {
+		case "":
+			fmt.Fprintf(w, " - %s: (not filled)\n", agent.Agent)
+		case "fallback":
+			fmt.Fprintf(w, " - %s: %s (fallback)\n", agent.Agent, agent.Model)
+		default:
+			fmt.Fprintf(w, " - %s: %s\n", agent.Agent, agent.Model)
+		}
+	}
+}
+
+func runPresets(config app.AppConfig, args []string) (textResult, error) {
+	if len(args) == 0 {
+		return nil, usageError(presetsUsage)
+	}
+	set, err := presets.Load(config)
+	if err != nil {
+		return nil, err
+	}
+	switch args[0] {
+	case "list":
+		if len(args) != 1 {
+			return nil, usageError(presetsUsage)
+		}
+		res := presetsListResult{Default: config.DefaultPreset, Presets: []presetSummary{}}
+		for _, def := range set.List() {
+			res.Presets = append(res.Presets, presetSummary{
+				Name:        def.Name,
+				Source:      def.Source,
+				Extends:     def.Spec.Extends,
+				Description: def.Spec.Description,
+				Default:     def.Name == config.DefaultPreset,
+			})
+		}
+		return res, nil
+	case "show":
+		if len(args) != 2 {
+			return nil, usageError(presetsUsage)
+		}
+		return showPreset(config, set, args[1])
+	default:
+		return nil, usageError(presetsUsage)
+	}
+}
+
+func showPreset(config app.AppConfig, set presets.Set, name string) (presetShowResult, error) {
+	resolved, err := set.Resolve(name)
+	if err != nil {
+		return presetShowResult{}, err
+	}
+	def, _ := set.Lookup(name)
+	res := presetShowResult{
+		Name:        name,
+		Source:      def.Source,
+		Path:        def.Path,
+		Description: resolved.Description,
+		Chain:       resolved.Chain,
+		Fallback:    resolved.Model,
+		Agents:      []presetAgent{},
+	}
+
+	agents := catalog.Load(config).Names()
+	listed := make(map[string]bool, len(agents))
+	for _, agent := range agents {
+		listed[agent] = true
+	}
+	var extra []string
+	for agent := range resolved.Agents {
+		if !listed[agent] {
+			extra = append(extra, agent)
+		}
+	}
+	sort.Strings(extra)
+	for _, agent := range append(agents, extra...) {
+		entry := presetAgent{Agent: agent}
+		if model, ok := resolved.Agents[agent]; ok {
+			entry.Model, entry.Via = model, "mapping"
+		} else if resolved.Model != "" {
+			entry.Model, entry.Via = resolved.Model, "fallback"
+		}
+		res.Agents = append(res.Agents, entry)
+	}
+	return res, nil
+}
+
+// resolvePreset loads the presets for the config dir and resolves one by name.
+func resolvePreset(config app.AppConfig, name string) (profile.Preset, error) {
+	set, err := presets.Load(config)
+	if err != nil {
+		return profile.Preset{}, err
+	}
+	resolved, err := set.Resolve(name)
+	if err != nil {
+		return profile.Preset{}, err
+	}
+	return resolved.Preset, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"moirai/internal/profile"
+)
+
+const presetsConfig = `{
+  "enableAutofill": true,
+  "defaultPreset": "cheap",
+  "presets": {
+    "team": {"description": "Team models", "default": "gpt-4.1", "agents": {"oracle": "o3"}},
+    "cheap": {"extends": "team", "agents": {"explore": "gpt-4o-mini"}}
+  }
+}`
+
+func TestRunPresetsListAndShow(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	if err := os.WriteFile(filepath.Join(configDir, "moirai.json"), []byte(presetsConfig), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "presets", "list"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	output := stdout.String()
+	for _, want := range []string{" * cheap (config, extends team)", "   openai (builtin)", "   team (config): Team models"} {
+		if !strings.Contains(output, want) {
+			t.Fatalf("expected %q in output:\n%s", want, output)
+		}
+	}
+
+	stdout.Reset()
+	if exitCode := run([]string{"moirai", "presets", "show", "cheap"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	output = stdout.String()
+	for _, want := range []string{"Extends: team", "Fallback: gpt-4.1", " - oracle: o3\n", " - explore: gpt-4o-mini\n", " - atlas: gpt-4.1 (fallback)"} {
+		if !strings.Contains(output, want) {
+			t.Fatalf("expected %q in output:\n%s", want, output)
+		}
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	if exitCode := run([]string{"moirai", "presets", "show", "missing"}, noTUI, stdout, stderr); exitCode != 1 {
+		t.Fatalf("expected exit code 1, got %d", exitCode)
+	}
+	if !strings.Contains(stderr.String(), "unknown preset: missing") {
+		t.Fatalf("unexpected stderr: %s", stderr.String())
+	}
+}
+
+func TestRunAutofillUsesUserPreset(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	if err := os.WriteFile(filepath.Join(configDir, "moirai.json"), []byte(presetsConfig), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "autofill", "alpha", "--preset", "cheap"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	cfg, err := profile.LoadProfile(filepath.Join(configDir, "oh-my-opencode.json.alpha"))
+	if err != nil {
+		t.Fatalf("LoadProfile: %v", err)
+	}
+	want := map[string]string{"oracle": "o3", "explore": "gpt-4o-mini", "sisyphus": "gpt-4.1"}
+	for agent, model := range want {
+		if got := cfg.Agents[agent].Model; got != model {
+			t.Fatalf("expected %s on %s, got %q", model, agent, got)
+		}
+	}
+}
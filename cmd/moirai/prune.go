@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+)
+
+type pruneResult struct {
+	DryRun   bool           `json:"dryRun"`
+	Profiles []pruneProfile `json:"profiles"`
+}
+
+type pruneProfile struct {
+	Profile    string   `json:"profile"`
+	Active     bool     `json:"active"`
+	Kept       int      `json:"kept"`
+	Deleted    []string `json:"deleted"`
+	FreedBytes int64    `json:"freedBytes"`
+}
+
+func (r pruneResult) writeText(w io.Writer) {
+	if r.DryRun {
+		fmt.Fprintln(w, "Prune (dry run):")
+	} else {
+		fmt.Fprintln(w, "Prune:")
+	}
+	if len(r.Profiles) == 0 {
+		fmt.Fprintln(w, " (no backups)")
+		return
+	}
+	verb := "deleted"
+	if r.DryRun {
+		verb = "would delete"
+	}
+	for _, entry := range r.Profiles {
+		label := entry.Profile
+		if entry.Active {
+			label = "(active config)"
+		}
+		fmt.Fprintf(w, " %s: keep %d, %s %d (%d bytes)\n", label, entry.Kept, verb, len(entry.Deleted), entry.FreedBytes)
+		for _, name := range entry.Deleted {
+			fmt.Fprintf(w, "   - %s\n", name)
+		}
+	}
+}
+
+const pruneUsage = "moirai prune [<profile>] [--dry-run]"
+
+func runPrune(config app.AppConfig, args []string) (pruneResult, error) {
+	pruneFlags := flag.NewFlagSet("prune", flag.ContinueOnError)
+	pruneFlags.SetOutput(io.Discard)
+	dryRun := pruneFlags.Bool("dry-run", false, "report without deleting")
+	profileName := ""
+	if len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		profileName = args[0]
+		args = args[1:]
+	}
+	if err := pruneFlags.Parse(args); err != nil {
+		return pruneResult{}, err
+	}
+	if pruneFlags.NArg() != 0 {
+		return pruneResult{}, usageError(pruneUsage)
+	}
+	if !config.BackupRetention.Enabled() {
+		return pruneResult{}, fmt.Errorf("no backup retention configured; set backupRetention in %s", app.ConfigFileName)
+	}
+
+	store := backup.NewStore(config)
+	var plans []backup.PrunePlan
+	if profileName != "" {
+		plan, err := backup.PlanProfilePrune(store, profileName, config.BackupRetention)
+		if err != nil {
+			return pruneResult{}, err
+		}
+		plans = []backup.PrunePlan{plan}
+	} else {
+		var err error
+		plans, err = backup.PlanPrune(store, config.BackupRetention)
+		if err != nil {
+			return pruneResult{}, err
+		}
+	}
+
+	res := pruneResult{DryRun: *dryRun, Profiles: make([]pruneProfile, 0, len(plans))}
+	for _, plan := range plans {
+		if !*dryRun {
+			if err := backup.ApplyPrune(plan); err != nil {
+				return pruneResult{}, err
+			}
+		}
+		deleted := make([]string, 0, len(plan.Delete))
+		for _, file := range plan.Delete {
+			deleted = append(deleted, file.Name)
+		}
+		res.Profiles = append(res.Profiles, pruneProfile{
+			Profile:    plan.Profile,
+			Active:     plan.Profile == backup.ActiveGroup,
+			Kept:       len(plan.Keep),
+			Deleted:    deleted,
+			FreedBytes: plan.FreedBytes(),
+		})
+	}
+	return res, nil
+}
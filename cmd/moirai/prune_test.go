@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+)
+
+func TestPruneDryRunKeepsFiles(t *testing.T) {
+	configDir := t.TempDir()
+	for _, name := range []string{
+		"oh-my-opencode.json.alpha",
+		"oh-my-opencode.json.alpha.bak.20240101-000000",
+		"oh-my-opencode.json.alpha.bak.20240102-000000",
+		"oh-my-opencode.json.alpha.bak.20240103-000000",
+	} {
+		if err := os.WriteFile(filepath.Join(configDir, name), []byte("{}"), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	config := app.AppConfig{ConfigDir: configDir, BackupRetention: app.BackupRetention{KeepLast: 1}}
+
+	res, err := runPrune(config, []string{"alpha", "--dry-run"})
+	if err != nil {
+		t.Fatalf("runPrune: %v", err)
+	}
+	if !res.DryRun || len(res.Profiles) != 1 || len(res.Profiles[0].Deleted) != 2 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	backups, err := backup.ListProfileBackups(backup.NewStore(config), "alpha")
+	if err != nil || len(backups) != 3 {
+		t.Fatalf("expected dry run to keep 3 backups, got %v %v", backups, err)
+	}
+
+	if _, err := runPrune(config, nil); err != nil {
+		t.Fatalf("runPrune: %v", err)
+	}
+	backups, err = backup.ListProfileBackups(backup.NewStore(config), "alpha")
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("expected 1 backup after prune, got %v %v", backups, err)
+	}
+}
+
+func TestBackupCommandAppliesRetention(t *testing.T) {
+	configDir := t.TempDir()
+	for _, name := range []string{
+		"oh-my-opencode.json.alpha",
+		"oh-my-opencode.json.alpha.bak.20240101-000000",
+		"oh-my-opencode.json.alpha.bak.20240102-000000",
+	} {
+		if err := os.WriteFile(filepath.Join(configDir, name), []byte(name), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	config := app.AppConfig{ConfigDir: configDir, BackupRetention: app.BackupRetention{KeepLast: 1}}
+
+	res, err := runBackup(config, []string{"alpha"})
+	if err != nil {
+		t.Fatalf("runBackup: %v", err)
+	}
+	backups, err := backup.ListProfileBackups(backup.NewStore(config), "alpha")
+	if err != nil || len(backups) != 1 || backups[0] != filepath.Base(res.Backup) {
+		t.Fatalf("expected only the new backup to be kept, got %v %v", backups, err)
+	}
+}
+
+func TestPruneRequiresPolicy(t *testing.T) {
+	config := app.AppConfig{ConfigDir: t.TempDir()}
+	if _, err := runPrune(config, nil); err == nil {
+		t.Fatal("expected error without retention policy")
+	}
+}
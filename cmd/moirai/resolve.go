@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"moirai/internal/app"
+	"moirai/internal/profile"
+	"moirai/internal/secrets"
+)
+
+const resolveUsage = "moirai resolve <profile> [--strict] [--show-secrets]"
+
+type resolveResult struct {
+	Profile    string              `json:"profile"`
+	Chain      []string            `json:"chain"`
+	Config     json.RawMessage     `json:"config"`
+	Origins    []profile.Origin    `json:"origins"`
+	References []profile.Reference `json:"references"`
+}
+
+func (r resolveResult) writeText(w io.Writer) {
+	fmt.Fprintf(w, "Profile: %s\n", r.Profile)
+	if len(r.Chain) > 1 {
+		fmt.Fprintf(w, "Extends: %s\n", strings.Join(r.Chain[:len(r.Chain)-1], ", "))
+	} else {
+		fmt.Fprintln(w, "Extends: (none)")
+	}
+	fmt.Fprintln(w, "Resolved:")
+	fmt.Fprint(w, string(r.Config))
+	fmt.Fprintln(w, "Origins:")
+	if len(r.Origins) == 0 {
+		fmt.Fprintln(w, " (none)")
+	}
+	for _, origin := range r.Origins {
+		fmt.Fprintf(w, " - %s <- %s\n", origin.Path, origin.Profile)
+	}
+	if len(r.References) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "References:")
+	for _, ref := range r.References {
+		if ref.Resolved {
+			fmt.Fprintf(w, " - %s: %s (resolved)\n", ref.Path, ref.Placeholder)
+			continue
+		}
+		fmt.Fprintf(w, " - %s: %s unresolved: %s\n", ref.Path, ref.Placeholder, ref.Error)
+	}
+}
+
+// runResolve previews the config apply would materialize: parents merged
+// and references expanded. Secret values are redacted unless asked for.
+func runResolve(config app.AppConfig, args []string) (resolveResult, error) {
+	name, flagArgs := splitPositional(args)
+	resolveFlags := flag.NewFlagSet("resolve", flag.ContinueOnError)
+	resolveFlags.SetOutput(io.Discard)
+	strict := resolveFlags.Bool("strict", config.StrictReferences, "fail on unresolved references")
+	showSecrets := resolveFlags.Bool("show-secrets", false, "print secret values")
+	if err := resolveFlags.Parse(flagArgs); err != nil {
+		return resolveResult{}, err
+	}
+	if name == "" || resolveFlags.NArg() != 0 {
+		return resolveResult{}, usageError(resolveUsage)
+	}
+	resolved, err := profile.ResolveProfile(config.ConfigDir, name)
+	if err != nil {
+		return resolveResult{}, err
+	}
+	data, refs, err := profile.ExpandReferences(resolved.Data, profile.ReferenceOptions{BaseDir: config.ConfigDir})
+	if err != nil {
+		return resolveResult{}, err
+	}
+	if *strict {
+		if err := profile.UnresolvedError(name, refs); err != nil {
+			return resolveResult{}, err
+		}
+	}
+	if !*showSecrets {
+		if data, _, err = secrets.RedactJSON(data); err != nil {
+			return resolveResult{}, err
+		}
+	}
+	return resolveResult{
+		Profile:    resolved.Name,
+		Chain:      resolved.Chain,
+		Config:     json.RawMessage(data),
+		Origins:    resolved.Origins,
+		References: refs,
+	}, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunResolvePreviewsReferences(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	t.Setenv("MOIRAI_TEST_KEY", "sk-from-env")
+	profileData := `{"provider":{"apiKey":"{env:MOIRAI_TEST_KEY}","model":"{env:MOIRAI_TEST_UNSET}"}}`
+	if err := os.WriteFile(filepath.Join(configDir, "oh-my-opencode.json.alpha"), []byte(profileData), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "resolve", "alpha"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("resolve failed with %d: %s", exitCode, stderr.String())
+	}
+	out := stdout.String()
+	if strings.Contains(out, "sk-from-env") || !strings.Contains(out, "<redacted>") {
+		t.Fatalf("expected redacted preview, got %s", out)
+	}
+	if !strings.Contains(out, "provider.apiKey: {env:MOIRAI_TEST_KEY} (resolved)") || !strings.Contains(out, "provider.model: {env:MOIRAI_TEST_UNSET} unresolved") {
+		t.Fatalf("expected references listed, got %s", out)
+	}
+
+	stdout.Reset()
+	if exitCode := run([]string{"moirai", "resolve", "alpha", "--show-secrets"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("resolve failed with %d: %s", exitCode, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "sk-from-env") {
+		t.Fatalf("expected secret with --show-secrets, got %s", stdout.String())
+	}
+
+	stderr.Reset()
+	if exitCode := run([]string{"moirai", "apply", "alpha", "--strict"}, noTUI, stdout, stderr); exitCode != 1 {
+		t.Fatalf("expected strict apply to fail, got %d", exitCode)
+	}
+	if !strings.Contains(stderr.String(), "MOIRAI_TEST_UNSET is not set") {
+		t.Fatalf("unexpected stderr %q", stderr.String())
+	}
+
+	stdout.Reset()
+	if exitCode := run([]string{"moirai", "apply", "alpha"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("apply failed with %d: %s", exitCode, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Warning: {env:MOIRAI_TEST_UNSET} at provider.model is unresolved") {
+		t.Fatalf("expected unresolved warning, got %q", stdout.String())
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"moirai/internal/app"
+	"moirai/internal/profile"
+	"moirai/internal/secrets"
+)
+
+const secretsUsage = "moirai secrets scan [<profile>...]"
+
+type secretsScanResult struct {
+	Profiles []secretsProfile `json:"profiles"`
+	Total    int              `json:"total"`
+}
+
+type secretsProfile struct {
+	Profile  string            `json:"profile"`
+	Path     string            `json:"path"`
+	Mode     string            `json:"mode"`
+	Findings []secrets.Finding `json:"findings"`
+	Error    string            `json:"error,omitempty"`
+}
+
+func (r secretsScanResult) writeText(w io.Writer) {
+	if r.Total == 0 {
+		fmt.Fprintln(w, "No secrets found.")
+	}
+	for _, entry := range r.Profiles {
+		if entry.Error != "" {
+			fmt.Fprintf(w, "%s: cannot scan: %s\n", entry.Profile, entry.Error)
+			continue
+		}
+		if len(entry.Findings) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s (%s, mode %s):\n", entry.Profile, entry.Path, entry.Mode)
+		for _, finding := range entry.Findings {
+			fmt.Fprintf(w, " - %s: %s\n", finding.Path, finding.Reason)
+		}
+	}
+}
+
+func runSecrets(config app.AppConfig, args []string) (secretsScanResult, int, error) {
+	if len(args) == 0 || args[0] != "scan" {
+		return secretsScanResult{}, 1, usageError(secretsUsage)
+	}
+	names := args[1:]
+	if len(names) == 0 {
+		profiles, err := profile.DiscoverProfiles(config.ConfigDir)
+		if err != nil {
+			return secretsScanResult{}, 1, err
+		}
+		for _, info := range profiles {
+			names = append(names, info.Name)
+		}
+	}
+
+	res := secretsScanResult{Profiles: []secretsProfile{}}
+	for _, name := range names {
+		if err := profile.ValidateName(name); err != nil {
+			return secretsScanResult{}, 1, err
+		}
+		entry := secretsProfile{Profile: name, Path: profile.ProfilePath(config.ConfigDir, name), Findings: []secrets.Finding{}}
+		info, err := os.Stat(entry.Path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return secretsScanResult{}, 1, fmt.Errorf("profile %q not found", name)
+			}
+			return secretsScanResult{}, 1, err
+		}
+		entry.Mode = fmt.Sprintf("%04o", info.Mode().Perm())
+		data, err := os.ReadFile(entry.Path)
+		if err != nil {
+			return secretsScanResult{}, 1, err
+		}
+		findings, err := secrets.ScanJSON(data)
+		if err != nil {
+			entry.Error = err.Error()
+		} else {
+			entry.Findings = findings
+			res.Total += len(findings)
+		}
+		res.Profiles = append(res.Profiles, entry)
+	}
+	if res.Total > 0 {
+		return res, 2, nil
+	}
+	return res, 0, nil
+}
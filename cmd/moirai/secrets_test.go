@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"moirai/internal/secrets"
+)
+
+func TestRunSecretsScan(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	if err := os.WriteFile(filepath.Join(configDir, "oh-my-opencode.json.beta"), []byte(`{"provider":{"openai":{"apiKey":"abc123","baseURL":"{env:BASE}"}}}`), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "secrets", "scan"}, noTUI, stdout, stderr); exitCode != 2 {
+		t.Fatalf("expected exit code 2, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "beta") || !strings.Contains(out, "provider.openai.apiKey") || strings.Contains(out, "abc123") {
+		t.Fatalf("unexpected scan output %q", out)
+	}
+
+	stdout.Reset()
+	if exitCode := run([]string{"moirai", "--output", "json", "secrets", "scan", "alpha"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	var envelope struct {
+		Result secretsScanResult `json:"result"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &envelope); err != nil {
+		t.Fatalf("parse output: %v\n%s", err, stdout.String())
+	}
+	if envelope.Result.Total != 0 || len(envelope.Result.Profiles) != 1 {
+		t.Fatalf("unexpected result %+v", envelope.Result)
+	}
+}
+
+func TestRunDiffRedactsSecrets(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	if err := os.WriteFile(filepath.Join(configDir, "oh-my-opencode.json.beta"), []byte(`{"agents":{},"provider":{"apiKey":"abc123"}}`), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "--output", "json", "diff", "--between", "alpha", "beta"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("diff failed with %d: %s", exitCode, stderr.String())
+	}
+	if strings.Contains(stdout.String(), "abc123") || !strings.Contains(stdout.String(), secrets.Placeholder) {
+		t.Fatalf("expected redacted diff, got %s", stdout.String())
+	}
+
+	stdout.Reset()
+	if exitCode := run([]string{"moirai", "--output", "yaml", "diff", "--between", "alpha", "beta"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("diff failed with %d: %s", exitCode, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), secrets.Placeholder) {
+		t.Fatalf("expected the placeholder unescaped, got %s", stdout.String())
+	}
+
+	stdout.Reset()
+	if exitCode := run([]string{"moirai", "diff", "--between", "alpha", "beta", "--show-secrets"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("diff failed with %d: %s", exitCode, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "abc123") {
+		t.Fatalf("expected secret with --show-secrets, got %s", stdout.String())
+	}
+}
+
+func TestRunExportRedactsSecrets(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	if err := os.WriteFile(filepath.Join(configDir, "oh-my-opencode.json.alpha"), []byte(`{"provider":{"apiKey":"abc123"}}`), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	bundlePath := filepath.Join(t.TempDir(), "team.tar.gz")
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "export", "alpha", "-o", bundlePath}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("export failed with %d: %s", exitCode, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Redacted secrets: 1") {
+		t.Fatalf("unexpected export output %q", stdout.String())
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"moirai/internal/app"
+	"moirai/internal/profile"
+	"moirai/internal/schema"
+)
+
+const validateUsage = "moirai validate <profile>...|--all [--schema <pathOrURL>]"
+
+type validateResult struct {
+	Profiles []validateProfile `json:"profiles"`
+	Errors   int               `json:"errors"`
+}
+
+type validateProfile struct {
+	schema.Result
+	// Error is set when the profile could not be loaded at all.
+	Error string `json:"error,omitempty"`
+}
+
+func (r validateResult) writeText(w io.Writer) {
+	for _, entry := range r.Profiles {
+		switch {
+		case entry.Error != "":
+			fmt.Fprintf(w, "%s: cannot validate: %s\n", entry.Profile, entry.Error)
+		case entry.Skipped != "":
+			fmt.Fprintf(w, "%s: skipped (%s)\n", entry.Profile, entry.Skipped)
+		case entry.Valid():
+			fmt.Fprintf(w, "%s: valid (%s, %s)\n", entry.Profile, entry.Schema, entry.Origin)
+		default:
+			fmt.Fprintf(w, "%s: %d schema errors (%s, %s)\n", entry.Profile, len(entry.Errors), entry.Schema, entry.Origin)
+			for _, err := range entry.Errors {
+				fmt.Fprintf(w, " - %s\n", err)
+			}
+		}
+	}
+}
+
+func runValidate(config app.AppConfig, args []string) (validateResult, int, error) {
+	names, flagArgs := splitLeadingArgs(args)
+	validateFlags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	validateFlags.SetOutput(io.Discard)
+	all := validateFlags.Bool("all", false, "validate every profile")
+	schemaPath := validateFlags.String("schema", config.SchemaPath, "schema used instead of $schema")
+	if err := validateFlags.Parse(flagArgs); err != nil {
+		return validateResult{}, 1, err
+	}
+	names = append(names, validateFlags.Args()...)
+	if *all == (len(names) > 0) {
+		return validateResult{}, 1, usageError(validateUsage)
+	}
+	if *all {
+		profiles, err := profile.DiscoverProfiles(config.ConfigDir)
+		if err != nil {
+			return validateResult{}, 1, err
+		}
+		for _, info := range profiles {
+			names = append(names, info.Name)
+		}
+	}
+
+	validator := schema.NewValidator(config.ConfigDir, schema.Options{Path: *schemaPath})
+	res := validateResult{Profiles: []validateProfile{}}
+	failed := false
+	for _, name := range names {
+		if err := profile.ValidateName(name); err != nil {
+			return validateResult{}, 1, err
+		}
+		result, err := validator.Profile(name)
+		if err != nil {
+			if !*all {
+				return validateResult{}, 1, err
+			}
+			result = schema.Result{Profile: name, Errors: []schema.Error{}}
+			res.Profiles = append(res.Profiles, validateProfile{Result: result, Error: err.Error()})
+			failed = true
+			continue
+		}
+		res.Errors += len(result.Errors)
+		res.Profiles = append(res.Profiles, validateProfile{Result: result})
+	}
+	if res.Errors > 0 || failed {
+		return res, 2, nil
+	}
+	return res, 0, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"moirai/internal/link"
+)
+
+func TestRunValidate(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	if err := os.WriteFile(filepath.Join(configDir, "schema.json"), []byte(`{"properties":{"agents":{"additionalProperties":{"properties":{"temperature":{"type":"number","maximum":2}}}}}}`), 0o600); err != nil {
+		t.Fatalf("write schema: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "oh-my-opencode.json.gamma"), []byte(`{"$schema":"schema.json","agents":{"oracle":{"temperature":"hot"}}}`), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "validate", "--all"}, noTUI, stdout, stderr); exitCode != 2 {
+		t.Fatalf("expected exit code 2, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"alpha: skipped (no $schema)", "gamma: 1 schema errors", "/agents/oracle/temperature: expected number, got string"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+
+	stdout.Reset()
+	if exitCode := run([]string{"moirai", "--output", "json", "validate", "alpha", "--schema", "schema.json"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected exit code 0, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	var envelope struct {
+		Result validateResult `json:"result"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &envelope); err != nil {
+		t.Fatalf("parse output: %v\n%s", err, stdout.String())
+	}
+	if len(envelope.Result.Profiles) != 1 || envelope.Result.Profiles[0].Schema != "schema.json" || envelope.Result.Profiles[0].Skipped != "" {
+		t.Fatalf("unexpected result %+v", envelope.Result)
+	}
+
+	if exitCode := run([]string{"moirai", "validate"}, noTUI, stdout, stderr); exitCode != 1 {
+		t.Fatalf("expected usage error, got %d", exitCode)
+	}
+}
+
+func TestRunApplyValidatesSchema(t *testing.T) {
+	configDir := setupOutputConfig(t)
+	if err := os.WriteFile(filepath.Join(configDir, "schema.json"), []byte(`{"properties":{"google_auth":{"type":"boolean"}}}`), 0o600); err != nil {
+		t.Fatalf("write schema: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(configDir, "oh-my-opencode.json.gamma"), []byte(`{"$schema":"schema.json","agents":{},"google_auth":"yes"}`), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exitCode := run([]string{"moirai", "apply", "gamma"}, noTUI, stdout, stderr); exitCode != 1 {
+		t.Fatalf("expected apply to fail, got %d", exitCode)
+	}
+	if !strings.Contains(stderr.String(), "/google_auth: expected boolean, got string") {
+		t.Fatalf("expected pointer in error, got %q", stderr.String())
+	}
+	if active, _, _ := link.ActiveProfile(configDir); active != "beta" {
+		t.Fatalf("expected active profile unchanged, got %q", active)
+	}
+
+	if exitCode := run([]string{"moirai", "apply", "gamma", "--no-validate"}, noTUI, stdout, stderr); exitCode != 0 {
+		t.Fatalf("expected apply with --no-validate to succeed, got %d (stderr: %s)", exitCode, stderr.String())
+	}
+	if active, _, _ := link.ActiveProfile(configDir); active != "gamma" {
+		t.Fatalf("expected gamma active, got %q", active)
+	}
+}
@@ -3,24 +3,172 @@ package app
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
+// ConfigFileName is the moirai settings file inside the config dir.
+const ConfigFileName = "moirai.json"
+
+// StateDir returns the directory where moirai keeps generated state for a
+// config dir (model cache, materialized profiles).
+func StateDir(configDir string) string {
+	return filepath.Join(configDir, "moirai")
+}
+
+// BackupsDir returns the default backup store for a config dir. Backups are
+// kept in one subdirectory per profile.
+func BackupsDir(configDir string) string {
+	return filepath.Join(StateDir(configDir), "backups")
+}
+
+// DefaultLockTimeout is how long commands wait for another moirai process
+// to release the config dir lock.
+const DefaultLockTimeout = 5 * time.Second
+
 type AppConfig struct {
-	ConfigDir      string
-	EnableAutofill bool
+	ConfigDir       string
+	EnableAutofill  bool
+	BackupRetention BackupRetention
+	LockTimeout     time.Duration
+	AgentCatalog    AgentCatalog
+	Presets         map[string]PresetSpec
+	// DefaultPreset is the preset used by the TUI autofill action.
+	DefaultPreset string
+	// DirectoryProfiles maps directories to the profile `moirai auto` applies.
+	DirectoryProfiles []DirectoryProfile
+	// StrictReferences makes apply fail when an {env:} or {file:}
+	// reference in a profile cannot be resolved.
+	StrictReferences bool
+	// SchemaPath is a schema path or URL used to validate profiles instead
+	// of their $schema.
+	SchemaPath string
+	// BackupDir is the directory new backups are written to.
+	BackupDir string
+	// Git configures the git-backed profile history.
+	Git GitSettings
+}
+
+// DefaultGitBranch is the branch the profile history is kept on.
+const DefaultGitBranch = "main"
+
+// GitSettings enables a git repository, managed by moirai, that records a
+// commit for every operation that changes a profile.
+type GitSettings struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Remote is the URL `moirai sync` pulls from and pushes to.
+	Remote string `json:"remote,omitempty"`
+	// Branch defaults to DefaultGitBranch.
+	Branch string `json:"branch,omitempty"`
+}
+
+// DirectoryProfile selects a profile for directories matching Path, a glob
+// in filepath.Match syntax that may start with "~/". A pattern that matches
+// a directory also covers everything below it.
+type DirectoryProfile struct {
+	Path    string `json:"path"`
+	Profile string `json:"profile"`
+}
+
+// DefaultPresetName is the built-in preset used when none is configured.
+const DefaultPresetName = "openai"
+
+// PresetsDir returns the directory holding one <name>.json file per preset.
+func PresetsDir(configDir string) string {
+	return filepath.Join(StateDir(configDir), "presets")
+}
+
+// PresetSpec defines an autofill preset in moirai.json or the presets dir.
+type PresetSpec struct {
+	Description string `json:"description,omitempty"`
+	// Extends names a preset whose default and agent models are inherited.
+	Extends string `json:"extends,omitempty"`
+	// Default is the model for agents without an entry in Agents.
+	Default string            `json:"default,omitempty"`
+	Agents  map[string]string `json:"agents,omitempty"`
+}
+
+// AgentCatalog customizes the agents moirai knows about. Entries override
+// the metadata of built-in or schema-discovered agents with the same name
+// and add agents that are not known yet.
+type AgentCatalog struct {
+	// Replace drops the built-in agents instead of extending them.
+	Replace bool `json:"replace,omitempty"`
+	// DiscoverSchema reads agent names from the JSON schema referenced by
+	// profiles' $schema. Unset means enabled.
+	DiscoverSchema *bool                `json:"discoverSchema,omitempty"`
+	Agents         map[string]AgentSpec `json:"agents,omitempty"`
+}
+
+// AgentSpec is the metadata of one catalog agent. Unset fields keep the
+// value from the built-in or discovered entry.
+type AgentSpec struct {
+	Description string `json:"description,omitempty"`
+	Required    *bool  `json:"required,omitempty"`
+	ModelClass  string `json:"modelClass,omitempty"`
+}
+
+// SchemaDiscoveryEnabled reports whether agents are discovered from $schema.
+func (c AgentCatalog) SchemaDiscoveryEnabled() bool {
+	return c.DiscoverSchema == nil || *c.DiscoverSchema
+}
+
+func (c AgentCatalog) validate() error {
+	for name := range c.Agents {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("agentCatalog.agents: agent name must not be empty")
+		}
+	}
+	return nil
+}
+
+// BackupRetention limits the backups kept for each profile. Zero values
+// disable the corresponding rule; the newest backup is always kept.
+type BackupRetention struct {
+	KeepLast       int   `json:"keepLast,omitempty"`
+	KeepDailyDays  int   `json:"keepDailyDays,omitempty"`
+	KeepWeeklyDays int   `json:"keepWeeklyDays,omitempty"`
+	MaxTotalBytes  int64 `json:"maxTotalBytes,omitempty"`
+}
+
+// Enabled reports whether any retention rule is configured.
+func (r BackupRetention) Enabled() bool {
+	return r.KeepLast > 0 || r.KeepDailyDays > 0 || r.KeepWeeklyDays > 0 || r.MaxTotalBytes > 0
+}
+
+func (r BackupRetention) validate() error {
+	if r.KeepLast < 0 || r.KeepDailyDays < 0 || r.KeepWeeklyDays < 0 || r.MaxTotalBytes < 0 {
+		return fmt.Errorf("backupRetention values must not be negative")
+	}
+	return nil
 }
 
 type fileConfig struct {
-	EnableAutofill *bool `json:"enableAutofill"`
+	EnableAutofill    *bool                 `json:"enableAutofill"`
+	BackupRetention   *BackupRetention      `json:"backupRetention"`
+	LockTimeoutMs     *int                  `json:"lockTimeoutMs"`
+	AgentCatalog      *AgentCatalog         `json:"agentCatalog"`
+	Presets           map[string]PresetSpec `json:"presets"`
+	DefaultPreset     *string               `json:"defaultPreset"`
+	DirectoryProfiles []DirectoryProfile    `json:"directoryProfiles"`
+	StrictReferences  *bool                 `json:"strictReferences"`
+	SchemaPath        *string               `json:"schemaPath"`
+	BackupDir         *string               `json:"backupDir"`
+	Git               *GitSettings          `json:"git"`
 }
 
 func LoadConfig(configDir string, enableAutofillOverride *bool) (AppConfig, error) {
 	config := AppConfig{
-		ConfigDir: filepath.Clean(configDir),
+		ConfigDir:     filepath.Clean(configDir),
+		LockTimeout:   DefaultLockTimeout,
+		DefaultPreset: DefaultPresetName,
 	}
-	configPath := filepath.Join(config.ConfigDir, "moirai.json")
+	config.BackupDir = BackupsDir(config.ConfigDir)
+	config.Git.Branch = DefaultGitBranch
+	configPath := filepath.Join(config.ConfigDir, ConfigFileName)
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		if !errors.Is(err, os.ErrNotExist) {
@@ -34,6 +182,55 @@ func LoadConfig(configDir string, enableAutofillOverride *bool) (AppConfig, erro
 		if fileCfg.EnableAutofill != nil {
 			config.EnableAutofill = *fileCfg.EnableAutofill
 		}
+		if fileCfg.BackupRetention != nil {
+			if err := fileCfg.BackupRetention.validate(); err != nil {
+				return AppConfig{}, err
+			}
+			config.BackupRetention = *fileCfg.BackupRetention
+		}
+		if fileCfg.LockTimeoutMs != nil {
+			if *fileCfg.LockTimeoutMs < 0 {
+				return AppConfig{}, fmt.Errorf("lockTimeoutMs must not be negative")
+			}
+			config.LockTimeout = time.Duration(*fileCfg.LockTimeoutMs) * time.Millisecond
+		}
+		if fileCfg.AgentCatalog != nil {
+			if err := fileCfg.AgentCatalog.validate(); err != nil {
+				return AppConfig{}, err
+			}
+			config.AgentCatalog = *fileCfg.AgentCatalog
+		}
+		config.Presets = fileCfg.Presets
+		for _, entry := range fileCfg.DirectoryProfiles {
+			if strings.TrimSpace(entry.Path) == "" || strings.TrimSpace(entry.Profile) == "" {
+				return AppConfig{}, fmt.Errorf("directoryProfiles entries need a path and a profile")
+			}
+		}
+		config.DirectoryProfiles = fileCfg.DirectoryProfiles
+		if fileCfg.DefaultPreset != nil && *fileCfg.DefaultPreset != "" {
+			config.DefaultPreset = *fileCfg.DefaultPreset
+		}
+		if fileCfg.StrictReferences != nil {
+			config.StrictReferences = *fileCfg.StrictReferences
+		}
+		if fileCfg.SchemaPath != nil {
+			config.SchemaPath = strings.TrimSpace(*fileCfg.SchemaPath)
+		}
+		if fileCfg.BackupDir != nil && strings.TrimSpace(*fileCfg.BackupDir) != "" {
+			backupDir, err := resolveBackupDir(config.ConfigDir, strings.TrimSpace(*fileCfg.BackupDir))
+			if err != nil {
+				return AppConfig{}, fmt.Errorf("backupDir: %w", err)
+			}
+			config.BackupDir = backupDir
+		}
+		if fileCfg.Git != nil {
+			config.Git = *fileCfg.Git
+			config.Git.Remote = strings.TrimSpace(config.Git.Remote)
+			config.Git.Branch = strings.TrimSpace(config.Git.Branch)
+			if config.Git.Branch == "" {
+				config.Git.Branch = DefaultGitBranch
+			}
+		}
 	}
 
 	if enableAutofillOverride != nil {
@@ -42,3 +239,19 @@ func LoadConfig(configDir string, enableAutofillOverride *bool) (AppConfig, erro
 
 	return config, nil
 }
+
+// resolveBackupDir expands a leading "~/" and makes a relative path relative
+// to the config dir.
+func resolveBackupDir(configDir, path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, strings.TrimPrefix(strings.TrimPrefix(path, "~"), "/"))
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(configDir, path)
+	}
+	return filepath.Clean(path), nil
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLoadConfigMissingFileDefaults(t *testing.T) {
@@ -19,12 +20,40 @@ func TestLoadConfigMissingFileDefaults(t *testing.T) {
 	if config.ConfigDir != filepath.Clean(configDir) {
 		t.Fatalf("expected ConfigDir to be %q, got %q", filepath.Clean(configDir), config.ConfigDir)
 	}
+	if config.LockTimeout != DefaultLockTimeout {
+		t.Fatalf("expected default lock timeout, got %v", config.LockTimeout)
+	}
+	if config.BackupDir != BackupsDir(configDir) {
+		t.Fatalf("expected default BackupDir %q, got %q", BackupsDir(configDir), config.BackupDir)
+	}
+}
+
+func TestLoadConfigBackupDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configDir := t.TempDir()
+	configPath := filepath.Join(configDir, "moirai.json")
+	for raw, want := range map[string]string{
+		"~/backups/moirai": filepath.Join(home, "backups", "moirai"),
+		"../backups":       filepath.Join(filepath.Dir(configDir), "backups"),
+	} {
+		if err := os.WriteFile(configPath, []byte(`{"backupDir": "`+raw+`"}`), 0o600); err != nil {
+			t.Fatalf("expected to write config file, got %v", err)
+		}
+		config, err := LoadConfig(configDir, nil)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if config.BackupDir != want {
+			t.Fatalf("backupDir %q: expected %q, got %q", raw, want, config.BackupDir)
+		}
+	}
 }
 
 func TestLoadConfigValidFile(t *testing.T) {
 	configDir := t.TempDir()
 	configPath := filepath.Join(configDir, "moirai.json")
-	if err := os.WriteFile(configPath, []byte(`{"enableAutofill": true}`), 0o600); err != nil {
+	if err := os.WriteFile(configPath, []byte(`{"enableAutofill": true, "strictReferences": true, "schemaPath": "~/schemas/omo.json"}`), 0o600); err != nil {
 		t.Fatalf("expected to write config file, got %v", err)
 	}
 
@@ -35,4 +64,83 @@ func TestLoadConfigValidFile(t *testing.T) {
 	if !config.EnableAutofill {
 		t.Fatalf("expected EnableAutofill to be true")
 	}
+	if !config.StrictReferences {
+		t.Fatalf("expected StrictReferences to be true")
+	}
+	if config.SchemaPath != "~/schemas/omo.json" {
+		t.Fatalf("unexpected SchemaPath %q", config.SchemaPath)
+	}
+}
+
+func TestLoadConfigBackupRetention(t *testing.T) {
+	configDir := t.TempDir()
+	configPath := filepath.Join(configDir, "moirai.json")
+	data := []byte(`{"backupRetention": {"keepLast": 5, "keepDailyDays": 7, "maxTotalBytes": 1024}}`)
+	if err := os.WriteFile(configPath, data, 0o600); err != nil {
+		t.Fatalf("expected to write config file, got %v", err)
+	}
+
+	config, err := LoadConfig(configDir, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	expected := BackupRetention{KeepLast: 5, KeepDailyDays: 7, MaxTotalBytes: 1024}
+	if config.BackupRetention != expected {
+		t.Fatalf("expected %+v, got %+v", expected, config.BackupRetention)
+	}
+	if !config.BackupRetention.Enabled() {
+		t.Fatalf("expected retention to be enabled")
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"backupRetention": {"keepLast": -1}}`), 0o600); err != nil {
+		t.Fatalf("expected to write config file, got %v", err)
+	}
+	if _, err := LoadConfig(configDir, nil); err == nil {
+		t.Fatalf("expected error for negative retention")
+	}
+}
+
+func TestLoadConfigLockTimeout(t *testing.T) {
+	configDir := t.TempDir()
+	configPath := filepath.Join(configDir, "moirai.json")
+	if err := os.WriteFile(configPath, []byte(`{"lockTimeoutMs": 250}`), 0o600); err != nil {
+		t.Fatalf("expected to write config file, got %v", err)
+	}
+	config, err := LoadConfig(configDir, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if config.LockTimeout != 250*time.Millisecond {
+		t.Fatalf("expected 250ms, got %v", config.LockTimeout)
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"lockTimeoutMs": -1}`), 0o600); err != nil {
+		t.Fatalf("expected to write config file, got %v", err)
+	}
+	if _, err := LoadConfig(configDir, nil); err == nil {
+		t.Fatalf("expected error for negative lock timeout")
+	}
+}
+
+func TestLoadConfigGitSettings(t *testing.T) {
+	configDir := t.TempDir()
+	config, err := LoadConfig(configDir, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if config.Git.Enabled || config.Git.Branch != DefaultGitBranch {
+		t.Fatalf("unexpected default git settings %+v", config.Git)
+	}
+
+	configPath := filepath.Join(configDir, "moirai.json")
+	if err := os.WriteFile(configPath, []byte(`{"git": {"enabled": true, "remote": " git@example.com:me/profiles.git "}}`), 0o600); err != nil {
+		t.Fatalf("expected to write config file, got %v", err)
+	}
+	config, err = LoadConfig(configDir, nil)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !config.Git.Enabled || config.Git.Remote != "git@example.com:me/profiles.git" || config.Git.Branch != DefaultGitBranch {
+		t.Fatalf("unexpected git settings %+v", config.Git)
+	}
 }
@@ -0,0 +1,115 @@
+package auto
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"moirai/internal/app"
+	"moirai/internal/profile"
+	"moirai/internal/util"
+)
+
+// MarkerFileName is the per-directory file naming the profile to use.
+const MarkerFileName = ".moirai"
+
+// Source records how a directory was matched to a profile.
+type Source string
+
+const (
+	SourceMarker Source = "marker"
+	SourceConfig Source = "config"
+)
+
+// Match is the profile selected for a directory.
+type Match struct {
+	Profile string `json:"profile"`
+	Source  Source `json:"source"`
+	// Dir is the directory (dir or one of its ancestors) that matched.
+	Dir string `json:"dir"`
+	// Rule is the marker file path or the directoryProfiles pattern.
+	Rule string `json:"rule"`
+}
+
+// Resolve finds the profile for dir. Starting at dir and walking up to the
+// filesystem root, the first directory with a .moirai marker or a matching
+// directoryProfiles entry wins; markers take precedence over config entries
+// for the same directory, and config entries are tried in order.
+func Resolve(config app.AppConfig, dir string) (Match, bool, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return Match{}, false, err
+	}
+	patterns := make([]string, 0, len(config.DirectoryProfiles))
+	for _, entry := range config.DirectoryProfiles {
+		pattern, err := util.ExpandUser(entry.Path)
+		if err != nil {
+			return Match{}, false, err
+		}
+		patterns = append(patterns, filepath.Clean(pattern))
+	}
+
+	for current := abs; ; {
+		markerPath := filepath.Join(current, MarkerFileName)
+		name, ok, err := readMarker(markerPath)
+		if err != nil {
+			return Match{}, false, err
+		}
+		if ok {
+			return Match{Profile: name, Source: SourceMarker, Dir: current, Rule: markerPath}, true, nil
+		}
+		for i, pattern := range patterns {
+			matched, err := filepath.Match(pattern, current)
+			if err != nil {
+				return Match{}, false, fmt.Errorf("directoryProfiles: invalid path %q: %w", config.DirectoryProfiles[i].Path, err)
+			}
+			if matched {
+				entry := config.DirectoryProfiles[i]
+				if err := profile.ValidateName(entry.Profile); err != nil {
+					return Match{}, false, fmt.Errorf("directoryProfiles: %q: %w", entry.Path, err)
+				}
+				return Match{Profile: entry.Profile, Source: SourceConfig, Dir: current, Rule: entry.Path}, true, nil
+			}
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return Match{}, false, nil
+		}
+		current = parent
+	}
+}
+
+// readMarker returns the profile named in a marker file: the first line that
+// is neither blank nor a # comment. Markers may come from untrusted
+// checkouts, so the name must be a valid profile name.
+func readMarker(path string) (string, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) || os.IsPermission(err) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	if !info.Mode().IsRegular() {
+		return "", false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := profile.ValidateName(line); err != nil {
+			return "", false, fmt.Errorf("%s: %w", path, err)
+		}
+		return line, true, nil
+	}
+	return "", false, fmt.Errorf("%s does not name a profile", path)
+}
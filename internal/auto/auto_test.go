@@ -0,0 +1,97 @@
+package auto
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"moirai/internal/app"
+)
+
+func TestResolvePrefersNearestMarker(t *testing.T) {
+	root := t.TempDir()
+	repo := filepath.Join(root, "clients", "acme")
+	nested := filepath.Join(repo, "services", "api")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, MarkerFileName), []byte("# client profile\n\nacme\n"), 0o644); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+	config := app.AppConfig{DirectoryProfiles: []app.DirectoryProfile{
+		{Path: filepath.Join(root, "clients", "*"), Profile: "clients"},
+		{Path: root, Profile: "default"},
+	}}
+
+	match, ok, err := Resolve(config, nested)
+	if err != nil || !ok {
+		t.Fatalf("Resolve: %v %v", ok, err)
+	}
+	if match.Profile != "acme" || match.Source != SourceMarker || match.Dir != repo {
+		t.Fatalf("unexpected match %#v", match)
+	}
+
+	other := filepath.Join(root, "clients", "globex", "src")
+	if err := os.MkdirAll(other, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	match, ok, err = Resolve(config, other)
+	if err != nil || !ok || match.Profile != "clients" || match.Source != SourceConfig {
+		t.Fatalf("expected glob match, got %#v %v %v", match, ok, err)
+	}
+
+	match, ok, err = Resolve(config, root)
+	if err != nil || !ok || match.Profile != "default" {
+		t.Fatalf("expected root mapping, got %#v %v %v", match, ok, err)
+	}
+}
+
+func TestResolveWithoutMatch(t *testing.T) {
+	_, ok, err := Resolve(app.AppConfig{}, t.TempDir())
+	if err != nil || ok {
+		t.Fatalf("expected no match, got %v %v", ok, err)
+	}
+}
+
+func TestResolveRejectsEmptyMarker(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, MarkerFileName), []byte("# nothing\n"), 0o644); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+	if _, _, err := Resolve(app.AppConfig{}, dir); err == nil || !strings.Contains(err.Error(), "does not name a profile") {
+		t.Fatalf("expected empty marker error, got %v", err)
+	}
+}
+
+func TestResolveRejectsPathInProfileName(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, MarkerFileName), []byte("x/../../../repo/evil.json\n"), 0o644); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+	if _, _, err := Resolve(app.AppConfig{}, dir); err == nil || !strings.Contains(err.Error(), "path separators") {
+		t.Fatalf("expected invalid marker name error, got %v", err)
+	}
+
+	config := app.AppConfig{DirectoryProfiles: []app.DirectoryProfile{{Path: dir, Profile: "../evil"}}}
+	if _, _, err := Resolve(config, t.TempDir()); err != nil {
+		t.Fatalf("unmatched entries must not fail: %v", err)
+	}
+	other := t.TempDir()
+	config.DirectoryProfiles[0].Path = other
+	if _, _, err := Resolve(config, other); err == nil || !strings.Contains(err.Error(), "directoryProfiles") {
+		t.Fatalf("expected invalid directoryProfiles name error, got %v", err)
+	}
+}
+
+func TestHook(t *testing.T) {
+	for _, shell := range Shells() {
+		script, err := Hook(shell)
+		if err != nil || !strings.Contains(script, "moirai auto --quiet") {
+			t.Fatalf("%s: unexpected hook %q %v", shell, script, err)
+		}
+	}
+	if _, err := Hook("tcsh"); err == nil {
+		t.Fatal("expected unsupported shell error")
+	}
+}
@@ -0,0 +1,3 @@
+// Package auto picks the profile for a working directory from .moirai marker
+// files and the directoryProfiles mapping in moirai.json.
+package auto
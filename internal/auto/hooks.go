@@ -0,0 +1,54 @@
+package auto
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var hooks = map[string]string{
+	"bash": `_moirai_auto() {
+  if [ "$PWD" != "${_MOIRAI_AUTO_PWD:-}" ]; then
+    _MOIRAI_AUTO_PWD="$PWD"
+    command moirai auto --quiet
+  fi
+}
+case ";${PROMPT_COMMAND:-};" in
+  *";_moirai_auto;"*) ;;
+  *) PROMPT_COMMAND="_moirai_auto${PROMPT_COMMAND:+;$PROMPT_COMMAND}" ;;
+esac
+`,
+	"zsh": `_moirai_auto() {
+  command moirai auto --quiet
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook chpwd _moirai_auto
+_moirai_auto
+`,
+	"fish": `function _moirai_auto --on-variable PWD
+    command moirai auto --quiet
+end
+_moirai_auto
+`,
+}
+
+// Shells lists the shells Hook supports.
+func Shells() []string {
+	names := make([]string, 0, len(hooks))
+	for name := range hooks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Hook returns the snippet that runs `moirai auto --quiet` whenever the
+// shell changes directory. Add it to the shell's startup file with
+// eval "$(moirai auto hook bash)" or the shell's equivalent.
+func Hook(shell string) (string, error) {
+	script, ok := hooks[shell]
+	if !ok {
+		return "", fmt.Errorf("unsupported shell %q (want %s)", shell, strings.Join(Shells(), ", "))
+	}
+	return script, nil
+}
@@ -0,0 +1,97 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"moirai/internal/journal"
+	"moirai/internal/profile"
+)
+
+// ListActiveBackups returns the names of the active config backups taken
+// before an apply replaced a regular file, newest first. It covers the
+// backup store and backups kept in the config dir.
+func ListActiveBackups(store Store) ([]string, error) {
+	files, err := groupFiles(store, ActiveGroup)
+	if err != nil {
+		return nil, err
+	}
+	backups := make([]string, 0, len(files))
+	for _, file := range files {
+		backups = append(backups, file.Name)
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i] > backups[j]
+	})
+	return backups, nil
+}
+
+// ResolveActiveBackup returns the path of an active config backup given as
+// a path or a name.
+func ResolveActiveBackup(store Store, from string) (string, error) {
+	if from == "" {
+		return "", fmt.Errorf("backup path is required")
+	}
+	return resolveGroupBackup(store, ActiveGroup, from)
+}
+
+// RestoreActiveFromBackup writes an active config backup to the active
+// config as a regular file. A regular active config is backed up first with
+// opts, whose Reason defaults to ReasonRestore, and its backup path
+// returned; a symlink is replaced and recorded in the journal, so undo can
+// point it back at its profile.
+func RestoreActiveFromBackup(store Store, from string, opts Options) (RestoreResult, error) {
+	backupPath, err := ResolveActiveBackup(store, from)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	// Read the source before taking the pre-restore backup: retention may
+	// prune old backups as soon as a new one is written.
+	backupInfo, err := os.Stat(backupPath)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	backupData, err := os.ReadFile(backupPath)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+
+	activePath := filepath.Join(store.ConfigDir, activeFileName)
+	entry := journal.Entry{Op: journal.OpRestore, File: activeFileName}
+	info, err := os.Lstat(activePath)
+	switch {
+	case err != nil && !os.IsNotExist(err):
+		return RestoreResult{}, err
+	case err != nil:
+	case info.Mode()&os.ModeSymlink != 0:
+		if entry.PreviousActive, err = os.Readlink(activePath); err != nil {
+			return RestoreResult{}, err
+		}
+	default:
+		if entry.BeforeHash, err = journal.HashFile(activePath); err != nil {
+			return RestoreResult{}, err
+		}
+		if opts.Reason == "" {
+			opts.Reason = ReasonRestore
+		}
+		preBackup, err := BackupActiveWith(store, opts)
+		if err != nil {
+			return RestoreResult{}, err
+		}
+		entry.Backup = preBackup.Path
+	}
+
+	// Renaming the temp file over the active config replaces a symlink
+	// itself rather than the profile it points at.
+	if err := profile.SaveProfileDataAtomic(activePath, backupData, backupInfo.Mode().Perm()); err != nil {
+		return RestoreResult{}, err
+	}
+	entry.AfterHash, _ = journal.HashFile(activePath)
+	res := RestoreResult{PreBackup: entry.Backup}
+	if recorded, err := journal.Record(store.ConfigDir, entry); err == nil {
+		res.Entry = recorded
+	}
+	return res, nil
+}
@@ -0,0 +1,107 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"moirai/internal/journal"
+)
+
+func TestListAndRestoreActiveBackups(t *testing.T) {
+	dir := t.TempDir()
+	activePath := filepath.Join(dir, activeFileName)
+	legacy := activeFileName + backupMarker + "20240101-000000"
+	if err := os.WriteFile(filepath.Join(dir, legacy), []byte("legacy"), 0o600); err != nil {
+		t.Fatalf("write legacy backup: %v", err)
+	}
+	if err := os.WriteFile(activePath, []byte("hand-written"), 0o600); err != nil {
+		t.Fatalf("write active: %v", err)
+	}
+	stored, err := BackupActive(testStore(dir))
+	if err != nil {
+		t.Fatalf("BackupActive: %v", err)
+	}
+
+	backups, err := ListActiveBackups(testStore(dir))
+	if err != nil {
+		t.Fatalf("ListActiveBackups: %v", err)
+	}
+	if len(backups) != 2 || backups[0] != filepath.Base(stored) || backups[1] != legacy {
+		t.Fatalf("expected store and legacy backups newest first, got %v", backups)
+	}
+
+	res, err := RestoreActiveFromBackup(testStore(dir), legacy, Options{})
+	if err != nil {
+		t.Fatalf("RestoreActiveFromBackup: %v", err)
+	}
+	if res.PreBackup != stored {
+		t.Fatalf("expected the identical pre-restore backup %s to be reused, got %s", stored, res.PreBackup)
+	}
+	if data, _ := os.ReadFile(activePath); string(data) != "legacy" {
+		t.Fatalf("expected legacy content, got %q", data)
+	}
+	entries, _ := journal.Read(dir)
+	last := entries[len(entries)-1]
+	if last.Op != journal.OpRestore || last.File != activeFileName || last.Backup != stored {
+		t.Fatalf("unexpected journal entry: %+v", last)
+	}
+}
+
+func TestRestoreActiveReplacesSymlink(t *testing.T) {
+	dir := t.TempDir()
+	profilePath := filepath.Join(dir, profilePrefix+"alpha")
+	if err := os.WriteFile(profilePath, []byte("profile"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	activePath := filepath.Join(dir, activeFileName)
+	if err := os.WriteFile(activePath, []byte("hand-written"), 0o600); err != nil {
+		t.Fatalf("write active: %v", err)
+	}
+	stored, err := BackupActive(testStore(dir))
+	if err != nil {
+		t.Fatalf("BackupActive: %v", err)
+	}
+	if err := os.Remove(activePath); err != nil {
+		t.Fatalf("remove active: %v", err)
+	}
+	if err := os.Symlink(profilePrefix+"alpha", activePath); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+
+	res, err := RestoreActiveFromBackup(testStore(dir), filepath.Base(stored), Options{})
+	if err != nil {
+		t.Fatalf("RestoreActiveFromBackup: %v", err)
+	}
+	if res.PreBackup != "" {
+		t.Fatalf("expected no pre-restore backup for a symlink, got %s", res.PreBackup)
+	}
+	info, err := os.Lstat(activePath)
+	if err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Fatalf("expected a regular active config, got %v %v", info, err)
+	}
+	if data, _ := os.ReadFile(profilePath); string(data) != "profile" {
+		t.Fatalf("expected the linked profile to be left alone, got %q", data)
+	}
+	entries, _ := journal.Read(dir)
+	if last := entries[len(entries)-1]; last.PreviousActive != profilePrefix+"alpha" || last.Active != "" {
+		t.Fatalf("expected the replaced symlink to be journaled, got %+v", last)
+	}
+}
+
+func TestRestoreActiveRejectsProfileBackup(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, profilePrefix+"alpha"), []byte("profile"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	profileBackup, err := BackupProfile(testStore(dir), "alpha")
+	if err != nil {
+		t.Fatalf("BackupProfile: %v", err)
+	}
+	if _, err := RestoreActiveFromBackup(testStore(dir), profileBackup, Options{}); err == nil {
+		t.Fatal("expected a profile backup to be rejected")
+	}
+	if _, err := os.Lstat(filepath.Join(dir, activeFileName)); !os.IsNotExist(err) {
+		t.Fatalf("expected no active config to be written, got %v", err)
+	}
+}
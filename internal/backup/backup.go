@@ -1,13 +1,14 @@
 package backup
 
 import (
-	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
+	"moirai/internal/journal"
 	"moirai/internal/profile"
 	"moirai/internal/util"
 )
@@ -18,109 +19,113 @@ const backupMarker = ".bak."
 
 var timestamp = util.Timestamp
 
-func uniqueBackupPath(dir, baseName string) (string, error) {
-	tryPath := func(name string) (string, bool, error) {
-		path := filepath.Join(dir, name)
-		if _, err := os.Stat(path); err != nil {
-			if errors.Is(err, os.ErrNotExist) {
-				return path, true, nil
-			}
-			return "", false, err
-		}
-		return path, false, nil
-	}
-
-	path, ok, err := tryPath(baseName)
-	if err != nil {
-		return "", err
-	}
-	if ok {
-		return path, nil
-	}
-
-	for i := 1; ; i++ {
-		path, ok, err := tryPath(fmt.Sprintf("%s-%d", baseName, i))
-		if err != nil {
-			return "", err
-		}
-		if ok {
-			return path, nil
-		}
-	}
+// BackupActive creates a backup of the active config file before an apply
+// replaces it.
+func BackupActive(store Store) (string, error) {
+	entry, err := BackupActiveWith(store, Options{Reason: ReasonApply})
+	return entry.Path, err
 }
 
-// BackupActive creates a backup of the active config file in dir.
-func BackupActive(dir string) (string, error) {
-	backupName := activeFileName + backupMarker + timestamp()
-	backupPath, err := uniqueBackupPath(dir, backupName)
-	if err != nil {
-		return "", err
-	}
-	activePath := filepath.Join(dir, activeFileName)
+// BackupActiveWith creates a backup of the active config file and records
+// opts in the backup index. When the newest active backup already holds the
+// same content, that backup is returned instead.
+func BackupActiveWith(store Store, opts Options) (Entry, error) {
+	activePath := filepath.Join(store.ConfigDir, activeFileName)
+	return writeBackup(store, activePath, ActiveGroup, opts)
+}
 
-	if err := util.CopyFileAtomic(activePath, backupPath); err != nil {
-		return "", err
-	}
-	return backupPath, nil
+// BackupProfile creates a manual backup of the named profile.
+func BackupProfile(store Store, profileName string) (string, error) {
+	entry, err := BackupProfileWith(store, profileName, Options{Reason: ReasonManual})
+	return entry.Path, err
 }
 
-// BackupProfile creates a backup of the named profile in dir.
-func BackupProfile(dir, profileName string) (string, error) {
-	if profileName == "" {
-		return "", fmt.Errorf("profile name is required")
+// BackupProfileWith creates a backup of the named profile and records opts
+// in the backup index. When the newest backup of the profile already holds
+// the same content, that backup is returned instead.
+func BackupProfileWith(store Store, profileName string, opts Options) (Entry, error) {
+	if err := profile.ValidateName(profileName); err != nil {
+		return Entry{}, err
 	}
 	profileFile := profilePrefix + profileName
-	profilePath := filepath.Join(dir, profileFile)
+	profilePath := filepath.Join(store.ConfigDir, profileFile)
 	if _, err := os.Stat(profilePath); err != nil {
-		return "", err
+		return Entry{}, err
 	}
+	return writeBackup(store, profilePath, profileName, opts)
+}
 
-	backupName := profileFile + backupMarker + timestamp()
-	backupPath, err := uniqueBackupPath(dir, backupName)
+// ListProfileBackups returns the names of the profile backups, newest
+// first. It covers the backup store and backups kept in the config dir;
+// Locate turns a name into a path.
+func ListProfileBackups(store Store, profileName string) ([]string, error) {
+	if err := profile.ValidateName(profileName); err != nil {
+		return nil, err
+	}
+	files, err := groupFiles(store, profileName)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	if err := util.CopyFileAtomic(profilePath, backupPath); err != nil {
-		return "", err
+	backups := make([]string, 0, len(files))
+	for _, file := range files {
+		backups = append(backups, file.Name)
 	}
-	return backupPath, nil
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i] > backups[j]
+	})
+	return backups, nil
 }
 
-// ListProfileBackups returns the profile backups in dir, newest first.
-func ListProfileBackups(dir, profileName string) ([]string, error) {
-	if profileName == "" {
-		return nil, fmt.Errorf("profile name is required")
+// RenameProfileBackups moves the backups of oldName so they belong to newName.
+// Backups in the store move to the store directory of newName; backups kept
+// in the config dir are renamed in place. It returns the new backup paths.
+func RenameProfileBackups(store Store, oldName, newName string) ([]string, error) {
+	if err := profile.ValidateName(oldName); err != nil {
+		return nil, err
+	}
+	if err := profile.ValidateName(newName); err != nil {
+		return nil, err
 	}
-	entries, err := util.ListDir(dir)
+	files, err := groupFiles(store, oldName)
 	if err != nil {
 		return nil, err
 	}
-
-	prefix := profilePrefix + profileName + backupMarker
-	backups := make([]string, 0)
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
+	dir := store.ConfigDir
+	oldPrefix := groupPrefix(oldName)
+	newPrefix := groupPrefix(newName)
+	oldStore := groupDir(store.Dir, oldName)
+	newStore := groupDir(store.Dir, newName)
+	renamed := make([]string, 0, len(files))
+	names := make(map[string]string, len(files))
+	// Index entries follow the files even when a rename fails halfway.
+	defer func() { _ = renameIndexed(store, names) }()
+	for _, file := range files {
+		targetDir := dir
+		if filepath.Dir(file.Path) == oldStore {
+			targetDir = newStore
+			if err := os.MkdirAll(targetDir, 0o700); err != nil {
+				return renamed, err
+			}
 		}
-		name := entry.Name()
-		if !strings.HasPrefix(name, prefix) {
-			continue
+		name, err := uniqueBackupName([]string{newStore, dir}, newPrefix+strings.TrimPrefix(file.Name, oldPrefix))
+		if err != nil {
+			return renamed, err
 		}
-		if len(name) == len(prefix) {
-			continue
+		target := filepath.Join(targetDir, name)
+		if err := os.Rename(file.Path, target); err != nil {
+			return renamed, err
 		}
-		backups = append(backups, name)
+		renamed = append(renamed, target)
+		names[file.Name] = name
 	}
-
-	sort.Slice(backups, func(i, j int) bool {
-		return backups[i] > backups[j]
-	})
-	return backups, nil
+	// The old store directory is empty now unless it held unrelated files.
+	_ = os.Remove(oldStore)
+	return renamed, nil
 }
 
 // LatestProfileBackup returns the newest backup name for a profile.
-func LatestProfileBackup(dir, profileName string) (string, bool, error) {
-	backups, err := ListProfileBackups(dir, profileName)
+func LatestProfileBackup(store Store, profileName string) (string, bool, error) {
+	backups, err := ListProfileBackups(store, profileName)
 	if err != nil {
 		return "", false, err
 	}
@@ -130,92 +135,147 @@ func LatestProfileBackup(dir, profileName string) (string, bool, error) {
 	return backups[0], true, nil
 }
 
-// RestoreProfileFromBackup restores the profile file from a backup in dir.
-func RestoreProfileFromBackup(dir, profileName string, from string) (string, error) {
-	if profileName == "" {
-		return "", fmt.Errorf("profile name is required")
+// BackupTime reports the creation time encoded in a backup file name.
+func BackupTime(name string) (time.Time, bool) {
+	idx := strings.LastIndex(name, backupMarker)
+	if idx < 0 {
+		return time.Time{}, false
+	}
+	stamp := name[idx+len(backupMarker):]
+	if len(stamp) > len(util.TimestampLayout) {
+		stamp = stamp[:len(util.TimestampLayout)]
+	}
+	parsed, err := util.ParseTimestamp(stamp)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return parsed, true
+}
+
+// RestoreResult reports what a restore changed.
+type RestoreResult struct {
+	// PreBackup is the backup of the content the restore replaced; it is
+	// empty when a symlinked active config was replaced.
+	PreBackup string
+	// Entry is the journal entry recorded for the restore; it is zero when
+	// the restore could not be journaled.
+	Entry journal.Entry
+}
+
+// RestoreProfileFromBackup restores the profile file from one of its
+// backups. The current content is backed up first with opts, whose Reason
+// defaults to ReasonRestore.
+func RestoreProfileFromBackup(store Store, profileName string, from string, opts Options) (RestoreResult, error) {
+	if err := profile.ValidateName(profileName); err != nil {
+		return RestoreResult{}, err
 	}
 	if from == "" {
-		return "", fmt.Errorf("backup path is required")
+		return RestoreResult{}, fmt.Errorf("backup path is required")
 	}
 
 	profileFile := profilePrefix + profileName
-	profilePath := filepath.Join(dir, profileFile)
+	profilePath := filepath.Join(store.ConfigDir, profileFile)
 	if _, err := os.Stat(profilePath); err != nil {
-		return "", err
+		return RestoreResult{}, err
 	}
 
-	backupPath, err := resolveBackupPath(dir, from)
+	backupPath, err := resolveGroupBackup(store, profileName, from)
 	if err != nil {
-		return "", err
+		return RestoreResult{}, err
 	}
 
-	inDir, err := isInConfigDir(backupPath, dir)
+	// Read the source before taking the pre-restore backup: retention may
+	// prune old backups as soon as a new one is written.
+	backupInfo, err := os.Stat(backupPath)
 	if err != nil {
-		return "", err
+		return RestoreResult{}, err
 	}
-	if !inDir {
-		return "", fmt.Errorf("backup must be in config dir")
+	backupData, err := os.ReadFile(backupPath)
+	if err != nil {
+		return RestoreResult{}, err
 	}
 
-	base := filepath.Base(backupPath)
-	prefix := profileFile + backupMarker
-	if !strings.HasPrefix(base, prefix) || len(base) == len(prefix) {
-		return "", fmt.Errorf("backup does not match profile")
+	beforeHash, err := journal.HashFile(profilePath)
+	if err != nil {
+		return RestoreResult{}, err
 	}
-
-	preBackupPath, err := BackupProfile(dir, profileName)
+	if opts.Reason == "" {
+		opts.Reason = ReasonRestore
+	}
+	preBackup, err := BackupProfileWith(store, profileName, opts)
 	if err != nil {
-		return "", err
+		return RestoreResult{}, err
 	}
+	preBackupPath := preBackup.Path
+	if err := profile.SaveProfileDataAtomic(profilePath, backupData, backupInfo.Mode().Perm()); err != nil {
+		return RestoreResult{}, err
+	}
+	afterHash, _ := journal.HashFile(profilePath)
+	res := RestoreResult{PreBackup: preBackupPath}
+	// The restore already happened; failing to journal it only loses the
+	// ability to undo it.
+	if recorded, err := journal.Record(store.ConfigDir, journal.Entry{
+		Op:         journal.OpRestore,
+		Profile:    profileName,
+		File:       profileFile,
+		BeforeHash: beforeHash,
+		AfterHash:  afterHash,
+		Backup:     preBackupPath,
+	}); err == nil {
+		res.Entry = recorded
+	}
+	return res, nil
+}
 
-	backupInfo, err := os.Stat(backupPath)
+// resolveGroupBackup resolves from like resolveBackupPath and checks that
+// the backup belongs to group and sits in its store dir or the config dir.
+func resolveGroupBackup(store Store, group, from string) (string, error) {
+	backupPath, err := resolveBackupPath(store, from)
 	if err != nil {
 		return "", err
 	}
-	backupData, err := os.ReadFile(backupPath)
+
+	inDir, err := isInDir(backupPath, store.ConfigDir)
 	if err != nil {
 		return "", err
 	}
-	if err := profile.SaveProfileDataAtomic(profilePath, backupData, backupInfo.Mode().Perm()); err != nil {
-		return "", err
+	if !inDir {
+		if inDir, err = isInDir(backupPath, groupDir(store.Dir, group)); err != nil {
+			return "", err
+		}
+	}
+	if !inDir {
+		return "", fmt.Errorf("backup must be in the backup store or config dir")
 	}
 
-	return preBackupPath, nil
+	base := filepath.Base(backupPath)
+	prefix := groupPrefix(group)
+	if !strings.HasPrefix(base, prefix) || len(base) == len(prefix) {
+		if group == ActiveGroup {
+			return "", fmt.Errorf("backup is not a backup of the active config")
+		}
+		return "", fmt.Errorf("backup does not match profile")
+	}
+	return backupPath, nil
 }
 
-func resolveBackupPath(dir, from string) (string, error) {
+// resolveBackupPath accepts a path to a backup or the name of one in the
+// backup store or config dir.
+func resolveBackupPath(store Store, from string) (string, error) {
 	if _, err := os.Stat(from); err == nil {
 		return from, nil
 	} else if !os.IsNotExist(err) {
 		return "", err
 	}
+	if filepath.Base(from) == from {
+		if path, err := Locate(store, from); err == nil {
+			return path, nil
+		}
+	}
 
-	candidate := filepath.Join(dir, from)
+	candidate := filepath.Join(store.ConfigDir, from)
 	if _, err := os.Stat(candidate); err != nil {
 		return "", err
 	}
 	return candidate, nil
 }
-
-func isInConfigDir(path, dir string) (bool, error) {
-	pathAbs, err := filepath.Abs(path)
-	if err != nil {
-		return false, err
-	}
-	dirAbs, err := filepath.Abs(dir)
-	if err != nil {
-		return false, err
-	}
-
-	pathResolved := pathAbs
-	if resolved, err := filepath.EvalSymlinks(pathAbs); err == nil {
-		pathResolved = resolved
-	}
-	dirResolved := dirAbs
-	if resolved, err := filepath.EvalSymlinks(dirAbs); err == nil {
-		dirResolved = resolved
-	}
-
-	return filepath.Dir(pathResolved) == dirResolved, nil
-}
@@ -16,7 +16,7 @@ func TestBackupProfileCreatesBackup(t *testing.T) {
 		t.Fatalf("write profile: %v", err)
 	}
 
-	backupPath, err := BackupProfile(dir, profileName)
+	backupPath, err := BackupProfile(testStore(dir), profileName)
 	if err != nil {
 		t.Fatalf("BackupProfile: %v", err)
 	}
@@ -42,7 +42,7 @@ func TestBackupProfileCopiesContent(t *testing.T) {
 		t.Fatalf("write profile: %v", err)
 	}
 
-	backupPath, err := BackupProfile(dir, profileName)
+	backupPath, err := BackupProfile(testStore(dir), profileName)
 	if err != nil {
 		t.Fatalf("BackupProfile: %v", err)
 	}
@@ -70,7 +70,7 @@ func TestBackupProfileAvoidsOverwriteOnTimestampCollision(t *testing.T) {
 		t.Fatalf("write profile: %v", err)
 	}
 
-	firstBackup, err := BackupProfile(dir, profileName)
+	firstBackup, err := BackupProfile(testStore(dir), profileName)
 	if err != nil {
 		t.Fatalf("BackupProfile(1): %v", err)
 	}
@@ -79,7 +79,7 @@ func TestBackupProfileAvoidsOverwriteOnTimestampCollision(t *testing.T) {
 		t.Fatalf("write profile: %v", err)
 	}
 
-	secondBackup, err := BackupProfile(dir, profileName)
+	secondBackup, err := BackupProfile(testStore(dir), profileName)
 	if err != nil {
 		t.Fatalf("BackupProfile(2): %v", err)
 	}
@@ -120,7 +120,7 @@ func TestListProfileBackupsNewestFirst(t *testing.T) {
 		}
 	}
 
-	listed, err := ListProfileBackups(dir, profileName)
+	listed, err := ListProfileBackups(testStore(dir), profileName)
 	if err != nil {
 		t.Fatalf("ListProfileBackups: %v", err)
 	}
@@ -156,7 +156,7 @@ func TestListProfileBackupsIgnoresOtherProfiles(t *testing.T) {
 		}
 	}
 
-	listed, err := ListProfileBackups(dir, profileName)
+	listed, err := ListProfileBackups(testStore(dir), profileName)
 	if err != nil {
 		t.Fatalf("ListProfileBackups: %v", err)
 	}
@@ -185,7 +185,7 @@ func TestLatestProfileBackupSelectsNewest(t *testing.T) {
 		}
 	}
 
-	got, ok, err := LatestProfileBackup(dir, profileName)
+	got, ok, err := LatestProfileBackup(testStore(dir), profileName)
 	if err != nil {
 		t.Fatalf("LatestProfileBackup: %v", err)
 	}
@@ -198,13 +198,13 @@ func TestLatestProfileBackupSelectsNewest(t *testing.T) {
 }
 
 func TestBackupProfileRequiresName(t *testing.T) {
-	if _, err := BackupProfile(t.TempDir(), ""); err == nil {
+	if _, err := BackupProfile(testStore(t.TempDir()), ""); err == nil {
 		t.Fatal("expected error for empty profile name")
 	}
 }
 
 func TestListProfileBackupsRequiresName(t *testing.T) {
-	if _, err := ListProfileBackups(t.TempDir(), ""); err == nil {
+	if _, err := ListProfileBackups(testStore(t.TempDir()), ""); err == nil {
 		t.Fatal("expected error for empty profile name")
 	}
 }
@@ -218,7 +218,7 @@ func TestBackupActiveCopiesContent(t *testing.T) {
 		t.Fatalf("write active: %v", err)
 	}
 
-	backupPath, err := BackupActive(dir)
+	backupPath, err := BackupActive(testStore(dir))
 	if err != nil {
 		t.Fatalf("BackupActive: %v", err)
 	}
@@ -250,7 +250,7 @@ func TestRestoreProfileFromBackupWritesContent(t *testing.T) {
 		t.Fatalf("write backup: %v", err)
 	}
 
-	if _, err := RestoreProfileFromBackup(dir, profileName, backupName); err != nil {
+	if _, err := RestoreProfileFromBackup(testStore(dir), profileName, backupName, Options{}); err != nil {
 		t.Fatalf("RestoreProfileFromBackup: %v", err)
 	}
 
@@ -290,18 +290,18 @@ func TestRestoreProfileFromBackupCreatesPreBackup(t *testing.T) {
 		t.Fatalf("write backup: %v", err)
 	}
 
-	preBackupPath, err := RestoreProfileFromBackup(dir, profileName, backupName)
+	res, err := RestoreProfileFromBackup(testStore(dir), profileName, backupName, Options{})
 	if err != nil {
 		t.Fatalf("RestoreProfileFromBackup: %v", err)
 	}
 
-	base := filepath.Base(preBackupPath)
+	base := filepath.Base(res.PreBackup)
 	expectedPrefix := profilePrefix + profileName + backupMarker
 	if !strings.HasPrefix(base, expectedPrefix) {
 		t.Fatalf("expected prefix %s in %s", expectedPrefix, base)
 	}
 
-	preBackupContent, err := os.ReadFile(preBackupPath)
+	preBackupContent, err := os.ReadFile(res.PreBackup)
 	if err != nil {
 		t.Fatalf("read pre-backup: %v", err)
 	}
@@ -324,7 +324,7 @@ func TestRestoreProfileFromBackupRejectsWrongPrefix(t *testing.T) {
 		t.Fatalf("write backup: %v", err)
 	}
 
-	if _, err := RestoreProfileFromBackup(dir, profileName, backupName); err == nil {
+	if _, err := RestoreProfileFromBackup(testStore(dir), profileName, backupName, Options{}); err == nil {
 		t.Fatal("expected error for mismatched backup prefix")
 	}
 }
@@ -338,7 +338,7 @@ func TestRestoreProfileFromBackupRequiresFrom(t *testing.T) {
 		t.Fatalf("write profile: %v", err)
 	}
 
-	if _, err := RestoreProfileFromBackup(dir, profileName, ""); err == nil {
+	if _, err := RestoreProfileFromBackup(testStore(dir), profileName, "", Options{}); err == nil {
 		t.Fatal("expected error for empty backup path")
 	}
 }
@@ -352,7 +352,7 @@ func TestRestoreProfileFromBackupRejectsMissingBackup(t *testing.T) {
 		t.Fatalf("write profile: %v", err)
 	}
 
-	if _, err := RestoreProfileFromBackup(dir, profileName, "missing.bak"); err == nil {
+	if _, err := RestoreProfileFromBackup(testStore(dir), profileName, "missing.bak", Options{}); err == nil {
 		t.Fatal("expected error for missing backup")
 	}
 }
@@ -373,7 +373,7 @@ func TestRestoreProfileFromBackupRejectsBackupOutsideConfigDir(t *testing.T) {
 		t.Fatalf("write backup: %v", err)
 	}
 
-	if _, err := RestoreProfileFromBackup(dir, profileName, backupPath); err == nil {
+	if _, err := RestoreProfileFromBackup(testStore(dir), profileName, backupPath, Options{}); err == nil {
 		t.Fatal("expected error for backup outside config dir")
 	}
 }
@@ -395,7 +395,7 @@ func TestRestoreProfileFromBackupWithAbsolutePath(t *testing.T) {
 		t.Fatalf("write backup: %v", err)
 	}
 
-	if _, err := RestoreProfileFromBackup(dir, profileName, backupPath); err != nil {
+	if _, err := RestoreProfileFromBackup(testStore(dir), profileName, backupPath, Options{}); err != nil {
 		t.Fatalf("RestoreProfileFromBackup: %v", err)
 	}
 
@@ -421,7 +421,7 @@ func TestRestoreProfileFromBackupAvoidsOverwritingSourceBackupOnCollision(t *tes
 		t.Fatalf("write profile: %v", err)
 	}
 
-	backupPath, err := BackupProfile(dir, profileName)
+	backupPath, err := BackupProfile(testStore(dir), profileName)
 	if err != nil {
 		t.Fatalf("BackupProfile: %v", err)
 	}
@@ -430,15 +430,15 @@ func TestRestoreProfileFromBackupAvoidsOverwritingSourceBackupOnCollision(t *tes
 		t.Fatalf("write profile: %v", err)
 	}
 
-	preBackupPath, err := RestoreProfileFromBackup(dir, profileName, backupPath)
+	res, err := RestoreProfileFromBackup(testStore(dir), profileName, backupPath, Options{})
 	if err != nil {
 		t.Fatalf("RestoreProfileFromBackup: %v", err)
 	}
-	if preBackupPath == backupPath {
+	if res.PreBackup == backupPath {
 		t.Fatalf("expected pre-backup path to differ from source backup path")
 	}
 
-	preBackupContent, err := os.ReadFile(preBackupPath)
+	preBackupContent, err := os.ReadFile(res.PreBackup)
 	if err != nil {
 		t.Fatalf("read pre-backup: %v", err)
 	}
@@ -0,0 +1,299 @@
+package backup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"moirai/internal/app"
+	"moirai/internal/journal"
+	"moirai/internal/profile"
+	"moirai/internal/util"
+)
+
+// IndexFileName is the name of the backup metadata index in the state dir.
+const IndexFileName = "backups.json"
+
+// Reasons recorded for backups.
+const (
+	ReasonManual   = "manual"
+	ReasonApply    = "apply"
+	ReasonSave     = "save"
+	ReasonAutofill = "autofill"
+	ReasonRestore  = "restore"
+	ReasonDelete   = "delete"
+	ReasonImport   = "import"
+	ReasonDoctor   = "doctor"
+	ReasonUndo     = "undo"
+	ReasonAdopt    = "adopt"
+)
+
+// Options describe why a backup is taken.
+type Options struct {
+	// Reason names the operation the backup precedes; it defaults to
+	// ReasonManual.
+	Reason string
+	// Note is an optional free-form comment.
+	Note string
+	// Retention is applied to the backups of the same profile once the new
+	// backup is written; the zero value keeps every backup.
+	Retention app.BackupRetention
+}
+
+// Meta is the metadata recorded for one backup file.
+type Meta struct {
+	Reason    string    `json:"reason,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	SHA256    string    `json:"sha256"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Entry is a backup file together with its metadata.
+type Entry struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Meta
+	// Reused is set when the content matched the newest backup, which was
+	// returned instead of writing an identical file.
+	Reused bool `json:"reused,omitempty"`
+}
+
+type index struct {
+	Backups map[string]Meta `json:"backups"`
+}
+
+// IndexPath returns the path of the backup metadata index for configDir.
+func IndexPath(configDir string) string {
+	return filepath.Join(app.StateDir(configDir), IndexFileName)
+}
+
+// Describe returns the metadata of the named backups in store. Backups
+// written before the index existed, or whose entry was lost, get their hash
+// computed from the file and carry no reason.
+func Describe(store Store, names []string) ([]Entry, error) {
+	idx, err := readIndex(store.ConfigDir)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(names))
+	for _, name := range names {
+		path, err := Locate(store, name)
+		if err != nil {
+			return nil, err
+		}
+		meta, ok := idx.Backups[name]
+		if !ok {
+			if meta.SHA256, err = journal.HashFile(path); err != nil {
+				return nil, err
+			}
+			if created, ok := BackupTime(name); ok {
+				meta.CreatedAt = created
+			}
+		}
+		entries = append(entries, Entry{Name: name, Path: path, Meta: meta})
+	}
+	return entries, nil
+}
+
+// writeBackup copies source to a new backup of group in the backup store,
+// unless the newest backup of the group already holds the same content, and
+// records the backup in the index. When pruning by opts.Retention fails, the
+// new backup is kept and returned together with the error.
+func writeBackup(store Store, source, group string, opts Options) (Entry, error) {
+	if opts.Reason == "" {
+		opts.Reason = ReasonManual
+	}
+	sum, err := journal.HashFile(source)
+	if err != nil {
+		return Entry{}, err
+	}
+	idx, err := readIndex(store.ConfigDir)
+	if err != nil {
+		// A damaged index must not block backups; it is rewritten from here on.
+		idx = index{Backups: make(map[string]Meta)}
+	}
+
+	latest, err := latestBackup(store, group)
+	if err != nil {
+		return Entry{}, err
+	}
+	if latest.Name != "" {
+		latestPath := latest.Path
+		meta, ok := idx.Backups[latest.Name]
+		if !ok {
+			if meta.SHA256, err = journal.HashFile(latestPath); err != nil {
+				return Entry{}, err
+			}
+		}
+		if meta.SHA256 == sum {
+			if opts.Note != "" && opts.Note != meta.Note {
+				if !ok {
+					if created, found := BackupTime(latest.Name); found {
+						meta.CreatedAt = created
+					}
+				}
+				meta.Note = opts.Note
+				idx.Backups[latest.Name] = meta
+				if err := writeIndex(store, idx); err != nil {
+					return Entry{}, err
+				}
+			}
+			return Entry{Name: latest.Name, Path: latestPath, Meta: meta, Reused: true}, nil
+		}
+	}
+
+	target := groupDir(store.Dir, group)
+	if err := os.MkdirAll(target, 0o700); err != nil {
+		return Entry{}, err
+	}
+	name, err := uniqueBackupName([]string{target, store.ConfigDir}, groupPrefix(group)+timestamp())
+	if err != nil {
+		return Entry{}, err
+	}
+	backupPath := filepath.Join(target, name)
+	if err := util.CopyFileAtomic(source, backupPath); err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{
+		Name: name,
+		Path: backupPath,
+		Meta: Meta{
+			Reason:    opts.Reason,
+			Version:   app.Version,
+			SHA256:    sum,
+			Note:      opts.Note,
+			CreatedAt: now().UTC().Truncate(time.Second),
+		},
+	}
+	idx.Backups[entry.Name] = entry.Meta
+	// The backup itself is in place; a failure to index it only loses
+	// metadata that Describe partly recomputes.
+	_ = writeIndex(store, idx)
+	if err := enforceRetention(store, group, opts.Retention); err != nil {
+		return entry, fmt.Errorf("prune old backups: %w", err)
+	}
+	return entry, nil
+}
+
+// AddProfileBackup stores data as the backup of profileName taken at stamp,
+// as when backups are imported from a bundle. An existing backup of that
+// name in either layout is kept; written reports whether data was stored.
+func AddProfileBackup(store Store, profileName, stamp string, data []byte) (path string, written bool, err error) {
+	if err := profile.ValidateName(profileName); err != nil {
+		return "", false, err
+	}
+	name := groupPrefix(profileName) + stamp
+	if _, ok := BackupTime(name); !ok || strings.ContainsAny(stamp, `/\`) {
+		return "", false, fmt.Errorf("invalid backup %q", stamp)
+	}
+	if existing, err := locate(store, profileName, name); err == nil {
+		return existing, false, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", false, err
+	}
+	target := groupDir(store.Dir, profileName)
+	if err := os.MkdirAll(target, 0o700); err != nil {
+		return "", false, err
+	}
+	path = filepath.Join(target, name)
+	if err := profile.SaveProfileDataAtomic(path, data, 0o600); err != nil {
+		return "", false, err
+	}
+	idx, err := readIndex(store.ConfigDir)
+	if err != nil {
+		idx = index{Backups: make(map[string]Meta)}
+	}
+	sum, err := journal.HashFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	meta := Meta{Reason: ReasonImport, Version: app.Version, SHA256: sum}
+	if created, ok := BackupTime(name); ok {
+		meta.CreatedAt = created.UTC()
+	}
+	idx.Backups[name] = meta
+	_ = writeIndex(store, idx)
+	return path, true, nil
+}
+
+// renameIndexed moves index entries to new backup names.
+func renameIndexed(store Store, renames map[string]string) error {
+	if len(renames) == 0 {
+		return nil
+	}
+	idx, err := readIndex(store.ConfigDir)
+	if err != nil {
+		return err
+	}
+	for oldName, newName := range renames {
+		if meta, ok := idx.Backups[oldName]; ok {
+			delete(idx.Backups, oldName)
+			idx.Backups[newName] = meta
+		}
+	}
+	return writeIndex(store, idx)
+}
+
+// latestBackup returns the newest backup of group, or a zero BackupFile when
+// there is none.
+func latestBackup(store Store, group string) (BackupFile, error) {
+	files, err := groupFiles(store, group)
+	if err != nil {
+		return BackupFile{}, err
+	}
+	var latest BackupFile
+	for _, file := range files {
+		if file.Name > latest.Name {
+			latest = file
+		}
+	}
+	return latest, nil
+}
+
+func readIndex(dir string) (index, error) {
+	idx := index{Backups: make(map[string]Meta)}
+	data, err := os.ReadFile(IndexPath(dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return idx, nil
+		}
+		return index{}, err
+	}
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return index{}, fmt.Errorf("parse backup index: %w", err)
+	}
+	if idx.Backups == nil {
+		idx.Backups = make(map[string]Meta)
+	}
+	return idx, nil
+}
+
+// writeIndex saves idx, dropping entries whose backup file no longer exists
+// so that pruned backups do not accumulate in the index.
+func writeIndex(store Store, idx index) error {
+	for name := range idx.Backups {
+		group, ok := groupOf(name)
+		if !ok {
+			delete(idx.Backups, name)
+			continue
+		}
+		if _, err := locate(store, group, name); errors.Is(err, os.ErrNotExist) {
+			delete(idx.Backups, name)
+		}
+	}
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	path := IndexPath(store.ConfigDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return profile.SaveProfileDataAtomic(path, data, 0o600)
+}
@@ -0,0 +1,148 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"moirai/internal/app"
+	"moirai/internal/journal"
+)
+
+func TestBackupProfileWithRecordsMetadata(t *testing.T) {
+	dir := t.TempDir()
+	profileFile := filepath.Join(dir, profilePrefix+"alpha")
+	if err := os.WriteFile(profileFile, []byte("data"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+
+	entry, err := BackupProfileWith(testStore(dir), "alpha", Options{Reason: ReasonAutofill, Note: "before experiment"})
+	if err != nil {
+		t.Fatalf("BackupProfileWith: %v", err)
+	}
+	wantHash, _ := journal.HashFile(profileFile)
+	if entry.Reason != ReasonAutofill || entry.Note != "before experiment" || entry.SHA256 != wantHash || entry.Version != app.Version || entry.Reused {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+
+	described, err := Describe(testStore(dir), []string{entry.Name})
+	if err != nil {
+		t.Fatalf("Describe: %v", err)
+	}
+	if len(described) != 1 || described[0].Meta != entry.Meta {
+		t.Fatalf("expected indexed metadata %+v, got %+v", entry.Meta, described)
+	}
+	if info, err := os.Stat(IndexPath(dir)); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected private index, got %v %v", info, err)
+	}
+}
+
+func TestBackupProfileDefaultsToManualReason(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, profilePrefix+"alpha"), []byte("data"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	path, err := BackupProfile(testStore(dir), "alpha")
+	if err != nil {
+		t.Fatalf("BackupProfile: %v", err)
+	}
+	described, err := Describe(testStore(dir), []string{filepath.Base(path)})
+	if err != nil {
+		t.Fatalf("Describe: %v", err)
+	}
+	if described[0].Reason != ReasonManual {
+		t.Fatalf("expected manual reason, got %+v", described[0])
+	}
+}
+
+func TestBackupProfileWithReusesIdenticalBackup(t *testing.T) {
+	oldTimestamp := timestamp
+	stamps := []string{"20240101-000000", "20240102-000000", "20240103-000000"}
+	timestamp = func() string {
+		stamp := stamps[0]
+		stamps = stamps[1:]
+		return stamp
+	}
+	t.Cleanup(func() { timestamp = oldTimestamp })
+
+	dir := t.TempDir()
+	profileFile := filepath.Join(dir, profilePrefix+"alpha")
+	if err := os.WriteFile(profileFile, []byte("same"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	first, err := BackupProfileWith(testStore(dir), "alpha", Options{Reason: ReasonSave})
+	if err != nil {
+		t.Fatalf("BackupProfileWith(1): %v", err)
+	}
+	second, err := BackupProfileWith(testStore(dir), "alpha", Options{Reason: ReasonManual, Note: "keep"})
+	if err != nil {
+		t.Fatalf("BackupProfileWith(2): %v", err)
+	}
+	if !second.Reused || second.Path != first.Path {
+		t.Fatalf("expected the first backup to be reused, got %+v", second)
+	}
+	if second.Reason != ReasonSave || second.Note != "keep" {
+		t.Fatalf("expected original reason with the new note, got %+v", second)
+	}
+
+	if err := os.WriteFile(profileFile, []byte("changed"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	third, err := BackupProfileWith(testStore(dir), "alpha", Options{Reason: ReasonSave})
+	if err != nil {
+		t.Fatalf("BackupProfileWith(3): %v", err)
+	}
+	if third.Reused || third.Path == first.Path {
+		t.Fatalf("expected a new backup for changed content, got %+v", third)
+	}
+
+	backups, err := ListProfileBackups(testStore(dir), "alpha")
+	if err != nil || len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %v %v", backups, err)
+	}
+	described, err := Describe(testStore(dir), backups)
+	if err != nil {
+		t.Fatalf("Describe: %v", err)
+	}
+	if described[1].Note != "keep" {
+		t.Fatalf("expected note to be indexed, got %+v", described[1])
+	}
+}
+
+func TestDescribeHashesUnindexedBackups(t *testing.T) {
+	dir := t.TempDir()
+	name := profilePrefix + "alpha" + backupMarker + "20240101-000000"
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("legacy"), 0o600); err != nil {
+		t.Fatalf("write backup: %v", err)
+	}
+	described, err := Describe(testStore(dir), []string{name})
+	if err != nil {
+		t.Fatalf("Describe: %v", err)
+	}
+	wantHash, _ := journal.HashFile(path)
+	if described[0].Reason != "" || described[0].SHA256 != wantHash || described[0].CreatedAt.IsZero() {
+		t.Fatalf("unexpected legacy entry: %+v", described[0])
+	}
+}
+
+func TestRenameProfileBackupsMovesMetadata(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, profilePrefix+"alpha"), []byte("data"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	if _, err := BackupProfileWith(testStore(dir), "alpha", Options{Note: "renamed"}); err != nil {
+		t.Fatalf("BackupProfileWith: %v", err)
+	}
+	renamed, err := RenameProfileBackups(testStore(dir), "alpha", "beta")
+	if err != nil || len(renamed) != 1 {
+		t.Fatalf("RenameProfileBackups: %v %v", renamed, err)
+	}
+	described, err := Describe(testStore(dir), []string{filepath.Base(renamed[0])})
+	if err != nil {
+		t.Fatalf("Describe: %v", err)
+	}
+	if described[0].Note != "renamed" || described[0].Reason != ReasonManual {
+		t.Fatalf("expected metadata to follow the rename, got %+v", described[0])
+	}
+}
@@ -0,0 +1,299 @@
+package backup
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"moirai/internal/app"
+	"moirai/internal/journal"
+	"moirai/internal/profile"
+	"moirai/internal/util"
+)
+
+// ActiveGroup is the PrunePlan profile name used for backups of the
+// unmanaged active config file.
+const ActiveGroup = ""
+
+// BackupFile describes a backup considered by retention.
+type BackupFile struct {
+	Name      string
+	Path      string
+	Size      int64
+	CreatedAt time.Time
+}
+
+// PrunePlan lists which backups of one profile are kept and deleted.
+type PrunePlan struct {
+	Profile string
+	Keep    []BackupFile
+	Delete  []BackupFile
+}
+
+// FreedBytes returns the total size of the backups scheduled for deletion.
+func (p PrunePlan) FreedBytes() int64 {
+	var total int64
+	for _, file := range p.Delete {
+		total += file.Size
+	}
+	return total
+}
+
+var now = time.Now
+
+// PlanPrune computes the retention plan for every backup group in store,
+// ordered by profile name.
+func PlanPrune(store Store, policy app.BackupRetention) ([]PrunePlan, error) {
+	groups, err := backupGroups(store)
+	if err != nil {
+		return nil, err
+	}
+	pinned, err := pinnedBackups(store.ConfigDir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	plans := make([]PrunePlan, 0, len(names))
+	current := now()
+	for _, name := range names {
+		plans = append(plans, planRetention(name, groups[name], policy, current, pinned))
+	}
+	return plans, nil
+}
+
+// PlanProfilePrune computes the retention plan for a single profile.
+func PlanProfilePrune(store Store, profileName string, policy app.BackupRetention) (PrunePlan, error) {
+	if err := profile.ValidateName(profileName); err != nil {
+		return PrunePlan{}, err
+	}
+	files, err := groupFiles(store, profileName)
+	if err != nil {
+		return PrunePlan{}, err
+	}
+	pinned, err := pinnedBackups(store.ConfigDir)
+	if err != nil {
+		return PrunePlan{}, err
+	}
+	return planRetention(profileName, files, policy, now(), pinned), nil
+}
+
+// ApplyPrune deletes the backups scheduled for deletion by plan.
+func ApplyPrune(plan PrunePlan) error {
+	for _, file := range plan.Delete {
+		if err := os.Remove(file.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// enforceRetention applies policy to one backup group after a new backup
+// was written.
+func enforceRetention(store Store, profileName string, policy app.BackupRetention) error {
+	if !policy.Enabled() {
+		return nil
+	}
+	files, err := groupFiles(store, profileName)
+	if err != nil {
+		return err
+	}
+	pinned, err := pinnedBackups(store.ConfigDir)
+	if err != nil {
+		return err
+	}
+	return ApplyPrune(planRetention(profileName, files, policy, now(), pinned))
+}
+
+// pinnedBackups returns the names of the backups that journal entries which
+// can still be undone restore from. Undo needs them, so retention keeps them
+// whatever the policy says.
+func pinnedBackups(dir string) (map[string]bool, error) {
+	entries, err := journal.Read(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+	undone := journal.Undone(entries)
+	pinned := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Backup != "" && !undone[entry.ID] {
+			// Backups may have moved into the store since; undo finds them
+			// by name.
+			pinned[filepath.Base(entry.Backup)] = true
+		}
+	}
+	return pinned, nil
+}
+
+func planRetention(profileName string, files []BackupFile, policy app.BackupRetention, current time.Time, pinned map[string]bool) PrunePlan {
+	sorted := append([]BackupFile(nil), files...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].CreatedAt.Equal(sorted[j].CreatedAt) {
+			return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+		}
+		return sorted[i].Name > sorted[j].Name
+	})
+	plan := PrunePlan{Profile: profileName}
+	if len(sorted) == 0 || !policy.Enabled() {
+		plan.Keep = sorted
+		return plan
+	}
+
+	keep := make([]bool, len(sorted))
+	hasKeepRules := policy.KeepLast > 0 || policy.KeepDailyDays > 0 || policy.KeepWeeklyDays > 0
+	if !hasKeepRules {
+		for i := range keep {
+			keep[i] = true
+		}
+	}
+	keep[0] = true
+	for i := 0; i < policy.KeepLast && i < len(sorted); i++ {
+		keep[i] = true
+	}
+	for i, file := range sorted {
+		if pinned[file.Name] {
+			keep[i] = true
+		}
+	}
+	keepNewestPerPeriod(sorted, keep, current, policy.KeepDailyDays, func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepNewestPerPeriod(sorted, keep, current, policy.KeepWeeklyDays, func(t time.Time) string {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	})
+
+	if policy.MaxTotalBytes > 0 {
+		var total int64
+		for i, file := range sorted {
+			if keep[i] {
+				total += file.Size
+			}
+		}
+		for i := len(sorted) - 1; i > 0 && total > policy.MaxTotalBytes; i-- {
+			if keep[i] && !pinned[sorted[i].Name] {
+				keep[i] = false
+				total -= sorted[i].Size
+			}
+		}
+	}
+
+	for i, file := range sorted {
+		if keep[i] {
+			plan.Keep = append(plan.Keep, file)
+		} else {
+			plan.Delete = append(plan.Delete, file)
+		}
+	}
+	return plan
+}
+
+func keepNewestPerPeriod(sorted []BackupFile, keep []bool, current time.Time, days int, period func(time.Time) string) {
+	if days <= 0 {
+		return
+	}
+	cutoff := current.AddDate(0, 0, -days)
+	seen := make(map[string]struct{})
+	for i, file := range sorted {
+		if file.CreatedAt.Before(cutoff) {
+			continue
+		}
+		key := period(file.CreatedAt)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		keep[i] = true
+	}
+}
+
+// backupGroups returns all backups in the backup store and the config dir
+// keyed by profile name, using ActiveGroup for backups of the active config
+// file.
+func backupGroups(store Store) (map[string][]BackupFile, error) {
+	dirs := []string{}
+	subdirs, err := util.ListDir(store.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range subdirs {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(store.Dir, entry.Name()))
+		}
+	}
+	dirs = append(dirs, store.ConfigDir)
+
+	groups := make(map[string][]BackupFile)
+	seen := make(map[string]bool)
+	for _, groupDir := range dirs {
+		entries, err := util.ListDir(groupDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || seen[entry.Name()] {
+				continue
+			}
+			group, ok := groupOf(entry.Name())
+			if !ok {
+				continue
+			}
+			file, err := backupFileInfo(groupDir, entry)
+			if err != nil {
+				return nil, err
+			}
+			seen[entry.Name()] = true
+			groups[group] = append(groups[group], file)
+		}
+	}
+	return groups, nil
+}
+
+func listBackupFiles(dir, prefix string) ([]BackupFile, error) {
+	entries, err := util.ListDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	files := make([]BackupFile, 0)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) || len(name) == len(prefix) {
+			continue
+		}
+		file, err := backupFileInfo(dir, entry)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func backupFileInfo(dir string, entry os.DirEntry) (BackupFile, error) {
+	info, err := entry.Info()
+	if err != nil {
+		return BackupFile{}, err
+	}
+	createdAt, ok := BackupTime(entry.Name())
+	if !ok {
+		createdAt = info.ModTime()
+	}
+	return BackupFile{
+		Name:      entry.Name(),
+		Path:      filepath.Join(dir, entry.Name()),
+		Size:      info.Size(),
+		CreatedAt: createdAt,
+	}, nil
+}
@@ -0,0 +1,218 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"moirai/internal/app"
+	"moirai/internal/journal"
+)
+
+func writeBackupFile(t *testing.T, dir, profileName, stamp string, size int) {
+	t.Helper()
+	name := profilePrefix + profileName + backupMarker + stamp
+	if profileName == "" {
+		name = activeFileName + backupMarker + stamp
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0o600); err != nil {
+		t.Fatalf("write backup %s: %v", name, err)
+	}
+}
+
+func fixedNow(t *testing.T, value string) {
+	t.Helper()
+	parsed, err := time.ParseInLocation("20060102-150405", value, time.Local)
+	if err != nil {
+		t.Fatalf("parse now: %v", err)
+	}
+	prev := now
+	now = func() time.Time { return parsed }
+	t.Cleanup(func() { now = prev })
+}
+
+func planNames(files []BackupFile) []string {
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	return names
+}
+
+func TestPlanProfilePruneKeepLast(t *testing.T) {
+	dir := t.TempDir()
+	fixedNow(t, "20240110-120000")
+	for _, stamp := range []string{"20240101-000000", "20240102-000000", "20240103-000000", "20240104-000000"} {
+		writeBackupFile(t, dir, "alpha", stamp, 10)
+	}
+
+	plan, err := PlanProfilePrune(testStore(dir), "alpha", app.BackupRetention{KeepLast: 2})
+	if err != nil {
+		t.Fatalf("PlanProfilePrune: %v", err)
+	}
+	keep := planNames(plan.Keep)
+	del := planNames(plan.Delete)
+	if len(keep) != 2 || keep[0] != profilePrefix+"alpha"+backupMarker+"20240104-000000" {
+		t.Fatalf("unexpected keep: %v", keep)
+	}
+	if len(del) != 2 || del[1] != profilePrefix+"alpha"+backupMarker+"20240101-000000" {
+		t.Fatalf("unexpected delete: %v", del)
+	}
+	if plan.FreedBytes() != 20 {
+		t.Fatalf("expected 20 freed bytes, got %d", plan.FreedBytes())
+	}
+}
+
+func TestPlanProfilePruneKeepDaily(t *testing.T) {
+	dir := t.TempDir()
+	fixedNow(t, "20240110-120000")
+	stamps := []string{
+		"20240110-090000",
+		"20240110-080000",
+		"20240109-100000",
+		"20240101-000000",
+	}
+	for _, stamp := range stamps {
+		writeBackupFile(t, dir, "alpha", stamp, 1)
+	}
+
+	plan, err := PlanProfilePrune(testStore(dir), "alpha", app.BackupRetention{KeepDailyDays: 3})
+	if err != nil {
+		t.Fatalf("PlanProfilePrune: %v", err)
+	}
+	keep := planNames(plan.Keep)
+	expected := []string{
+		profilePrefix + "alpha" + backupMarker + "20240110-090000",
+		profilePrefix + "alpha" + backupMarker + "20240109-100000",
+	}
+	if len(keep) != len(expected) || keep[0] != expected[0] || keep[1] != expected[1] {
+		t.Fatalf("expected keep %v, got %v", expected, keep)
+	}
+	if len(plan.Delete) != 2 {
+		t.Fatalf("expected 2 deletions, got %v", planNames(plan.Delete))
+	}
+}
+
+func TestPlanProfilePruneMaxTotalBytesKeepsNewest(t *testing.T) {
+	dir := t.TempDir()
+	fixedNow(t, "20240110-120000")
+	writeBackupFile(t, dir, "alpha", "20240101-000000", 50)
+	writeBackupFile(t, dir, "alpha", "20240102-000000", 50)
+	writeBackupFile(t, dir, "alpha", "20240103-000000", 200)
+
+	plan, err := PlanProfilePrune(testStore(dir), "alpha", app.BackupRetention{MaxTotalBytes: 100})
+	if err != nil {
+		t.Fatalf("PlanProfilePrune: %v", err)
+	}
+	keep := planNames(plan.Keep)
+	if len(keep) != 1 || keep[0] != profilePrefix+"alpha"+backupMarker+"20240103-000000" {
+		t.Fatalf("expected only newest kept, got %v", keep)
+	}
+}
+
+func TestPlanPruneGroupsProfilesAndActive(t *testing.T) {
+	dir := t.TempDir()
+	fixedNow(t, "20240110-120000")
+	writeBackupFile(t, dir, "alpha", "20240101-000000", 1)
+	writeBackupFile(t, dir, "alpha", "20240102-000000", 1)
+	writeBackupFile(t, dir, "beta", "20240101-000000", 1)
+	writeBackupFile(t, dir, ActiveGroup, "20240101-000000", 1)
+	writeBackupFile(t, dir, ActiveGroup, "20240102-000000", 1)
+
+	plans, err := PlanPrune(testStore(dir), app.BackupRetention{KeepLast: 1})
+	if err != nil {
+		t.Fatalf("PlanPrune: %v", err)
+	}
+	if len(plans) != 3 {
+		t.Fatalf("expected 3 groups, got %d", len(plans))
+	}
+	if plans[0].Profile != ActiveGroup || len(plans[0].Delete) != 1 {
+		t.Fatalf("unexpected active plan: %+v", plans[0])
+	}
+	if plans[1].Profile != "alpha" || len(plans[1].Delete) != 1 {
+		t.Fatalf("unexpected alpha plan: %+v", plans[1])
+	}
+	if plans[2].Profile != "beta" || len(plans[2].Delete) != 0 {
+		t.Fatalf("unexpected beta plan: %+v", plans[2])
+	}
+
+	for _, plan := range plans {
+		if err := ApplyPrune(plan); err != nil {
+			t.Fatalf("ApplyPrune: %v", err)
+		}
+	}
+	remaining, err := ListProfileBackups(testStore(dir), "alpha")
+	if err != nil {
+		t.Fatalf("ListProfileBackups: %v", err)
+	}
+	if len(remaining) != 1 {
+		t.Fatalf("expected 1 alpha backup left, got %v", remaining)
+	}
+}
+
+func TestBackupProfileEnforcesRetention(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, profilePrefix+"alpha"), []byte("{}"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	writeBackupFile(t, dir, "alpha", "20200101-000000", 1)
+	writeBackupFile(t, dir, "alpha", "20200102-000000", 1)
+
+	if _, err := BackupProfile(testStore(dir), "alpha"); err != nil {
+		t.Fatalf("BackupProfile: %v", err)
+	}
+	if backups, _ := ListProfileBackups(testStore(dir), "alpha"); len(backups) != 3 {
+		t.Fatalf("expected no pruning without a policy, got %v", backups)
+	}
+	if err := os.WriteFile(filepath.Join(dir, profilePrefix+"alpha"), []byte(`{"changed":true}`), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	if _, err := BackupProfileWith(testStore(dir), "alpha", Options{Retention: app.BackupRetention{KeepLast: 2}}); err != nil {
+		t.Fatalf("BackupProfileWith: %v", err)
+	}
+	backups, err := ListProfileBackups(testStore(dir), "alpha")
+	if err != nil {
+		t.Fatalf("ListProfileBackups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups after retention, got %v", backups)
+	}
+	for _, name := range backups {
+		if created, _ := BackupTime(name); created.Year() == 2020 {
+			t.Fatalf("expected the older backups pruned, got %v", backups)
+		}
+	}
+}
+
+func TestPruneKeepsBackupsUndoNeeds(t *testing.T) {
+	dir := t.TempDir()
+	fixedNow(t, "20240110-120000")
+	for _, stamp := range []string{"20240101-000000", "20240102-000000", "20240103-000000", "20240104-000000"} {
+		writeBackupFile(t, dir, "alpha", stamp, 10)
+	}
+	needed := profilePrefix + "alpha" + backupMarker + "20240101-000000"
+	reverted := profilePrefix + "alpha" + backupMarker + "20240102-000000"
+	recorded, err := journal.Record(dir, journal.Entry{Op: journal.OpRestore, Profile: "alpha", Backup: filepath.Join(dir, reverted)})
+	if err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if _, err := journal.Record(dir, journal.Entry{Op: journal.OpUndo, Profile: "alpha", Undoes: recorded.ID}); err != nil {
+		t.Fatalf("Record undo: %v", err)
+	}
+	if _, err := journal.Record(dir, journal.Entry{Op: journal.OpRestore, Profile: "alpha", Backup: filepath.Join(dir, needed)}); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	plan, err := PlanProfilePrune(testStore(dir), "alpha", app.BackupRetention{KeepLast: 1, MaxTotalBytes: 10})
+	if err != nil {
+		t.Fatalf("PlanProfilePrune: %v", err)
+	}
+	keep := planNames(plan.Keep)
+	if len(keep) != 2 || keep[1] != needed {
+		t.Fatalf("expected the newest backup and the one undo needs kept, got %v", keep)
+	}
+	if deleted := planNames(plan.Delete); len(deleted) != 2 || deleted[1] != reverted {
+		t.Fatalf("expected the backup of the undone operation deleted, got %v", deleted)
+	}
+}
@@ -0,0 +1,249 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"moirai/internal/app"
+	"moirai/internal/util"
+)
+
+// activeStoreDir is the store subdirectory holding backups of the unmanaged
+// active config file. Profile backups live in a subdirectory named after the
+// profile; the file name prefixes keep the two apart even for a profile of
+// the same name.
+const activeStoreDir = "_active"
+
+// Store locates the backups of a config dir: new backups go to Dir, while
+// backups written before the store existed stay readable in ConfigDir
+// itself.
+type Store struct {
+	ConfigDir string
+	Dir       string
+}
+
+// NewStore returns the backup store configured by config: the backupDir
+// setting of moirai.json, or app.BackupsDir by default. The setting is
+// resolved once here so that backup operations never reload moirai.json.
+func NewStore(config app.AppConfig) Store {
+	dir := config.BackupDir
+	if dir == "" {
+		dir = app.BackupsDir(config.ConfigDir)
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return Store{ConfigDir: config.ConfigDir, Dir: dir}
+}
+
+// OrDefault returns s, or the default store of configDir when s is the zero
+// value, as for an options field left unset.
+func (s Store) OrDefault(configDir string) Store {
+	if s == (Store{}) {
+		return NewStore(app.AppConfig{ConfigDir: configDir})
+	}
+	return s
+}
+
+// groupDir returns the store subdirectory of a backup group.
+func groupDir(store, group string) string {
+	if group == ActiveGroup {
+		return filepath.Join(store, activeStoreDir)
+	}
+	return filepath.Join(store, group)
+}
+
+// groupPrefix returns the file name prefix shared by the backups of a group.
+func groupPrefix(group string) string {
+	if group == ActiveGroup {
+		return activeFileName + backupMarker
+	}
+	return profilePrefix + group + backupMarker
+}
+
+// groupOf returns the group a backup file name belongs to.
+func groupOf(name string) (string, bool) {
+	idx := strings.Index(name, backupMarker)
+	if idx < 0 || idx+len(backupMarker) == len(name) {
+		return "", false
+	}
+	base := name[:idx]
+	switch {
+	case base == activeFileName:
+		return ActiveGroup, true
+	case strings.HasPrefix(base, profilePrefix) && len(base) > len(profilePrefix):
+		return strings.TrimPrefix(base, profilePrefix), true
+	}
+	return "", false
+}
+
+// Locate returns the path of the named backup, looking in the backup store
+// first and then in the config dir.
+func Locate(store Store, name string) (string, error) {
+	group, ok := groupOf(name)
+	if !ok || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("%q is not a backup name", name)
+	}
+	return locate(store, group, name)
+}
+
+func locate(store Store, group, name string) (string, error) {
+	for _, candidate := range []string{filepath.Join(groupDir(store.Dir, group), name), filepath.Join(store.ConfigDir, name)} {
+		if _, err := os.Lstat(candidate); err == nil {
+			return candidate, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("backup %q: %w", name, os.ErrNotExist)
+}
+
+// groupFiles lists the backups of one group in the store and in the config
+// dir. A name present in both is reported once, from the store.
+func groupFiles(store Store, group string) ([]BackupFile, error) {
+	prefix := groupPrefix(group)
+	files, err := listBackupFiles(groupDir(store.Dir, group), prefix)
+	if err != nil {
+		return nil, err
+	}
+	legacy, err := listBackupFiles(store.ConfigDir, prefix)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(files))
+	for _, file := range files {
+		seen[file.Name] = true
+	}
+	for _, file := range legacy {
+		if !seen[file.Name] {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// uniqueBackupName returns baseName, or baseName with a numeric suffix, so
+// that no file of that name exists in any of dirs.
+func uniqueBackupName(dirs []string, baseName string) (string, error) {
+	taken := func(name string) (bool, error) {
+		for _, dir := range dirs {
+			if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+				return true, nil
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return false, err
+			}
+		}
+		return false, nil
+	}
+	name := baseName
+	for i := 1; ; i++ {
+		busy, err := taken(name)
+		if err != nil {
+			return "", err
+		}
+		if !busy {
+			return name, nil
+		}
+		name = fmt.Sprintf("%s-%d", baseName, i)
+	}
+}
+
+// Move records a backup moved into the store.
+type Move struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Migrate moves the backups kept in the config dir into the backup store,
+// one subdirectory per profile, and keeps their metadata. With dryRun set it
+// only reports the moves.
+func Migrate(store Store, dryRun bool) ([]Move, error) {
+	dir := store.ConfigDir
+	entries, err := util.ListDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if _, ok := groupOf(entry.Name()); ok {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	moves := make([]Move, 0, len(names))
+	renames := make(map[string]string)
+	// Index entries follow the files even when a move fails halfway.
+	defer func() {
+		if !dryRun {
+			_ = renameIndexed(store, renames)
+		}
+	}()
+	for _, name := range names {
+		group, _ := groupOf(name)
+		target := groupDir(store.Dir, group)
+		newName, err := uniqueBackupName([]string{target}, name)
+		if err != nil {
+			return moves, err
+		}
+		move := Move{From: filepath.Join(dir, name), To: filepath.Join(target, newName)}
+		if !dryRun {
+			if err := os.MkdirAll(target, 0o700); err != nil {
+				return moves, err
+			}
+			if err := moveFile(move.From, move.To); err != nil {
+				return moves, err
+			}
+			if newName != name {
+				renames[name] = newName
+			}
+		}
+		moves = append(moves, move)
+	}
+	return moves, nil
+}
+
+// moveFile renames src to dst, copying when they are on different file
+// systems.
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := util.CopyFileAtomic(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// isInDir reports whether path is a direct child of dir, following symlinks
+// in either.
+func isInDir(path, dir string) (bool, error) {
+	pathAbs, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	dirAbs, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+
+	pathResolved := pathAbs
+	if resolved, err := filepath.EvalSymlinks(pathAbs); err == nil {
+		pathResolved = resolved
+	}
+	dirResolved := dirAbs
+	if resolved, err := filepath.EvalSymlinks(dirAbs); err == nil {
+		dirResolved = resolved
+	}
+
+	return filepath.Dir(pathResolved) == dirResolved, nil
+}
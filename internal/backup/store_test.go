@@ -0,0 +1,234 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"moirai/internal/app"
+)
+
+// testStore returns the default backup store of dir.
+func testStore(dir string) Store {
+	return NewStore(app.AppConfig{ConfigDir: dir})
+}
+
+func TestBackupProfileWritesToStore(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, profilePrefix+"alpha"), []byte("data"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	path, err := BackupProfile(testStore(dir), "alpha")
+	if err != nil {
+		t.Fatalf("BackupProfile: %v", err)
+	}
+	if filepath.Dir(path) != filepath.Join(app.BackupsDir(dir), "alpha") {
+		t.Fatalf("expected backup in the per-profile store dir, got %s", path)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*"+backupMarker+"*")); len(matches) != 0 {
+		t.Fatalf("expected no backups in the config dir, got %v", matches)
+	}
+}
+
+func TestBackupDirSettingRelocatesStore(t *testing.T) {
+	dir := t.TempDir()
+	store := t.TempDir()
+	data := []byte(`{"backupDir": "` + filepath.ToSlash(store) + `"}`)
+	if err := os.WriteFile(filepath.Join(dir, app.ConfigFileName), data, 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, profilePrefix+"alpha"), []byte("data"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	config, err := app.LoadConfig(dir, nil)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	path, err := BackupProfile(NewStore(config), "alpha")
+	if err != nil {
+		t.Fatalf("BackupProfile: %v", err)
+	}
+	if filepath.Dir(path) != filepath.Join(store, "alpha") {
+		t.Fatalf("expected backup under the configured store, got %s", path)
+	}
+}
+
+func TestBrokenConfigDoesNotBlockBackups(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, app.ConfigFileName), []byte("{broken"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, profilePrefix+"alpha"), []byte("data"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	path, err := BackupProfile(testStore(dir), "alpha")
+	if err != nil {
+		t.Fatalf("BackupProfile: %v", err)
+	}
+	backups, err := ListProfileBackups(testStore(dir), "alpha")
+	if err != nil || len(backups) != 1 || backups[0] != filepath.Base(path) {
+		t.Fatalf("ListProfileBackups = %v, %v", backups, err)
+	}
+}
+
+func TestLegacyBackupsStayListedAndRestorable(t *testing.T) {
+	dir := t.TempDir()
+	profilePath := filepath.Join(dir, profilePrefix+"alpha")
+	if err := os.WriteFile(profilePath, []byte("current"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	legacy := profilePrefix + "alpha" + backupMarker + "20240101-000000"
+	if err := os.WriteFile(filepath.Join(dir, legacy), []byte("legacy"), 0o600); err != nil {
+		t.Fatalf("write legacy backup: %v", err)
+	}
+	stored, err := BackupProfile(testStore(dir), "alpha")
+	if err != nil {
+		t.Fatalf("BackupProfile: %v", err)
+	}
+
+	backups, err := ListProfileBackups(testStore(dir), "alpha")
+	if err != nil {
+		t.Fatalf("ListProfileBackups: %v", err)
+	}
+	if len(backups) != 2 || backups[0] != filepath.Base(stored) || backups[1] != legacy {
+		t.Fatalf("expected store and legacy backups newest first, got %v", backups)
+	}
+	if path, err := Locate(testStore(dir), legacy); err != nil || path != filepath.Join(dir, legacy) {
+		t.Fatalf("Locate(legacy) = %q, %v", path, err)
+	}
+
+	if _, err := RestoreProfileFromBackup(testStore(dir), "alpha", legacy, Options{}); err != nil {
+		t.Fatalf("restore legacy backup: %v", err)
+	}
+	if data, _ := os.ReadFile(profilePath); string(data) != "legacy" {
+		t.Fatalf("expected legacy content, got %q", data)
+	}
+	if _, err := RestoreProfileFromBackup(testStore(dir), "alpha", filepath.Base(stored), Options{}); err != nil {
+		t.Fatalf("restore stored backup by name: %v", err)
+	}
+	if data, _ := os.ReadFile(profilePath); string(data) != "current" {
+		t.Fatalf("expected stored content, got %q", data)
+	}
+}
+
+func TestRestoreRejectsAnotherProfilesStoreDir(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"alpha", "beta"} {
+		if err := os.WriteFile(filepath.Join(dir, profilePrefix+name), []byte(name), 0o600); err != nil {
+			t.Fatalf("write profile: %v", err)
+		}
+	}
+	betaBackup, err := BackupProfile(testStore(dir), "beta")
+	if err != nil {
+		t.Fatalf("BackupProfile: %v", err)
+	}
+	if _, err := RestoreProfileFromBackup(testStore(dir), "alpha", betaBackup, Options{}); err == nil {
+		t.Fatal("expected restore from another profile's backup to fail")
+	}
+}
+
+func TestMigrateMovesLegacyBackupsWithMetadata(t *testing.T) {
+	dir := t.TempDir()
+	profileBackup := profilePrefix + "alpha" + backupMarker + "20240101-000000"
+	activeBackup := activeFileName + backupMarker + "20240101-000000"
+	for _, name := range []string{profilePrefix + "alpha", profileBackup, activeBackup} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	idx := index{Backups: map[string]Meta{profileBackup: {Reason: ReasonSave, Note: "kept"}}}
+	if err := writeIndex(testStore(dir), idx); err != nil {
+		t.Fatalf("writeIndex: %v", err)
+	}
+
+	planned, err := Migrate(testStore(dir), true)
+	if err != nil || len(planned) != 2 {
+		t.Fatalf("dry run: %v %v", planned, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, profileBackup)); err != nil {
+		t.Fatalf("dry run moved a backup: %v", err)
+	}
+
+	moved, err := Migrate(testStore(dir), false)
+	if err != nil || len(moved) != 2 {
+		t.Fatalf("Migrate: %v %v", moved, err)
+	}
+	store := app.BackupsDir(dir)
+	for _, want := range []string{filepath.Join(store, "alpha", profileBackup), filepath.Join(store, activeStoreDir, activeBackup)} {
+		if _, err := os.Stat(want); err != nil {
+			t.Fatalf("expected %s: %v", want, err)
+		}
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*"+backupMarker+"*")); len(matches) != 0 {
+		t.Fatalf("expected the config dir to be free of backups, got %v", matches)
+	}
+	described, err := Describe(testStore(dir), []string{profileBackup})
+	if err != nil {
+		t.Fatalf("Describe: %v", err)
+	}
+	if described[0].Note != "kept" || described[0].Reason != ReasonSave {
+		t.Fatalf("expected metadata to survive the migration, got %+v", described[0])
+	}
+
+	if again, err := Migrate(testStore(dir), false); err != nil || len(again) != 0 {
+		t.Fatalf("expected a second migration to be a no-op, got %v %v", again, err)
+	}
+}
+
+func TestRenameProfileBackupsMovesStoreDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, profilePrefix+"alpha"), []byte("data"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	if _, err := BackupProfile(testStore(dir), "alpha"); err != nil {
+		t.Fatalf("BackupProfile: %v", err)
+	}
+	renamed, err := RenameProfileBackups(testStore(dir), "alpha", "beta")
+	if err != nil || len(renamed) != 1 {
+		t.Fatalf("RenameProfileBackups: %v %v", renamed, err)
+	}
+	if filepath.Dir(renamed[0]) != filepath.Join(app.BackupsDir(dir), "beta") {
+		t.Fatalf("expected backup in the new store dir, got %s", renamed[0])
+	}
+	if _, err := os.Stat(filepath.Join(app.BackupsDir(dir), "alpha")); !os.IsNotExist(err) {
+		t.Fatalf("expected the old store dir to be removed, got %v", err)
+	}
+}
+
+func TestBackupRejectsPathInProfileName(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "opencode")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(filepath.Dir(dir), "foo"), []byte("outside"), 0o600); err != nil {
+		t.Fatalf("write outside file: %v", err)
+	}
+	name := "x/../../foo"
+	if _, err := BackupProfile(testStore(dir), name); err == nil {
+		t.Fatal("expected BackupProfile to reject the name")
+	}
+	if _, err := RestoreProfileFromBackup(testStore(dir), name, "anything", Options{}); err == nil {
+		t.Fatal("expected RestoreProfileFromBackup to reject the name")
+	}
+	if _, err := ListProfileBackups(testStore(dir), name); err == nil {
+		t.Fatal("expected ListProfileBackups to reject the name")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected nothing written to the config dir, got %v", entries)
+	}
+}
+
+func TestBakSuffixedProfileCannotShadowBackups(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"x", "x.bak"} {
+		if err := os.WriteFile(filepath.Join(dir, profilePrefix+name), []byte(name), 0o600); err != nil {
+			t.Fatalf("write profile: %v", err)
+		}
+	}
+	if _, err := BackupProfile(testStore(dir), "x.bak"); err == nil {
+		t.Fatal("expected BackupProfile to reject a name ending in .bak")
+	}
+	if backups, err := ListProfileBackups(testStore(dir), "x"); err != nil || len(backups) != 0 {
+		t.Fatalf("expected no backups of x, got %v %v", backups, err)
+	}
+}
@@ -0,0 +1,273 @@
+package bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+	"moirai/internal/profile"
+	"moirai/internal/secrets"
+)
+
+// FormatVersion is the bundle layout version written to the manifest.
+const FormatVersion = 1
+
+const (
+	manifestName = "moirai-bundle.json"
+	profilesDir  = "profiles"
+	backupsDir   = "backups"
+	backupMarker = ".bak."
+	// maxEntrySize bounds each file read from a bundle.
+	maxEntrySize = 16 << 20
+)
+
+// Manifest describes the contents of a bundle.
+type Manifest struct {
+	FormatVersion int               `json:"formatVersion"`
+	MoiraiVersion string            `json:"moiraiVersion"`
+	CreatedAt     time.Time         `json:"createdAt"`
+	Profiles      []ManifestProfile `json:"profiles"`
+}
+
+// ManifestProfile lists one bundled profile and the timestamps of its
+// bundled backups.
+type ManifestProfile struct {
+	Name    string   `json:"name"`
+	Backups []string `json:"backups,omitempty"`
+	// Redacted counts the secret values replaced in the profile and its
+	// backups.
+	Redacted int `json:"redacted,omitempty"`
+}
+
+// Profile is a bundled profile with its raw content.
+type Profile struct {
+	Name    string
+	Data    []byte
+	Backups []Backup
+}
+
+// Backup is a bundled profile backup. Stamp is the part of the backup file
+// name after ".bak.".
+type Backup struct {
+	Stamp string
+	Data  []byte
+}
+
+// Bundle is a parsed bundle.
+type Bundle struct {
+	Manifest Manifest
+	Profiles []Profile
+}
+
+// ExportOptions configures Export.
+type ExportOptions struct {
+	IncludeBackups bool
+	// IncludeSecrets keeps secret values; by default they are redacted.
+	IncludeSecrets bool
+	// Backups is the store bundled backups are read from; the zero value
+	// means the default store of the config dir.
+	Backups backup.Store
+}
+
+var now = time.Now
+
+// Export writes the named profiles from dir to w as a gzip-compressed tar
+// archive. Every profile must parse with profile.LoadProfile.
+func Export(w io.Writer, dir string, names []string, opts ExportOptions) (Manifest, error) {
+	if len(names) == 0 {
+		return Manifest{}, fmt.Errorf("at least one profile is required")
+	}
+	manifest := Manifest{
+		FormatVersion: FormatVersion,
+		MoiraiVersion: app.Version,
+		CreatedAt:     now().UTC().Truncate(time.Second),
+	}
+	store := opts.Backups.OrDefault(dir)
+	files := make(map[string][]byte)
+	var order []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if err := profile.ValidateName(name); err != nil {
+			return Manifest{}, err
+		}
+		profilePath := profile.ProfilePath(dir, name)
+		if _, err := profile.LoadProfile(profilePath); err != nil {
+			if os.IsNotExist(err) {
+				return Manifest{}, fmt.Errorf("profile %q not found", name)
+			}
+			return Manifest{}, fmt.Errorf("profile %q: %w", name, err)
+		}
+		data, err := os.ReadFile(profilePath)
+		if err != nil {
+			return Manifest{}, err
+		}
+		entry := ManifestProfile{Name: name}
+		if data, err = redactEntry(data, opts, &entry); err != nil {
+			return Manifest{}, fmt.Errorf("profile %q: %w", name, err)
+		}
+		filePath := path.Join(profilesDir, name+".json")
+		files[filePath] = data
+		order = append(order, filePath)
+
+		if opts.IncludeBackups {
+			backups, err := backup.ListProfileBackups(store, name)
+			if err != nil {
+				return Manifest{}, err
+			}
+			for _, backupName := range backups {
+				stamp := backupName[strings.LastIndex(backupName, backupMarker)+len(backupMarker):]
+				backupPath, err := backup.Locate(store, backupName)
+				if err != nil {
+					return Manifest{}, err
+				}
+				data, err := os.ReadFile(backupPath)
+				if err != nil {
+					return Manifest{}, err
+				}
+				if data, err = redactEntry(data, opts, &entry); err != nil {
+					return Manifest{}, fmt.Errorf("backup %s: %w", backupName, err)
+				}
+				filePath := path.Join(backupsDir, name, stamp)
+				files[filePath] = data
+				order = append(order, filePath)
+				entry.Backups = append(entry.Backups, stamp)
+			}
+		}
+		manifest.Profiles = append(manifest.Profiles, entry)
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return Manifest{}, err
+	}
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := writeEntry(tw, manifestName, append(manifestData, '\n'), manifest.CreatedAt); err != nil {
+		return Manifest{}, err
+	}
+	for _, filePath := range order {
+		if err := writeEntry(tw, filePath, files[filePath], manifest.CreatedAt); err != nil {
+			return Manifest{}, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return Manifest{}, err
+	}
+	if err := gz.Close(); err != nil {
+		return Manifest{}, err
+	}
+	return manifest, nil
+}
+
+// redactEntry replaces secret values in data unless the options keep them,
+// adding the number of replaced values to entry.
+func redactEntry(data []byte, opts ExportOptions, entry *ManifestProfile) ([]byte, error) {
+	if opts.IncludeSecrets {
+		return data, nil
+	}
+	redacted, count, err := secrets.RedactJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("scan for secrets: %w", err)
+	}
+	entry.Redacted += count
+	return redacted, nil
+}
+
+func writeEntry(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	header := &tar.Header{
+		Name:     name,
+		Mode:     0o600,
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// Read parses a bundle and checks that its files match the manifest.
+func Read(r io.Reader) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("read bundle: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+
+	files := make(map[string][]byte)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read bundle: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Size > maxEntrySize {
+			return nil, fmt.Errorf("bundle entry %s is too large", header.Name)
+		}
+		data, err := io.ReadAll(io.LimitReader(tr, maxEntrySize+1))
+		if err != nil {
+			return nil, fmt.Errorf("read bundle: %w", err)
+		}
+		files[path.Clean(header.Name)] = data
+	}
+
+	manifestData, ok := files[manifestName]
+	if !ok {
+		return nil, fmt.Errorf("not a moirai bundle: %s is missing", manifestName)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("parse bundle manifest: %w", err)
+	}
+	if manifest.FormatVersion < 1 || manifest.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("unsupported bundle format version %d", manifest.FormatVersion)
+	}
+
+	b := &Bundle{Manifest: manifest}
+	for _, entry := range manifest.Profiles {
+		if err := profile.ValidateName(entry.Name); err != nil {
+			return nil, fmt.Errorf("bundle manifest: %w", err)
+		}
+		data, ok := files[path.Join(profilesDir, entry.Name+".json")]
+		if !ok {
+			return nil, fmt.Errorf("bundle is missing profile %q", entry.Name)
+		}
+		p := Profile{Name: entry.Name, Data: data}
+		for _, stamp := range entry.Backups {
+			if _, ok := backup.BackupTime(backupMarker + stamp); !ok || strings.ContainsAny(stamp, `/\`) {
+				return nil, fmt.Errorf("bundle manifest: invalid backup %q for profile %q", stamp, entry.Name)
+			}
+			data, ok := files[path.Join(backupsDir, entry.Name, stamp)]
+			if !ok {
+				return nil, fmt.Errorf("bundle is missing backup %q of profile %q", stamp, entry.Name)
+			}
+			p.Backups = append(p.Backups, Backup{Stamp: stamp, Data: data})
+		}
+		b.Profiles = append(b.Profiles, p)
+	}
+	if len(b.Profiles) == 0 {
+		return nil, fmt.Errorf("bundle contains no profiles")
+	}
+	return b, nil
+}
@@ -0,0 +1,183 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+	"moirai/internal/profile"
+)
+
+func writeProfile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(profile.ProfilePath(dir, name), []byte(content), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+}
+
+func exportBundle(t *testing.T, dir string, names []string, opts ExportOptions) *Bundle {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := Export(&buf, dir, names, opts); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	b, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	return b
+}
+
+func TestExportRoundTripWithBackups(t *testing.T) {
+	src := t.TempDir()
+	writeProfile(t, src, "alpha", `{"agents":{"oracle":{"model":"o3"}}}`)
+	writeProfile(t, src, "beta", `{}`)
+	backupName := profile.ProfilePath(src, "alpha") + ".bak.20250101-120000"
+	if err := os.WriteFile(backupName, []byte(`{"old":true}`), 0o600); err != nil {
+		t.Fatalf("write backup: %v", err)
+	}
+
+	b := exportBundle(t, src, []string{"alpha", "beta"}, ExportOptions{IncludeBackups: true})
+	if b.Manifest.FormatVersion != FormatVersion || b.Manifest.MoiraiVersion == "" || b.Manifest.CreatedAt.IsZero() {
+		t.Fatalf("unexpected manifest %#v", b.Manifest)
+	}
+	if len(b.Profiles) != 2 || len(b.Profiles[0].Backups) != 1 {
+		t.Fatalf("unexpected profiles %#v", b.Profiles)
+	}
+
+	dst := t.TempDir()
+	results, err := Import(dst, b, ImportOptions{})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if len(results) != 2 || results[0].Action != ActionCreated || len(results[0].Backups) != 1 {
+		t.Fatalf("unexpected results %#v", results)
+	}
+	data, err := os.ReadFile(profile.ProfilePath(dst, "alpha"))
+	if err != nil || !strings.Contains(string(data), `"o3"`) {
+		t.Fatalf("unexpected imported profile %q %v", data, err)
+	}
+	if _, err := backup.Locate(backup.NewStore(app.AppConfig{ConfigDir: dst}), "oh-my-opencode.json.alpha.bak.20250101-120000"); err != nil {
+		t.Fatalf("expected backup restored: %v", err)
+	}
+}
+
+func TestExportRedactsSecrets(t *testing.T) {
+	src := t.TempDir()
+	writeProfile(t, src, "alpha", `{"provider":{"apiKey":"abc123"},"agents":{"oracle":{"model":"o3"}}}`)
+	if err := os.WriteFile(profile.ProfilePath(src, "alpha")+".bak.20250101-120000", []byte(`{"token":"xyz"}`), 0o600); err != nil {
+		t.Fatalf("write backup: %v", err)
+	}
+
+	b := exportBundle(t, src, []string{"alpha"}, ExportOptions{IncludeBackups: true})
+	if b.Manifest.Profiles[0].Redacted != 2 {
+		t.Fatalf("expected 2 redacted values, got %#v", b.Manifest.Profiles[0])
+	}
+	got := string(b.Profiles[0].Data) + string(b.Profiles[0].Backups[0].Data)
+	if strings.Contains(got, "abc123") || strings.Contains(got, "xyz") || !strings.Contains(got, `"o3"`) {
+		t.Fatalf("unexpected bundled data %q", got)
+	}
+
+	b = exportBundle(t, src, []string{"alpha"}, ExportOptions{IncludeSecrets: true})
+	if b.Manifest.Profiles[0].Redacted != 0 || !strings.Contains(string(b.Profiles[0].Data), "abc123") {
+		t.Fatalf("expected secrets kept, got %q", b.Profiles[0].Data)
+	}
+}
+
+func TestImportConflictPolicies(t *testing.T) {
+	src := t.TempDir()
+	writeProfile(t, src, "alpha", `{"agents":{"oracle":{"model":"new"}}}`)
+	b := exportBundle(t, src, []string{"alpha"}, ExportOptions{})
+
+	dst := t.TempDir()
+	writeProfile(t, dst, "alpha", `{"agents":{"oracle":{"model":"old"}}}`)
+
+	if _, err := Import(dst, b, ImportOptions{}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+
+	results, err := Import(dst, b, ImportOptions{OnConflict: ConflictSkip})
+	if err != nil || results[0].Action != ActionSkipped {
+		t.Fatalf("expected skip, got %#v %v", results, err)
+	}
+
+	results, err = Import(dst, b, ImportOptions{OnConflict: ConflictRename})
+	if err != nil || results[0].Action != ActionRenamed || results[0].Target != "alpha-imported" {
+		t.Fatalf("expected rename, got %#v %v", results, err)
+	}
+	results, err = Import(dst, b, ImportOptions{OnConflict: ConflictRename})
+	if err != nil || results[0].Target != "alpha-imported-2" {
+		t.Fatalf("expected second rename, got %#v %v", results, err)
+	}
+
+	results, err = Import(dst, b, ImportOptions{OnConflict: ConflictOverwrite})
+	if err != nil {
+		t.Fatalf("Import overwrite: %v", err)
+	}
+	res := results[0]
+	if res.Action != ActionOverwritten || res.Backup == "" || len(res.Changes) != 1 || res.Changes[0].Path != "agents.oracle.model" {
+		t.Fatalf("unexpected overwrite result %#v", res)
+	}
+	backupData, err := os.ReadFile(res.Backup)
+	if err != nil || !strings.Contains(string(backupData), `"old"`) {
+		t.Fatalf("expected backup of the old profile, got %q %v", backupData, err)
+	}
+
+	results, err = Import(dst, b, ImportOptions{As: "gamma"})
+	if err != nil || results[0].Target != "gamma" || results[0].Action != ActionCreated {
+		t.Fatalf("expected import as gamma, got %#v %v", results, err)
+	}
+}
+
+func TestImportValidatesBeforeWriting(t *testing.T) {
+	b := &Bundle{Profiles: []Profile{
+		{Name: "good", Data: []byte(`{}`)},
+		{Name: "bad", Data: []byte(`{not json`)},
+	}}
+	dst := t.TempDir()
+	if _, err := Import(dst, b, ImportOptions{}); err == nil || !strings.Contains(err.Error(), `bundled profile "bad"`) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	entries, err := os.ReadDir(dst)
+	if err != nil {
+		t.Fatalf("readdir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected nothing written, found %v", entries)
+	}
+
+	if _, err := Import(dst, b, ImportOptions{As: "x"}); err == nil {
+		t.Fatal("expected --as to require a single-profile bundle")
+	}
+}
+
+func TestReadRejectsUnsafeBackupNames(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	manifest := `{"formatVersion":1,"profiles":[{"name":"alpha","backups":["../../evil"]}]}`
+	for name, content := range map[string]string{manifestName: manifest, "profiles/alpha.json": "{}"} {
+		if err := writeEntry(tw, name, []byte(content), time.Now()); err != nil {
+			t.Fatalf("writeEntry: %v", err)
+		}
+	}
+	tw.Close()
+	gz.Close()
+	if _, err := Read(&buf); err == nil || !strings.Contains(err.Error(), "invalid backup") {
+		t.Fatalf("expected invalid backup error, got %v", err)
+	}
+}
+
+func TestExportMissingProfile(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := Export(&buf, t.TempDir(), []string{"missing"}, ExportOptions{})
+	if err == nil || !strings.Contains(err.Error(), `profile "missing" not found`) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
@@ -0,0 +1,3 @@
+// Package bundle exports profiles, with their metadata and optionally their
+// backups, as portable .tar.gz bundles and imports them into a config dir.
+package bundle
@@ -0,0 +1,252 @@
+package bundle
+
+import (
+	"fmt"
+	"os"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+	"moirai/internal/profile"
+	"moirai/internal/util"
+)
+
+// Conflict selects what Import does when a profile name is already taken.
+type Conflict string
+
+const (
+	// ConflictFail refuses to import when any target profile exists.
+	ConflictFail      Conflict = ""
+	ConflictRename    Conflict = "rename"
+	ConflictOverwrite Conflict = "overwrite"
+	ConflictSkip      Conflict = "skip"
+)
+
+// ParseConflict parses an --on-conflict value.
+func ParseConflict(value string) (Conflict, error) {
+	switch Conflict(value) {
+	case ConflictFail, ConflictRename, ConflictOverwrite, ConflictSkip:
+		return Conflict(value), nil
+	}
+	return "", fmt.Errorf("invalid conflict policy %q (want rename, overwrite or skip)", value)
+}
+
+// Action records what Import did with one profile.
+type Action string
+
+const (
+	ActionCreated     Action = "created"
+	ActionRenamed     Action = "renamed"
+	ActionOverwritten Action = "overwritten"
+	ActionSkipped     Action = "skipped"
+)
+
+// ImportOptions configures Import.
+type ImportOptions struct {
+	// As renames the profile of a single-profile bundle.
+	As         string
+	OnConflict Conflict
+	// Backups is the store overwritten profiles are backed up to and bundled
+	// backups are written to; the zero value means the default store of the
+	// config dir.
+	Backups backup.Store
+	// Retention is applied to the backups of overwritten profiles.
+	Retention app.BackupRetention
+}
+
+// ImportResult reports the outcome for one bundled profile.
+type ImportResult struct {
+	Profile string `json:"profile"`
+	Target  string `json:"target"`
+	Action  Action `json:"action"`
+	// Backup is the backup taken of an overwritten profile.
+	Backup string `json:"backup,omitempty"`
+	// Changes lists what an overwrite changed in the existing profile.
+	Changes []profile.Change `json:"changes,omitempty"`
+	// Backups are the bundled backups written to the config dir.
+	Backups []string `json:"backups,omitempty"`
+}
+
+type importPlan struct {
+	source  Profile
+	result  ImportResult
+	staged  string
+	oldPath string
+}
+
+// Import writes the bundled profiles into dir. Every profile is validated
+// with profile.LoadProfile before anything is written, and profiles that are
+// overwritten are backed up first.
+func Import(dir string, b *Bundle, opts ImportOptions) ([]ImportResult, error) {
+	if opts.As != "" {
+		if len(b.Profiles) != 1 {
+			return nil, fmt.Errorf("--as needs a bundle with exactly one profile, got %d", len(b.Profiles))
+		}
+		if err := profile.ValidateName(opts.As); err != nil {
+			return nil, err
+		}
+	}
+
+	plans, err := planImport(dir, b, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		for _, plan := range plans {
+			if plan.staged != "" {
+				_ = os.Remove(plan.staged)
+			}
+		}
+	}()
+	for i := range plans {
+		if plans[i].result.Action == ActionSkipped {
+			continue
+		}
+		staged, err := stage(dir, plans[i].source)
+		if err != nil {
+			return nil, err
+		}
+		plans[i].staged = staged
+	}
+
+	store := opts.Backups.OrDefault(dir)
+	results := make([]ImportResult, 0, len(plans))
+	for i := range plans {
+		plan := &plans[i]
+		if plan.result.Action != ActionSkipped {
+			if err := commit(store, opts.Retention, plan); err != nil {
+				return results, err
+			}
+			backups, err := writeBackups(store, plan.result.Target, plan.source.Backups)
+			plan.result.Backups = backups
+			if err != nil {
+				return append(results, plan.result), err
+			}
+		}
+		results = append(results, plan.result)
+	}
+	return results, nil
+}
+
+func planImport(dir string, b *Bundle, opts ImportOptions) ([]importPlan, error) {
+	taken := make(map[string]bool)
+	exists := func(name string) bool {
+		if taken[name] {
+			return true
+		}
+		return util.FileExists(profile.ProfilePath(dir, name))
+	}
+
+	plans := make([]importPlan, 0, len(b.Profiles))
+	for _, p := range b.Profiles {
+		target := p.Name
+		if opts.As != "" {
+			target = opts.As
+		}
+		result := ImportResult{Profile: p.Name, Target: target, Action: ActionCreated}
+		plan := importPlan{source: p}
+		if exists(target) {
+			switch opts.OnConflict {
+			case ConflictRename:
+				target = freeName(target, exists)
+				result.Target, result.Action = target, ActionRenamed
+			case ConflictOverwrite:
+				if taken[target] {
+					return nil, fmt.Errorf("bundle imports profile %q twice", target)
+				}
+				result.Action = ActionOverwritten
+				plan.oldPath = profile.ProfilePath(dir, target)
+			case ConflictSkip:
+				result.Action = ActionSkipped
+			default:
+				return nil, fmt.Errorf("profile %q already exists (use --on-conflict rename|overwrite|skip)", target)
+			}
+		}
+		taken[target] = true
+		plan.result = result
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+func freeName(name string, exists func(string) bool) string {
+	candidate := name + "-imported"
+	for i := 2; exists(candidate); i++ {
+		candidate = fmt.Sprintf("%s-imported-%d", name, i)
+	}
+	return candidate
+}
+
+// stage writes a bundled profile to a temp file in dir and validates it.
+func stage(dir string, p Profile) (string, error) {
+	tempFile, err := os.CreateTemp(dir, ".tmp-import-")
+	if err != nil {
+		return "", err
+	}
+	tempName := tempFile.Name()
+	_, writeErr := tempFile.Write(p.Data)
+	closeErr := tempFile.Close()
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr == nil {
+		writeErr = os.Chmod(tempName, 0o600)
+	}
+	if writeErr != nil {
+		_ = os.Remove(tempName)
+		return "", writeErr
+	}
+	if _, err := profile.LoadProfile(tempName); err != nil {
+		_ = os.Remove(tempName)
+		return "", fmt.Errorf("bundled profile %q: %w", p.Name, err)
+	}
+	return tempName, nil
+}
+
+func commit(store backup.Store, retention app.BackupRetention, plan *importPlan) error {
+	dir := store.ConfigDir
+	targetPath := profile.ProfilePath(dir, plan.result.Target)
+	if plan.result.Action == ActionOverwritten {
+		oldCfg, err := profile.LoadProfile(plan.oldPath)
+		if err == nil {
+			newCfg, err := profile.LoadProfile(plan.staged)
+			if err != nil {
+				return err
+			}
+			if plan.result.Changes, err = profile.DiffConfigs(oldCfg, newCfg); err != nil {
+				return err
+			}
+		}
+		backupEntry, err := backup.BackupProfileWith(store, plan.result.Target, backup.Options{Reason: backup.ReasonImport, Retention: retention})
+		if err != nil {
+			return fmt.Errorf("backup profile %q: %w", plan.result.Target, err)
+		}
+		plan.result.Backup = backupEntry.Path
+		if info, err := os.Stat(targetPath); err == nil {
+			_ = os.Chmod(plan.staged, info.Mode().Perm())
+		}
+	} else if util.FileExists(targetPath) {
+		return fmt.Errorf("profile %q already exists", plan.result.Target)
+	}
+	if err := os.Rename(plan.staged, targetPath); err != nil {
+		return err
+	}
+	plan.staged = ""
+	util.SyncDir(dir)
+	return nil
+}
+
+// writeBackups restores bundled backups of the target profile into the
+// backup store, keeping existing backups with the same timestamp.
+func writeBackups(store backup.Store, target string, backups []Backup) ([]string, error) {
+	var written []string
+	for _, b := range backups {
+		backupPath, ok, err := backup.AddProfileBackup(store, target, b.Stamp, b.Data)
+		if err != nil {
+			return written, err
+		}
+		if ok {
+			written = append(written, backupPath)
+		}
+	}
+	return written, nil
+}
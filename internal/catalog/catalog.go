@@ -0,0 +1,157 @@
+package catalog
+
+import (
+	"sort"
+
+	"moirai/internal/app"
+	"moirai/internal/profile"
+)
+
+// Source records where a catalog agent was defined.
+type Source string
+
+const (
+	SourceBuiltin Source = "builtin"
+	SourceSchema  Source = "schema"
+	SourceConfig  Source = "config"
+)
+
+// Agent describes one oh-my-opencode agent.
+type Agent struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Required agents should always have a model; doctor reports them and
+	// autofill fills them.
+	Required bool `json:"required"`
+	// ModelClass is the kind of model recommended for the agent, such as
+	// "reasoning", "fast" or "vision".
+	ModelClass string `json:"modelClass,omitempty"`
+	Source     Source `json:"source"`
+}
+
+// Catalog is an ordered list of agents.
+type Catalog struct {
+	Agents []Agent
+}
+
+var builtinMetadata = map[string]Agent{
+	"sisyphus":          {Description: "Primary orchestrator that plans, delegates and finishes tasks", ModelClass: "reasoning"},
+	"prometheus":        {Description: "Planner that interviews you and writes work plans", ModelClass: "reasoning"},
+	"oracle":            {Description: "Consultant for architecture and hard debugging", ModelClass: "reasoning"},
+	"librarian":         {Description: "Researches documentation and open-source code", ModelClass: "general"},
+	"explore":           {Description: "Fast codebase search", ModelClass: "fast"},
+	"multimodal-looker": {Description: "Reads images, PDFs and diagrams", ModelClass: "vision"},
+	"metis":             {Description: "Pre-planning analysis that surfaces hidden requirements", ModelClass: "reasoning"},
+	"momus":             {Description: "Reviews plans for gaps and ambiguity", ModelClass: "reasoning"},
+	"atlas":             {Description: "Executes work plans by driving the todo list", ModelClass: "general"},
+}
+
+// Builtin returns the agents bundled with moirai, in profile.KnownAgents order.
+func Builtin() Catalog {
+	names := profile.KnownAgents()
+	agents := make([]Agent, 0, len(names))
+	for _, name := range names {
+		agent := builtinMetadata[name]
+		agent.Name = name
+		agent.Required = true
+		agent.Source = SourceBuiltin
+		agents = append(agents, agent)
+	}
+	return Catalog{Agents: agents}
+}
+
+// Names returns every agent name in catalog order.
+func (c Catalog) Names() []string {
+	names := make([]string, 0, len(c.Agents))
+	for _, agent := range c.Agents {
+		names = append(names, agent.Name)
+	}
+	return names
+}
+
+// Required returns the names of required agents in catalog order.
+func (c Catalog) Required() []string {
+	names := make([]string, 0, len(c.Agents))
+	for _, agent := range c.Agents {
+		if agent.Required {
+			names = append(names, agent.Name)
+		}
+	}
+	return names
+}
+
+// Lookup returns the agent with the given name.
+func (c Catalog) Lookup(name string) (Agent, bool) {
+	for _, agent := range c.Agents {
+		if agent.Name == name {
+			return agent, true
+		}
+	}
+	return Agent{}, false
+}
+
+// Load builds the catalog for the config dir: the built-in agents (unless
+// replaced), then agents discovered from profile schemas, then moirai.json
+// overrides. Schema discovery is best effort; unreadable schemas are skipped.
+func Load(config app.AppConfig) Catalog {
+	c := Catalog{}
+	if !config.AgentCatalog.Replace {
+		c = Builtin()
+	}
+	if config.AgentCatalog.SchemaDiscoveryEnabled() {
+		for _, discovered := range discoverSchemaAgents(config.ConfigDir) {
+			c.merge(discovered)
+		}
+	}
+	names := make([]string, 0, len(config.AgentCatalog.Agents))
+	for name := range config.AgentCatalog.Agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		spec := config.AgentCatalog.Agents[name]
+		c.override(name, spec)
+	}
+	return c
+}
+
+// merge adds a discovered agent, or fills in a missing description of an
+// agent that is already known.
+func (c *Catalog) merge(agent Agent) {
+	for i := range c.Agents {
+		if c.Agents[i].Name != agent.Name {
+			continue
+		}
+		if c.Agents[i].Description == "" {
+			c.Agents[i].Description = agent.Description
+		}
+		return
+	}
+	c.Agents = append(c.Agents, agent)
+}
+
+func (c *Catalog) override(name string, spec app.AgentSpec) {
+	for i := range c.Agents {
+		if c.Agents[i].Name != name {
+			continue
+		}
+		applySpec(&c.Agents[i], spec)
+		c.Agents[i].Source = SourceConfig
+		return
+	}
+	agent := Agent{Name: name, Source: SourceConfig}
+	applySpec(&agent, spec)
+	c.Agents = append(c.Agents, agent)
+}
+
+func applySpec(agent *Agent, spec app.AgentSpec) {
+	if spec.Description != "" {
+		agent.Description = spec.Description
+	}
+	if spec.Required != nil {
+		agent.Required = *spec.Required
+	}
+	if spec.ModelClass != "" {
+		agent.ModelClass = spec.ModelClass
+	}
+}
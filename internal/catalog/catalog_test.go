@@ -0,0 +1,134 @@
+package catalog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"moirai/internal/app"
+	"moirai/internal/profile"
+	"moirai/internal/schema"
+)
+
+const testSchema = `{
+  "properties": {
+    "agents": {"$ref": "#/definitions/Agents"}
+  },
+  "definitions": {
+    "Agents": {
+      "type": "object",
+      "required": ["sisyphus"],
+      "properties": {
+        "sisyphus": {"$ref": "#/definitions/Agent"},
+        "hephaestus": {"description": "Deep worker", "$ref": "#/definitions/Agent"}
+      }
+    },
+    "Agent": {"type": "object", "description": "Agent override"}
+  }
+}`
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestBuiltinMatchesKnownAgents(t *testing.T) {
+	c := Builtin()
+	if !reflect.DeepEqual(c.Names(), profile.KnownAgents()) {
+		t.Fatalf("unexpected names %v", c.Names())
+	}
+	if !reflect.DeepEqual(c.Required(), profile.KnownAgents()) {
+		t.Fatalf("expected all built-in agents required, got %v", c.Required())
+	}
+	oracle, ok := c.Lookup("oracle")
+	if !ok || oracle.Description == "" || oracle.ModelClass != "reasoning" || oracle.Source != SourceBuiltin {
+		t.Fatalf("unexpected oracle entry %#v", oracle)
+	}
+}
+
+func TestLoadMergesSchemaAndConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "schema.json"), testSchema)
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.alpha"), `{"$schema":"schema.json"}`)
+
+	notRequired := false
+	c := Load(app.AppConfig{
+		ConfigDir: dir,
+		AgentCatalog: app.AgentCatalog{Agents: map[string]app.AgentSpec{
+			"explore": {Required: &notRequired, ModelClass: "cheap"},
+			"custom":  {Description: "My agent"},
+		}},
+	})
+
+	names := c.Names()
+	want := append(profile.KnownAgents(), "hephaestus", "custom")
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("unexpected names %v", names)
+	}
+	heph, _ := c.Lookup("hephaestus")
+	if heph.Description != "Deep worker" || heph.Required || heph.Source != SourceSchema {
+		t.Fatalf("unexpected schema agent %#v", heph)
+	}
+	explore, _ := c.Lookup("explore")
+	if explore.Required || explore.ModelClass != "cheap" || explore.Source != SourceConfig || explore.Description == "" {
+		t.Fatalf("unexpected override %#v", explore)
+	}
+	for _, name := range c.Required() {
+		if name == "explore" || name == "custom" {
+			t.Fatalf("unexpected required agent %q", name)
+		}
+	}
+}
+
+func TestLoadReplaceAndDisableSchema(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "schema.json"), testSchema)
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.alpha"), `{"$schema":"schema.json"}`)
+
+	disabled := false
+	required := true
+	c := Load(app.AppConfig{
+		ConfigDir: dir,
+		AgentCatalog: app.AgentCatalog{
+			Replace:        true,
+			DiscoverSchema: &disabled,
+			Agents:         map[string]app.AgentSpec{"solo": {Required: &required}},
+		},
+	})
+	if !reflect.DeepEqual(c.Names(), []string{"solo"}) || !reflect.DeepEqual(c.Required(), []string{"solo"}) {
+		t.Fatalf("unexpected catalog %#v", c)
+	}
+}
+
+func TestReadSchemaCachesRemoteSchema(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		_, _ = w.Write([]byte(testSchema))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.alpha"), `{"$schema":"`+server.URL+`/schema.json"}`)
+
+	for i := 0; i < 2; i++ {
+		agents := discoverSchemaAgents(dir)
+		if len(agents) != 2 {
+			t.Fatalf("expected 2 schema agents, got %#v", agents)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("expected a single fetch, got %d", requests)
+	}
+
+	server.Close()
+	entries, err := os.ReadDir(schema.CacheDir(dir))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected cached schema, got %v %v", entries, err)
+	}
+}
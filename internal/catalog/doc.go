@@ -0,0 +1,3 @@
+// Package catalog builds the list of oh-my-opencode agents moirai knows
+// about from built-in defaults, profile schemas and moirai.json.
+package catalog
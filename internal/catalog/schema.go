@@ -0,0 +1,124 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"moirai/internal/profile"
+	"moirai/internal/schema"
+)
+
+const maxRefDepth = 8
+
+// discoverSchemaAgents reads the agents declared by every distinct $schema
+// referenced from the profiles in dir.
+func discoverSchemaAgents(dir string) []Agent {
+	agents := make([]Agent, 0)
+	for _, ref := range schemaRefs(dir) {
+		data, _, err := schema.Read(dir, ref)
+		if err != nil {
+			continue
+		}
+		found, err := agentsFromSchema(data)
+		if err != nil {
+			continue
+		}
+		agents = append(agents, found...)
+	}
+	return agents
+}
+
+func schemaRefs(dir string) []string {
+	profiles, err := profile.DiscoverProfiles(dir)
+	if err != nil {
+		return nil
+	}
+	seen := make(map[string]struct{})
+	refs := make([]string, 0)
+	for _, info := range profiles {
+		cfg, err := profile.LoadProfile(info.Path)
+		if err != nil || cfg.Schema == "" {
+			continue
+		}
+		if _, ok := seen[cfg.Schema]; ok {
+			continue
+		}
+		seen[cfg.Schema] = struct{}{}
+		refs = append(refs, cfg.Schema)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// agentsFromSchema returns the agents declared under properties.agents,
+// following local $ref pointers.
+func agentsFromSchema(data []byte) ([]Agent, error) {
+	var root map[string]any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	properties, _ := resolveRef(root, root)["properties"].(map[string]any)
+	agentsNode, ok := properties["agents"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("schema has no agents property")
+	}
+	agentsNode = resolveRef(root, agentsNode)
+	agentProps, ok := agentsNode["properties"].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("schema does not list agents")
+	}
+	required := make(map[string]bool)
+	if list, ok := agentsNode["required"].([]any); ok {
+		for _, item := range list {
+			if name, ok := item.(string); ok {
+				required[name] = true
+			}
+		}
+	}
+
+	names := make([]string, 0, len(agentProps))
+	for name := range agentProps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	agents := make([]Agent, 0, len(names))
+	for _, name := range names {
+		agent := Agent{Name: name, Required: required[name], Source: SourceSchema}
+		if node, ok := agentProps[name].(map[string]any); ok {
+			if description, ok := node["description"].(string); ok {
+				agent.Description = description
+			} else if description, ok := resolveRef(root, node)["description"].(string); ok {
+				agent.Description = description
+			}
+		}
+		agents = append(agents, agent)
+	}
+	return agents, nil
+}
+
+// resolveRef follows "#/..." references within the schema document.
+func resolveRef(root, node map[string]any) map[string]any {
+	for depth := 0; depth < maxRefDepth; depth++ {
+		ref, ok := node["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return node
+		}
+		var current any = root
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+			obj, ok := current.(map[string]any)
+			if !ok {
+				return node
+			}
+			current = obj[part]
+		}
+		next, ok := current.(map[string]any)
+		if !ok {
+			return node
+		}
+		node = next
+	}
+	return node
+}
@@ -0,0 +1,563 @@
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+	"moirai/internal/link"
+	"moirai/internal/profile"
+	"moirai/internal/schema"
+	"moirai/internal/secrets"
+	"moirai/internal/util"
+)
+
+const (
+	activeFileName = "oh-my-opencode.json"
+	profilePrefix  = activeFileName + "."
+	tempPrefix     = ".tmp-"
+)
+
+// Check IDs.
+const (
+	CheckActiveDangling      = "active-dangling"
+	CheckActiveForeign       = "active-foreign"
+	CheckActiveRegularFile   = "active-regular-file"
+	CheckProfileParse        = "profile-parse"
+	CheckProfileExtends      = "profile-extends"
+	CheckReferenceUnresolved = "reference-unresolved"
+	CheckSchemaInvalid       = "schema-invalid"
+	CheckAgentUnknown        = "agent-unknown"
+	CheckAgentMissingModel   = "agent-missing-model"
+	CheckModelUnknown        = "model-unknown"
+	CheckFilePermissions     = "file-permissions"
+	CheckSecretsReadable     = "secrets-readable"
+	CheckStrayTempFiles      = "stray-temp-files"
+)
+
+// DefaultChecks returns the built-in checks in the order they run. File
+// permissions come first so backups taken by later fixes get the fixed mode.
+func DefaultChecks() []Check {
+	return []Check{
+		{ID: CheckFilePermissions, Description: "config file is writable by group or others", Run: checkFilePermissions},
+		{ID: CheckSecretsReadable, Description: "profile with secrets is readable by others", Run: checkSecretsReadable},
+		{ID: CheckActiveDangling, Description: "active symlink points to a missing file", Run: checkActiveDangling},
+		{ID: CheckActiveForeign, Description: "active symlink points outside the profiles", Run: checkActiveForeign},
+		{ID: CheckActiveRegularFile, Description: "active config is a regular file", Run: checkActiveRegularFile},
+		{ID: CheckProfileParse, Description: "profile is not valid JSON", Run: checkProfileParse},
+		{ID: CheckProfileExtends, Description: "profile inheritance cannot be resolved", Run: checkProfileExtends},
+		{ID: CheckReferenceUnresolved, Description: "{env:} or {file:} reference cannot be resolved", Run: checkReferenceUnresolved},
+		{ID: CheckSchemaInvalid, Description: "profile does not match its JSON schema", Run: checkSchemaInvalid},
+		{ID: CheckAgentUnknown, Description: "agent name is not a known agent", Run: checkAgentUnknown},
+		{ID: CheckAgentMissingModel, Description: "required agent has no model", Run: checkAgentMissingModel},
+		{ID: CheckModelUnknown, Description: "model is not in the cached opencode models list", Run: checkModelUnknown},
+		{ID: CheckStrayTempFiles, Description: "temp file left by an interrupted write", Run: checkStrayTempFiles},
+	}
+}
+
+type activeState struct {
+	path   string
+	info   os.FileInfo
+	target string
+	full   string
+}
+
+func readActive(dir string) (*activeState, error) {
+	path := filepath.Join(dir, activeFileName)
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	state := &activeState{path: path, info: info}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return state, nil
+	}
+	target, err := os.Readlink(path)
+	if err != nil {
+		return nil, err
+	}
+	state.target = target
+	state.full = target
+	if !filepath.IsAbs(target) {
+		state.full = filepath.Join(dir, target)
+	}
+	return state, nil
+}
+
+func checkActiveDangling(env *Env) ([]Finding, error) {
+	active, err := readActive(env.ConfigDir)
+	if err != nil || active == nil || active.target == "" {
+		return nil, err
+	}
+	if _, err := os.Stat(active.full); err == nil || !os.IsNotExist(err) {
+		return nil, nil
+	}
+	dir := env.ConfigDir
+	return []Finding{{
+		Severity: SeverityError,
+		Path:     active.path,
+		Message:  fmt.Sprintf("active symlink points to missing %s", active.target),
+		fix: func() (string, error) {
+			return "", link.DeactivateProfile(dir)
+		},
+	}}, nil
+}
+
+func checkActiveForeign(env *Env) ([]Finding, error) {
+	active, err := readActive(env.ConfigDir)
+	if err != nil || active == nil {
+		return nil, err
+	}
+	if active.target == "" {
+		if active.info.Mode().IsRegular() {
+			return nil, nil
+		}
+		return []Finding{{
+			Severity: SeverityError,
+			Path:     active.path,
+			Message:  "active config is neither a file nor a symlink",
+		}}, nil
+	}
+	if _, err := os.Stat(active.full); err != nil {
+		// Dangling links are reported by their own check.
+		return nil, nil
+	}
+	if isProfileTarget(env.ConfigDir, active.full) {
+		return nil, nil
+	}
+	return []Finding{{
+		Severity: SeverityWarning,
+		Path:     active.path,
+		Message:  fmt.Sprintf("active symlink points to %s, which is not a profile in the config dir", active.target),
+	}}, nil
+}
+
+func isProfileTarget(dir, full string) bool {
+	base := filepath.Base(full)
+	if !strings.HasPrefix(base, profilePrefix) || strings.Contains(base, ".bak.") {
+		return false
+	}
+	parent := filepath.Clean(filepath.Dir(full))
+	resolvedDir := filepath.Join(app.StateDir(dir), "resolved")
+	if parent != filepath.Clean(dir) && parent != filepath.Clean(resolvedDir) {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(dir, base))
+	return err == nil
+}
+
+func checkActiveRegularFile(env *Env) ([]Finding, error) {
+	active, err := readActive(env.ConfigDir)
+	if err != nil || active == nil || !active.info.Mode().IsRegular() {
+		return nil, err
+	}
+	return []Finding{{
+		Severity: SeverityWarning,
+		Path:     active.path,
+		Message:  "active config is a regular file, not a profile symlink; apply will back it up and replace it",
+	}}, nil
+}
+
+func checkProfileParse(env *Env) ([]Finding, error) {
+	profiles, err := env.loadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	findings := make([]Finding, 0)
+	for _, state := range profiles {
+		if state.loadErr == nil {
+			continue
+		}
+		findings = append(findings, Finding{
+			Severity: SeverityError,
+			Profile:  state.info.Name,
+			Path:     state.info.Path,
+			Message:  fmt.Sprintf("cannot parse profile: %v", state.loadErr),
+		})
+	}
+	return findings, nil
+}
+
+func checkProfileExtends(env *Env) ([]Finding, error) {
+	profiles, err := env.loadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	findings := make([]Finding, 0)
+	for _, state := range profiles {
+		if state.loadErr != nil {
+			continue
+		}
+		if _, err := profile.ResolveProfile(env.ConfigDir, state.info.Name); err != nil {
+			findings = append(findings, Finding{
+				Severity: SeverityError,
+				Profile:  state.info.Name,
+				Path:     state.info.Path,
+				Message:  err.Error(),
+			})
+		}
+	}
+	return findings, nil
+}
+
+func checkAgentUnknown(env *Env) ([]Finding, error) {
+	profiles, err := env.loadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	knownNames := env.Catalog.Names()
+	known := make(map[string]struct{}, len(knownNames))
+	for _, name := range knownNames {
+		known[name] = struct{}{}
+	}
+	findings := make([]Finding, 0)
+	for _, state := range profiles {
+		if state.cfg == nil {
+			continue
+		}
+		for _, agent := range sortedAgents(state.cfg) {
+			if _, ok := known[agent]; ok {
+				continue
+			}
+			finding := Finding{
+				Severity: SeverityWarning,
+				Profile:  state.info.Name,
+				Path:     state.info.Path,
+				Message:  fmt.Sprintf("unknown agent %q", agent),
+			}
+			if suggestion, ok := suggestAgent(agent, knownNames); ok {
+				finding.Message = fmt.Sprintf("unknown agent %q (did you mean %q?)", agent, suggestion)
+				if _, taken := state.cfg.Agents[suggestion]; !taken {
+					finding.fix = renameAgentFix(env.Backups.OrDefault(env.ConfigDir), env.Retention, state.info, agent, suggestion)
+				}
+			}
+			findings = append(findings, finding)
+		}
+	}
+	return findings, nil
+}
+
+func renameAgentFix(store backup.Store, retention app.BackupRetention, info profile.ProfileInfo, from, to string) func() (string, error) {
+	return func() (string, error) {
+		cfg, err := profile.LoadProfile(info.Path)
+		if err != nil {
+			return "", err
+		}
+		entry, ok := cfg.Agents[from]
+		if !ok {
+			return "", fmt.Errorf("agent %q no longer present", from)
+		}
+		if _, taken := cfg.Agents[to]; taken {
+			return "", fmt.Errorf("agent %q already defined", to)
+		}
+		backupEntry, err := backup.BackupProfileWith(store, info.Name, backup.Options{Reason: backup.ReasonDoctor, Retention: retention})
+		if err != nil {
+			return "", err
+		}
+		backupPath := backupEntry.Path
+		delete(cfg.Agents, from)
+		cfg.Agents[to] = entry
+		if err := profile.SaveProfileAtomic(info.Path, cfg); err != nil {
+			return backupPath, err
+		}
+		return backupPath, nil
+	}
+}
+
+// suggestAgent returns the known agent closest to name when it is a likely
+// typo: at most two edits away and closer than any other candidate.
+func suggestAgent(name string, known []string) (string, bool) {
+	best := ""
+	bestDistance := 3
+	unique := false
+	lowered := strings.ToLower(name)
+	for _, candidate := range known {
+		distance := editDistance(lowered, candidate)
+		switch {
+		case distance < bestDistance:
+			best, bestDistance, unique = candidate, distance, true
+		case distance == bestDistance:
+			unique = false
+		}
+	}
+	return best, best != "" && unique
+}
+
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func checkAgentMissingModel(env *Env) ([]Finding, error) {
+	return forEachResolved(env, func(state profileState, cfg *profile.RootConfig) []Finding {
+		findings := make([]Finding, 0)
+		for _, agent := range profile.MissingAgents(cfg, env.Catalog.Required()) {
+			message := fmt.Sprintf("agent %q has no model", agent)
+			if info, ok := env.Catalog.Lookup(agent); ok && info.ModelClass != "" {
+				message += fmt.Sprintf(" (recommended: %s model)", info.ModelClass)
+			}
+			findings = append(findings, Finding{
+				Severity: SeverityWarning,
+				Profile:  state.info.Name,
+				Path:     state.info.Path,
+				Message:  message,
+			})
+		}
+		return findings
+	})
+}
+
+func checkModelUnknown(env *Env) ([]Finding, error) {
+	if len(env.Models) == 0 {
+		return nil, nil
+	}
+	available := make(map[string]struct{}, len(env.Models))
+	for _, model := range env.Models {
+		available[model] = struct{}{}
+	}
+	return forEachResolved(env, func(state profileState, cfg *profile.RootConfig) []Finding {
+		findings := make([]Finding, 0)
+		for _, agent := range sortedAgents(cfg) {
+			model := strings.TrimSpace(cfg.Agents[agent].Model)
+			if model == "" {
+				continue
+			}
+			if _, ok := available[model]; ok {
+				continue
+			}
+			findings = append(findings, Finding{
+				Severity: SeverityWarning,
+				Profile:  state.info.Name,
+				Path:     state.info.Path,
+				Message:  fmt.Sprintf("agent %q uses model %q, which is not in the cached opencode models list", agent, model),
+			})
+		}
+		return findings
+	})
+}
+
+// forEachResolved calls fn with the resolved config of every profile that
+// loads and resolves; failures are reported by the parse and extends checks.
+func forEachResolved(env *Env, fn func(profileState, *profile.RootConfig) []Finding) ([]Finding, error) {
+	profiles, err := env.loadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	findings := make([]Finding, 0)
+	for _, state := range profiles {
+		if state.loadErr != nil {
+			continue
+		}
+		resolved, err := profile.ResolveProfile(env.ConfigDir, state.info.Name)
+		if err != nil {
+			continue
+		}
+		findings = append(findings, fn(state, resolved.Config)...)
+	}
+	return findings, nil
+}
+
+func sortedAgents(cfg *profile.RootConfig) []string {
+	names := make([]string, 0, len(cfg.Agents))
+	for name := range cfg.Agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func checkFilePermissions(env *Env) ([]Finding, error) {
+	entries, err := util.ListDir(env.ConfigDir)
+	if err != nil {
+		return nil, err
+	}
+	findings := make([]Finding, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if name != activeFileName && name != app.ConfigFileName && !strings.HasPrefix(name, profilePrefix) {
+			continue
+		}
+		path := filepath.Join(env.ConfigDir, name)
+		info, err := os.Lstat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		perm := info.Mode().Perm()
+		if perm&0o022 == 0 {
+			continue
+		}
+		fixed := perm &^ 0o022
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Path:     path,
+			Message:  fmt.Sprintf("mode %04o is writable by group or others", perm),
+			fix: func() (string, error) {
+				return "", os.Chmod(path, fixed)
+			},
+		})
+	}
+	return findings, nil
+}
+
+// checkSecretsReadable warns about world-readable profiles that hold secret
+// values. The fix restricts the file to its owner.
+func checkSecretsReadable(env *Env) ([]Finding, error) {
+	profiles, err := env.loadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	findings := make([]Finding, 0)
+	for _, state := range profiles {
+		path := state.info.Path
+		info, err := os.Lstat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		perm := info.Mode().Perm()
+		if perm&0o004 == 0 {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		found, err := secrets.ScanJSON(data)
+		if err != nil || len(found) == 0 {
+			continue
+		}
+		paths := make([]string, 0, len(found))
+		for _, finding := range found {
+			paths = append(paths, finding.Path)
+		}
+		fixed := perm &^ 0o077
+		findings = append(findings, Finding{
+			Severity: SeverityWarning,
+			Profile:  state.info.Name,
+			Path:     path,
+			Message:  fmt.Sprintf("mode %04o is readable by others and the profile contains secrets (%s)", perm, strings.Join(paths, ", ")),
+			fix: func() (string, error) {
+				return "", os.Chmod(path, fixed)
+			},
+		})
+	}
+	return findings, nil
+}
+
+// checkReferenceUnresolved reports placeholders that would be left in the
+// active config when the profile is applied in the current environment.
+func checkReferenceUnresolved(env *Env) ([]Finding, error) {
+	profiles, err := env.loadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	findings := make([]Finding, 0)
+	for _, state := range profiles {
+		if state.loadErr != nil {
+			continue
+		}
+		data, err := os.ReadFile(state.info.Path)
+		if err != nil {
+			return nil, err
+		}
+		_, refs, err := profile.ExpandReferences(data, profile.ReferenceOptions{BaseDir: env.ConfigDir})
+		if err != nil {
+			continue
+		}
+		for _, ref := range profile.UnresolvedReferences(refs) {
+			findings = append(findings, Finding{
+				Severity: SeverityWarning,
+				Profile:  state.info.Name,
+				Path:     state.info.Path,
+				Message:  fmt.Sprintf("%s at %s is unresolved: %s", ref.Placeholder, ref.Path, ref.Error),
+			})
+		}
+	}
+	return findings, nil
+}
+
+func checkSchemaInvalid(env *Env) ([]Finding, error) {
+	profiles, err := env.loadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	validator := schema.NewValidator(env.ConfigDir, schema.Options{Path: env.SchemaPath})
+	findings := make([]Finding, 0)
+	for _, state := range profiles {
+		if state.loadErr != nil {
+			continue
+		}
+		result, err := validator.Profile(state.info.Name)
+		if err != nil {
+			// Inheritance errors are reported by the profile-extends check.
+			continue
+		}
+		for _, schemaErr := range result.Errors {
+			findings = append(findings, Finding{
+				Severity: SeverityError,
+				Profile:  state.info.Name,
+				Path:     state.info.Path,
+				Message:  schemaErr.String(),
+			})
+		}
+	}
+	return findings, nil
+}
+
+func checkStrayTempFiles(env *Env) ([]Finding, error) {
+	stateDir := app.StateDir(env.ConfigDir)
+	dirs := []string{env.ConfigDir, stateDir, filepath.Join(stateDir, "resolved")}
+	findings := make([]Finding, 0)
+	for _, dir := range dirs {
+		entries, err := util.ListDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasPrefix(entry.Name(), tempPrefix) {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			findings = append(findings, Finding{
+				Severity: SeverityWarning,
+				Path:     path,
+				Message:  "temp file left by an interrupted write",
+				fix: func() (string, error) {
+					return "", os.Remove(path)
+				},
+			})
+		}
+	}
+	return findings, nil
+}
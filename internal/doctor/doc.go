@@ -0,0 +1,3 @@
+// Package doctor runs health checks over a config dir and applies their
+// automatic fixes.
+package doctor
@@ -0,0 +1,147 @@
+package doctor
+
+import (
+	"fmt"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+	"moirai/internal/catalog"
+	"moirai/internal/profile"
+)
+
+// Severity ranks how serious a finding is.
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Finding is a single problem reported by a check.
+type Finding struct {
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	Profile  string   `json:"profile,omitempty"`
+	Path     string   `json:"path,omitempty"`
+	Message  string   `json:"message"`
+	Fixable  bool     `json:"fixable"`
+	Fixed    bool     `json:"fixed,omitempty"`
+	Backup   string   `json:"backup,omitempty"`
+	FixError string   `json:"fixError,omitempty"`
+
+	// fix repairs the problem and returns the path of any backup it took.
+	fix func() (string, error)
+}
+
+// Check inspects the config dir. Check IDs are stable and appear in output.
+type Check struct {
+	ID          string
+	Description string
+	Run         func(env *Env) ([]Finding, error)
+}
+
+// Env is the input shared by all checks.
+type Env struct {
+	ConfigDir string
+	// Profile limits profile checks to one profile when set.
+	Profile string
+	Catalog catalog.Catalog
+	// Models is the cached `opencode models` list; nil skips model checks.
+	Models []string
+	// SchemaPath replaces the profiles' $schema when validating them.
+	SchemaPath string
+	// Backups is the store fixes back profiles up to; the zero value means
+	// the default store of ConfigDir.
+	Backups backup.Store
+	// Retention is applied to the backups fixes take.
+	Retention app.BackupRetention
+
+	loaded   bool
+	profiles []profileState
+}
+
+type profileState struct {
+	info    profile.ProfileInfo
+	cfg     *profile.RootConfig
+	loadErr error
+}
+
+func (env *Env) loadProfiles() ([]profileState, error) {
+	if env.loaded {
+		return env.profiles, nil
+	}
+	infos, err := profile.DiscoverProfiles(env.ConfigDir)
+	if err != nil {
+		return nil, err
+	}
+	states := make([]profileState, 0, len(infos))
+	found := env.Profile == ""
+	for _, info := range infos {
+		if env.Profile != "" && info.Name != env.Profile {
+			continue
+		}
+		found = true
+		cfg, err := profile.LoadProfile(info.Path)
+		states = append(states, profileState{info: info, cfg: cfg, loadErr: err})
+	}
+	if !found {
+		return nil, fmt.Errorf("profile %q not found", env.Profile)
+	}
+	env.loaded = true
+	env.profiles = states
+	return states, nil
+}
+
+// Run executes checks in order and collects their findings.
+func Run(env Env, checks []Check) ([]Finding, error) {
+	if env.Profile != "" {
+		if _, err := env.loadProfiles(); err != nil {
+			return nil, err
+		}
+	}
+	findings := make([]Finding, 0)
+	for _, check := range checks {
+		found, err := check.Run(&env)
+		if err != nil {
+			return nil, fmt.Errorf("check %s: %w", check.ID, err)
+		}
+		for _, finding := range found {
+			finding.Check = check.ID
+			finding.Fixable = finding.fix != nil
+			findings = append(findings, finding)
+		}
+	}
+	return findings, nil
+}
+
+// Fix applies the automatic fix of every fixable finding and records the
+// outcome on it. Fixes that rewrite a profile back it up first.
+func Fix(findings []Finding) {
+	for i := range findings {
+		finding := &findings[i]
+		if finding.fix == nil || finding.Fixed {
+			continue
+		}
+		backupPath, err := finding.fix()
+		if err != nil {
+			finding.FixError = err.Error()
+			continue
+		}
+		finding.Fixed = true
+		finding.Backup = backupPath
+	}
+}
+
+// Failed reports whether any unfixed warning or error remains.
+func Failed(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Fixed {
+			continue
+		}
+		if finding.Severity == SeverityWarning || finding.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
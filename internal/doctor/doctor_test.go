@@ -0,0 +1,242 @@
+package doctor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"moirai/internal/catalog"
+)
+
+func testCatalog(names ...string) catalog.Catalog {
+	c := catalog.Catalog{}
+	for _, name := range names {
+		c.Agents = append(c.Agents, catalog.Agent{Name: name, Required: true})
+	}
+	return c
+}
+
+func writeFile(t *testing.T, path, content string, perm os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		t.Fatalf("chmod %s: %v", path, err)
+	}
+}
+
+func findingsByCheck(findings []Finding) map[string][]Finding {
+	byCheck := make(map[string][]Finding)
+	for _, finding := range findings {
+		byCheck[finding.Check] = append(byCheck[finding.Check], finding)
+	}
+	return byCheck
+}
+
+func TestRunReportsProblemsAcrossConfigDir(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.alpha"), `{"agents":{"oracel":{"model":"gpt-x"},"oracle":{"model":""}}}`, 0o600)
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.broken"), `{"agents":`, 0o600)
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.child"), `{"moirai":{"extends":["missing"]}}`, 0o600)
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.shared"), `{"agents":{"sisyphus":{"model":"known"}}}`, 0o666)
+	writeFile(t, filepath.Join(dir, ".tmp-123"), "partial", 0o600)
+	if err := os.Symlink("oh-my-opencode.json.gone", filepath.Join(dir, "oh-my-opencode.json")); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+
+	findings, err := Run(Env{
+		ConfigDir: dir,
+		Catalog:   testCatalog("oracle", "sisyphus"),
+		Models:    []string{"known"},
+	}, DefaultChecks())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	byCheck := findingsByCheck(findings)
+
+	expectations := map[string]int{
+		CheckActiveDangling:    1,
+		CheckProfileParse:      1,
+		CheckProfileExtends:    1,
+		CheckAgentUnknown:      1,
+		CheckModelUnknown:      1,
+		CheckFilePermissions:   1,
+		CheckStrayTempFiles:    1,
+		CheckActiveForeign:     0,
+		CheckActiveRegularFile: 0,
+	}
+	for check, count := range expectations {
+		if len(byCheck[check]) != count {
+			t.Fatalf("expected %d %s findings, got %#v", count, check, byCheck[check])
+		}
+	}
+	unknown := byCheck[CheckAgentUnknown][0]
+	if !strings.Contains(unknown.Message, `did you mean "oracle"`) || unknown.Fixable {
+		t.Fatalf("expected suggestion without fix (oracle already defined), got %#v", unknown)
+	}
+	if byCheck[CheckActiveDangling][0].Severity != SeverityError || !byCheck[CheckActiveDangling][0].Fixable {
+		t.Fatalf("unexpected dangling finding %#v", byCheck[CheckActiveDangling][0])
+	}
+	if !Failed(findings) {
+		t.Fatal("expected failures")
+	}
+}
+
+func TestFixAppliesFixesWithBackup(t *testing.T) {
+	dir := t.TempDir()
+	profilePath := filepath.Join(dir, "oh-my-opencode.json.alpha")
+	writeFile(t, profilePath, `{"agents":{"sisyphos":{"model":"m"}}}`, 0o664)
+	tempPath := filepath.Join(dir, ".tmp-456")
+	writeFile(t, tempPath, "partial", 0o600)
+	if err := os.Symlink("oh-my-opencode.json.gone", filepath.Join(dir, "oh-my-opencode.json")); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+
+	findings, err := Run(Env{ConfigDir: dir, Catalog: testCatalog("sisyphus")}, DefaultChecks())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	Fix(findings)
+	for _, finding := range findings {
+		if finding.Fixable && !finding.Fixed {
+			t.Fatalf("expected fix to succeed, got %#v", finding)
+		}
+	}
+
+	byCheck := findingsByCheck(findings)
+	renamed := byCheck[CheckAgentUnknown][0]
+	if !renamed.Fixed || renamed.Backup == "" {
+		t.Fatalf("expected agent rename with backup, got %#v", renamed)
+	}
+	backupData, err := os.ReadFile(renamed.Backup)
+	if err != nil || !strings.Contains(string(backupData), "sisyphos") {
+		t.Fatalf("expected backup of original profile, got %q %v", backupData, err)
+	}
+	data, err := os.ReadFile(profilePath)
+	if err != nil {
+		t.Fatalf("read profile: %v", err)
+	}
+	if !strings.Contains(string(data), `"sisyphus"`) || strings.Contains(string(data), "sisyphos") {
+		t.Fatalf("expected agent renamed, got %s", data)
+	}
+	info, err := os.Stat(profilePath)
+	if err != nil || info.Mode().Perm()&0o022 != 0 {
+		t.Fatalf("expected group write removed, got %v %v", info.Mode(), err)
+	}
+	if _, err := os.Stat(tempPath); !os.IsNotExist(err) {
+		t.Fatalf("expected temp file removed, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "oh-my-opencode.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected dangling symlink removed, got %v", err)
+	}
+
+	again, err := Run(Env{ConfigDir: dir, Catalog: testCatalog("sisyphus")}, DefaultChecks())
+	if err != nil {
+		t.Fatalf("Run again: %v", err)
+	}
+	if len(again) != 0 {
+		t.Fatalf("expected clean config dir, got %#v", again)
+	}
+}
+
+func TestSecretsReadableCheck(t *testing.T) {
+	dir := t.TempDir()
+	secretPath := filepath.Join(dir, "oh-my-opencode.json.keys")
+	writeFile(t, secretPath, `{"provider":{"apiKey":"abc123"}}`, 0o644)
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.plain"), `{"agents":{}}`, 0o644)
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.private"), `{"token":"abc123"}`, 0o600)
+
+	checks := []Check{{ID: CheckSecretsReadable, Run: checkSecretsReadable}}
+	findings, err := Run(Env{ConfigDir: dir}, checks)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(findings) != 1 || findings[0].Profile != "keys" || !findings[0].Fixable {
+		t.Fatalf("unexpected findings %#v", findings)
+	}
+	if !strings.Contains(findings[0].Message, "provider.apiKey") || strings.Contains(findings[0].Message, "abc123") {
+		t.Fatalf("unexpected message %q", findings[0].Message)
+	}
+
+	Fix(findings)
+	info, err := os.Stat(secretPath)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected mode 0600 after fix, got %v %v", info.Mode(), err)
+	}
+}
+
+func TestReferenceUnresolvedCheck(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MOIRAI_TEST_SET", "value")
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.alpha"), `{"a":"{env:MOIRAI_TEST_SET}","b":"{env:MOIRAI_TEST_UNSET}","c":"{file:missing.txt}"}`, 0o600)
+
+	checks := []Check{{ID: CheckReferenceUnresolved, Run: checkReferenceUnresolved}}
+	findings, err := Run(Env{ConfigDir: dir}, checks)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(findings) != 2 || findings[0].Profile != "alpha" {
+		t.Fatalf("unexpected findings %#v", findings)
+	}
+	if !strings.Contains(findings[0].Message, "{env:MOIRAI_TEST_UNSET} at b") || !strings.Contains(findings[1].Message, "{file:missing.txt} at c") {
+		t.Fatalf("unexpected messages %#v", findings)
+	}
+}
+
+func TestSchemaInvalidCheck(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "schema.json"), `{"properties":{"agents":{"additionalProperties":{"properties":{"temperature":{"maximum":2}}}}}}`, 0o600)
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.alpha"), `{"$schema":"schema.json","agents":{"oracle":{"temperature":5}}}`, 0o600)
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.beta"), `{"agents":{"oracle":{"temperature":5}}}`, 0o600)
+
+	checks := []Check{{ID: CheckSchemaInvalid, Run: checkSchemaInvalid}}
+	findings, err := Run(Env{ConfigDir: dir}, checks)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(findings) != 1 || findings[0].Profile != "alpha" || findings[0].Message != "/agents/oracle/temperature: must be <= 2" {
+		t.Fatalf("unexpected findings %#v", findings)
+	}
+
+	findings, err = Run(Env{ConfigDir: dir, SchemaPath: "schema.json"}, checks)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(findings) != 2 || findings[1].Profile != "beta" {
+		t.Fatalf("expected schema override to cover beta, got %#v", findings)
+	}
+}
+
+func TestRunLimitsProfileChecks(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.alpha"), `{"agents":{}}`, 0o600)
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.broken"), `{`, 0o600)
+
+	findings, err := Run(Env{ConfigDir: dir, Profile: "alpha", Catalog: testCatalog("oracle")}, DefaultChecks())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(findings) != 1 || findings[0].Check != CheckAgentMissingModel || findings[0].Profile != "alpha" {
+		t.Fatalf("unexpected findings %#v", findings)
+	}
+
+	if _, err := Run(Env{ConfigDir: dir, Profile: "missing"}, DefaultChecks()); err == nil {
+		t.Fatal("expected error for unknown profile")
+	}
+}
+
+func TestSuggestAgent(t *testing.T) {
+	known := []string{"oracle", "explore", "metis", "momus"}
+	if got, ok := suggestAgent("Oracel", known); !ok || got != "oracle" {
+		t.Fatalf("expected oracle, got %q %v", got, ok)
+	}
+	if _, ok := suggestAgent("completely-different", known); ok {
+		t.Fatal("expected no suggestion")
+	}
+	// One edit from metis, two from momus.
+	if got, ok := suggestAgent("metus", known); !ok || got != "metis" {
+		t.Fatalf("expected metis, got %q %v", got, ok)
+	}
+}
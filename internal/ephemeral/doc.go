@@ -0,0 +1,3 @@
+// Package ephemeral builds private config homes in which a single process
+// sees one profile as the active config, leaving the global symlink alone.
+package ephemeral
@@ -0,0 +1,139 @@
+package ephemeral
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"moirai/internal/lock"
+	"moirai/internal/profile"
+)
+
+const (
+	activeFileName = "oh-my-opencode.json"
+	// opencodeDirName is the config dir name under XDG_CONFIG_HOME.
+	opencodeDirName = "opencode"
+)
+
+// Env is a temporary XDG config home for one child process.
+type Env struct {
+	// ConfigHome is the value to pass as XDG_CONFIG_HOME.
+	ConfigHome string
+	// ConfigDir is the opencode config dir inside ConfigHome.
+	ConfigDir string
+	// Profile is the profile materialized as the active config.
+	Profile string
+}
+
+// Prepare creates a temp config home that mirrors the config home around
+// configDir through symlinks, except that oh-my-opencode.json is a private
+// copy of the resolved profile with the agent model overrides applied and
+// {env:} and {file:} references expanded.
+// Callers must call Cleanup when the child process has exited.
+func Prepare(configDir, profileName string, overrides map[string]string) (*Env, error) {
+	resolved, err := profile.ResolveProfile(configDir, profileName)
+	if err != nil {
+		return nil, err
+	}
+	data := resolved.Data
+	if len(overrides) > 0 {
+		agents := make([]string, 0, len(overrides))
+		for agent := range overrides {
+			agents = append(agents, agent)
+		}
+		sort.Strings(agents)
+		for _, agent := range agents {
+			if _, err := profile.SetAgentModel(resolved.Config, agent, overrides[agent]); err != nil {
+				return nil, err
+			}
+		}
+		data, err = json.MarshalIndent(resolved.Config, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, '\n')
+	}
+	if data, _, err = profile.ExpandReferences(data, profile.ReferenceOptions{BaseDir: configDir}); err != nil {
+		return nil, err
+	}
+
+	home, err := os.MkdirTemp("", "moirai-exec-")
+	if err != nil {
+		return nil, err
+	}
+	env := &Env{
+		ConfigHome: home,
+		ConfigDir:  filepath.Join(home, opencodeDirName),
+		Profile:    profileName,
+	}
+	if err := env.populate(filepath.Clean(configDir), data); err != nil {
+		_ = env.Cleanup()
+		return nil, err
+	}
+	return env, nil
+}
+
+func (e *Env) populate(configDir string, active []byte) error {
+	// Other tools started by the child also read XDG_CONFIG_HOME, so the
+	// siblings of the opencode dir are linked too.
+	if err := linkEntries(filepath.Dir(configDir), e.ConfigHome, func(name string) bool {
+		return name == filepath.Base(configDir) || name == opencodeDirName
+	}); err != nil {
+		return err
+	}
+	if err := os.Mkdir(e.ConfigDir, 0o700); err != nil {
+		return err
+	}
+	if err := linkEntries(configDir, e.ConfigDir, func(name string) bool {
+		return name == activeFileName || name == lock.FileName || strings.HasPrefix(name, ".tmp-")
+	}); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(e.ConfigDir, activeFileName), active, 0o600)
+}
+
+// linkEntries creates a symlink in dst for every entry of src not skipped.
+func linkEntries(src, dst string, skip func(name string) bool) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if skip(name) {
+			continue
+		}
+		if err := os.Symlink(filepath.Join(src, name), filepath.Join(dst, name)); err != nil {
+			return fmt.Errorf("mirror %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Cleanup removes the temp config home. Symlinks are removed without
+// touching their targets.
+func (e *Env) Cleanup() error {
+	if e == nil || e.ConfigHome == "" {
+		return nil
+	}
+	return os.RemoveAll(e.ConfigHome)
+}
+
+// Environ returns base with XDG_CONFIG_HOME pointing at the temp config
+// home and MOIRAI_PROFILE naming the profile.
+func (e *Env) Environ(base []string) []string {
+	out := make([]string, 0, len(base)+2)
+	for _, kv := range base {
+		if strings.HasPrefix(kv, "XDG_CONFIG_HOME=") || strings.HasPrefix(kv, "MOIRAI_PROFILE=") {
+			continue
+		}
+		out = append(out, kv)
+	}
+	return append(out, "XDG_CONFIG_HOME="+e.ConfigHome, "MOIRAI_PROFILE="+e.Profile)
+}
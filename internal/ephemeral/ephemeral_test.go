@@ -0,0 +1,76 @@
+package ephemeral
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrepareMirrorsConfigHome(t *testing.T) {
+	home := t.TempDir()
+	configDir := filepath.Join(home, "opencode")
+	if err := os.MkdirAll(configDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(home, "git"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	files := map[string]string{
+		"opencode.json":                 `{"theme":"dark"}`,
+		"oh-my-opencode.json.base":      `{"agents":{"oracle":{"model":"base"}}}`,
+		"oh-my-opencode.json.child":     `{"moirai":{"extends":["base"]},"agents":{"explore":{"model":"fast"}}}`,
+		"oh-my-opencode.json.unrelated": `{}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(configDir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := os.Symlink("oh-my-opencode.json.unrelated", filepath.Join(configDir, "oh-my-opencode.json")); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+
+	env, err := Prepare(configDir, "child", map[string]string{"oracle": "o3"})
+	if err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(env.ConfigDir, "oh-my-opencode.json"))
+	if err != nil {
+		t.Fatalf("read active: %v", err)
+	}
+	content := string(data)
+	if !strings.Contains(content, `"o3"`) || !strings.Contains(content, `"fast"`) || strings.Contains(content, `"moirai"`) {
+		t.Fatalf("unexpected active config:\n%s", content)
+	}
+	if target, err := os.Readlink(filepath.Join(env.ConfigDir, "opencode.json")); err != nil || target != filepath.Join(configDir, "opencode.json") {
+		t.Fatalf("expected opencode.json mirrored, got %q %v", target, err)
+	}
+	if _, err := os.Lstat(filepath.Join(env.ConfigHome, "git")); err != nil {
+		t.Fatalf("expected sibling config dirs mirrored: %v", err)
+	}
+	environ := env.Environ([]string{"XDG_CONFIG_HOME=/old", "PATH=/bin"})
+	if strings.Join(environ, " ") != "PATH=/bin XDG_CONFIG_HOME="+env.ConfigHome+" MOIRAI_PROFILE=child" {
+		t.Fatalf("unexpected environment %v", environ)
+	}
+
+	if err := env.Cleanup(); err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+	if _, err := os.Stat(env.ConfigHome); !os.IsNotExist(err) {
+		t.Fatalf("expected temp home removed, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(configDir, "opencode.json")); err != nil {
+		t.Fatalf("cleanup must not touch mirrored files: %v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(configDir, "oh-my-opencode.json")); err != nil || target != "oh-my-opencode.json.unrelated" {
+		t.Fatalf("global symlink changed: %q %v", target, err)
+	}
+}
+
+func TestPrepareUnknownProfile(t *testing.T) {
+	if _, err := Prepare(t.TempDir(), "missing", nil); err == nil {
+		t.Fatal("expected error for missing profile")
+	}
+}
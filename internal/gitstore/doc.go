@@ -0,0 +1,4 @@
+// Package gitstore keeps the profiles of a config dir in a git repository
+// managed by moirai. The repository lives in the moirai state dir and uses
+// the config dir as its work tree, tracking only profile files.
+package gitstore
@@ -0,0 +1,108 @@
+package gitstore
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogEntry is one commit of the profile history.
+type LogEntry struct {
+	Hash    string    `json:"hash"`
+	Time    time.Time `json:"time"`
+	Author  string    `json:"author"`
+	Subject string    `json:"subject"`
+	// Op and Profile come from the commit trailers moirai writes; commits
+	// made elsewhere leave them empty.
+	Op      string `json:"op,omitempty"`
+	Profile string `json:"profile,omitempty"`
+}
+
+// logFormat separates fields with US and records with RS, which cannot
+// occur in the fields.
+const logFormat = "%H%x1f%aI%x1f%an%x1f%s%x1f%(trailers:key=" + trailerOp + ",valueonly,separator=%x2C)%x1f%(trailers:key=" + trailerProfile + ",valueonly,separator=%x2C)%x1e"
+
+// Log returns the commits of the history, newest first. With a profile, it
+// lists the commits that changed the profile's file or record an operation
+// on it, such as an apply. A limit of zero or less lists every commit.
+func (s *Store) Log(profileName string, limit int) ([]LogEntry, error) {
+	if _, err := s.revParse("HEAD"); err != nil {
+		return []LogEntry{}, nil
+	}
+	out, err := s.git("log", "--format="+logFormat, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	touched := map[string]bool{}
+	if profileName != "" {
+		paths, err := s.git("log", "--format=%H", "HEAD", "--", profilePrefix+profileName)
+		if err != nil {
+			return nil, err
+		}
+		for _, hash := range strings.Fields(paths) {
+			touched[hash] = true
+		}
+	}
+
+	entries := make([]LogEntry, 0)
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.Split(strings.TrimSpace(record), "\x1f")
+		if len(fields) != 6 {
+			continue
+		}
+		entry := LogEntry{
+			Hash:    fields[0],
+			Author:  fields[2],
+			Subject: fields[3],
+			Op:      strings.TrimSpace(fields[4]),
+			Profile: strings.TrimSpace(fields[5]),
+		}
+		if parsed, err := time.Parse(time.RFC3339, fields[1]); err == nil {
+			entry.Time = parsed
+		}
+		if profileName != "" && !touched[entry.Hash] && entry.Profile != profileName {
+			continue
+		}
+		entries = append(entries, entry)
+		if limit > 0 && len(entries) == limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// Show returns the content of the named profile at rev. Relative revisions
+// such as HEAD~2 and the short hashes printed by Log are accepted.
+func (s *Store) Show(profileName, rev string) ([]byte, error) {
+	hash, err := s.revParse(rev)
+	if err != nil {
+		return nil, err
+	}
+	out, err := s.git("show", hash+":"+profilePrefix+profileName)
+	if err != nil {
+		return nil, fmt.Errorf("profile %q does not exist at %s", profileName, shortHash(hash))
+	}
+	return []byte(out), nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
+
+// count returns the number of commits in a rev-list range.
+func (s *Store) count(revRange string) (int, error) {
+	out, err := s.git("rev-list", "--count", revRange)
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		return 0, errors.New("git rev-list: unexpected output")
+	}
+	return n, nil
+}
@@ -0,0 +1,190 @@
+package gitstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"moirai/internal/app"
+	"moirai/internal/util"
+)
+
+const (
+	activeFileName = "oh-my-opencode.json"
+	profilePrefix  = activeFileName + "."
+	// gitDirName is the repository directory inside the moirai state dir.
+	gitDirName = "git"
+)
+
+// Trailers recorded in commit messages.
+const (
+	trailerOp      = "Moirai-Op"
+	trailerProfile = "Moirai-Profile"
+	trailerJournal = "Moirai-Journal"
+)
+
+// excludeRules make the config dir look like a repository of profiles only:
+// the active config, backups, temp files and moirai's own state stay out.
+const excludeRules = `/*
+!/oh-my-opencode.json.?*
+*.bak.*
+`
+
+// ErrDisabled is returned when the git store is not enabled in moirai.json.
+var ErrDisabled = errors.New("git store is not enabled; set \"git\": {\"enabled\": true} in " + app.ConfigFileName)
+
+var runCommand = util.RunCommand
+
+// Store is the git repository of one config dir.
+type Store struct {
+	dir      string
+	gitDir   string
+	settings app.GitSettings
+}
+
+// Open returns the git store of the config dir dir with the caller's
+// settings, creating the repository on first use. It returns ErrDisabled
+// unless settings enable the store.
+func Open(dir string, settings app.GitSettings) (*Store, error) {
+	if !settings.Enabled {
+		return nil, ErrDisabled
+	}
+	if settings.Branch == "" {
+		settings.Branch = app.DefaultGitBranch
+	}
+	s := &Store{
+		dir:      dir,
+		gitDir:   filepath.Join(app.StateDir(dir), gitDirName),
+		settings: settings,
+	}
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GitDir returns the repository directory of the store.
+func (s *Store) GitDir() string {
+	return s.gitDir
+}
+
+func (s *Store) init() error {
+	if _, err := os.Stat(filepath.Join(s.gitDir, "HEAD")); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.MkdirAll(s.gitDir, 0o700); err != nil {
+		return err
+	}
+	if _, err := s.git("init", "--quiet", "--initial-branch="+s.settings.Branch); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.gitDir, "info", "exclude"), []byte(excludeRules), 0o644); err != nil {
+		return err
+	}
+	// Commits need an identity; fall back to a local one when the user has
+	// not configured theirs.
+	if _, err := s.git("config", "user.email"); err != nil {
+		if _, err := s.git("config", "user.name", "moirai"); err != nil {
+			return err
+		}
+		if _, err := s.git("config", "user.email", "moirai@localhost"); err != nil {
+			return err
+		}
+	}
+	_, err := s.commit(Change{Op: "init"}, true)
+	return err
+}
+
+// Change describes the operation a commit records.
+type Change struct {
+	Op      string
+	Profile string
+	// Journal is the ID of the journal entry of the operation, if any.
+	Journal string
+}
+
+func (c Change) message() string {
+	subject := c.Op
+	if c.Profile != "" {
+		subject += " " + c.Profile
+	}
+	lines := []string{subject, ""}
+	lines = append(lines, trailerOp+": "+c.Op)
+	if c.Profile != "" {
+		lines = append(lines, trailerProfile+": "+c.Profile)
+	}
+	if c.Journal != "" {
+		lines = append(lines, trailerJournal+": "+c.Journal)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Commit records change. With a profile, only that profile's file is
+// committed, so unrelated edits wait for their own operation or `moirai
+// sync`; otherwise every profile change is. A commit is made even when the
+// file did not change, so applies show up in the history too. It returns
+// the new commit hash.
+func Commit(dir string, settings app.GitSettings, change Change) (string, error) {
+	s, err := Open(dir, settings)
+	if err != nil {
+		return "", err
+	}
+	return s.commit(change, true)
+}
+
+func (s *Store) commit(change Change, allowEmpty bool) (string, error) {
+	if change.Profile != "" {
+		if err := s.stage(profilePrefix + change.Profile); err != nil {
+			return "", err
+		}
+	} else if _, err := s.git("add", "--all"); err != nil {
+		return "", err
+	}
+	if !allowEmpty {
+		if _, err := s.git("diff", "--cached", "--quiet"); err == nil {
+			return "", nil
+		}
+	}
+	if _, err := s.git("commit", "--quiet", "--no-verify", "--no-gpg-sign", "--allow-empty", "--message", change.message()); err != nil {
+		return "", err
+	}
+	return s.revParse("HEAD")
+}
+
+// stage adds the current state of a file, including its removal, to the
+// index. A file that neither exists nor was ever committed is skipped.
+func (s *Store) stage(name string) error {
+	if _, err := os.Lstat(filepath.Join(s.dir, name)); os.IsNotExist(err) {
+		if _, err := s.git("ls-files", "--error-unmatch", "--", name); err != nil {
+			return nil
+		}
+	}
+	_, err := s.git("add", "--all", "--", name)
+	return err
+}
+
+func (s *Store) revParse(rev string) (string, error) {
+	out, err := s.git("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %q", rev)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// git runs a git command against the store and returns its output.
+func (s *Store) git(args ...string) (string, error) {
+	full := append([]string{"--git-dir=" + s.gitDir, "--work-tree=" + s.dir}, args...)
+	stdout, stderr, err := runCommand(context.Background(), "git", full...)
+	if err != nil {
+		if msg := strings.TrimSpace(stderr); msg != "" {
+			return stdout, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return stdout, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout, nil
+}
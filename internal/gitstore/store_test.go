@@ -0,0 +1,267 @@
+package gitstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"moirai/internal/app"
+)
+
+// setupGit isolates git from the user's configuration and skips the test
+// when git is not installed.
+func setupGit(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+}
+
+func writeConfigDir(t *testing.T, remote string, profiles map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	config := `{"git": {"enabled": true, "remote": "` + filepath.ToSlash(remote) + `"}}`
+	if err := os.WriteFile(filepath.Join(dir, app.ConfigFileName), []byte(config), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	for name, content := range profiles {
+		writeProfile(t, dir, name, content)
+	}
+	return dir
+}
+
+// settingsOf returns the git settings moirai.json in dir configures.
+func settingsOf(t *testing.T, dir string) app.GitSettings {
+	t.Helper()
+	config, err := app.LoadConfig(dir, nil)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	return config.Git
+}
+
+func writeProfile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, profilePrefix+name), []byte(content), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+}
+
+func newBareRemote(t *testing.T) string {
+	t.Helper()
+	remote := filepath.Join(t.TempDir(), "profiles.git")
+	if _, stderr, err := runCommand(context.Background(), "git", "init", "--quiet", "--bare", remote); err != nil {
+		t.Fatalf("git init --bare: %v %s", err, stderr)
+	}
+	return remote
+}
+
+func TestOpenRequiresEnabledStore(t *testing.T) {
+	if _, err := Open(t.TempDir(), app.GitSettings{}); !errors.Is(err, ErrDisabled) {
+		t.Fatalf("expected ErrDisabled, got %v", err)
+	}
+}
+
+func TestOpenUsesCallerSettings(t *testing.T) {
+	setupGit(t)
+	dir := t.TempDir()
+	writeProfile(t, dir, "alpha", `{"a":1}`)
+	// No moirai.json: the caller's settings alone enable the store.
+	store, err := Open(dir, app.GitSettings{Enabled: true})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	branch, err := store.git("symbolic-ref", "--short", "HEAD")
+	if err != nil || strings.TrimSpace(branch) != app.DefaultGitBranch {
+		t.Fatalf("expected branch %s, got %q %v", app.DefaultGitBranch, branch, err)
+	}
+}
+
+func TestCommitTracksOnlyProfiles(t *testing.T) {
+	setupGit(t)
+	dir := writeConfigDir(t, "", map[string]string{"alpha": `{"a":1}`})
+	for _, name := range []string{"oh-my-opencode.json", "opencode.json", "oh-my-opencode.json.alpha.bak.20240101-000000"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	store, err := Open(dir, settingsOf(t, dir))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	files, err := store.git("ls-files")
+	if err != nil {
+		t.Fatalf("ls-files: %v", err)
+	}
+	if strings.TrimSpace(files) != profilePrefix+"alpha" {
+		t.Fatalf("expected only the profile to be tracked, got %q", files)
+	}
+}
+
+func TestCommitLogAndShow(t *testing.T) {
+	setupGit(t)
+	dir := writeConfigDir(t, "", map[string]string{"alpha": `{"a":1}`, "beta": `{"b":1}`})
+	if _, err := Open(dir, settingsOf(t, dir)); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	writeProfile(t, dir, "alpha", `{"a":2}`)
+	writeProfile(t, dir, "beta", `{"b":2}`)
+	if _, err := Commit(dir, settingsOf(t, dir), Change{Op: "save", Profile: "alpha", Journal: "abcd1234"}); err != nil {
+		t.Fatalf("Commit(save): %v", err)
+	}
+	if _, err := Commit(dir, settingsOf(t, dir), Change{Op: "apply", Profile: "alpha"}); err != nil {
+		t.Fatalf("Commit(apply): %v", err)
+	}
+
+	store, err := Open(dir, settingsOf(t, dir))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	log, err := store.Log("alpha", 0)
+	if err != nil {
+		t.Fatalf("Log: %v", err)
+	}
+	if len(log) != 3 || log[0].Op != "apply" || log[1].Op != "save" || log[1].Profile != "alpha" || log[2].Op != "init" {
+		t.Fatalf("unexpected alpha log: %+v", log)
+	}
+	if log[1].Subject != "save alpha" || log[1].Time.IsZero() {
+		t.Fatalf("unexpected save commit: %+v", log[1])
+	}
+	if limited, _ := store.Log("alpha", 1); len(limited) != 1 {
+		t.Fatalf("expected limit to apply, got %+v", limited)
+	}
+
+	// The save committed only alpha; beta's edit is still pending.
+	betaLog, err := store.Log("beta", 0)
+	if err != nil || len(betaLog) != 1 {
+		t.Fatalf("expected only the initial commit for beta, got %+v %v", betaLog, err)
+	}
+	data, err := store.Show("beta", "HEAD")
+	if err != nil || string(data) != `{"b":1}` {
+		t.Fatalf("Show(beta, HEAD) = %q, %v", data, err)
+	}
+	data, err = store.Show("alpha", log[2].Hash[:8])
+	if err != nil || string(data) != `{"a":1}` {
+		t.Fatalf("Show(alpha, init) = %q, %v", data, err)
+	}
+	if _, err := store.Show("alpha", "no-such-rev"); err == nil {
+		t.Fatal("expected an unknown revision to fail")
+	}
+}
+
+func TestCommitRecordsDeletedProfile(t *testing.T) {
+	setupGit(t)
+	dir := writeConfigDir(t, "", map[string]string{"alpha": `{}`})
+	if _, err := Open(dir, settingsOf(t, dir)); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, profilePrefix+"alpha")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := Commit(dir, settingsOf(t, dir), Change{Op: "delete", Profile: "alpha"}); err != nil {
+		t.Fatalf("Commit(delete): %v", err)
+	}
+	store, _ := Open(dir, settingsOf(t, dir))
+	if files, _ := store.git("ls-files"); strings.TrimSpace(files) != "" {
+		t.Fatalf("expected the profile to be removed from the index, got %q", files)
+	}
+	// A profile that was never committed is skipped rather than failing.
+	if _, err := Commit(dir, settingsOf(t, dir), Change{Op: "delete", Profile: "ghost"}); err != nil {
+		t.Fatalf("Commit(ghost): %v", err)
+	}
+}
+
+func TestSyncWithBareRemote(t *testing.T) {
+	setupGit(t)
+	remote := newBareRemote(t)
+
+	first := writeConfigDir(t, remote, map[string]string{"alpha": `{"a":1}`})
+	firstStore, err := Open(first, settingsOf(t, first))
+	if err != nil {
+		t.Fatalf("Open(first, settingsOf(t, first)): %v", err)
+	}
+	res, err := firstStore.Sync()
+	if err != nil {
+		t.Fatalf("Sync(first): %v", err)
+	}
+	if res.Pushed != 1 || res.Pulled != 0 {
+		t.Fatalf("unexpected first sync: %+v", res)
+	}
+
+	second := writeConfigDir(t, remote, map[string]string{"beta": `{"b":1}`})
+	secondStore, err := Open(second, settingsOf(t, second))
+	if err != nil {
+		t.Fatalf("Open(second, settingsOf(t, second)): %v", err)
+	}
+	res, err = secondStore.Sync()
+	if err != nil {
+		t.Fatalf("Sync(second): %v", err)
+	}
+	if res.Pulled != 1 || len(res.Updated) != 1 || res.Updated[0] != "alpha" || res.Pushed == 0 {
+		t.Fatalf("unexpected second sync: %+v", res)
+	}
+	if data, err := os.ReadFile(filepath.Join(second, profilePrefix+"alpha")); err != nil || string(data) != `{"a":1}` {
+		t.Fatalf("expected alpha to be pulled, got %q %v", data, err)
+	}
+
+	// Uncommitted edits are snapshotted before syncing.
+	writeProfile(t, first, "alpha", `{"a":2}`)
+	res, err = firstStore.Sync()
+	if err != nil {
+		t.Fatalf("Sync(first, again): %v", err)
+	}
+	if res.Snapshot == "" || res.Pulled == 0 || res.Pushed == 0 {
+		t.Fatalf("unexpected third sync: %+v", res)
+	}
+	if _, err := os.Stat(filepath.Join(first, profilePrefix+"beta")); err != nil {
+		t.Fatalf("expected beta to be pulled: %v", err)
+	}
+}
+
+func TestSyncAbortsOnConflict(t *testing.T) {
+	setupGit(t)
+	remote := newBareRemote(t)
+	first := writeConfigDir(t, remote, map[string]string{"alpha": `{"a":1}`})
+	firstStore, _ := Open(first, settingsOf(t, first))
+	if _, err := firstStore.Sync(); err != nil {
+		t.Fatalf("Sync(first): %v", err)
+	}
+	second := writeConfigDir(t, remote, nil)
+	secondStore, _ := Open(second, settingsOf(t, second))
+	if _, err := secondStore.Sync(); err != nil {
+		t.Fatalf("Sync(second): %v", err)
+	}
+
+	writeProfile(t, first, "alpha", `{"a":"first"}`)
+	writeProfile(t, second, "alpha", `{"a":"second"}`)
+	if _, err := firstStore.Sync(); err != nil {
+		t.Fatalf("Sync(first, edit): %v", err)
+	}
+	if _, err := secondStore.Sync(); err == nil {
+		t.Fatal("expected conflicting edits to fail the sync")
+	}
+	if data, _ := os.ReadFile(filepath.Join(second, profilePrefix+"alpha")); string(data) != `{"a":"second"}` {
+		t.Fatalf("expected the local profile to be left alone, got %q", data)
+	}
+}
+
+func TestSyncRequiresRemote(t *testing.T) {
+	setupGit(t)
+	dir := writeConfigDir(t, "", nil)
+	store, err := Open(dir, settingsOf(t, dir))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := store.Sync(); err == nil || !strings.Contains(err.Error(), "remote") {
+		t.Fatalf("expected missing remote error, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package gitstore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// remoteName is the git remote moirai manages for the configured URL.
+const remoteName = "origin"
+
+// SyncResult reports what Sync exchanged with the remote.
+type SyncResult struct {
+	Remote string `json:"remote"`
+	Branch string `json:"branch"`
+	// Snapshot is the commit made for profile changes that no operation had
+	// committed yet, if there were any.
+	Snapshot string `json:"snapshot,omitempty"`
+	Pulled   int    `json:"pulled"`
+	Pushed   int    `json:"pushed"`
+	// Updated lists the profiles the pull changed.
+	Updated []string `json:"updated"`
+}
+
+// Sync commits pending profile changes, merges the remote branch and pushes
+// the result. When the merge conflicts, it is aborted and the profiles are
+// left as they were.
+func (s *Store) Sync() (SyncResult, error) {
+	res := SyncResult{Remote: s.settings.Remote, Branch: s.settings.Branch, Updated: []string{}}
+	if s.settings.Remote == "" {
+		return res, fmt.Errorf("no git remote configured; set \"git\": {\"remote\": <url>} in moirai.json")
+	}
+	if err := s.ensureRemote(); err != nil {
+		return res, err
+	}
+	snapshot, err := s.commit(Change{Op: "sync"}, false)
+	if err != nil {
+		return res, err
+	}
+	res.Snapshot = snapshot
+
+	if _, err := s.git("fetch", "--quiet", remoteName); err != nil {
+		return res, err
+	}
+	tracking := "refs/remotes/" + remoteName + "/" + s.settings.Branch
+	if _, err := s.revParse(tracking); err == nil {
+		if res.Pulled, err = s.count("HEAD.." + tracking); err != nil {
+			return res, err
+		}
+		if res.Pulled > 0 {
+			before, err := s.revParse("HEAD")
+			if err != nil {
+				return res, err
+			}
+			if _, err := s.git("merge", "--quiet", "--no-edit", "--allow-unrelated-histories", tracking); err != nil {
+				_, _ = s.git("merge", "--abort")
+				return res, fmt.Errorf("local and remote profile histories conflict, nothing was changed: %w", err)
+			}
+			if res.Updated, err = s.changedProfiles(before, "HEAD"); err != nil {
+				return res, err
+			}
+		}
+		if res.Pushed, err = s.count(tracking + "..HEAD"); err != nil {
+			return res, err
+		}
+	} else if res.Pushed, err = s.count("HEAD"); err != nil {
+		return res, err
+	}
+
+	if res.Pushed > 0 {
+		if _, err := s.git("push", "--quiet", remoteName, "HEAD:refs/heads/"+s.settings.Branch); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+func (s *Store) ensureRemote() error {
+	current, err := s.git("remote", "get-url", remoteName)
+	if err != nil {
+		_, err := s.git("remote", "add", remoteName, s.settings.Remote)
+		return err
+	}
+	if strings.TrimSpace(current) != s.settings.Remote {
+		_, err := s.git("remote", "set-url", remoteName, s.settings.Remote)
+		return err
+	}
+	return nil
+}
+
+// changedProfiles returns the names of the profiles that differ between two
+// revisions.
+func (s *Store) changedProfiles(from, to string) ([]string, error) {
+	out, err := s.git("diff", "--name-only", from, to)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0)
+	for _, line := range strings.Split(out, "\n") {
+		if name := strings.TrimPrefix(strings.TrimSpace(line), profilePrefix); name != strings.TrimSpace(line) && name != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,3 @@
+// Package journal keeps an append-only log of the operations that change
+// profiles or the active config, so they can be listed and undone.
+package journal
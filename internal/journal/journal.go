@@ -0,0 +1,210 @@
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"moirai/internal/app"
+)
+
+// FileName is the journal file inside the moirai state dir.
+const FileName = "journal.jsonl"
+
+const activeFileName = "oh-my-opencode.json"
+
+// Operation kinds.
+const (
+	OpApply    = "apply"
+	OpSave     = "save"
+	OpAutofill = "autofill"
+	OpRestore  = "restore"
+	OpDelete   = "delete"
+	OpUndo     = "undo"
+	OpAdopt    = "adopt"
+)
+
+// Entry is one recorded operation. File contents are identified by their
+// SHA-256; an empty hash means the file did not exist.
+type Entry struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Op      string    `json:"op"`
+	Profile string    `json:"profile,omitempty"`
+	// File is the config file the operation wrote, relative to the config
+	// dir.
+	File       string `json:"file,omitempty"`
+	BeforeHash string `json:"beforeHash,omitempty"`
+	AfterHash  string `json:"afterHash,omitempty"`
+	// Backup holds the content File had before the operation.
+	Backup string `json:"backup,omitempty"`
+	// PreviousActive and Active are the active symlink targets before and
+	// after an operation that switched it; empty means there was no symlink.
+	PreviousActive string `json:"previousActive,omitempty"`
+	Active         string `json:"active,omitempty"`
+	// Undoes is the ID of the entry an undo reverted.
+	Undoes string `json:"undoes,omitempty"`
+}
+
+// SwitchesActive reports whether the operation changed the active symlink.
+func (e Entry) SwitchesActive() bool {
+	return e.PreviousActive != e.Active
+}
+
+// Path returns the journal file for a config dir.
+func Path(configDir string) string {
+	return filepath.Join(app.StateDir(configDir), FileName)
+}
+
+// Record appends entry to the journal of dir, filling in its ID and time,
+// and returns the stored entry.
+func Record(dir string, entry Entry) (Entry, error) {
+	id, err := newID()
+	if err != nil {
+		return Entry{}, err
+	}
+	entry.ID = id
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC().Truncate(time.Second)
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	path := Path(dir)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return Entry{}, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return Entry{}, err
+	}
+	// A single write of a whole line keeps concurrent appends from
+	// interleaving.
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return Entry{}, err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return Entry{}, err
+	}
+	if err := file.Close(); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// Read returns the journal entries of dir, oldest first. Lines that cannot
+// be parsed, such as one cut short by a crash, are skipped.
+func Read(dir string) ([]Entry, error) {
+	data, err := os.ReadFile(Path(dir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Entry{}, nil
+		}
+		return nil, err
+	}
+	entries := make([]Entry, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil || entry.ID == "" {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// Find returns the entry whose ID is id or starts with it.
+func Find(entries []Entry, id string) (Entry, error) {
+	var found []Entry
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+		if id != "" && strings.HasPrefix(entry.ID, id) {
+			found = append(found, entry)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Entry{}, fmt.Errorf("operation %q not found", id)
+	case 1:
+		return found[0], nil
+	default:
+		return Entry{}, fmt.Errorf("operation id %q is ambiguous", id)
+	}
+}
+
+// Undone returns the IDs of the entries that a later undo reverted and that
+// were not redone by undoing that undo.
+func Undone(entries []Entry) map[string]bool {
+	undone := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Op != OpUndo || entry.Undoes == "" {
+			continue
+		}
+		undone[entry.Undoes] = true
+		// Undoing an undo restores the state the undone entry produced.
+		if target, err := Find(entries, entry.Undoes); err == nil && target.Op == OpUndo {
+			delete(undone, target.Undoes)
+		}
+	}
+	return undone
+}
+
+// HashFile returns the hex SHA-256 of the file at path, or "" when it does
+// not exist.
+func HashFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ActiveTarget returns the target of the active config symlink in dir, or ""
+// when the active config is missing or a regular file.
+func ActiveTarget(dir string) (string, error) {
+	path := filepath.Join(dir, activeFileName)
+	info, err := os.Lstat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return "", nil
+	}
+	return os.Readlink(path)
+}
+
+func newID() (string, error) {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,87 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordAndRead(t *testing.T) {
+	dir := t.TempDir()
+	first, err := Record(dir, Entry{Op: OpApply, Profile: "alpha", Active: "oh-my-opencode.json.alpha"})
+	if err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if len(first.ID) != 8 || first.Time.IsZero() {
+		t.Fatalf("expected ID and time filled in, got %#v", first)
+	}
+	second, err := Record(dir, Entry{Op: OpUndo, Profile: "alpha", Undoes: first.ID})
+	if err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	// A line cut short by a crash is skipped.
+	file, err := os.OpenFile(Path(dir), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	_, _ = file.WriteString(`{"id":"trunc`)
+	file.Close()
+
+	entries, err := Read(dir)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(entries) != 2 || entries[0].ID != first.ID || entries[1].Undoes != first.ID {
+		t.Fatalf("unexpected entries %#v", entries)
+	}
+	if info, err := os.Stat(Path(dir)); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected private journal, got %v %v", info, err)
+	}
+
+	if found, err := Find(entries, first.ID[:4]); err != nil || found.ID != first.ID {
+		t.Fatalf("expected prefix lookup, got %#v %v", found, err)
+	}
+	if _, err := Find(entries, "zzzz"); err == nil {
+		t.Fatal("expected error for unknown id")
+	}
+
+	undone := Undone(entries)
+	if !undone[first.ID] || undone[second.ID] {
+		t.Fatalf("unexpected undone set %v", undone)
+	}
+	redo, err := Record(dir, Entry{Op: OpUndo, Undoes: second.ID})
+	if err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	entries = append(entries, redo)
+	undone = Undone(entries)
+	if undone[first.ID] || !undone[second.ID] {
+		t.Fatalf("expected undoing the undo to redo, got %v", undone)
+	}
+}
+
+func TestHashFileAndActiveTarget(t *testing.T) {
+	dir := t.TempDir()
+	if hash, err := HashFile(filepath.Join(dir, "missing")); err != nil || hash != "" {
+		t.Fatalf("expected empty hash for missing file, got %q %v", hash, err)
+	}
+	path := filepath.Join(dir, "oh-my-opencode.json.alpha")
+	if err := os.WriteFile(path, []byte("{}"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	hash, err := HashFile(path)
+	if err != nil || hash != "44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a" {
+		t.Fatalf("unexpected hash %q %v", hash, err)
+	}
+
+	if target, err := ActiveTarget(dir); err != nil || target != "" {
+		t.Fatalf("expected no active target, got %q %v", target, err)
+	}
+	if err := os.Symlink("oh-my-opencode.json.alpha", filepath.Join(dir, activeFileName)); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+	if target, err := ActiveTarget(dir); err != nil || target != "oh-my-opencode.json.alpha" {
+		t.Fatalf("unexpected active target %q %v", target, err)
+	}
+}
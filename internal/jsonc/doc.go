@@ -0,0 +1,3 @@
+// Package jsonc reads JSON with comments and trailing commas and rewrites
+// such documents in place, keeping the formatting of untouched parts.
+package jsonc
@@ -0,0 +1,80 @@
+package jsonc
+
+import (
+	"bytes"
+	"errors"
+)
+
+var errUnterminatedComment = errors.New("unterminated block comment")
+
+// Standardize returns a copy of data with comments and trailing commas
+// replaced by spaces. Line breaks are kept, so the result is plain JSON with
+// the same byte offsets and line numbers as data.
+func Standardize(data []byte) ([]byte, error) {
+	out := make([]byte, len(data))
+	copy(out, data)
+
+	// lastComma is the offset of a comma that may turn out to be trailing:
+	// only whitespace and comments have followed it so far.
+	lastComma := -1
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case c == '"':
+			lastComma = -1
+			i = skipString(out, i)
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				if out[i] != '\r' {
+					out[i] = ' '
+				}
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				return nil, errUnterminatedComment
+			}
+			end += i + 4
+			for ; i < end; i++ {
+				if out[i] != '\n' && out[i] != '\r' {
+					out[i] = ' '
+				}
+			}
+			i--
+		case c == ',':
+			lastComma = i
+		case c == '}' || c == ']':
+			if lastComma >= 0 {
+				out[lastComma] = ' '
+			}
+			lastComma = -1
+		case isSpace(c):
+		default:
+			lastComma = -1
+		}
+	}
+	return out, nil
+}
+
+// HasExtensions reports whether data uses comments or trailing commas.
+func HasExtensions(data []byte) bool {
+	std, err := Standardize(data)
+	return err != nil || !bytes.Equal(std, data)
+}
+
+// skipString returns the offset of the closing quote of the string starting
+// at start, or the last offset if the string is unterminated.
+func skipString(data []byte, start int) int {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return len(data) - 1
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
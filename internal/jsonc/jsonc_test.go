@@ -0,0 +1,106 @@
+package jsonc
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestStandardize(t *testing.T) {
+	input := "{\n  // line comment\n  \"a\": \"http://x\", /* block\n comment */\n  \"b\": [1, 2,],\n}\n"
+	out, err := Standardize([]byte(input))
+	if err != nil {
+		t.Fatalf("Standardize: %v", err)
+	}
+	if len(out) != len(input) || strings.Count(string(out), "\n") != strings.Count(input, "\n") {
+		t.Fatalf("expected offsets and lines kept, got %q", out)
+	}
+	var value map[string]any
+	if err := json.Unmarshal(out, &value); err != nil {
+		t.Fatalf("expected plain JSON, got %v:\n%s", err, out)
+	}
+	if value["a"] != "http://x" {
+		t.Fatalf("expected // inside strings kept, got %#v", value)
+	}
+	if !HasExtensions([]byte(input)) || HasExtensions([]byte(`{"a": [1, 2]}`)) {
+		t.Fatal("unexpected HasExtensions result")
+	}
+	if _, err := Standardize([]byte(`{"a": 1 /* open`)); err == nil {
+		t.Fatal("expected error for unterminated comment")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		updated  string
+		want     string
+	}{
+		{
+			name:     "unchanged",
+			original: "{\n  // keep\n  \"b\": 1,\n  \"a\": 2,\n}\n",
+			updated:  `{"a":2,"b":1}`,
+			want:     "{\n  // keep\n  \"b\": 1,\n  \"a\": 2,\n}\n",
+		},
+		{
+			name:     "replace scalar",
+			original: "{\n  \"agents\": {\n    // main agent\n    \"oracle\": { \"model\": \"old\" }, // note\n    \"explore\": {\"model\": \"x\"}\n  }\n}",
+			updated:  `{"agents":{"explore":{"model":"x"},"oracle":{"model":"new"}}}`,
+			want:     "{\n  \"agents\": {\n    // main agent\n    \"oracle\": { \"model\": \"new\" }, // note\n    \"explore\": {\"model\": \"x\"}\n  }\n}",
+		},
+		{
+			name:     "add member after comment",
+			original: "{\n\t\"a\": 1 // one\n}\n",
+			updated:  `{"a":1,"b":{"c":true}}`,
+			want:     "{\n\t\"a\": 1, // one\n\t\"b\": {\n\t\t\"c\": true\n\t}\n}\n",
+		},
+		{
+			name:     "four space indent",
+			original: "{\n    \"a\": {\n        \"x\": 1\n    }\n}",
+			updated:  `{"a":{"x":1,"y":[true]}}`,
+			want:     "{\n    \"a\": {\n        \"x\": 1,\n        \"y\": [\n            true\n        ]\n    }\n}",
+		},
+		{
+			name:     "add member after trailing comma",
+			original: "{\n  \"a\": 1,\n}",
+			updated:  `{"a":1,"b":2}`,
+			want:     "{\n  \"a\": 1,\n  \"b\": 2\n}",
+		},
+		{
+			name:     "add inline",
+			original: `{"agents": {"oracle": {"model": "a"}}}`,
+			updated:  `{"agents":{"oracle":{"model":"a","variant":"high"}}}`,
+			want:     `{"agents": {"oracle": {"model": "a", "variant": "high"}}}`,
+		},
+		{
+			name:     "remove middle member with comment",
+			original: "{\n  \"a\": 1,\n  \"b\": 2, // gone\n  \"c\": 3\n}",
+			updated:  `{"a":1,"c":3}`,
+			want:     "{\n  \"a\": 1,\n  \"c\": 3\n}",
+		},
+		{
+			name:     "remove last member and add",
+			original: "{\n  \"a\": 1,\n  \"b\": 2\n}",
+			updated:  `{"a":1,"z":3}`,
+			want:     "{\n  \"a\": 1,\n  \"z\": 3\n}",
+		},
+		{
+			name:     "replace array",
+			original: "{\n  \"list\": [1, 2]\n}",
+			updated:  `{"list":[1,2,3]}`,
+			want:     "{\n  \"list\": [\n    1,\n    2,\n    3\n  ]\n}",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Update([]byte(tc.original), []byte(tc.updated))
+			if err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("unexpected result:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,444 @@
+package jsonc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// node is a parsed JSON value with its byte span in the document.
+type node struct {
+	start, end int
+	// kind is '{' or '[' for containers and 0 for scalars.
+	kind     byte
+	members  []member
+	elements []*node
+}
+
+type member struct {
+	key      string
+	keyStart int
+	value    *node
+}
+
+type parser struct {
+	data []byte
+	pos  int
+}
+
+// parse builds the node tree of a standardized document.
+func parse(data []byte) (*node, error) {
+	p := &parser{data: data}
+	root, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos != len(data) {
+		return nil, fmt.Errorf("unexpected data at offset %d", p.pos)
+	}
+	return root, nil
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.data) && isSpace(p.data[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *parser) value() (*node, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, fmt.Errorf("unexpected end of document")
+	}
+	start := p.pos
+	switch p.data[p.pos] {
+	case '{':
+		return p.object(start)
+	case '[':
+		return p.array(start)
+	case '"':
+		p.pos = skipString(p.data, p.pos) + 1
+	default:
+		for p.pos < len(p.data) && !isSpace(p.data[p.pos]) && !bytes.ContainsRune([]byte(",:]}"), rune(p.data[p.pos])) {
+			p.pos++
+		}
+		if p.pos == start {
+			return nil, fmt.Errorf("unexpected %q at offset %d", p.data[p.pos], p.pos)
+		}
+	}
+	return &node{start: start, end: p.pos}, nil
+}
+
+func (p *parser) object(start int) (*node, error) {
+	n := &node{start: start, kind: '{'}
+	p.pos++
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return nil, fmt.Errorf("unterminated object at offset %d", start)
+		}
+		if p.data[p.pos] == '}' {
+			p.pos++
+			n.end = p.pos
+			return n, nil
+		}
+		if len(n.members) > 0 {
+			if p.data[p.pos] != ',' {
+				return nil, fmt.Errorf("expected ',' at offset %d", p.pos)
+			}
+			p.pos++
+			p.skipSpace()
+		}
+		if p.pos >= len(p.data) || p.data[p.pos] != '"' {
+			return nil, fmt.Errorf("expected object key at offset %d", p.pos)
+		}
+		keyStart := p.pos
+		p.pos = skipString(p.data, p.pos) + 1
+		var key string
+		if err := json.Unmarshal(p.data[keyStart:p.pos], &key); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.data) || p.data[p.pos] != ':' {
+			return nil, fmt.Errorf("expected ':' at offset %d", p.pos)
+		}
+		p.pos++
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		n.members = append(n.members, member{key: key, keyStart: keyStart, value: value})
+	}
+}
+
+func (p *parser) array(start int) (*node, error) {
+	n := &node{start: start, kind: '['}
+	p.pos++
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) {
+			return nil, fmt.Errorf("unterminated array at offset %d", start)
+		}
+		if p.data[p.pos] == ']' {
+			p.pos++
+			n.end = p.pos
+			return n, nil
+		}
+		if len(n.elements) > 0 {
+			if p.data[p.pos] != ',' {
+				return nil, fmt.Errorf("expected ',' at offset %d", p.pos)
+			}
+			p.pos++
+		}
+		value, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		n.elements = append(n.elements, value)
+	}
+}
+
+// edit replaces original[start:end] with text.
+type edit struct {
+	start, end int
+	text       string
+}
+
+// Update rewrites original, a JSON or JSONC document, so that it holds the
+// same value as updated while keeping comments, key order and formatting of
+// everything that did not change. Changed scalars are replaced in place,
+// removed keys are cut out with their line, and new keys are appended to
+// their object in the style of their siblings.
+func Update(original, updated []byte) ([]byte, error) {
+	std, err := Standardize(original)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parse(std)
+	if err != nil {
+		return nil, err
+	}
+	want, err := decode(updated)
+	if err != nil {
+		return nil, err
+	}
+	u := &updater{original: original, std: std, indent: detectIndent(original)}
+	if err := u.update(root, want); err != nil {
+		return nil, err
+	}
+	sort.Slice(u.edits, func(i, j int) bool {
+		return u.edits[i].start > u.edits[j].start
+	})
+	out := append([]byte(nil), original...)
+	for _, e := range u.edits {
+		out = append(out[:e.start], append([]byte(e.text), out[e.end:]...)...)
+	}
+
+	// Never write a document that does not hold the requested value.
+	check, err := Standardize(out)
+	if err != nil {
+		return nil, err
+	}
+	got, err := decode(check)
+	if err != nil {
+		return nil, fmt.Errorf("patched document is invalid: %w", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		return nil, fmt.Errorf("patched document does not match the update")
+	}
+	return out, nil
+}
+
+type updater struct {
+	original []byte
+	std      []byte
+	// indent is the document's indentation unit, used for new nested values.
+	indent string
+	edits  []edit
+}
+
+func (u *updater) update(n *node, want any) error {
+	switch typed := want.(type) {
+	case map[string]any:
+		if n.kind == '{' {
+			return u.updateObject(n, typed)
+		}
+	case []any:
+		if n.kind == '[' && len(n.elements) == len(typed) {
+			for i, element := range n.elements {
+				if err := u.update(element, typed[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	default:
+		if n.kind == 0 {
+			current, err := decode(u.std[n.start:n.end])
+			if err == nil && reflect.DeepEqual(current, want) {
+				return nil
+			}
+		}
+	}
+	return u.replace(n, want)
+}
+
+func (u *updater) replace(n *node, want any) error {
+	text, err := encodeIndented(want, u.lineIndent(n.start), u.indent)
+	if err != nil {
+		return err
+	}
+	u.edits = append(u.edits, edit{start: n.start, end: n.end, text: text})
+	return nil
+}
+
+func (u *updater) updateObject(n *node, want map[string]any) error {
+	// With duplicate keys the last one wins, as in encoding/json.
+	present := make(map[string]int, len(n.members))
+	for i, m := range n.members {
+		present[m.key] = i
+	}
+	kept := make([]int, 0, len(n.members))
+	for i, m := range n.members {
+		if _, ok := want[m.key]; ok && present[m.key] == i {
+			kept = append(kept, i)
+		}
+	}
+	added := make([]string, 0)
+	for key := range want {
+		if _, ok := present[key]; !ok {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+
+	if len(kept) == 0 {
+		if len(n.members) == 0 && len(added) == 0 {
+			return nil
+		}
+		return u.replace(n, want)
+	}
+	for _, i := range kept {
+		m := n.members[i]
+		if err := u.update(m.value, want[m.key]); err != nil {
+			return err
+		}
+	}
+
+	lastKept := kept[len(kept)-1]
+	for i, m := range n.members {
+		if _, ok := want[m.key]; ok && present[m.key] == i {
+			continue
+		}
+		if i < lastKept {
+			u.edits = append(u.edits, edit{start: u.memberLineStart(m), end: u.memberLineEnd(m)})
+		}
+	}
+
+	after := n.members[lastKept]
+	multiLine := bytes.IndexByte(u.std[after.value.end:n.end-1], '\n') >= 0
+	text, err := u.membersText(want, added, u.lineIndent(after.keyStart), multiLine)
+	if err != nil {
+		return err
+	}
+	if lastKept < len(n.members)-1 {
+		// Cut everything from the end of the last kept value to the end of
+		// the last removed one, taking the separating comma with it, and
+		// put the added members there.
+		last := n.members[len(n.members)-1]
+		u.edits = append(u.edits, edit{start: after.value.end, end: last.value.end, text: text})
+		return nil
+	}
+	if text == "" {
+		return nil
+	}
+	if !multiLine {
+		u.edits = append(u.edits, edit{start: after.value.end, end: after.value.end, text: text})
+		return nil
+	}
+
+	// Insert at the end of the last member's line so a trailing comment
+	// stays with that member.
+	lineEnd := after.value.end + bytes.IndexByte(u.std[after.value.end:], '\n')
+	if lineEnd > after.value.end && u.original[lineEnd-1] == '\r' {
+		lineEnd--
+	}
+	text = strings.TrimPrefix(text, ",")
+	next := after.value.end
+	for next < lineEnd && isSpace(u.original[next]) {
+		next++
+	}
+	if next < lineEnd && u.original[next] == ',' {
+		// An existing trailing comma already separates the new members.
+		u.edits = append(u.edits, edit{start: lineEnd, end: lineEnd, text: text})
+		return nil
+	}
+	if lineEnd == after.value.end {
+		u.edits = append(u.edits, edit{start: lineEnd, end: lineEnd, text: "," + text})
+		return nil
+	}
+	u.edits = append(u.edits,
+		edit{start: after.value.end, end: after.value.end, text: ","},
+		edit{start: lineEnd, end: lineEnd, text: text},
+	)
+	return nil
+}
+
+// membersText encodes the added keys, each preceded by a comma, on new lines
+// with the given indentation for multi-line objects and inline otherwise.
+func (u *updater) membersText(want map[string]any, added []string, indent string, multiLine bool) (string, error) {
+	var text strings.Builder
+	for _, key := range added {
+		encodedKey, err := encodeIndented(key, "", "")
+		if err != nil {
+			return "", err
+		}
+		prefix := ""
+		if multiLine {
+			prefix = indent
+		}
+		value, err := encodeIndented(want[key], prefix, u.indent)
+		if err != nil {
+			return "", err
+		}
+		if multiLine {
+			text.WriteString(",\n" + indent)
+		} else {
+			text.WriteString(", ")
+		}
+		text.WriteString(encodedKey + ": " + value)
+	}
+	return text.String(), nil
+}
+
+// memberLineStart returns where a removed member starts, including its
+// indentation when it begins its line.
+func (u *updater) memberLineStart(m member) int {
+	start := m.keyStart
+	for start > 0 && (u.std[start-1] == ' ' || u.std[start-1] == '\t') {
+		start--
+	}
+	if start == 0 || u.std[start-1] == '\n' {
+		return start
+	}
+	return m.keyStart
+}
+
+// memberLineEnd returns where a removed member that is followed by another
+// member ends: after its comma, and after the rest of its line when only
+// whitespace or a comment follows.
+func (u *updater) memberLineEnd(m member) int {
+	comma := u.nextNonSpace(m.value.end)
+	end := comma + 1
+	next := u.nextNonSpace(end)
+	newline := bytes.IndexByte(u.std[end:next], '\n')
+	if newline < 0 {
+		return next
+	}
+	lineStart := u.memberLineStart(m)
+	if lineStart != m.keyStart {
+		// The member had its own line: drop the line break too.
+		return end + newline + 1
+	}
+	return end
+}
+
+// nextNonSpace returns the offset of the next significant byte at or after
+// pos in the standardized document.
+func (u *updater) nextNonSpace(pos int) int {
+	for pos < len(u.std) && isSpace(u.std[pos]) {
+		pos++
+	}
+	return pos
+}
+
+// lineIndent returns the leading whitespace of the line containing pos.
+func (u *updater) lineIndent(pos int) string {
+	start := bytes.LastIndexByte(u.original[:pos], '\n') + 1
+	end := start
+	for end < len(u.original) && (u.original[end] == ' ' || u.original[end] == '\t') {
+		end++
+	}
+	return string(u.original[start:end])
+}
+
+// defaultIndent is used for documents without indented lines.
+const defaultIndent = "  "
+
+// detectIndent returns the leading whitespace of the first indented line,
+// which for a pretty-printed document is one indentation level.
+func detectIndent(data []byte) string {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) == 0 || len(trimmed) == len(line) {
+			continue
+		}
+		return string(line[:len(line)-len(trimmed)])
+	}
+	return defaultIndent
+}
+
+func encodeIndented(value any, prefix, indent string) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent(prefix, indent)
+	if err := encoder.Encode(value); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+func decode(data []byte) (any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
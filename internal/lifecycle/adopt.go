@@ -0,0 +1,171 @@
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+	"moirai/internal/journal"
+	"moirai/internal/link"
+	"moirai/internal/profile"
+)
+
+// DefaultAdoptName is the profile name the TUI offers for an unmanaged
+// active config.
+const DefaultAdoptName = "default"
+
+// adoptDeclinedFile, in the state dir, records that the user declined the
+// TUI's offer to adopt the unmanaged active config.
+const adoptDeclinedFile = "adopt-declined"
+
+// ErrNotUnmanaged indicates there is no regular-file active config to adopt.
+var ErrNotUnmanaged = errors.New("active config is not an unmanaged file")
+
+// AdoptResult reports what AdoptActive changed.
+type AdoptResult struct {
+	Path   string
+	Backup string
+	// Entry is the journal entry of the adoption, if one was recorded.
+	Entry journal.Entry
+}
+
+// UnmanagedActive reports whether the active config in dir is a regular file
+// rather than a symlink to a profile.
+func UnmanagedActive(dir string) (bool, error) {
+	info, err := os.Lstat(filepath.Join(dir, activeFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return info.Mode().IsRegular(), nil
+}
+
+// DeclineAdopt records that the unmanaged active config in dir should not be
+// offered for adoption again. It can still be adopted with AdoptActive.
+func DeclineAdopt(dir string) error {
+	path := filepath.Join(app.StateDir(dir), adoptDeclinedFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, nil, 0o600)
+}
+
+// AdoptDeclined reports whether DeclineAdopt was called for dir.
+func AdoptDeclined(dir string) bool {
+	_, err := os.Stat(filepath.Join(app.StateDir(dir), adoptDeclinedFile))
+	return err == nil
+}
+
+// SuggestAdoptName returns DefaultAdoptName, or the first free name
+// derived from it.
+func SuggestAdoptName(dir string) (string, error) {
+	name := DefaultAdoptName
+	for i := 2; ; i++ {
+		if _, err := os.Lstat(profile.ProfilePath(dir, name)); os.IsNotExist(err) {
+			return name, nil
+		} else if err != nil {
+			return "", err
+		}
+		name = fmt.Sprintf("%s-%d", DefaultAdoptName, i)
+	}
+}
+
+// AdoptActive turns a regular-file active config into the profile name: the
+// content is copied to the profile and the active config is replaced by a
+// symlink to it in a single rename. The file is backed up first and the
+// change journaled, so undo puts the regular file back; the profile stays.
+func AdoptActive(config app.AppConfig, name string) (AdoptResult, error) {
+	dir := config.ConfigDir
+	if err := profile.ValidateName(name); err != nil {
+		return AdoptResult{}, err
+	}
+	activePath := filepath.Join(dir, activeFileName)
+	info, err := os.Lstat(activePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return AdoptResult{}, fmt.Errorf("%w: %s does not exist", ErrNotUnmanaged, activeFileName)
+		}
+		return AdoptResult{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return AdoptResult{}, fmt.Errorf("%w: %s is already a symlink", ErrNotUnmanaged, activeFileName)
+	}
+	data, err := os.ReadFile(activePath)
+	if err != nil {
+		return AdoptResult{}, err
+	}
+	if _, err := profile.ParseProfile(data); err != nil {
+		return AdoptResult{}, fmt.Errorf("active config: %w", err)
+	}
+	profileFile := profilePrefix + name
+	path := filepath.Join(dir, profileFile)
+	if err := ensureAbsent(path, name); err != nil {
+		return AdoptResult{}, err
+	}
+
+	saved, err := backup.BackupActiveWith(backup.NewStore(config), backup.Options{Reason: backup.ReasonAdopt, Retention: config.BackupRetention})
+	if err != nil {
+		return AdoptResult{}, fmt.Errorf("backup active config: %w", err)
+	}
+	if err := profile.SaveProfileDataAtomic(path, data, info.Mode().Perm()); err != nil {
+		return AdoptResult{}, err
+	}
+	if err := link.SetActiveTarget(dir, profileFile); err != nil {
+		_ = os.Remove(path)
+		return AdoptResult{}, err
+	}
+	hash, _ := journal.HashFile(path)
+	// The switch already happened; failing to journal it only loses the
+	// ability to undo it.
+	res := AdoptResult{Path: path, Backup: saved.Path}
+	if recorded, err := journal.Record(dir, journal.Entry{
+		Op:         journal.OpAdopt,
+		Profile:    name,
+		File:       activeFileName,
+		BeforeHash: hash,
+		AfterHash:  hash,
+		Backup:     saved.Path,
+		Active:     profileFile,
+	}); err == nil {
+		res.Entry = recorded
+	}
+	return res, nil
+}
+
+// AdoptBackup creates the profile name from an active config backup, so a
+// hand-written config an apply replaced becomes a named profile again. It
+// returns the path of the new profile.
+func AdoptBackup(config app.AppConfig, name, from string) (string, error) {
+	dir := config.ConfigDir
+	if err := profile.ValidateName(name); err != nil {
+		return "", err
+	}
+	backupPath, err := backup.ResolveActiveBackup(backup.NewStore(config), from)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(backupPath)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(backupPath)
+	if err != nil {
+		return "", err
+	}
+	if _, err := profile.ParseProfile(data); err != nil {
+		return "", fmt.Errorf("backup %s: %w", from, err)
+	}
+	path := profile.ProfilePath(dir, name)
+	if err := ensureAbsent(path, name); err != nil {
+		return "", err
+	}
+	if err := profile.SaveProfileDataAtomic(path, data, info.Mode().Perm()); err != nil {
+		return "", err
+	}
+	return path, nil
+}
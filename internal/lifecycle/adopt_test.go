@@ -0,0 +1,166 @@
+package lifecycle
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+	"moirai/internal/link"
+)
+
+func TestAdoptBackupCreatesProfile(t *testing.T) {
+	dir := t.TempDir()
+	writeProfile(t, dir, "alpha", `{"agents":{}}`)
+	activePath := filepath.Join(dir, "oh-my-opencode.json")
+	if err := os.WriteFile(activePath, []byte(`{"hand":"written"}`), 0o600); err != nil {
+		t.Fatalf("write active: %v", err)
+	}
+	if err := link.ApplyProfile(dir, "alpha"); err != nil {
+		t.Fatalf("ApplyProfile: %v", err)
+	}
+	backups, err := backup.ListActiveBackups(testStore(dir))
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("expected one active backup, got %v %v", backups, err)
+	}
+
+	path, err := AdoptBackup(app.AppConfig{ConfigDir: dir}, "legacy", backups[0])
+	if err != nil {
+		t.Fatalf("AdoptBackup: %v", err)
+	}
+	if readFile(t, path) != `{"hand":"written"}` {
+		t.Fatalf("unexpected adopted content %q", readFile(t, path))
+	}
+	if _, err := AdoptBackup(app.AppConfig{ConfigDir: dir}, "legacy", backups[0]); !errors.Is(err, ErrProfileExists) {
+		t.Fatalf("expected ErrProfileExists, got %v", err)
+	}
+	if active, _, _ := link.ActiveProfile(dir); active != "alpha" {
+		t.Fatalf("expected alpha to stay active, got %q", active)
+	}
+}
+
+func TestAdoptBackupRejectsInvalidJSON(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "oh-my-opencode.json"), []byte("not json"), 0o600); err != nil {
+		t.Fatalf("write active: %v", err)
+	}
+	saved, err := backup.BackupActive(testStore(dir))
+	if err != nil {
+		t.Fatalf("BackupActive: %v", err)
+	}
+	if _, err := AdoptBackup(app.AppConfig{ConfigDir: dir}, "broken", saved); err == nil {
+		t.Fatal("expected an unparsable backup to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "oh-my-opencode.json.broken")); !os.IsNotExist(err) {
+		t.Fatalf("expected no profile to be written, got %v", err)
+	}
+}
+
+func TestUndoRestoreActiveRelinksProfile(t *testing.T) {
+	dir := t.TempDir()
+	writeProfile(t, dir, "alpha", `{"agents":{}}`)
+	activePath := filepath.Join(dir, "oh-my-opencode.json")
+	if err := os.WriteFile(activePath, []byte(`{"hand":"written"}`), 0o600); err != nil {
+		t.Fatalf("write active: %v", err)
+	}
+	if err := link.ApplyProfile(dir, "alpha"); err != nil {
+		t.Fatalf("ApplyProfile: %v", err)
+	}
+	backups, _ := backup.ListActiveBackups(testStore(dir))
+	if _, err := backup.RestoreActiveFromBackup(testStore(dir), backups[0], backup.Options{}); err != nil {
+		t.Fatalf("RestoreActiveFromBackup: %v", err)
+	}
+	if _, err := Undo(app.AppConfig{ConfigDir: dir}, "", false); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if active, ok, _ := link.ActiveProfile(dir); !ok || active != "alpha" {
+		t.Fatalf("expected alpha linked again, got %q %v", active, ok)
+	}
+}
+
+func TestUndoRestoreActiveRemovesCreatedFile(t *testing.T) {
+	dir := t.TempDir()
+	activePath := filepath.Join(dir, "oh-my-opencode.json")
+	if err := os.WriteFile(activePath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write active: %v", err)
+	}
+	saved, err := backup.BackupActive(testStore(dir))
+	if err != nil {
+		t.Fatalf("BackupActive: %v", err)
+	}
+	if err := os.Remove(activePath); err != nil {
+		t.Fatalf("remove active: %v", err)
+	}
+	if _, err := backup.RestoreActiveFromBackup(testStore(dir), saved, backup.Options{}); err != nil {
+		t.Fatalf("RestoreActiveFromBackup: %v", err)
+	}
+	if _, err := Undo(app.AppConfig{ConfigDir: dir}, "", false); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if _, err := os.Lstat(activePath); !os.IsNotExist(err) {
+		t.Fatalf("expected the restored active config to be removed, got %v", err)
+	}
+}
+
+func TestAdoptActiveLinksNewProfile(t *testing.T) {
+	dir := t.TempDir()
+	activePath := filepath.Join(dir, "oh-my-opencode.json")
+	if err := os.WriteFile(activePath, []byte(`{"hand":"written"}`), 0o600); err != nil {
+		t.Fatalf("write active: %v", err)
+	}
+	if unmanaged, err := UnmanagedActive(dir); err != nil || !unmanaged {
+		t.Fatalf("expected an unmanaged active config, got %v %v", unmanaged, err)
+	}
+
+	res, err := AdoptActive(app.AppConfig{ConfigDir: dir}, "mine")
+	if err != nil {
+		t.Fatalf("AdoptActive: %v", err)
+	}
+	if readFile(t, res.Path) != `{"hand":"written"}` || readFile(t, res.Backup) != `{"hand":"written"}` {
+		t.Fatalf("expected the profile and backup to hold the active content")
+	}
+	if active, ok, _ := link.ActiveProfile(dir); !ok || active != "mine" {
+		t.Fatalf("expected mine active, got %q %v", active, ok)
+	}
+	if unmanaged, _ := UnmanagedActive(dir); unmanaged {
+		t.Fatal("expected the active config to be managed after adopting")
+	}
+	if _, err := AdoptActive(app.AppConfig{ConfigDir: dir}, "again"); !errors.Is(err, ErrNotUnmanaged) {
+		t.Fatalf("expected ErrNotUnmanaged for a symlink, got %v", err)
+	}
+
+	// Undo puts the regular file back and keeps the profile.
+	if _, err := Undo(app.AppConfig{ConfigDir: dir}, "", false); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	info, err := os.Lstat(activePath)
+	if err != nil || info.Mode()&os.ModeSymlink != 0 || readFile(t, activePath) != `{"hand":"written"}` {
+		t.Fatalf("expected the regular active file back, got %v %v", info, err)
+	}
+	if _, err := os.Stat(res.Path); err != nil {
+		t.Fatalf("expected the adopted profile to stay: %v", err)
+	}
+}
+
+func TestAdoptActiveRefusesTakenNameAndMissingActive(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := AdoptActive(app.AppConfig{ConfigDir: dir}, "mine"); !errors.Is(err, ErrNotUnmanaged) {
+		t.Fatalf("expected ErrNotUnmanaged without an active config, got %v", err)
+	}
+	writeProfile(t, dir, "default", `{}`)
+	activePath := filepath.Join(dir, "oh-my-opencode.json")
+	if err := os.WriteFile(activePath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write active: %v", err)
+	}
+	if _, err := AdoptActive(app.AppConfig{ConfigDir: dir}, "default"); !errors.Is(err, ErrProfileExists) {
+		t.Fatalf("expected ErrProfileExists, got %v", err)
+	}
+	if name, err := SuggestAdoptName(dir); err != nil || name != "default-2" {
+		t.Fatalf("SuggestAdoptName = %q, %v", name, err)
+	}
+	if info, _ := os.Lstat(activePath); info.Mode()&os.ModeSymlink != 0 {
+		t.Fatal("expected the active config to be left alone")
+	}
+}
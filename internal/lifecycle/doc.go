@@ -0,0 +1,2 @@
+// Package lifecycle creates, clones, renames and deletes profiles.
+package lifecycle
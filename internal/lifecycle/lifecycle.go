@@ -0,0 +1,223 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+	"moirai/internal/journal"
+	"moirai/internal/link"
+	"moirai/internal/profile"
+)
+
+const defaultProfilePerm = 0o600
+
+// ErrProfileExists indicates the target profile name is already taken.
+var ErrProfileExists = errors.New("profile already exists")
+
+// ErrProfileActive indicates an operation was refused on the active profile.
+var ErrProfileActive = errors.New("profile is active")
+
+// CreateOptions selects the initial content of a new profile.
+// At most one of From and Preset may be set; otherwise the profile is empty.
+// Agents lists the agents a preset fills; nil means profile.KnownAgents.
+type CreateOptions struct {
+	From   string
+	Preset *profile.Preset
+	Agents []string
+}
+
+// CreateProfile writes a new profile and returns its path.
+func CreateProfile(dir, name string, opts CreateOptions) (string, error) {
+	if err := profile.ValidateName(name); err != nil {
+		return "", err
+	}
+	if opts.From != "" && opts.Preset != nil {
+		return "", fmt.Errorf("--from and --preset are mutually exclusive")
+	}
+	if opts.From != "" {
+		return CloneProfile(dir, opts.From, name)
+	}
+
+	path := profile.ProfilePath(dir, name)
+	if err := ensureAbsent(path, name); err != nil {
+		return "", err
+	}
+	cfg := &profile.RootConfig{}
+	if opts.Preset != nil {
+		agents := opts.Agents
+		if agents == nil {
+			agents = profile.KnownAgents()
+		}
+		profile.ApplyAutofill(cfg, agents, *opts.Preset)
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	data = append(data, '\n')
+	if err := profile.SaveProfileDataAtomic(path, data, defaultProfilePerm); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// CloneProfile copies the source profile to a new profile and returns its path.
+func CloneProfile(dir, source, name string) (string, error) {
+	if err := profile.ValidateName(source); err != nil {
+		return "", err
+	}
+	if err := profile.ValidateName(name); err != nil {
+		return "", err
+	}
+	sourcePath := profile.ProfilePath(dir, source)
+	info, err := os.Stat(sourcePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("profile %q not found", source)
+		}
+		return "", err
+	}
+	if _, err := profile.LoadProfile(sourcePath); err != nil {
+		return "", err
+	}
+	path := profile.ProfilePath(dir, name)
+	if err := ensureAbsent(path, name); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return "", err
+	}
+	if err := profile.SaveProfileDataAtomic(path, data, info.Mode().Perm()); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// RenameResult reports what RenameProfile changed.
+type RenameResult struct {
+	Path       string
+	Retargeted bool
+	Backups    []string
+	// Entry is the journal entry of the retarget, if one was recorded.
+	Entry journal.Entry
+}
+
+// RenameProfile renames a profile in the config dir of config, its backups
+// and, when it is active, the active symlink target.
+func RenameProfile(config app.AppConfig, oldName, newName string) (RenameResult, error) {
+	dir := config.ConfigDir
+	store := backup.NewStore(config)
+	if err := profile.ValidateName(oldName); err != nil {
+		return RenameResult{}, err
+	}
+	if err := profile.ValidateName(newName); err != nil {
+		return RenameResult{}, err
+	}
+	if oldName == newName {
+		return RenameResult{}, fmt.Errorf("old and new profile names are the same")
+	}
+	activeName, hasActive, err := link.ActiveProfile(dir)
+	if err != nil {
+		return RenameResult{}, err
+	}
+
+	newPath, err := CloneProfile(dir, oldName, newName)
+	if err != nil {
+		return RenameResult{}, err
+	}
+	res := RenameResult{Path: newPath}
+	if hasActive && activeName == oldName {
+		applied, err := link.ApplyProfileWith(dir, newName, link.ApplyOptions{Backups: store, Retention: config.BackupRetention})
+		if err != nil {
+			_ = os.Remove(newPath)
+			return RenameResult{}, fmt.Errorf("retarget active profile: %w", err)
+		}
+		res.Retargeted = true
+		res.Entry = applied.Entry
+	}
+	if err := os.Remove(profile.ProfilePath(dir, oldName)); err != nil {
+		return res, err
+	}
+	backups, err := backup.RenameProfileBackups(store, oldName, newName)
+	res.Backups = backups
+	if err != nil {
+		return res, fmt.Errorf("rename backups: %w", err)
+	}
+	return res, nil
+}
+
+// DeleteResult reports what DeleteProfile changed.
+type DeleteResult struct {
+	Backup        string
+	WasActive     bool
+	ActiveRemoved bool
+	// Entry is the journal entry of the deletion, if one was recorded.
+	Entry journal.Entry
+}
+
+// DeleteProfile removes a profile from the config dir of config after taking
+// a final backup. It refuses to delete the active profile unless force is
+// set, in which case the active symlink is removed as well.
+func DeleteProfile(config app.AppConfig, name string, force bool) (DeleteResult, error) {
+	dir := config.ConfigDir
+	if err := profile.ValidateName(name); err != nil {
+		return DeleteResult{}, err
+	}
+	path := profile.ProfilePath(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return DeleteResult{}, fmt.Errorf("profile %q not found", name)
+		}
+		return DeleteResult{}, err
+	}
+	activeName, hasActive, err := link.ActiveProfile(dir)
+	if err != nil {
+		return DeleteResult{}, err
+	}
+	res := DeleteResult{WasActive: hasActive && activeName == name}
+	if res.WasActive && !force {
+		return DeleteResult{}, fmt.Errorf("%w: %q (use --force to delete it anyway)", ErrProfileActive, name)
+	}
+
+	entry := journal.Entry{Op: journal.OpDelete, Profile: name, File: filepath.Base(path)}
+	if entry.BeforeHash, err = journal.HashFile(path); err != nil {
+		return DeleteResult{}, err
+	}
+	backupEntry, err := backup.BackupProfileWith(backup.NewStore(config), name, backup.Options{Reason: backup.ReasonDelete, Retention: config.BackupRetention})
+	if err != nil {
+		return DeleteResult{}, fmt.Errorf("backup profile: %w", err)
+	}
+	res.Backup = backupEntry.Path
+	entry.Backup = backupEntry.Path
+	if res.WasActive {
+		if entry.PreviousActive, err = journal.ActiveTarget(dir); err != nil {
+			return res, err
+		}
+		if err := link.DeactivateProfile(dir); err != nil {
+			return res, err
+		}
+		res.ActiveRemoved = true
+	}
+	if err := os.Remove(path); err != nil {
+		return res, err
+	}
+	if recorded, err := journal.Record(dir, entry); err == nil {
+		res.Entry = recorded
+	}
+	return res, nil
+}
+
+func ensureAbsent(path, name string) error {
+	if _, err := os.Lstat(path); err == nil {
+		return fmt.Errorf("%w: %q", ErrProfileExists, name)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package lifecycle
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+	"moirai/internal/link"
+	"moirai/internal/profile"
+)
+
+// testStore returns the default backup store of dir.
+func testStore(dir string) backup.Store {
+	return backup.NewStore(app.AppConfig{ConfigDir: dir})
+}
+
+func writeProfile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "oh-my-opencode.json."+name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write profile %q: %v", name, err)
+	}
+	return path
+}
+
+func activate(t *testing.T, dir, name string) {
+	t.Helper()
+	if err := os.Symlink("oh-my-opencode.json."+name, filepath.Join(dir, "oh-my-opencode.json")); err != nil {
+		t.Skipf("symlink not supported: %v", err)
+	}
+}
+
+func TestCreateProfileEmptyAndPreset(t *testing.T) {
+	dir := t.TempDir()
+	path, err := CreateProfile(dir, "blank", CreateOptions{})
+	if err != nil {
+		t.Fatalf("CreateProfile: %v", err)
+	}
+	cfg, err := profile.LoadProfile(path)
+	if err != nil {
+		t.Fatalf("LoadProfile: %v", err)
+	}
+	if len(cfg.Agents) != 0 {
+		t.Fatalf("expected empty profile, got %v", cfg.Agents)
+	}
+
+	preset, _ := profile.PresetByName("openai")
+	path, err = CreateProfile(dir, "filled", CreateOptions{Preset: &preset})
+	if err != nil {
+		t.Fatalf("CreateProfile preset: %v", err)
+	}
+	cfg, err = profile.LoadProfile(path)
+	if err != nil {
+		t.Fatalf("LoadProfile: %v", err)
+	}
+	if missing := profile.MissingAgents(cfg, profile.KnownAgents()); len(missing) != 0 {
+		t.Fatalf("expected all agents filled, missing %v", missing)
+	}
+
+	if _, err := CreateProfile(dir, "blank", CreateOptions{}); !errors.Is(err, ErrProfileExists) {
+		t.Fatalf("expected ErrProfileExists, got %v", err)
+	}
+}
+
+func TestCreateProfileRejectsInvalidName(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"", "../evil", "a.bak.1"} {
+		if _, err := CreateProfile(dir, name, CreateOptions{}); err == nil {
+			t.Fatalf("expected error for name %q", name)
+		}
+	}
+}
+
+func TestCloneProfileCopiesContent(t *testing.T) {
+	dir := t.TempDir()
+	content := `{"agents":{"oracle":{"model":"x"}},"custom":1}`
+	writeProfile(t, dir, "src", content)
+
+	path, err := CloneProfile(dir, "src", "dst")
+	if err != nil {
+		t.Fatalf("CloneProfile: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read clone: %v", err)
+	}
+	if string(data) != content {
+		t.Fatalf("clone content mismatch: %q", data)
+	}
+	if _, err := CloneProfile(dir, "missing", "other"); err == nil {
+		t.Fatal("expected error cloning missing profile")
+	}
+}
+
+func TestRenameProfileRetargetsActiveAndBackups(t *testing.T) {
+	dir := t.TempDir()
+	writeProfile(t, dir, "old", `{}`)
+	activate(t, dir, "old")
+	if _, err := backup.BackupProfile(testStore(dir), "old"); err != nil {
+		t.Fatalf("BackupProfile: %v", err)
+	}
+
+	res, err := RenameProfile(app.AppConfig{ConfigDir: dir}, "old", "new")
+	if err != nil {
+		t.Fatalf("RenameProfile: %v", err)
+	}
+	if !res.Retargeted || len(res.Backups) != 1 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if _, err := os.Stat(profile.ProfilePath(dir, "old")); !os.IsNotExist(err) {
+		t.Fatalf("expected old profile removed, got %v", err)
+	}
+	name, ok, err := link.ActiveProfile(dir)
+	if err != nil || !ok || name != "new" {
+		t.Fatalf("expected active new, got %q %v %v", name, ok, err)
+	}
+	backups, err := backup.ListProfileBackups(testStore(dir), "new")
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("expected 1 backup for new, got %v %v", backups, err)
+	}
+	if old, _ := backup.ListProfileBackups(testStore(dir), "old"); len(old) != 0 {
+		t.Fatalf("expected no backups left for old, got %v", old)
+	}
+}
+
+func TestRenameProfileRefusesExistingTarget(t *testing.T) {
+	dir := t.TempDir()
+	writeProfile(t, dir, "a", `{}`)
+	writeProfile(t, dir, "b", `{}`)
+	if _, err := RenameProfile(app.AppConfig{ConfigDir: dir}, "a", "b"); !errors.Is(err, ErrProfileExists) {
+		t.Fatalf("expected ErrProfileExists, got %v", err)
+	}
+}
+
+func TestDeleteProfileRefusesActiveUnlessForced(t *testing.T) {
+	dir := t.TempDir()
+	path := writeProfile(t, dir, "live", `{"k":1}`)
+	activate(t, dir, "live")
+
+	if _, err := DeleteProfile(app.AppConfig{ConfigDir: dir}, "live", false); !errors.Is(err, ErrProfileActive) {
+		t.Fatalf("expected ErrProfileActive, got %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected profile kept, got %v", err)
+	}
+
+	res, err := DeleteProfile(app.AppConfig{ConfigDir: dir}, "live", true)
+	if err != nil {
+		t.Fatalf("DeleteProfile: %v", err)
+	}
+	if !res.ActiveRemoved {
+		t.Fatalf("expected active symlink removed")
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "oh-my-opencode.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected active symlink gone, got %v", err)
+	}
+	data, err := os.ReadFile(res.Backup)
+	if err != nil {
+		t.Fatalf("read final backup: %v", err)
+	}
+	if string(data) != `{"k":1}` {
+		t.Fatalf("final backup mismatch: %q", data)
+	}
+	if !strings.HasPrefix(filepath.Base(res.Backup), "oh-my-opencode.json.live.bak.") {
+		t.Fatalf("unexpected backup name %q", res.Backup)
+	}
+}
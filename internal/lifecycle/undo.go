@@ -0,0 +1,203 @@
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+	"moirai/internal/journal"
+	"moirai/internal/link"
+	"moirai/internal/profile"
+)
+
+const (
+	activeFileName = "oh-my-opencode.json"
+	profilePrefix  = activeFileName + "."
+)
+
+// ErrUndoConflict indicates that the files an operation wrote changed after
+// it, so undoing it would discard later edits.
+var ErrUndoConflict = errors.New("changed since the operation")
+
+// UndoResult reports what Undo reverted.
+type UndoResult struct {
+	// Undone is the journal entry that was reverted.
+	Undone journal.Entry
+	// Entry is the journal entry recorded for the undo itself.
+	Entry journal.Entry
+}
+
+// Undo reverts a journaled operation: the file it wrote gets back the
+// content saved in the operation's backup and the active symlink its
+// previous target. An empty id selects the newest operation that has not
+// been undone. Unless force is set, Undo refuses when the files changed
+// after the operation. The undo is journaled too, so it can be undone in
+// turn.
+func Undo(config app.AppConfig, id string, force bool) (UndoResult, error) {
+	dir := config.ConfigDir
+	entries, err := journal.Read(dir)
+	if err != nil {
+		return UndoResult{}, err
+	}
+	target, err := undoTarget(entries, id)
+	if err != nil {
+		return UndoResult{}, err
+	}
+	if !force {
+		if err := checkUndoable(dir, target); err != nil {
+			return UndoResult{}, err
+		}
+	}
+
+	entry := journal.Entry{Op: journal.OpUndo, Profile: target.Profile, Undoes: target.ID}
+	if target.SwitchesActive() {
+		if entry.PreviousActive, err = journal.ActiveTarget(dir); err != nil {
+			return UndoResult{}, err
+		}
+		entry.Active = target.PreviousActive
+	}
+	switch {
+	case target.File == activeFileName && target.Backup != "":
+		// The operation replaced a regular active config with a symlink.
+		entry.File = activeFileName
+		if err := restoreBackup(config, target, &entry); err != nil {
+			return UndoResult{}, err
+		}
+	case target.File == activeFileName && target.BeforeHash == "" && !target.SwitchesActive():
+		// The operation wrote a regular active config where there was none.
+		entry.File = activeFileName
+		if entry.BeforeHash, err = journal.HashFile(filepath.Join(dir, activeFileName)); err != nil {
+			return UndoResult{}, err
+		}
+		if err := link.SetActiveTarget(dir, ""); err != nil {
+			return UndoResult{}, err
+		}
+	case target.File != "" && target.File != activeFileName:
+		if err := revertFile(config, target, &entry); err != nil {
+			return UndoResult{}, err
+		}
+		fallthrough
+	default:
+		if target.SwitchesActive() {
+			if err := link.SetActiveTarget(dir, target.PreviousActive); err != nil {
+				return UndoResult{}, err
+			}
+		}
+	}
+
+	recorded, err := journal.Record(dir, entry)
+	if err != nil {
+		return UndoResult{}, fmt.Errorf("journal undo: %w", err)
+	}
+	return UndoResult{Undone: target, Entry: recorded}, nil
+}
+
+func undoTarget(entries []journal.Entry, id string) (journal.Entry, error) {
+	undone := journal.Undone(entries)
+	if id != "" {
+		target, err := journal.Find(entries, id)
+		if err != nil {
+			return journal.Entry{}, err
+		}
+		if undone[target.ID] {
+			return journal.Entry{}, fmt.Errorf("operation %s was already undone", target.ID)
+		}
+		return target, nil
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Op != journal.OpUndo && !undone[entry.ID] {
+			return entry, nil
+		}
+	}
+	return journal.Entry{}, fmt.Errorf("no operation to undo")
+}
+
+// checkUndoable verifies that the file and active symlink the operation
+// wrote still look the way it left them.
+func checkUndoable(dir string, target journal.Entry) error {
+	// A regular active config left by an undo is checked by content, like
+	// profiles.
+	if target.File != "" && (target.File != activeFileName || target.Active == "") {
+		current, err := journal.HashFile(filepath.Join(dir, target.File))
+		if err != nil {
+			return err
+		}
+		if current != target.AfterHash {
+			return fmt.Errorf("%s %w %s (use --force to undo anyway)", target.File, ErrUndoConflict, target.ID)
+		}
+	}
+	if target.SwitchesActive() {
+		current, err := journal.ActiveTarget(dir)
+		if err != nil {
+			return err
+		}
+		if current != target.Active {
+			return fmt.Errorf("active config %w %s (use --force to undo anyway)", ErrUndoConflict, target.ID)
+		}
+	}
+	return nil
+}
+
+// revertFile gives the profile file target wrote its previous content,
+// backing up the current content first, and records the change in entry.
+func revertFile(config app.AppConfig, target journal.Entry, entry *journal.Entry) error {
+	path := filepath.Join(config.ConfigDir, target.File)
+	entry.File = target.File
+	var err error
+	if entry.BeforeHash, err = journal.HashFile(path); err != nil {
+		return err
+	}
+	if entry.BeforeHash != "" {
+		name := strings.TrimPrefix(target.File, profilePrefix)
+		saved, err := backup.BackupProfileWith(backup.NewStore(config), name, backup.Options{Reason: backup.ReasonUndo, Retention: config.BackupRetention})
+		if err != nil {
+			return fmt.Errorf("backup profile: %w", err)
+		}
+		entry.Backup = saved.Path
+	}
+	if target.Backup != "" {
+		return restoreBackup(config, target, entry)
+	}
+	if target.BeforeHash != "" {
+		return fmt.Errorf("operation %s has no backup to restore", target.ID)
+	}
+	// The operation created the file.
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// restoreBackup writes the content of target's backup to its file. A backup
+// that was migrated into the backup store since is found by name.
+func restoreBackup(config app.AppConfig, target journal.Entry, entry *journal.Entry) error {
+	backupPath := target.Backup
+	info, err := os.Stat(backupPath)
+	if os.IsNotExist(err) {
+		if moved, locateErr := backup.Locate(backup.NewStore(config), filepath.Base(backupPath)); locateErr == nil {
+			backupPath = moved
+			info, err = os.Stat(backupPath)
+		}
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("backup %s of operation %s no longer exists", target.Backup, target.ID)
+		}
+		return err
+	}
+	data, err := os.ReadFile(backupPath)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(config.ConfigDir, target.File)
+	if err := profile.SaveProfileDataAtomic(path, data, info.Mode().Perm()); err != nil {
+		return err
+	}
+	entry.AfterHash, err = journal.HashFile(path)
+	return err
+}
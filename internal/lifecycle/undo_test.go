@@ -0,0 +1,127 @@
+package lifecycle
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"moirai/internal/app"
+	"moirai/internal/backup"
+	"moirai/internal/journal"
+	"moirai/internal/link"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestUndoApplyRestoresPreviousActive(t *testing.T) {
+	dir := t.TempDir()
+	writeProfile(t, dir, "alpha", `{"agents":{}}`)
+	writeProfile(t, dir, "beta", `{"agents":{}}`)
+	activate(t, dir, "alpha")
+
+	if err := link.ApplyProfile(dir, "beta"); err != nil {
+		t.Fatalf("ApplyProfile: %v", err)
+	}
+	res, err := Undo(app.AppConfig{ConfigDir: dir}, "", false)
+	if err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if res.Undone.Op != journal.OpApply || res.Entry.Undoes != res.Undone.ID {
+		t.Fatalf("unexpected undo result %#v", res)
+	}
+	if active, _, _ := link.ActiveProfile(dir); active != "alpha" {
+		t.Fatalf("expected alpha active again, got %q", active)
+	}
+	if _, err := Undo(app.AppConfig{ConfigDir: dir}, res.Undone.ID, false); err == nil {
+		t.Fatal("expected error undoing an operation twice")
+	}
+
+	// Undoing the undo switches back to beta.
+	if _, err := Undo(app.AppConfig{ConfigDir: dir}, res.Entry.ID, false); err != nil {
+		t.Fatalf("Undo undo: %v", err)
+	}
+	if active, _, _ := link.ActiveProfile(dir); active != "beta" {
+		t.Fatalf("expected beta active after redo, got %q", active)
+	}
+}
+
+func TestUndoApplyRestoresRegularActiveFile(t *testing.T) {
+	dir := t.TempDir()
+	writeProfile(t, dir, "alpha", `{"agents":{}}`)
+	activePath := filepath.Join(dir, "oh-my-opencode.json")
+	if err := os.WriteFile(activePath, []byte(`{"hand":"written"}`), 0o600); err != nil {
+		t.Fatalf("write active: %v", err)
+	}
+	if err := link.ApplyProfile(dir, "alpha"); err != nil {
+		t.Fatalf("ApplyProfile: %v", err)
+	}
+	if _, err := Undo(app.AppConfig{ConfigDir: dir}, "", false); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	info, err := os.Lstat(activePath)
+	if err != nil || info.Mode()&os.ModeSymlink != 0 || readFile(t, activePath) != `{"hand":"written"}` {
+		t.Fatalf("expected regular active file restored, got %v %v", info, err)
+	}
+}
+
+func TestUndoRestoreAndConflict(t *testing.T) {
+	dir := t.TempDir()
+	path := writeProfile(t, dir, "alpha", `{"v":1}`)
+	first, err := backup.BackupProfile(testStore(dir), "alpha")
+	if err != nil {
+		t.Fatalf("BackupProfile: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"v":2}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := backup.RestoreProfileFromBackup(testStore(dir), "alpha", first, backup.Options{}); err != nil {
+		t.Fatalf("RestoreProfileFromBackup: %v", err)
+	}
+	if readFile(t, path) != `{"v":1}` {
+		t.Fatal("expected restored content")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"v":3}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := Undo(app.AppConfig{ConfigDir: dir}, "", false); !errors.Is(err, ErrUndoConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	res, err := Undo(app.AppConfig{ConfigDir: dir}, "", true)
+	if err != nil {
+		t.Fatalf("Undo --force: %v", err)
+	}
+	if readFile(t, path) != `{"v":2}` || res.Entry.Backup == "" {
+		t.Fatalf("expected content before the restore, got %q %#v", readFile(t, path), res.Entry)
+	}
+}
+
+func TestUndoDeleteRestoresProfileAndActive(t *testing.T) {
+	dir := t.TempDir()
+	path := writeProfile(t, dir, "alpha", `{"agents":{"oracle":{}}}`)
+	activate(t, dir, "alpha")
+	if _, err := DeleteProfile(app.AppConfig{ConfigDir: dir}, "alpha", true); err != nil {
+		t.Fatalf("DeleteProfile: %v", err)
+	}
+	if _, err := Undo(app.AppConfig{ConfigDir: dir}, "", false); err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if readFile(t, path) != `{"agents":{"oracle":{}}}` {
+		t.Fatal("expected profile restored")
+	}
+	if active, _, _ := link.ActiveProfile(dir); active != "alpha" {
+		t.Fatalf("expected alpha active again, got %q", active)
+	}
+
+	if _, err := Undo(app.AppConfig{ConfigDir: dir}, "", false); err == nil {
+		t.Fatal("expected nothing left to undo")
+	}
+}
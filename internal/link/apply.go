@@ -4,14 +4,54 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
+	"moirai/internal/app"
 	"moirai/internal/backup"
+	"moirai/internal/journal"
+	"moirai/internal/profile"
+	"moirai/internal/util"
 )
 
+const resolvedDirName = "resolved"
+
+// ApplyOptions control how a profile is applied.
+type ApplyOptions struct {
+	// Strict fails the apply when an {env:} or {file:} reference cannot be
+	// resolved instead of leaving the placeholder in the active config.
+	Strict bool
+	// Backups is the store a regular active config is backed up to before
+	// it is replaced; the zero value means the default store of the config
+	// dir.
+	Backups backup.Store
+	// Retention is applied to the active config backups.
+	Retention app.BackupRetention
+}
+
+// ApplyResult reports what ApplyProfileWith did.
+type ApplyResult struct {
+	// Unresolved lists the references that could not be resolved.
+	Unresolved []profile.Reference
+	// Entry is the journal entry recorded for the switch; it is zero when the
+	// active config did not change or the switch could not be journaled.
+	Entry journal.Entry
+}
+
 // ApplyProfile switches the active config symlink to the selected profile.
+// Profiles that extend other profiles or contain {env:} and {file:}
+// references are resolved first and the symlink points at the materialized
+// result in the moirai state dir.
 func ApplyProfile(dir, profileName string) error {
-	if profileName == "" {
-		return fmt.Errorf("profile name is required")
+	_, err := ApplyProfileWith(dir, profileName, ApplyOptions{})
+	return err
+}
+
+// ApplyProfileWith is ApplyProfile with options. References that cannot be
+// resolved are reported in the result; in strict mode they fail the apply
+// and the active config is left untouched.
+func ApplyProfileWith(dir, profileName string, opts ApplyOptions) (ApplyResult, error) {
+	if err := profile.ValidateName(profileName); err != nil {
+		return ApplyResult{}, err
 	}
 
 	targetName := profilePrefix + profileName
@@ -19,39 +59,182 @@ func ApplyProfile(dir, profileName string) error {
 	targetInfo, err := os.Stat(targetPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return fmt.Errorf("profile %q not found", profileName)
+			return ApplyResult{}, fmt.Errorf("profile %q not found", profileName)
 		}
-		return err
+		return ApplyResult{}, err
 	}
 	if targetInfo.IsDir() {
-		return fmt.Errorf("profile %q is a directory", profileName)
+		return ApplyResult{}, fmt.Errorf("profile %q is a directory", profileName)
+	}
+
+	linkTarget, unresolved, err := activeLinkTarget(dir, profileName, targetInfo.Mode().Perm(), opts)
+	if err != nil {
+		return ApplyResult{}, err
 	}
 
 	activePath := filepath.Join(dir, activeFileName)
+	entry := journal.Entry{Op: journal.OpApply, Profile: profileName, Active: linkTarget}
 	info, err := os.Lstat(activePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return os.Symlink(targetName, activePath)
+	if err != nil && !os.IsNotExist(err) {
+		return ApplyResult{}, err
+	}
+	switch {
+	case err != nil:
+	case info.Mode()&os.ModeSymlink != 0:
+		if entry.PreviousActive, err = os.Readlink(activePath); err != nil {
+			return ApplyResult{}, err
+		}
+	case !info.Mode().IsRegular():
+		return ApplyResult{}, fmt.Errorf("active config is not a regular file")
+	default:
+		saved, err := backup.BackupActiveWith(opts.Backups.OrDefault(dir), backup.Options{Reason: backup.ReasonApply, Retention: opts.Retention})
+		if err != nil {
+			return ApplyResult{}, fmt.Errorf("backup active config: %w", err)
+		}
+		backupPath := saved.Path
+		entry.File = activeFileName
+		entry.Backup = backupPath
+		if entry.BeforeHash, err = journal.HashFile(backupPath); err != nil {
+			return ApplyResult{}, err
+		}
+	}
+
+	if err := replaceWithSymlink(dir, linkTarget, activePath); err != nil {
+		return ApplyResult{}, err
+	}
+	res := ApplyResult{Unresolved: unresolved}
+	if entry.SwitchesActive() || entry.File != "" {
+		// The switch already happened; failing to journal it only loses
+		// the ability to undo it.
+		if recorded, err := journal.Record(dir, entry); err == nil {
+			res.Entry = recorded
 		}
-		return err
 	}
+	return res, nil
+}
 
-	if info.Mode()&os.ModeSymlink != 0 {
-		if err := os.Remove(activePath); err != nil {
+// SetActiveTarget points the active config symlink at target, relative to
+// dir, or removes the symlink when target is empty. It is used to put back a
+// previous active config without re-resolving a profile.
+func SetActiveTarget(dir, target string) error {
+	activePath := filepath.Join(dir, activeFileName)
+	if target == "" {
+		if err := os.Remove(activePath); err != nil && !os.IsNotExist(err) {
 			return err
 		}
-		return os.Symlink(targetName, activePath)
+		util.SyncDir(dir)
+		return nil
 	}
+	return replaceWithSymlink(dir, target, activePath)
+}
 
-	if !info.Mode().IsRegular() {
-		return fmt.Errorf("active config is not a regular file")
+// Hooks for tests to simulate failures between the steps of a switch.
+var (
+	symlink = os.Symlink
+	rename  = os.Rename
+)
+
+const maxTempLinkAttempts = 10
+
+// replaceWithSymlink points activePath at target without a window where
+// activePath is missing: the link is created under a temporary name and then
+// renamed over activePath, which atomically replaces a previous symlink or
+// regular file. On failure activePath is left untouched.
+func replaceWithSymlink(dir, target, activePath string) error {
+	var tempPath string
+	for attempt := 0; ; attempt++ {
+		tempPath = filepath.Join(dir, fmt.Sprintf(".tmp-link-%d-%d", os.Getpid(), time.Now().UnixNano()))
+		err := symlink(target, tempPath)
+		if err == nil {
+			break
+		}
+		if os.IsExist(err) && attempt < maxTempLinkAttempts {
+			continue
+		}
+		return err
 	}
+	if err := rename(tempPath, activePath); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	util.SyncDir(dir)
+	return nil
+}
 
-	if _, err := backup.BackupActive(dir); err != nil {
-		return fmt.Errorf("backup active config: %w", err)
+// activeLinkTarget returns the symlink target, relative to dir, for the
+// profile, and the references left unresolved. Plain profiles are linked
+// directly so edits take effect immediately; extended profiles and profiles
+// with references are materialized and must be re-applied after their
+// parents or referenced values change.
+func activeLinkTarget(dir, profileName string, perm os.FileMode, opts ApplyOptions) (string, []profile.Reference, error) {
+	targetName := profilePrefix + profileName
+	targetPath := filepath.Join(dir, targetName)
+	cfg, err := profile.LoadProfile(targetPath)
+	if err != nil {
+		// Unparseable profiles are linked as-is; opencode reports the error.
+		return targetName, nil, nil
+	}
+	meta, err := profile.ProfileMetadata(cfg)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(meta.Extends) == 0 {
+		data, err := os.ReadFile(targetPath)
+		if err != nil {
+			return "", nil, err
+		}
+		refs, err := profile.FindReferences(data)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(refs) == 0 {
+			return targetName, nil, nil
+		}
+	}
+	resolved, err := profile.ResolveProfile(dir, profileName)
+	if err != nil {
+		return "", nil, fmt.Errorf("resolve profile %q: %w", profileName, err)
+	}
+	data, refs, err := profile.ExpandReferences(resolved.Data, profile.ReferenceOptions{BaseDir: dir})
+	if err != nil {
+		return "", nil, err
 	}
-	if err := os.Remove(activePath); err != nil {
+	if opts.Strict {
+		if err := profile.UnresolvedError(profileName, refs); err != nil {
+			return "", nil, err
+		}
+	}
+	if len(refs) > 0 {
+		// Referenced values are often secrets kept out of the profile.
+		perm &^= 0o077
+	}
+	resolvedDir := filepath.Join(app.StateDir(dir), resolvedDirName)
+	if err := os.MkdirAll(resolvedDir, 0o755); err != nil {
+		return "", nil, err
+	}
+	resolvedPath := filepath.Join(resolvedDir, targetName)
+	if err := profile.SaveProfileDataAtomic(resolvedPath, data, perm); err != nil {
+		return "", nil, err
+	}
+	target, err := filepath.Rel(dir, resolvedPath)
+	if err != nil {
+		return "", nil, err
+	}
+	return target, profile.UnresolvedReferences(refs), nil
+}
+
+// DeactivateProfile removes the active config symlink, leaving profiles untouched.
+func DeactivateProfile(dir string) error {
+	activePath := filepath.Join(dir, activeFileName)
+	info, err := os.Lstat(activePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
 		return err
 	}
-	return os.Symlink(targetName, activePath)
+	if info.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("active config is not a symlink")
+	}
+	return os.Remove(activePath)
 }
@@ -1,10 +1,13 @@
 package link
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"moirai/internal/app"
 )
 
 func requireSymlink(t *testing.T) {
@@ -105,7 +108,8 @@ func TestApplyProfileBacksUpRegularFile(t *testing.T) {
 		t.Fatalf("ApplyProfile: %v", err)
 	}
 
-	entries, err := os.ReadDir(dir)
+	activeBackups := filepath.Join(app.BackupsDir(dir), "_active")
+	entries, err := os.ReadDir(activeBackups)
 	if err != nil {
 		t.Fatalf("readdir: %v", err)
 	}
@@ -120,7 +124,7 @@ func TestApplyProfileBacksUpRegularFile(t *testing.T) {
 		t.Fatalf("expected backup file")
 	}
 
-	backupPath := filepath.Join(dir, backupName)
+	backupPath := filepath.Join(activeBackups, backupName)
 	backupContent, err := os.ReadFile(backupPath)
 	if err != nil {
 		t.Fatalf("read backup: %v", err)
@@ -154,6 +158,16 @@ func TestApplyProfileMissingProfile(t *testing.T) {
 	}
 }
 
+func TestApplyProfileRejectsPathInName(t *testing.T) {
+	dir := t.TempDir()
+	if err := ApplyProfile(dir, "x/../../evil.json"); err == nil || !strings.Contains(err.Error(), "invalid profile name") {
+		t.Fatalf("expected invalid name error, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, activeFileName)); !os.IsNotExist(err) {
+		t.Fatalf("expected no active config to be written, got %v", err)
+	}
+}
+
 func TestApplyProfileActiveNotRegular(t *testing.T) {
 	requireSymlink(t)
 	dir := t.TempDir()
@@ -174,3 +188,190 @@ func TestApplyProfileActiveNotRegular(t *testing.T) {
 		t.Fatalf("expected error for non-regular active path")
 	}
 }
+
+func TestApplyProfileMaterializesExtendedProfile(t *testing.T) {
+	requireSymlink(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "oh-my-opencode.json.base"), []byte(`{"agents":{"oracle":{"model":"base"}}}`), 0o600); err != nil {
+		t.Fatalf("write base: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "oh-my-opencode.json.child"), []byte(`{"moirai":{"extends":["base"]},"extra":1}`), 0o600); err != nil {
+		t.Fatalf("write child: %v", err)
+	}
+
+	if err := ApplyProfile(dir, "child"); err != nil {
+		t.Fatalf("ApplyProfile: %v", err)
+	}
+	activePath := filepath.Join(dir, "oh-my-opencode.json")
+	target, err := os.Readlink(activePath)
+	if err != nil {
+		t.Fatalf("readlink: %v", err)
+	}
+	if target != filepath.Join("moirai", "resolved", "oh-my-opencode.json.child") {
+		t.Fatalf("unexpected link target %q", target)
+	}
+	data, err := os.ReadFile(activePath)
+	if err != nil {
+		t.Fatalf("read active: %v", err)
+	}
+	content := string(data)
+	if !strings.Contains(content, `"model": "base"`) || !strings.Contains(content, `"extra": 1`) || strings.Contains(content, `"moirai"`) {
+		t.Fatalf("unexpected materialized content:\n%s", content)
+	}
+	name, ok, err := ActiveProfile(dir)
+	if err != nil || !ok || name != "child" {
+		t.Fatalf("expected active child, got %q %v %v", name, ok, err)
+	}
+}
+
+func stubSwitchSteps(t *testing.T, symlinkFn func(string, string) error, renameFn func(string, string) error) {
+	t.Helper()
+	origSymlink, origRename := symlink, rename
+	if symlinkFn != nil {
+		symlink = symlinkFn
+	}
+	if renameFn != nil {
+		rename = renameFn
+	}
+	t.Cleanup(func() {
+		symlink, rename = origSymlink, origRename
+	})
+}
+
+func assertNoTempLinks(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("readdir: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".tmp-") {
+			t.Fatalf("unexpected leftover temp file %q", entry.Name())
+		}
+	}
+}
+
+func TestApplyProfileKeepsSymlinkWhenRenameFails(t *testing.T) {
+	requireSymlink(t)
+	dir := t.TempDir()
+	for _, name := range []string{"old", "new"} {
+		if err := os.WriteFile(filepath.Join(dir, "oh-my-opencode.json."+name), []byte("{}"), 0o600); err != nil {
+			t.Fatalf("write profile: %v", err)
+		}
+	}
+	if err := ApplyProfile(dir, "old"); err != nil {
+		t.Fatalf("ApplyProfile old: %v", err)
+	}
+
+	stubSwitchSteps(t, nil, func(string, string) error {
+		return errors.New("simulated crash before rename")
+	})
+	if err := ApplyProfile(dir, "new"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	target, err := os.Readlink(filepath.Join(dir, "oh-my-opencode.json"))
+	if err != nil {
+		t.Fatalf("readlink: %v", err)
+	}
+	if target != "oh-my-opencode.json.old" {
+		t.Fatalf("expected active to stay on old, got %q", target)
+	}
+	assertNoTempLinks(t, dir)
+}
+
+func TestApplyProfileKeepsRegularFileWhenSwitchFails(t *testing.T) {
+	requireSymlink(t)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "oh-my-opencode.json.alpha"), []byte("{}"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	activePath := filepath.Join(dir, "oh-my-opencode.json")
+	original := []byte(`{"k":"v"}`)
+
+	failing := func(string, string) error {
+		return errors.New("simulated crash")
+	}
+	steps := []struct {
+		name      string
+		symlinkFn func(string, string) error
+		renameFn  func(string, string) error
+	}{
+		{name: "symlink", symlinkFn: failing, renameFn: os.Rename},
+		{name: "rename", symlinkFn: os.Symlink, renameFn: failing},
+	}
+	for _, tc := range steps {
+		step := tc.name
+		if err := os.WriteFile(activePath, original, 0o600); err != nil {
+			t.Fatalf("write active: %v", err)
+		}
+		stubSwitchSteps(t, tc.symlinkFn, tc.renameFn)
+		if err := ApplyProfile(dir, "alpha"); err == nil {
+			t.Fatalf("%s: expected error", step)
+		}
+
+		info, err := os.Lstat(activePath)
+		if err != nil {
+			t.Fatalf("%s: lstat active: %v", step, err)
+		}
+		if !info.Mode().IsRegular() {
+			t.Fatalf("%s: expected active to remain a regular file", step)
+		}
+		data, err := os.ReadFile(activePath)
+		if err != nil || string(data) != string(original) {
+			t.Fatalf("%s: active content changed: %q %v", step, data, err)
+		}
+		assertNoTempLinks(t, dir)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(app.BackupsDir(dir), "_active", "oh-my-opencode.json.bak.*"))
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	if len(matches) == 0 {
+		t.Fatal("expected backup of the active file before switching")
+	}
+}
+
+func TestApplyProfileExpandsReferences(t *testing.T) {
+	requireSymlink(t)
+	dir := t.TempDir()
+	t.Setenv("MOIRAI_TEST_HOST", "example.com")
+	if err := os.WriteFile(filepath.Join(dir, "oh-my-opencode.json.old"), []byte("{}"), 0o600); err != nil {
+		t.Fatalf("write old: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "oh-my-opencode.json.refs"), []byte(`{"url":"https://{env:MOIRAI_TEST_HOST}","model":"{env:MOIRAI_TEST_UNSET}"}`), 0o644); err != nil {
+		t.Fatalf("write refs: %v", err)
+	}
+	if err := ApplyProfile(dir, "old"); err != nil {
+		t.Fatalf("ApplyProfile old: %v", err)
+	}
+	activePath := filepath.Join(dir, "oh-my-opencode.json")
+
+	if _, err := ApplyProfileWith(dir, "refs", ApplyOptions{Strict: true}); err == nil || !strings.Contains(err.Error(), "MOIRAI_TEST_UNSET") {
+		t.Fatalf("expected strict apply to fail, got %v", err)
+	}
+	if target, err := os.Readlink(activePath); err != nil || target != "oh-my-opencode.json.old" {
+		t.Fatalf("expected active to stay on old, got %q %v", target, err)
+	}
+
+	res, err := ApplyProfileWith(dir, "refs", ApplyOptions{})
+	if err != nil {
+		t.Fatalf("ApplyProfileWith: %v", err)
+	}
+	if len(res.Unresolved) != 1 || res.Unresolved[0].Path != "model" {
+		t.Fatalf("unexpected unresolved references %#v", res.Unresolved)
+	}
+	target, err := os.Readlink(activePath)
+	if err != nil || target != filepath.Join("moirai", "resolved", "oh-my-opencode.json.refs") {
+		t.Fatalf("expected materialized link, got %q %v", target, err)
+	}
+	data, err := os.ReadFile(activePath)
+	if err != nil || !strings.Contains(string(data), `"https://example.com"`) || !strings.Contains(string(data), "{env:MOIRAI_TEST_UNSET}") {
+		t.Fatalf("unexpected materialized content %q %v", data, err)
+	}
+	info, err := os.Stat(activePath)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected private materialized file, got %v %v", info.Mode(), err)
+	}
+}
@@ -0,0 +1,3 @@
+// Package lock provides the advisory lock that serializes moirai processes
+// modifying the same config dir.
+package lock
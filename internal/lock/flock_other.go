@@ -0,0 +1,15 @@
+//go:build !unix
+
+package lock
+
+import "os"
+
+// Platforms without flock get no cross-process exclusion; the lock file is
+// still written so other tools can see who is working on the config dir.
+func tryLock(_ *os.File) (bool, error) {
+	return true, nil
+}
+
+func unlock(_ *os.File) error {
+	return nil
+}
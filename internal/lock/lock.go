@@ -0,0 +1,104 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FileName is the lock file created in the config dir.
+const FileName = ".moirai.lock"
+
+const pollInterval = 50 * time.Millisecond
+
+// LockedError reports that another process holds the config dir lock.
+type LockedError struct {
+	Path string
+	PID  int
+}
+
+func (e *LockedError) Error() string {
+	if e.PID > 0 {
+		return fmt.Sprintf("config dir is locked by pid %d (%s)", e.PID, e.Path)
+	}
+	return fmt.Sprintf("config dir is locked by another process (%s)", e.Path)
+}
+
+// Lock is a held config dir lock.
+type Lock struct {
+	file *os.File
+}
+
+// Acquire takes the lock for dir, waiting up to timeout for another holder
+// to release it. The lock is released by Release or when the process exits.
+func Acquire(dir string, timeout time.Duration) (*Lock, error) {
+	path := filepath.Join(dir, FileName)
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := tryLock(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		if ok {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			_ = file.Close()
+			return nil, &LockedError{Path: path, PID: readPID(path)}
+		}
+		time.Sleep(pollInterval)
+	}
+
+	if err := writePID(file); err != nil {
+		_ = unlock(file)
+		_ = file.Close()
+		return nil, err
+	}
+	return &Lock{file: file}, nil
+}
+
+// Release drops the lock. It is safe to call on a nil or released lock.
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	// The file itself is kept: removing it would let a waiter lock an
+	// unlinked inode while a newcomer locks a fresh file.
+	_ = l.file.Truncate(0)
+	err := unlock(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
+
+func writePID(file *os.File) error {
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+func readPID(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,87 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireWritesPIDAndRelease(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Acquire(dir, time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatalf("read lock file: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != fmt.Sprint(os.Getpid()) {
+		t.Fatalf("unexpected lock content %q", data)
+	}
+	if err := l.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if err := l.Release(); err != nil {
+		t.Fatalf("second Release: %v", err)
+	}
+
+	again, err := Acquire(dir, time.Second)
+	if err != nil {
+		t.Fatalf("Acquire after release: %v", err)
+	}
+	_ = again.Release()
+}
+
+func TestAcquireTimesOutWhileHeld(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("flock not available")
+	}
+	dir := t.TempDir()
+	held, err := Acquire(dir, time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer held.Release()
+
+	start := time.Now()
+	_, err = Acquire(dir, 120*time.Millisecond)
+	var locked *LockedError
+	if !errors.As(err, &locked) {
+		t.Fatalf("expected LockedError, got %v", err)
+	}
+	if locked.PID != os.Getpid() {
+		t.Fatalf("expected pid %d, got %d", os.Getpid(), locked.PID)
+	}
+	if !strings.Contains(err.Error(), fmt.Sprintf("locked by pid %d", os.Getpid())) {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Fatal("expected Acquire to wait for the timeout")
+	}
+}
+
+func TestAcquireWaitsForRelease(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("flock not available")
+	}
+	dir := t.TempDir()
+	held, err := Acquire(dir, time.Second)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = held.Release()
+	}()
+	l, err := Acquire(dir, 5*time.Second)
+	if err != nil {
+		t.Fatalf("expected lock after release, got %v", err)
+	}
+	_ = l.Release()
+}
@@ -0,0 +1,3 @@
+// Package presets loads autofill presets from the built-in defaults,
+// moirai.json and the presets directory, and resolves preset inheritance.
+package presets
@@ -0,0 +1,182 @@
+package presets
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"moirai/internal/app"
+	"moirai/internal/profile"
+)
+
+// Source records where a preset was defined.
+type Source string
+
+const (
+	SourceBuiltin Source = "builtin"
+	SourceConfig  Source = "config"
+	SourceFile    Source = "file"
+)
+
+// Definition is a preset as written, before inheritance is resolved.
+type Definition struct {
+	Name   string
+	Spec   app.PresetSpec
+	Source Source
+	// Path is the preset file for SourceFile definitions.
+	Path string
+}
+
+// Resolved is a preset with its inheritance chain applied.
+type Resolved struct {
+	profile.Preset
+	Description string
+	// Chain lists the preset and its ancestors, nearest first.
+	Chain []string
+}
+
+// Set holds every preset available for a config dir.
+type Set struct {
+	defs map[string]Definition
+}
+
+var builtinNames = []string{"openai"}
+
+const presetFileExt = ".json"
+
+// Builtin returns the presets bundled with moirai.
+func Builtin() Set {
+	s := Set{defs: make(map[string]Definition)}
+	for _, name := range builtinNames {
+		preset, _ := profile.PresetByName(name)
+		s.defs[name] = Definition{
+			Name:   name,
+			Spec:   app.PresetSpec{Description: "Every agent on " + preset.Model, Default: preset.Model},
+			Source: SourceBuiltin,
+		}
+	}
+	return s
+}
+
+// Load returns the built-in presets overridden by presets from moirai.json
+// and from <name>.json files in the presets directory. A preset defined in
+// both moirai.json and the presets directory is an error.
+func Load(config app.AppConfig) (Set, error) {
+	s := Builtin()
+	for name, spec := range config.Presets {
+		if err := validateName(name); err != nil {
+			return Set{}, fmt.Errorf("moirai.json: %w", err)
+		}
+		s.defs[name] = Definition{Name: name, Spec: spec, Source: SourceConfig}
+	}
+
+	dir := app.PresetsDir(config.ConfigDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return Set{}, err
+	}
+	for _, entry := range entries {
+		fileName := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(fileName, presetFileExt) || strings.HasPrefix(fileName, ".") {
+			continue
+		}
+		name := strings.TrimSuffix(fileName, presetFileExt)
+		path := filepath.Join(dir, fileName)
+		if existing, ok := s.defs[name]; ok && existing.Source == SourceConfig {
+			return Set{}, fmt.Errorf("preset %q is defined in both moirai.json and %s", name, path)
+		}
+		if err := validateName(name); err != nil {
+			return Set{}, fmt.Errorf("%s: %w", path, err)
+		}
+		spec, err := readPresetFile(path)
+		if err != nil {
+			return Set{}, err
+		}
+		s.defs[name] = Definition{Name: name, Spec: spec, Source: SourceFile, Path: path}
+	}
+	return s, nil
+}
+
+func readPresetFile(path string) (app.PresetSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return app.PresetSpec{}, err
+	}
+	var spec app.PresetSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return app.PresetSpec{}, fmt.Errorf("parse preset %s: %w", path, err)
+	}
+	return spec, nil
+}
+
+func validateName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("preset name must not be empty")
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid preset name %q", name)
+	}
+	return nil
+}
+
+// List returns every preset definition sorted by name.
+func (s Set) List() []Definition {
+	defs := make([]Definition, 0, len(s.defs))
+	for _, def := range s.defs {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// Lookup returns the definition of a preset without resolving inheritance.
+func (s Set) Lookup(name string) (Definition, bool) {
+	def, ok := s.defs[name]
+	return def, ok
+}
+
+// Resolve applies the inheritance chain of a preset. Agent mappings of a
+// preset override those of the preset it extends; the default model is
+// inherited unless the preset sets its own.
+func (s Set) Resolve(name string) (Resolved, error) {
+	var chain []string
+	seen := make(map[string]bool)
+	for current := name; current != ""; {
+		if seen[current] {
+			return Resolved{}, fmt.Errorf("preset %q: inheritance cycle through %q", name, current)
+		}
+		seen[current] = true
+		def, ok := s.defs[current]
+		if !ok {
+			if current == name {
+				return Resolved{}, fmt.Errorf("unknown preset: %s", name)
+			}
+			return Resolved{}, fmt.Errorf("preset %q extends unknown preset %q", chain[len(chain)-1], current)
+		}
+		chain = append(chain, current)
+		current = def.Spec.Extends
+	}
+
+	resolved := Resolved{
+		Preset: profile.Preset{Name: name, Agents: make(map[string]string)},
+		Chain:  chain,
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		spec := s.defs[chain[i]].Spec
+		if spec.Default != "" {
+			resolved.Model = spec.Default
+		}
+		if spec.Description != "" {
+			resolved.Description = spec.Description
+		}
+		for agent, model := range spec.Agents {
+			if strings.TrimSpace(model) != "" {
+				resolved.Agents[agent] = model
+			}
+		}
+	}
+	return resolved, nil
+}
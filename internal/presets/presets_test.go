@@ -0,0 +1,123 @@
+package presets
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"moirai/internal/app"
+)
+
+func writePresetFile(t *testing.T, configDir, name, content string) {
+	t.Helper()
+	dir := app.PresetsDir(configDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir presets: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".json"), []byte(content), 0o600); err != nil {
+		t.Fatalf("write preset: %v", err)
+	}
+}
+
+func TestBuiltinOpenAI(t *testing.T) {
+	resolved, err := Builtin().Resolve("openai")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if resolved.Model != "gpt-4o-mini" || resolved.ModelFor("oracle") != "gpt-4o-mini" {
+		t.Fatalf("unexpected built-in preset %#v", resolved)
+	}
+}
+
+func TestLoadMergesConfigAndDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writePresetFile(t, dir, "cheap", `{"description":"Cheap","extends":"team","agents":{"explore":"mini"}}`)
+
+	set, err := Load(app.AppConfig{
+		ConfigDir: dir,
+		Presets: map[string]app.PresetSpec{
+			"team": {Default: "gpt-4.1", Agents: map[string]string{"oracle": "o3", "explore": "gpt-4.1"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	var names []string
+	for _, def := range set.List() {
+		names = append(names, def.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"cheap", "openai", "team"}) {
+		t.Fatalf("unexpected presets %v", names)
+	}
+	def, _ := set.Lookup("cheap")
+	if def.Source != SourceFile || def.Path == "" {
+		t.Fatalf("unexpected definition %#v", def)
+	}
+
+	resolved, err := set.Resolve("cheap")
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if !reflect.DeepEqual(resolved.Chain, []string{"cheap", "team"}) {
+		t.Fatalf("unexpected chain %v", resolved.Chain)
+	}
+	if resolved.ModelFor("explore") != "mini" || resolved.ModelFor("oracle") != "o3" || resolved.ModelFor("atlas") != "gpt-4.1" {
+		t.Fatalf("unexpected resolved models %#v", resolved.Agents)
+	}
+	if resolved.Description != "Cheap" {
+		t.Fatalf("unexpected description %q", resolved.Description)
+	}
+}
+
+func TestLoadOverridesBuiltin(t *testing.T) {
+	set, err := Load(app.AppConfig{
+		ConfigDir: t.TempDir(),
+		Presets:   map[string]app.PresetSpec{"openai": {Default: "gpt-4.1"}},
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	resolved, err := set.Resolve("openai")
+	if err != nil || resolved.Model != "gpt-4.1" {
+		t.Fatalf("expected override, got %#v %v", resolved, err)
+	}
+}
+
+func TestLoadRejectsDuplicateDefinition(t *testing.T) {
+	dir := t.TempDir()
+	writePresetFile(t, dir, "team", `{"default":"a"}`)
+	_, err := Load(app.AppConfig{
+		ConfigDir: dir,
+		Presets:   map[string]app.PresetSpec{"team": {Default: "b"}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "defined in both") {
+		t.Fatalf("expected duplicate error, got %v", err)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	set, err := Load(app.AppConfig{
+		ConfigDir: t.TempDir(),
+		Presets: map[string]app.PresetSpec{
+			"a":      {Extends: "b"},
+			"b":      {Extends: "a"},
+			"orphan": {Extends: "missing"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cases := map[string]string{
+		"a":       "cycle",
+		"orphan":  `extends unknown preset "missing"`,
+		"nothing": "unknown preset: nothing",
+	}
+	for name, want := range cases {
+		if _, err := set.Resolve(name); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %q, got %v", name, want, err)
+		}
+	}
+}
@@ -1,14 +1,29 @@
 package profile
 
-import "strings"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
 
 // Preset describes autofill defaults for agents.
 type Preset struct {
-	Name  string
+	Name string
+	// Model is the fallback for agents without an entry in Agents.
 	Model string
+	// Agents maps agent names to the model they are filled with.
+	Agents map[string]string
 }
 
-// PresetByName resolves a preset by name.
+// ModelFor returns the model the preset assigns to agent, or "" if none.
+func (p Preset) ModelFor(agent string) string {
+	if model, ok := p.Agents[agent]; ok && strings.TrimSpace(model) != "" {
+		return model
+	}
+	return p.Model
+}
+
+// PresetByName resolves a built-in preset by name.
 func PresetByName(name string) (Preset, bool) {
 	switch name {
 	case "openai":
@@ -18,28 +33,167 @@ func PresetByName(name string) (Preset, bool) {
 	}
 }
 
-// ApplyAutofill updates missing agents or empty models and reports changes.
-func ApplyAutofill(cfg *RootConfig, knownAgents []string, preset Preset) bool {
-	if cfg == nil {
-		return false
+// AutofillMode selects which agents autofill changes.
+type AutofillMode string
+
+const (
+	// AutofillMissing fills agents that are absent or have no model.
+	AutofillMissing AutofillMode = "missing"
+	// AutofillOverwrite resets every agent to the preset's model.
+	AutofillOverwrite AutofillMode = "overwrite"
+	// AutofillUpgrade replaces models that are not in the available model list.
+	AutofillUpgrade AutofillMode = "upgrade"
+	// AutofillOnly resets the listed agents to the preset's model.
+	AutofillOnly AutofillMode = "only"
+)
+
+// AutofillOptions configures PlanAutofill.
+type AutofillOptions struct {
+	Mode AutofillMode
+	// Agents lists the agents changed by AutofillOnly.
+	Agents []string
+	// Models is the available model list checked by AutofillUpgrade.
+	Models []string
+}
+
+// ParseAutofillMode parses "missing", "overwrite", "upgrade" or
+// "only=<agent>[,<agent>...]". An empty value selects AutofillMissing.
+func ParseAutofillMode(value string) (AutofillOptions, error) {
+	switch value {
+	case "", string(AutofillMissing):
+		return AutofillOptions{Mode: AutofillMissing}, nil
+	case string(AutofillOverwrite):
+		return AutofillOptions{Mode: AutofillOverwrite}, nil
+	case string(AutofillUpgrade):
+		return AutofillOptions{Mode: AutofillUpgrade}, nil
 	}
-	if cfg.Agents == nil {
-		cfg.Agents = make(map[string]AgentConfig)
+	list, ok := strings.CutPrefix(value, string(AutofillOnly)+"=")
+	if !ok {
+		return AutofillOptions{}, fmt.Errorf("invalid autofill mode %q (want missing, overwrite, upgrade or only=<agents>)", value)
+	}
+	var agents []string
+	for _, agent := range strings.Split(list, ",") {
+		if agent = strings.TrimSpace(agent); agent != "" {
+			agents = append(agents, agent)
+		}
+	}
+	if len(agents) == 0 {
+		return AutofillOptions{}, fmt.Errorf("autofill mode only= needs at least one agent")
+	}
+	return AutofillOptions{Mode: AutofillOnly, Agents: agents}, nil
+}
+
+// String formats the options the way ParseAutofillMode accepts them.
+func (o AutofillOptions) String() string {
+	if o.Mode == AutofillOnly {
+		return string(AutofillOnly) + "=" + strings.Join(o.Agents, ",")
+	}
+	if o.Mode == "" {
+		return string(AutofillMissing)
 	}
+	return string(o.Mode)
+}
+
+// AgentChange is one model change planned by autofill. From is empty for
+// agents that are absent or have no model.
+type AgentChange struct {
+	Agent string `json:"agent"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
 
-	changed := false
-	for _, agent := range knownAgents {
-		entry, ok := cfg.Agents[agent]
-		if !ok {
-			cfg.Agents[agent] = AgentConfig{Model: preset.Model}
-			changed = true
+// PlanAutofill lists the model changes autofill would make, without
+// modifying cfg. knownAgents are the agents filled by AutofillMissing and
+// reset by AutofillOverwrite in addition to the agents already configured.
+// Agents the preset has no model for are never changed.
+func PlanAutofill(cfg *RootConfig, knownAgents []string, preset Preset, opts AutofillOptions) ([]AgentChange, error) {
+	var current map[string]AgentConfig
+	if cfg != nil {
+		current = cfg.Agents
+	}
+
+	var candidates []string
+	switch opts.Mode {
+	case "", AutofillMissing:
+		candidates = knownAgents
+	case AutofillOverwrite:
+		candidates = append(append([]string(nil), knownAgents...), sortedAgentNames(current)...)
+	case AutofillUpgrade:
+		if len(opts.Models) == 0 {
+			return nil, fmt.Errorf("upgrade mode needs the cached model list; refresh models first")
+		}
+		candidates = sortedAgentNames(current)
+	case AutofillOnly:
+		if len(opts.Agents) == 0 {
+			return nil, fmt.Errorf("autofill mode only= needs at least one agent")
+		}
+		candidates = opts.Agents
+	default:
+		return nil, fmt.Errorf("invalid autofill mode %q", opts.Mode)
+	}
+
+	available := make(map[string]bool, len(opts.Models))
+	for _, model := range opts.Models {
+		available[model] = true
+	}
+	seen := make(map[string]bool, len(candidates))
+	var changes []AgentChange
+	for _, agent := range candidates {
+		if seen[agent] {
+			continue
+		}
+		seen[agent] = true
+		to := preset.ModelFor(agent)
+		if to == "" {
 			continue
 		}
-		if strings.TrimSpace(entry.Model) == "" {
-			entry.Model = preset.Model
-			cfg.Agents[agent] = entry
-			changed = true
+		from := strings.TrimSpace(current[agent].Model)
+		switch opts.Mode {
+		case "", AutofillMissing:
+			if from != "" {
+				continue
+			}
+		case AutofillUpgrade:
+			if from == "" || available[from] {
+				continue
+			}
+		}
+		if from == to {
+			continue
 		}
+		changes = append(changes, AgentChange{Agent: agent, From: from, To: to})
+	}
+	return changes, nil
+}
+
+func sortedAgentNames(agents map[string]AgentConfig) []string {
+	names := make([]string, 0, len(agents))
+	for name := range agents {
+		names = append(names, name)
 	}
-	return changed
+	sort.Strings(names)
+	return names
+}
+
+// ApplyAgentChanges writes planned model changes into cfg, keeping the other
+// settings of each agent, and reports whether anything changed.
+func ApplyAgentChanges(cfg *RootConfig, changes []AgentChange) bool {
+	if cfg == nil || len(changes) == 0 {
+		return false
+	}
+	if cfg.Agents == nil {
+		cfg.Agents = make(map[string]AgentConfig)
+	}
+	for _, change := range changes {
+		entry := cfg.Agents[change.Agent]
+		entry.Model = change.To
+		cfg.Agents[change.Agent] = entry
+	}
+	return true
+}
+
+// ApplyAutofill fills absent agents and empty models and reports changes.
+func ApplyAutofill(cfg *RootConfig, knownAgents []string, preset Preset) bool {
+	changes, _ := PlanAutofill(cfg, knownAgents, preset, AutofillOptions{Mode: AutofillMissing})
+	return ApplyAgentChanges(cfg, changes)
 }
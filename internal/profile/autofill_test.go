@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -50,6 +51,30 @@ func TestApplyAutofillKeepsExistingModel(t *testing.T) {
 	}
 }
 
+func TestApplyAutofillUsesPerAgentModels(t *testing.T) {
+	cfg := &RootConfig{}
+	preset := Preset{
+		Name:   "mixed",
+		Agents: map[string]string{"oracle": "o3", "explore": "mini"},
+	}
+
+	if !ApplyAutofill(cfg, []string{"oracle", "explore", "atlas"}, preset) {
+		t.Fatal("expected changes from autofill")
+	}
+	if cfg.Agents["oracle"].Model != "o3" || cfg.Agents["explore"].Model != "mini" {
+		t.Fatalf("unexpected models: %#v", cfg.Agents)
+	}
+	if _, ok := cfg.Agents["atlas"]; ok {
+		t.Fatal("expected agent without mapping or default to be left alone")
+	}
+
+	preset.Model = "fallback"
+	ApplyAutofill(cfg, []string{"atlas"}, preset)
+	if cfg.Agents["atlas"].Model != "fallback" {
+		t.Fatalf("expected fallback model, got %q", cfg.Agents["atlas"].Model)
+	}
+}
+
 func TestSaveProfileAtomicPreservesUnknownFields(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "oh-my-opencode.json.alpha")
@@ -122,3 +147,92 @@ func TestSaveProfileAtomicPreservesUnknownFields(t *testing.T) {
 		t.Fatalf("expected oracle model unchanged, got %v", oracle["model"])
 	}
 }
+
+func TestParseAutofillMode(t *testing.T) {
+	cases := map[string]AutofillOptions{
+		"":               {Mode: AutofillMissing},
+		"overwrite":      {Mode: AutofillOverwrite},
+		"upgrade":        {Mode: AutofillUpgrade},
+		"only=oracle, x": {Mode: AutofillOnly, Agents: []string{"oracle", "x"}},
+	}
+	for value, want := range cases {
+		got, err := ParseAutofillMode(value)
+		if err != nil {
+			t.Fatalf("%q: %v", value, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q: expected %#v, got %#v", value, want, got)
+		}
+	}
+	for _, value := range []string{"only=", "replace"} {
+		if _, err := ParseAutofillMode(value); err == nil {
+			t.Fatalf("%q: expected error", value)
+		}
+	}
+}
+
+func TestPlanAutofillModes(t *testing.T) {
+	newConfig := func() *RootConfig {
+		return &RootConfig{Agents: map[string]AgentConfig{
+			"oracle":  {Model: "retired"},
+			"explore": {Model: "fast"},
+			"custom":  {Model: ""},
+		}}
+	}
+	preset := Preset{Name: "p", Model: "fast", Agents: map[string]string{"oracle": "o3"}}
+	known := []string{"oracle", "explore", "atlas"}
+
+	cases := []struct {
+		name string
+		opts AutofillOptions
+		want []AgentChange
+	}{
+		{
+			name: "missing",
+			opts: AutofillOptions{Mode: AutofillMissing},
+			want: []AgentChange{{Agent: "atlas", To: "fast"}},
+		},
+		{
+			name: "overwrite",
+			opts: AutofillOptions{Mode: AutofillOverwrite},
+			want: []AgentChange{
+				{Agent: "oracle", From: "retired", To: "o3"},
+				{Agent: "atlas", To: "fast"},
+				{Agent: "custom", To: "fast"},
+			},
+		},
+		{
+			name: "upgrade",
+			opts: AutofillOptions{Mode: AutofillUpgrade, Models: []string{"fast", "o3"}},
+			want: []AgentChange{{Agent: "oracle", From: "retired", To: "o3"}},
+		},
+		{
+			name: "only",
+			opts: AutofillOptions{Mode: AutofillOnly, Agents: []string{"oracle"}},
+			want: []AgentChange{{Agent: "oracle", From: "retired", To: "o3"}},
+		},
+	}
+	for _, tc := range cases {
+		cfg := newConfig()
+		got, err := PlanAutofill(cfg, known, preset, tc.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: expected %#v, got %#v", tc.name, tc.want, got)
+		}
+		if cfg.Agents["oracle"].Model != "retired" {
+			t.Fatalf("%s: plan must not modify the config", tc.name)
+		}
+		ApplyAgentChanges(cfg, got)
+		for _, change := range got {
+			if cfg.Agents[change.Agent].Model != change.To {
+				t.Fatalf("%s: change not applied to %s", tc.name, change.Agent)
+			}
+		}
+	}
+
+	if _, err := PlanAutofill(newConfig(), known, preset, AutofillOptions{Mode: AutofillUpgrade}); err == nil {
+		t.Fatal("expected upgrade without a model list to fail")
+	}
+}
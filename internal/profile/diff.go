@@ -1,65 +1,302 @@
 package profile
 
 import (
-	"errors"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
-	"moirai/internal/util"
+	"moirai/internal/jsonc"
 )
 
-// DiffProfiles returns the colored diff between two profiles.
-func DiffProfiles(dir, profileA, profileB string) (string, error) {
-	if profileA == "" || profileB == "" {
-		return "", fmt.Errorf("profile name is required")
-	}
+// ChangeKind classifies a single structural difference.
+type ChangeKind string
 
-	pathA := filepath.Join(dir, profilePrefix+profileA)
-	pathB := filepath.Join(dir, profilePrefix+profileB)
+const (
+	ChangeAdded   ChangeKind = "added"
+	ChangeRemoved ChangeKind = "removed"
+	ChangeChanged ChangeKind = "changed"
+)
 
-	if _, err := os.Stat(pathA); err != nil {
-		return "", err
+// Change describes a difference at a JSON path between two configs.
+type Change struct {
+	Path string          `json:"path"`
+	Kind ChangeKind      `json:"kind"`
+	Old  json.RawMessage `json:"old,omitempty"`
+	New  json.RawMessage `json:"new,omitempty"`
+}
+
+// DiffProfiles returns the structural changes from profileA to profileB.
+func DiffProfiles(dir, profileA, profileB string) ([]Change, error) {
+	if err := ValidateName(profileA); err != nil {
+		return nil, err
 	}
-	if _, err := os.Stat(pathB); err != nil {
-		return "", err
+	if err := ValidateName(profileB); err != nil {
+		return nil, err
 	}
-
-	diff, err := util.GitDiffNoIndex(pathA, pathB)
+	cfgA, err := LoadProfile(filepath.Join(dir, profilePrefix+profileA))
 	if err != nil {
-		if errors.Is(err, util.ErrGitNotAvailable) {
-			return "", fmt.Errorf("git is required for diff: %w", err)
-		}
-		return "", err
+		return nil, err
+	}
+	cfgB, err := LoadProfile(filepath.Join(dir, profilePrefix+profileB))
+	if err != nil {
+		return nil, err
 	}
-	return diff, nil
+	return DiffConfigs(cfgA, cfgB)
 }
 
-// DiffProfileAgainstFile returns the colored diff between a profile and a file.
-func DiffProfileAgainstFile(dir, profileName, other string) (string, error) {
+// DiffProfileAgainstFile returns the structural changes from a file to a profile.
+func DiffProfileAgainstFile(dir, profileName, other string) ([]Change, error) {
 	if profileName == "" || other == "" {
-		return "", fmt.Errorf("profile name is required")
+		return nil, fmt.Errorf("profile name is required")
 	}
-
 	profilePath := filepath.Join(dir, profilePrefix+profileName)
 	if _, err := os.Stat(profilePath); err != nil {
-		return "", err
+		return nil, err
 	}
-
 	otherPath := other
 	if !filepath.IsAbs(otherPath) {
 		otherPath = filepath.Join(dir, other)
 	}
-	if _, err := os.Stat(otherPath); err != nil {
-		return "", err
+	otherCfg, err := LoadProfile(otherPath)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadProfile(profilePath)
+	if err != nil {
+		return nil, err
+	}
+	return DiffConfigs(otherCfg, cfg)
+}
+
+// DiffConfigs compares two configs, including preserved unknown fields.
+// Object key order and formatting are ignored; changes are sorted by path.
+func DiffConfigs(oldCfg, newCfg *RootConfig) ([]Change, error) {
+	oldValue, err := configValue(oldCfg)
+	if err != nil {
+		return nil, err
+	}
+	newValue, err := configValue(newCfg)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]Change, 0)
+	if err := diffValues("", oldValue, newValue, &changes); err != nil {
+		return nil, err
 	}
+	return changes, nil
+}
 
-	diff, err := util.GitDiffNoIndex(otherPath, profilePath)
+func configValue(cfg *RootConfig) (any, error) {
+	if cfg == nil {
+		return map[string]any{}, nil
+	}
+	data, err := json.Marshal(cfg)
 	if err != nil {
-		if errors.Is(err, util.ErrGitNotAvailable) {
-			return "", fmt.Errorf("git is required for diff: %w", err)
+		return nil, err
+	}
+	return decodeValue(data)
+}
+
+func decodeValue(data []byte) (any, error) {
+	data, err := jsonc.Standardize(data)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func diffValues(path string, oldValue, newValue any, changes *[]Change) error {
+	oldObj, oldIsObj := oldValue.(map[string]any)
+	newObj, newIsObj := newValue.(map[string]any)
+	if oldIsObj && newIsObj {
+		keys := make([]string, 0, len(oldObj)+len(newObj))
+		for key := range oldObj {
+			keys = append(keys, key)
+		}
+		for key := range newObj {
+			if _, ok := oldObj[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			childPath := JoinObjectPath(path, key)
+			oldChild, inOld := oldObj[key]
+			newChild, inNew := newObj[key]
+			switch {
+			case !inOld:
+				if err := appendChange(changes, childPath, ChangeAdded, nil, newChild); err != nil {
+					return err
+				}
+			case !inNew:
+				if err := appendChange(changes, childPath, ChangeRemoved, oldChild, nil); err != nil {
+					return err
+				}
+			default:
+				if err := diffValues(childPath, oldChild, newChild, changes); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	oldArr, oldIsArr := oldValue.([]any)
+	newArr, newIsArr := newValue.([]any)
+	if oldIsArr && newIsArr {
+		for i := 0; i < len(oldArr) || i < len(newArr); i++ {
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(oldArr):
+				if err := appendChange(changes, childPath, ChangeAdded, nil, newArr[i]); err != nil {
+					return err
+				}
+			case i >= len(newArr):
+				if err := appendChange(changes, childPath, ChangeRemoved, oldArr[i], nil); err != nil {
+					return err
+				}
+			default:
+				if err := diffValues(childPath, oldArr[i], newArr[i], changes); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if scalarEqual(oldValue, newValue) {
+		return nil
+	}
+	return appendChange(changes, path, ChangeChanged, oldValue, newValue)
+}
+
+func scalarEqual(a, b any) bool {
+	numA, okA := a.(json.Number)
+	numB, okB := b.(json.Number)
+	if okA && okB {
+		if numA == numB {
+			return true
+		}
+		floatA, errA := numA.Float64()
+		floatB, errB := numB.Float64()
+		return errA == nil && errB == nil && floatA == floatB
+	}
+	switch a.(type) {
+	case map[string]any, []any:
+		return false
+	}
+	switch b.(type) {
+	case map[string]any, []any:
+		return false
+	}
+	return a == b
+}
+
+func appendChange(changes *[]Change, path string, kind ChangeKind, oldValue, newValue any) error {
+	change := Change{Path: path, Kind: kind}
+	if kind != ChangeAdded {
+		encoded, err := json.Marshal(oldValue)
+		if err != nil {
+			return err
+		}
+		change.Old = encoded
+	}
+	if kind != ChangeRemoved {
+		encoded, err := json.Marshal(newValue)
+		if err != nil {
+			return err
+		}
+		change.New = encoded
+	}
+	*changes = append(*changes, change)
+	return nil
+}
+
+// JoinObjectPath appends an object key to a change path, quoting keys that
+// are not plain identifiers.
+func JoinObjectPath(path, key string) string {
+	if !isPlainPathKey(key) {
+		return fmt.Sprintf("%s[%s]", path, strconv.Quote(key))
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func isPlainPathKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_' || r == '-' || r == '$':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	diffColorReset   = "\x1b[0m"
+	diffColorHeader  = "\x1b[1m"
+	diffColorAdded   = "\x1b[32m"
+	diffColorRemoved = "\x1b[31m"
+	diffColorChanged = "\x1b[33m"
+)
+
+// FormatDiff renders changes as unified text, optionally with ANSI colors.
+// It returns an empty string when there are no changes.
+func FormatDiff(oldLabel, newLabel string, changes []Change, color bool) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	paint := func(style, text string) string {
+		if !color {
+			return text
+		}
+		return style + text + diffColorReset
+	}
+	var b strings.Builder
+	b.WriteString(paint(diffColorHeader, "--- "+oldLabel))
+	b.WriteString("\n")
+	b.WriteString(paint(diffColorHeader, "+++ "+newLabel))
+	b.WriteString("\n")
+	for _, change := range changes {
+		var line string
+		switch change.Kind {
+		case ChangeAdded:
+			line = paint(diffColorAdded, fmt.Sprintf("+ %s: %s", change.Path, formatDiffValue(change.New)))
+		case ChangeRemoved:
+			line = paint(diffColorRemoved, fmt.Sprintf("- %s: %s", change.Path, formatDiffValue(change.Old)))
+		default:
+			line = paint(diffColorChanged, fmt.Sprintf("~ %s: %s -> %s", change.Path, formatDiffValue(change.Old), formatDiffValue(change.New)))
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func formatDiffValue(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		if text != "" && !strings.ContainsAny(text, " \t\n\"") {
+			return text
 		}
-		return "", err
 	}
-	return diff, nil
+	return string(raw)
 }
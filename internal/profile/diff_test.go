@@ -1,73 +1,125 @@
 package profile
 
 import (
-	"errors"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
-
-	"moirai/internal/util"
 )
 
-func TestDiffProfiles_ReturnsColoredDiff(t *testing.T) {
-	if _, err := exec.LookPath("git"); err != nil {
-		t.Skip("git not available")
+func TestDiffProfiles_ReportsChangedPaths(t *testing.T) {
+	dir := t.TempDir()
+	writeProfileFile(t, dir, "a", `{"agents":{"oracle":{"model":"gpt-4o","temperature":0.2}},"theme":"dark"}`+"\n")
+	writeProfileFile(t, dir, "b", `{"theme":"dark","agents":{"oracle":{"model":"gpt-4.1","temperature":0.2},"explore":{"model":"mini"}}}`+"\n")
+
+	changes, err := DiffProfiles(dir, "a", "b")
+	if err != nil {
+		t.Fatalf("DiffProfiles: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %#v", changes)
+	}
+	if changes[0].Path != "agents.explore" || changes[0].Kind != ChangeAdded {
+		t.Fatalf("unexpected first change: %#v", changes[0])
 	}
+	if changes[1].Path != "agents.oracle.model" || changes[1].Kind != ChangeChanged {
+		t.Fatalf("unexpected second change: %#v", changes[1])
+	}
+	if string(changes[1].Old) != `"gpt-4o"` || string(changes[1].New) != `"gpt-4.1"` {
+		t.Fatalf("unexpected values: %s -> %s", changes[1].Old, changes[1].New)
+	}
+}
 
+func TestDiffProfiles_IgnoresKeyOrderAndFormatting(t *testing.T) {
 	dir := t.TempDir()
-	writeProfileFile(t, dir, "a", `{"a":1}`+"\n")
-	writeProfileFile(t, dir, "b", `{"a":2}`+"\n")
+	writeProfileFile(t, dir, "a", `{"a":1,"nested":{"x":[1,2],"y":true}}`+"\n")
+	writeProfileFile(t, dir, "b", "{\n  \"nested\": {\n    \"y\": true,\n    \"x\": [1, 2]\n  },\n  \"a\": 1.0\n}\n")
 
-	out, err := DiffProfiles(dir, "a", "b")
+	changes, err := DiffProfiles(dir, "a", "b")
 	if err != nil {
 		t.Fatalf("DiffProfiles: %v", err)
 	}
-	if out == "" || !strings.Contains(out, "diff --git") {
-		t.Fatalf("unexpected diff output:\n%s", out)
+	if len(changes) != 0 {
+		t.Fatalf("expected no changes, got %#v", changes)
 	}
 }
 
-func TestDiffProfileAgainstFile_ReturnsColoredDiff(t *testing.T) {
-	if _, err := exec.LookPath("git"); err != nil {
-		t.Skip("git not available")
+func TestDiffConfigs_ExtraFieldsAndArrays(t *testing.T) {
+	dir := t.TempDir()
+	writeProfileFile(t, dir, "a", `{"plugins":["x","y"],"old.key":1}`)
+	writeProfileFile(t, dir, "b", `{"plugins":["x"]}`)
+
+	changes, err := DiffProfiles(dir, "a", "b")
+	if err != nil {
+		t.Fatalf("DiffProfiles: %v", err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got %#v", changes)
+	}
+	if changes[0].Path != `["old.key"]` || changes[0].Kind != ChangeRemoved {
+		t.Fatalf("unexpected first change: %#v", changes[0])
 	}
+	if changes[1].Path != "plugins[1]" || changes[1].Kind != ChangeRemoved {
+		t.Fatalf("unexpected second change: %#v", changes[1])
+	}
+}
 
+func TestDiffProfileAgainstFile_ComparesFileToProfile(t *testing.T) {
 	dir := t.TempDir()
-	writeProfileFile(t, dir, "p", `{"x":1}`+"\n")
+	writeProfileFile(t, dir, "p", `{"x":2}`+"\n")
 	otherRel := "other.json"
-	if err := os.WriteFile(filepath.Join(dir, otherRel), []byte(`{"x":2}`+"\n"), 0o644); err != nil {
+	if err := os.WriteFile(filepath.Join(dir, otherRel), []byte(`{"x":1}`+"\n"), 0o644); err != nil {
 		t.Fatalf("write other: %v", err)
 	}
 
-	out, err := DiffProfileAgainstFile(dir, "p", otherRel)
+	changes, err := DiffProfileAgainstFile(dir, "p", otherRel)
 	if err != nil {
 		t.Fatalf("DiffProfileAgainstFile: %v", err)
 	}
-	if out == "" || !strings.Contains(out, "diff --git") {
-		t.Fatalf("unexpected diff output:\n%s", out)
+	if len(changes) != 1 || string(changes[0].Old) != "1" || string(changes[0].New) != "2" {
+		t.Fatalf("unexpected changes: %#v", changes)
 	}
 }
 
-func TestDiffProfiles_ReportsMissingGit(t *testing.T) {
+func TestDiffProfiles_MissingProfile(t *testing.T) {
 	dir := t.TempDir()
-	writeProfileFile(t, dir, "a", `{"a":1}`+"\n")
-	writeProfileFile(t, dir, "b", `{"a":2}`+"\n")
+	writeProfileFile(t, dir, "a", `{}`)
+	if _, err := DiffProfiles(dir, "a", "missing"); err == nil {
+		t.Fatal("expected error for missing profile")
+	}
+}
 
-	prev := util.GitBin
-	util.GitBin = "definitely-not-git"
-	t.Cleanup(func() { util.GitBin = prev })
+func TestDiffProfiles_RejectsPathInProfileName(t *testing.T) {
+	dir := t.TempDir()
+	writeProfileFile(t, dir, "a", `{}`)
+	if _, err := DiffProfiles(dir, "../x", "a"); err == nil {
+		t.Fatal("expected error for a name with a path")
+	}
+	if _, err := DiffProfiles(dir, "a", "x/../../b"); err == nil {
+		t.Fatal("expected error for a name with a path")
+	}
+}
 
-	_, err := DiffProfiles(dir, "a", "b")
-	if err == nil {
-		t.Fatal("expected error")
+func TestFormatDiff(t *testing.T) {
+	changes := []Change{
+		{Path: "agents.oracle.model", Kind: ChangeChanged, Old: []byte(`"gpt-4o"`), New: []byte(`"gpt-4.1"`)},
+		{Path: "agents.atlas", Kind: ChangeAdded, New: []byte(`{"model":"x"}`)},
+		{Path: "theme", Kind: ChangeRemoved, Old: []byte(`"light mode"`)},
+	}
+	out := FormatDiff("a", "b", changes, false)
+	want := "--- a\n+++ b\n" +
+		"~ agents.oracle.model: gpt-4o -> gpt-4.1\n" +
+		"+ agents.atlas: {\"model\":\"x\"}\n" +
+		"- theme: \"light mode\"\n"
+	if out != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", out, want)
 	}
-	if !errors.Is(err, util.ErrGitNotAvailable) {
-		t.Fatalf("expected ErrGitNotAvailable, got: %v", err)
+	colored := FormatDiff("a", "b", changes, true)
+	if !strings.Contains(colored, "\x1b[32m+ agents.atlas") {
+		t.Fatalf("expected colored added line, got %q", colored)
 	}
-	if !strings.Contains(err.Error(), "git is required for diff") {
-		t.Fatalf("unexpected error: %v", err)
+	if FormatDiff("a", "b", nil, true) != "" {
+		t.Fatal("expected empty output without changes")
 	}
 }
 
@@ -1,6 +1,7 @@
 package profile
 
 import (
+	"fmt"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -16,6 +17,31 @@ type ProfileInfo struct {
 	Path string
 }
 
+// ProfilePath returns the path of the named profile in dir.
+func ProfilePath(dir, name string) string {
+	return filepath.Join(dir, profilePrefix+name)
+}
+
+// ValidateName reports whether name can be used as a profile file suffix.
+func ValidateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("profile name is required")
+	}
+	if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return fmt.Errorf("invalid profile name %q: must not contain path separators", name)
+	}
+	// Backup file names append ".bak.<timestamp>" to the profile file, so a
+	// name ending in ".bak" would produce backups matching the prefix of
+	// another profile or of the active config.
+	if strings.Contains(name, ".bak.") || strings.HasPrefix(name, "bak.") || strings.HasSuffix(name, ".bak") || name == "bak" {
+		return fmt.Errorf("invalid profile name %q: must not contain \".bak.\" or end in \".bak\"", name)
+	}
+	if strings.TrimSpace(name) != name {
+		return fmt.Errorf("invalid profile name %q: must not start or end with whitespace", name)
+	}
+	return nil
+}
+
 // DiscoverProfiles returns the profiles found in dir.
 func DiscoverProfiles(dir string) ([]ProfileInfo, error) {
 	entries, err := util.ListDir(dir)
@@ -39,3 +39,16 @@ func TestDiscoverProfiles(t *testing.T) {
 		t.Fatalf("unexpected path: %s", profiles[0].Path)
 	}
 }
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"alpha", "team-a", "v1.2", "bakery", "x.backup"} {
+		if err := ValidateName(name); err != nil {
+			t.Fatalf("expected %q to be valid, got %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "a/b", "..", "x.bak.1", "x.bak", "bak", " padded"} {
+		if err := ValidateName(name); err == nil {
+			t.Fatalf("expected %q to be invalid", name)
+		}
+	}
+}
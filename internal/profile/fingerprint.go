@@ -0,0 +1,40 @@
+package profile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"time"
+)
+
+// Fingerprint identifies the on-disk content of a profile file so callers can
+// detect edits made by other processes since they loaded it.
+type Fingerprint struct {
+	ModTime time.Time
+	Hash    string
+}
+
+// FileFingerprint returns the modification time and SHA-256 of path.
+func FileFingerprint(path string) (Fingerprint, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+	sum := sha256.Sum256(data)
+	return Fingerprint{ModTime: info.ModTime(), Hash: hex.EncodeToString(sum[:])}, nil
+}
+
+// IsZero reports whether the fingerprint was never taken.
+func (f Fingerprint) IsZero() bool {
+	return f.Hash == ""
+}
+
+// Changed reports whether other describes different content. A file that was
+// only touched, keeping its content, is not considered changed.
+func (f Fingerprint) Changed(other Fingerprint) bool {
+	return f.Hash != other.Hash
+}
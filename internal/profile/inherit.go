@@ -0,0 +1,246 @@
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"moirai/internal/jsonc"
+)
+
+// MetadataKey is the reserved root key holding moirai-specific settings.
+// It is preserved in profiles through RootConfig.Extra and stripped from
+// resolved configs.
+const MetadataKey = "moirai"
+
+// Metadata holds moirai-specific profile settings.
+type Metadata struct {
+	Extends []string `json:"extends,omitempty"`
+}
+
+// ProfileMetadata returns the moirai settings stored in cfg.
+func ProfileMetadata(cfg *RootConfig) (Metadata, error) {
+	var meta Metadata
+	if cfg == nil || cfg.Extra == nil {
+		return meta, nil
+	}
+	raw, ok := cfg.Extra[MetadataKey]
+	if !ok {
+		return meta, nil
+	}
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return Metadata{}, fmt.Errorf("parse %q settings: %w", MetadataKey, err)
+	}
+	return meta, nil
+}
+
+// Origin records which profile supplied the value at a resolved path.
+type Origin struct {
+	Path    string `json:"path"`
+	Profile string `json:"profile"`
+}
+
+// Resolved is a profile with all of its parents merged in.
+type Resolved struct {
+	Name string
+	// Chain lists the merged profiles, parents before children, ending with Name.
+	Chain   []string
+	Config  *RootConfig
+	Data    []byte
+	Origins []Origin
+}
+
+// HasParents reports whether the profile extends any other profile.
+func (r *Resolved) HasParents() bool {
+	return r != nil && len(r.Chain) > 1
+}
+
+type resolvedLayer struct {
+	name  string
+	value map[string]any
+}
+
+// ResolveProfile loads the named profile and deep-merges the profiles it
+// extends. Parents are applied in the declared order, so later parents and
+// finally the profile itself win. Objects are merged key by key; arrays and
+// scalars are replaced.
+func ResolveProfile(dir, name string) (*Resolved, error) {
+	return resolveProfile(dir, name, nil)
+}
+
+// ResolveProfileData resolves the named profile as if its file held data,
+// which lets callers check unsaved edits against the profiles it extends.
+func ResolveProfileData(dir, name string, data []byte) (*Resolved, error) {
+	if data == nil {
+		data = []byte{}
+	}
+	return resolveProfile(dir, name, data)
+}
+
+func resolveProfile(dir, name string, own []byte) (*Resolved, error) {
+	layers, err := collectLayers(dir, name, nil, own)
+	if err != nil {
+		return nil, err
+	}
+	merged := map[string]any{}
+	for _, layer := range layers {
+		mergeValues(merged, layer.value)
+	}
+	data, err := json.MarshalIndent(merged, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, '\n')
+	var cfg RootConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse resolved profile: %w", err)
+	}
+
+	origins := make([]Origin, 0)
+	collectOrigins(merged, nil, layers, &origins)
+	sort.Slice(origins, func(i, j int) bool {
+		return origins[i].Path < origins[j].Path
+	})
+
+	return &Resolved{
+		Name:    name,
+		Chain:   layerChain(layers),
+		Config:  &cfg,
+		Data:    data,
+		Origins: origins,
+	}, nil
+}
+
+// collectLayers reads the named profile and its parents. A non-nil own
+// replaces the contents of the named profile's file.
+func collectLayers(dir, name string, stack []string, own []byte) ([]resolvedLayer, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	for _, seen := range stack {
+		if seen == name {
+			return nil, fmt.Errorf("profile inheritance cycle: %s -> %s", strings.Join(stack, " -> "), name)
+		}
+	}
+	stack = append(stack, name)
+
+	data := own
+	if data == nil {
+		var err error
+		data, err = os.ReadFile(ProfilePath(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("profile %q not found", name)
+			}
+			return nil, err
+		}
+	}
+	data, err := jsonc.Standardize(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse profile %q: %w", name, err)
+	}
+	var cfg RootConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse profile %q: %w", name, err)
+	}
+	meta, err := ProfileMetadata(&cfg)
+	if err != nil {
+		return nil, fmt.Errorf("profile %q: %w", name, err)
+	}
+	decoded, err := decodeValue(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse profile %q: %w", name, err)
+	}
+	value, ok := decoded.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("profile %q is not a JSON object", name)
+	}
+	delete(value, MetadataKey)
+
+	layers := make([]resolvedLayer, 0, len(meta.Extends)+1)
+	for _, parent := range meta.Extends {
+		parentLayers, err := collectLayers(dir, parent, stack, nil)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, parentLayers...)
+	}
+	return append(layers, resolvedLayer{name: name, value: value}), nil
+}
+
+func mergeValues(dst, src map[string]any) {
+	for key, value := range src {
+		srcObj, srcIsObj := value.(map[string]any)
+		dstObj, dstIsObj := dst[key].(map[string]any)
+		if srcIsObj && dstIsObj {
+			mergeValues(dstObj, srcObj)
+			continue
+		}
+		if srcIsObj {
+			copied := map[string]any{}
+			mergeValues(copied, srcObj)
+			dst[key] = copied
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+func collectOrigins(value map[string]any, segments []string, layers []resolvedLayer, origins *[]Origin) {
+	for key, child := range value {
+		childSegments := append(append([]string(nil), segments...), key)
+		if obj, ok := child.(map[string]any); ok && len(obj) > 0 {
+			collectOrigins(obj, childSegments, layers, origins)
+			continue
+		}
+		owner := ""
+		for i := len(layers) - 1; i >= 0; i-- {
+			if hasPath(layers[i].value, childSegments) {
+				owner = layers[i].name
+				break
+			}
+		}
+		path := ""
+		for _, segment := range childSegments {
+			path = JoinObjectPath(path, segment)
+		}
+		*origins = append(*origins, Origin{Path: path, Profile: owner})
+	}
+}
+
+func hasPath(value map[string]any, segments []string) bool {
+	current := value
+	for i, segment := range segments {
+		child, ok := current[segment]
+		if !ok {
+			return false
+		}
+		if i == len(segments)-1 {
+			return true
+		}
+		next, ok := child.(map[string]any)
+		if !ok {
+			return false
+		}
+		current = next
+	}
+	return false
+}
+
+func layerChain(layers []resolvedLayer) []string {
+	// A profile reached through several parents is listed once, at the
+	// position where it was last applied.
+	last := make(map[string]int, len(layers))
+	for i, layer := range layers {
+		last[layer.name] = i
+	}
+	chain := make([]string, 0, len(last))
+	for i, layer := range layers {
+		if last[layer.name] == i {
+			chain = append(chain, layer.name)
+		}
+	}
+	return chain
+}
@@ -0,0 +1,91 @@
+package profile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResolveProfileDeepMergesParents(t *testing.T) {
+	dir := t.TempDir()
+	writeProfileFile(t, dir, "base", `{"agents":{"oracle":{"model":"base-o","temperature":0.1},"explore":{"model":"base-e"}},"theme":{"name":"dark","size":1},"plugins":["a","b"]}`)
+	writeProfileFile(t, dir, "team", `{"agents":{"explore":{"model":"team-e"}},"theme":{"size":2}}`)
+	writeProfileFile(t, dir, "child", `{"moirai":{"extends":["base","team"]},"agents":{"oracle":{"model":"child-o"}},"plugins":["c"]}`)
+
+	resolved, err := ResolveProfile(dir, "child")
+	if err != nil {
+		t.Fatalf("ResolveProfile: %v", err)
+	}
+	if !reflect.DeepEqual(resolved.Chain, []string{"base", "team", "child"}) {
+		t.Fatalf("unexpected chain: %v", resolved.Chain)
+	}
+	cfg := resolved.Config
+	if cfg.Agents["oracle"].Model != "child-o" || cfg.Agents["explore"].Model != "team-e" {
+		t.Fatalf("unexpected agents: %#v", cfg.Agents)
+	}
+	if string(cfg.Agents["oracle"].Extra["temperature"]) != "0.1" {
+		t.Fatalf("expected inherited temperature, got %s", cfg.Agents["oracle"].Extra["temperature"])
+	}
+	if _, ok := cfg.Extra[MetadataKey]; ok {
+		t.Fatalf("expected metadata key stripped from resolved config")
+	}
+	theme := strings.Join(strings.Fields(string(cfg.Extra["theme"])), "")
+	if theme != `{"name":"dark","size":2}` {
+		t.Fatalf("unexpected theme: %s", theme)
+	}
+	if strings.Join(strings.Fields(string(cfg.Extra["plugins"])), "") != `["c"]` {
+		t.Fatalf("expected arrays replaced, got %s", cfg.Extra["plugins"])
+	}
+
+	origins := make(map[string]string, len(resolved.Origins))
+	for _, origin := range resolved.Origins {
+		origins[origin.Path] = origin.Profile
+	}
+	expected := map[string]string{
+		"agents.oracle.model":       "child",
+		"agents.oracle.temperature": "base",
+		"agents.explore.model":      "team",
+		"theme.name":                "base",
+		"theme.size":                "team",
+		"plugins":                   "child",
+	}
+	if !reflect.DeepEqual(origins, expected) {
+		t.Fatalf("unexpected origins:\n got %v\nwant %v", origins, expected)
+	}
+}
+
+func TestResolveProfileWithoutParents(t *testing.T) {
+	dir := t.TempDir()
+	writeProfileFile(t, dir, "solo", `{"agents":{"oracle":{"model":"x"}}}`)
+
+	resolved, err := ResolveProfile(dir, "solo")
+	if err != nil {
+		t.Fatalf("ResolveProfile: %v", err)
+	}
+	if resolved.HasParents() {
+		t.Fatalf("expected no parents, got chain %v", resolved.Chain)
+	}
+	if resolved.Config.Agents["oracle"].Model != "x" {
+		t.Fatalf("unexpected config: %#v", resolved.Config)
+	}
+}
+
+func TestResolveProfileDetectsCycles(t *testing.T) {
+	dir := t.TempDir()
+	writeProfileFile(t, dir, "a", `{"moirai":{"extends":["b"]}}`)
+	writeProfileFile(t, dir, "b", `{"moirai":{"extends":["a"]}}`)
+
+	_, err := ResolveProfile(dir, "a")
+	if err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+}
+
+func TestResolveProfileMissingParent(t *testing.T) {
+	dir := t.TempDir()
+	writeProfileFile(t, dir, "child", `{"moirai":{"extends":["ghost"]}}`)
+
+	if _, err := ResolveProfile(dir, "child"); err == nil || !strings.Contains(err.Error(), "ghost") {
+		t.Fatalf("expected missing parent error, got %v", err)
+	}
+}
@@ -4,14 +4,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+
+	"moirai/internal/jsonc"
 )
 
-// LoadProfile reads and parses a profile from path.
+// LoadProfile reads and parses a profile from path. Profiles may use JSONC
+// comments and trailing commas.
 func LoadProfile(path string) (*RootConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
+	return ParseProfile(data)
+}
+
+// ParseProfile parses profile data, which may use JSONC comments and
+// trailing commas.
+func ParseProfile(data []byte) (*RootConfig, error) {
+	data, err := jsonc.Standardize(data)
+	if err != nil {
+		return nil, fmt.Errorf("parse profile: %w", err)
+	}
 
 	var cfg RootConfig
 	if err := json.Unmarshal(data, &cfg); err != nil {
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -26,6 +27,47 @@ func TestLoadProfileParsesMinimalJSON(t *testing.T) {
 	}
 }
 
+func TestLoadAndSaveJSONCProfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "oh-my-opencode.json.alpha")
+	original := `{
+  // Daily setup
+  "$schema": "https://example.com/schema.json",
+  "agents": {
+    "sisyphus": { "model": "gpt-4" }, // main agent
+    /* fast lookups */
+    "explore": { "model": "mini" },
+  },
+}
+`
+	if err := os.WriteFile(path, []byte(original), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+
+	cfg, err := LoadProfile(path)
+	if err != nil {
+		t.Fatalf("LoadProfile: %v", err)
+	}
+	if cfg.Agents["explore"].Model != "mini" {
+		t.Fatalf("unexpected agents: %#v", cfg.Agents)
+	}
+	if _, err := SetAgentModel(cfg, "sisyphus", "gpt-5"); err != nil {
+		t.Fatalf("SetAgentModel: %v", err)
+	}
+	if err := SaveProfileAtomic(path, cfg); err != nil {
+		t.Fatalf("SaveProfileAtomic: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read profile: %v", err)
+	}
+	want := strings.Replace(original, `"gpt-4"`, `"gpt-5"`, 1)
+	if string(data) != want {
+		t.Fatalf("expected only the model to change, got:\n%s", data)
+	}
+}
+
 func TestLoadProfileInvalidJSON(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "oh-my-opencode.json.alpha")
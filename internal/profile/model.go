@@ -1,18 +1,29 @@
 package profile
 
-import "encoding/json"
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
 
 // RootConfig is the minimal configuration structure used by doctor.
 type RootConfig struct {
 	Schema string                 `json:"$schema,omitempty"`
 	Agents map[string]AgentConfig `json:"agents,omitempty"`
 	Extra  map[string]json.RawMessage
+
+	// order and agentOrder remember the key order of the parsed document
+	// so that marshaling keeps it.
+	order      []string
+	agentOrder []string
 }
 
 // AgentConfig is the minimal agent configuration used by doctor.
 type AgentConfig struct {
 	Model string `json:"model,omitempty"`
 	Extra map[string]json.RawMessage
+
+	order []string
 }
 
 // UnmarshalJSON preserves unknown fields alongside known fields.
@@ -21,6 +32,7 @@ func (cfg *RootConfig) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &raw); err != nil {
 		return err
 	}
+	cfg.order = objectKeys(data)
 
 	if value, ok := raw["$schema"]; ok {
 		if err := json.Unmarshal(value, &cfg.Schema); err != nil {
@@ -34,6 +46,7 @@ func (cfg *RootConfig) UnmarshalJSON(data []byte) error {
 			return err
 		}
 		cfg.Agents = agents
+		cfg.agentOrder = objectKeys(value)
 		delete(raw, "agents")
 	}
 
@@ -60,14 +73,22 @@ func (cfg RootConfig) MarshalJSON() ([]byte, error) {
 		merged["$schema"] = encoded
 	}
 	if cfg.Agents != nil {
-		encoded, err := json.Marshal(cfg.Agents)
+		agents := make(map[string]json.RawMessage, len(cfg.Agents))
+		for name, agent := range cfg.Agents {
+			encoded, err := json.Marshal(agent)
+			if err != nil {
+				return nil, err
+			}
+			agents[name] = encoded
+		}
+		encoded, err := encodeObject(agents, cfg.agentOrder)
 		if err != nil {
 			return nil, err
 		}
 		merged["agents"] = encoded
 	}
 
-	return json.Marshal(merged)
+	return encodeObject(merged, cfg.order)
 }
 
 // UnmarshalJSON preserves unknown fields alongside known fields.
@@ -76,6 +97,7 @@ func (cfg *AgentConfig) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &raw); err != nil {
 		return err
 	}
+	cfg.order = objectKeys(data)
 
 	if value, ok := raw["model"]; ok {
 		if err := json.Unmarshal(value, &cfg.Model); err != nil {
@@ -107,5 +129,72 @@ func (cfg AgentConfig) MarshalJSON() ([]byte, error) {
 		merged["model"] = encoded
 	}
 
-	return json.Marshal(merged)
+	return encodeObject(merged, cfg.order)
+}
+
+// objectKeys returns the keys of a JSON object in document order.
+func objectKeys(data []byte) []string {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil
+	}
+	keys := make([]string, 0)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+		key, ok := tok.(string)
+		if !ok {
+			return nil
+		}
+		keys = append(keys, key)
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil
+		}
+	}
+	return keys
+}
+
+// encodeObject encodes values as a JSON object, listing the keys in order
+// first and any other keys after them in sorted order.
+func encodeObject(values map[string]json.RawMessage, order []string) ([]byte, error) {
+	keys := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, key := range order {
+		if _, ok := values[key]; ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	rest := make([]string, 0)
+	for key := range values {
+		if !seen[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	keys = append(keys, rest...)
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		encodedKey, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(encodedKey)
+		buf.WriteByte(':')
+		if len(values[key]) == 0 {
+			buf.WriteString("null")
+			continue
+		}
+		buf.Write(values[key])
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
 }
@@ -0,0 +1,183 @@
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"moirai/internal/util"
+)
+
+// Reference kinds.
+const (
+	ReferenceEnv  = "env"
+	ReferenceFile = "file"
+)
+
+// referencePattern matches {env:VAR} and {file:path} placeholders inside
+// string values.
+var referencePattern = regexp.MustCompile(`\{(env|file):([^{}]+)\}`)
+
+// Reference is an {env:VAR} or {file:path} placeholder in a profile value.
+type Reference struct {
+	// Path is the JSON path of the string holding the placeholder.
+	Path        string `json:"path"`
+	Placeholder string `json:"placeholder"`
+	Kind        string `json:"kind"`
+	Target      string `json:"target"`
+	Resolved    bool   `json:"resolved"`
+	// Error explains why the placeholder could not be resolved.
+	Error string `json:"error,omitempty"`
+}
+
+// ReferenceOptions control how placeholders are resolved.
+type ReferenceOptions struct {
+	// BaseDir anchors relative {file:} paths, normally the config dir.
+	BaseDir string
+	// LookupEnv defaults to os.LookupEnv.
+	LookupEnv func(string) (string, bool)
+}
+
+// FindReferences lists the placeholders in a JSON document without resolving
+// them.
+func FindReferences(data []byte) ([]Reference, error) {
+	value, err := decodeValue(data)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]Reference, 0)
+	walkStrings(value, "", func(path, text string) string {
+		for _, match := range referencePattern.FindAllStringSubmatch(text, -1) {
+			refs = append(refs, Reference{Path: path, Placeholder: match[0], Kind: match[1], Target: match[2]})
+		}
+		return text
+	})
+	sortReferences(refs)
+	return refs, nil
+}
+
+// ExpandReferences replaces the placeholders in a JSON document with their
+// values and returns the re-encoded document together with every
+// placeholder found. Placeholders that cannot be resolved are left in place
+// and reported with Resolved false; documents without placeholders are
+// returned unchanged.
+func ExpandReferences(data []byte, opts ReferenceOptions) ([]byte, []Reference, error) {
+	value, err := decodeValue(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.LookupEnv == nil {
+		opts.LookupEnv = os.LookupEnv
+	}
+	refs := make([]Reference, 0)
+	expanded := walkStrings(value, "", func(path, text string) string {
+		return referencePattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+			match := referencePattern.FindStringSubmatch(placeholder)
+			ref := Reference{Path: path, Placeholder: placeholder, Kind: match[1], Target: match[2]}
+			resolved, err := resolveReference(ref, opts)
+			if err != nil {
+				ref.Error = err.Error()
+				refs = append(refs, ref)
+				return placeholder
+			}
+			ref.Resolved = true
+			refs = append(refs, ref)
+			return resolved
+		})
+	})
+	sortReferences(refs)
+	if len(refs) == 0 {
+		return data, refs, nil
+	}
+	out, err := json.MarshalIndent(expanded, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(out, '\n'), refs, nil
+}
+
+// UnresolvedReferences returns the references that could not be resolved.
+func UnresolvedReferences(refs []Reference) []Reference {
+	unresolved := make([]Reference, 0)
+	for _, ref := range refs {
+		if !ref.Resolved {
+			unresolved = append(unresolved, ref)
+		}
+	}
+	return unresolved
+}
+
+// UnresolvedError describes unresolved references as a single error, or
+// returns nil when every reference was resolved.
+func UnresolvedError(name string, refs []Reference) error {
+	unresolved := UnresolvedReferences(refs)
+	if len(unresolved) == 0 {
+		return nil
+	}
+	details := make([]string, 0, len(unresolved))
+	for _, ref := range unresolved {
+		details = append(details, fmt.Sprintf("%s %s: %s", ref.Path, ref.Placeholder, ref.Error))
+	}
+	return fmt.Errorf("profile %q has unresolved references: %s", name, strings.Join(details, "; "))
+}
+
+func resolveReference(ref Reference, opts ReferenceOptions) (string, error) {
+	target := strings.TrimSpace(ref.Target)
+	switch ref.Kind {
+	case ReferenceEnv:
+		value, ok := opts.LookupEnv(target)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", target)
+		}
+		return value, nil
+	case ReferenceFile:
+		path, err := util.ExpandUser(target)
+		if err != nil {
+			return "", err
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(opts.BaseDir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return "", fmt.Errorf("file %s does not exist", path)
+			}
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
+	default:
+		return "", fmt.Errorf("unknown reference kind %q", ref.Kind)
+	}
+}
+
+// walkStrings calls visit for every string in value, replacing it with the
+// result, and returns the updated value.
+func walkStrings(value any, path string, visit func(path, text string) string) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, child := range typed {
+			typed[key] = walkStrings(child, JoinObjectPath(path, key), visit)
+		}
+		return typed
+	case []any:
+		for i, child := range typed {
+			typed[i] = walkStrings(child, fmt.Sprintf("%s[%d]", path, i), visit)
+		}
+		return typed
+	case string:
+		return visit(path, typed)
+	default:
+		return value
+	}
+}
+
+func sortReferences(refs []Reference) {
+	sort.SliceStable(refs, func(i, j int) bool {
+		return refs[i].Path < refs[j].Path
+	})
+}
@@ -0,0 +1,64 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindReferences(t *testing.T) {
+	refs, err := FindReferences([]byte(`{"provider":{"baseURL":"https://{env:HOST}/{env:VERSION}"},"keys":["{file:key.txt}"],"plain":"x"}`))
+	if err != nil {
+		t.Fatalf("FindReferences: %v", err)
+	}
+	if len(refs) != 3 {
+		t.Fatalf("expected 3 references, got %#v", refs)
+	}
+	if refs[0].Path != "keys[0]" || refs[0].Kind != ReferenceFile || refs[0].Target != "key.txt" {
+		t.Fatalf("unexpected file reference %#v", refs[0])
+	}
+	if refs[1].Path != "provider.baseURL" || refs[1].Placeholder != "{env:HOST}" || refs[2].Target != "VERSION" {
+		t.Fatalf("unexpected env references %#v", refs[1:])
+	}
+}
+
+func TestExpandReferences(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "key.txt"), []byte("from-file\n"), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	env := map[string]string{"HOST": "example.com"}
+	opts := ReferenceOptions{
+		BaseDir: dir,
+		LookupEnv: func(name string) (string, bool) {
+			value, ok := env[name]
+			return value, ok
+		},
+	}
+
+	data, refs, err := ExpandReferences([]byte(`{"url":"https://{env:HOST}/v1","key":"{file:key.txt}","model":"{env:MISSING}","n":1}`), opts)
+	if err != nil {
+		t.Fatalf("ExpandReferences: %v", err)
+	}
+	content := string(data)
+	for _, want := range []string{`"url": "https://example.com/v1"`, `"key": "from-file"`, `"model": "{env:MISSING}"`, `"n": 1`} {
+		if !strings.Contains(content, want) {
+			t.Fatalf("expected %s in:\n%s", want, content)
+		}
+	}
+	unresolved := UnresolvedReferences(refs)
+	if len(refs) != 3 || len(unresolved) != 1 || unresolved[0].Path != "model" {
+		t.Fatalf("unexpected references %#v", refs)
+	}
+	err = UnresolvedError("alpha", refs)
+	if err == nil || !strings.Contains(err.Error(), "MISSING is not set") {
+		t.Fatalf("expected unresolved error, got %v", err)
+	}
+
+	plain := []byte(`{"agents":{}}`)
+	data, refs, err = ExpandReferences(plain, opts)
+	if err != nil || string(data) != string(plain) || len(refs) != 0 {
+		t.Fatalf("expected document without references unchanged, got %q %#v %v", data, refs, err)
+	}
+}
@@ -5,9 +5,17 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"moirai/internal/jsonc"
+	"moirai/internal/util"
 )
 
 // SaveProfileAtomic writes a config to path using a temp file and rename.
+// The existing file is patched in place, so only changed values are
+// rewritten and comments, key order, indentation and the final newline of
+// the rest of the document are kept. Plain JSON files that cannot be patched
+// are rewritten in full; a JSONC file that cannot be patched is left alone
+// and an error returned, since a full rewrite would drop its comments.
 func SaveProfileAtomic(path string, cfg *RootConfig) error {
 	if cfg == nil {
 		return fmt.Errorf("config is required")
@@ -23,6 +31,15 @@ func SaveProfileAtomic(path string, cfg *RootConfig) error {
 		return err
 	}
 	data = append(data, '\n')
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if patched, err := jsonc.Update(original, data); err == nil {
+		data = patched
+	} else if jsonc.HasExtensions(original) {
+		return fmt.Errorf("keep comments of %s: %w", filepath.Base(path), err)
+	}
 
 	return SaveProfileDataAtomic(path, data, info.Mode().Perm())
 }
@@ -47,11 +64,16 @@ func SaveProfileDataAtomic(path string, data []byte, perm os.FileMode) error {
 		_ = tempFile.Close()
 		return err
 	}
+	if err := tempFile.Sync(); err != nil {
+		_ = tempFile.Close()
+		return err
+	}
 	if err := tempFile.Close(); err != nil {
 		return err
 	}
 	if err := os.Rename(tempName, path); err != nil {
 		return err
 	}
+	util.SyncDir(dir)
 	return nil
 }
@@ -0,0 +1,147 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// corpusProfiles copies the round-trip corpus into a temp dir and returns the
+// copied paths by corpus file name.
+func corpusProfiles(t *testing.T) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join("testdata", "roundtrip"))
+	if err != nil {
+		t.Fatalf("read corpus: %v", err)
+	}
+	dir := t.TempDir()
+	paths := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join("testdata", "roundtrip", entry.Name()))
+		if err != nil {
+			t.Fatalf("read %s: %v", entry.Name(), err)
+		}
+		path := filepath.Join(dir, profilePrefix+strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())))
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("write %s: %v", entry.Name(), err)
+		}
+		paths[entry.Name()] = path
+	}
+	if len(paths) == 0 {
+		t.Fatal("empty round-trip corpus")
+	}
+	return paths
+}
+
+func TestSaveProfileRoundTripIsByteIdentical(t *testing.T) {
+	for name, path := range corpusProfiles(t) {
+		original, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: read: %v", name, err)
+		}
+		cfg, err := LoadProfile(path)
+		if err != nil {
+			t.Fatalf("%s: LoadProfile: %v", name, err)
+		}
+		if err := SaveProfileAtomic(path, cfg); err != nil {
+			t.Fatalf("%s: SaveProfileAtomic: %v", name, err)
+		}
+		saved, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: read saved: %v", name, err)
+		}
+		if string(saved) != string(original) {
+			t.Fatalf("%s: round trip changed the file:\n%s", name, saved)
+		}
+	}
+}
+
+func TestSaveProfileAgentEditChangesOneLine(t *testing.T) {
+	for name, path := range corpusProfiles(t) {
+		cfg, err := LoadProfile(path)
+		if err != nil {
+			t.Fatalf("%s: LoadProfile: %v", name, err)
+		}
+		if cfg.Agents["oracle"].Model == "" {
+			continue
+		}
+		original, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: read: %v", name, err)
+		}
+		if _, err := SetAgentModel(cfg, "oracle", "openai/o4-mini"); err != nil {
+			t.Fatalf("%s: SetAgentModel: %v", name, err)
+		}
+		if err := SaveProfileAtomic(path, cfg); err != nil {
+			t.Fatalf("%s: SaveProfileAtomic: %v", name, err)
+		}
+		saved, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("%s: read saved: %v", name, err)
+		}
+		before := strings.Split(string(original), "\n")
+		after := strings.Split(string(saved), "\n")
+		if len(before) != len(after) {
+			t.Fatalf("%s: expected the same number of lines, got:\n%s", name, saved)
+		}
+		changed := 0
+		for i := range before {
+			if before[i] != after[i] {
+				changed++
+				if !strings.Contains(after[i], `"openai/o4-mini"`) {
+					t.Fatalf("%s: unexpected change on line %d: %q", name, i+1, after[i])
+				}
+			}
+		}
+		if changed != 1 {
+			t.Fatalf("%s: expected one changed line, got %d:\n%s", name, changed, saved)
+		}
+	}
+}
+
+func TestSaveProfileRefusesToDropComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), profilePrefix+"alpha")
+	cfg := &RootConfig{Agents: map[string]AgentConfig{"oracle": {Model: "openai/o3"}}}
+
+	// The file broke after it was loaded, so it cannot be patched.
+	broken := "{\n  // keep me\n  \"agents\": {,\n}\n"
+	if err := os.WriteFile(path, []byte(broken), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	if err := SaveProfileAtomic(path, cfg); err == nil {
+		t.Fatal("expected saving over an unpatchable JSONC file to fail")
+	}
+	if data, _ := os.ReadFile(path); string(data) != broken {
+		t.Fatalf("expected the file left alone, got:\n%s", data)
+	}
+
+	// Plain JSON has nothing to lose and is rewritten in full.
+	if err := os.WriteFile(path, []byte("{\"agents\": }\n"), 0o600); err != nil {
+		t.Fatalf("write profile: %v", err)
+	}
+	if err := SaveProfileAtomic(path, cfg); err != nil {
+		t.Fatalf("SaveProfileAtomic: %v", err)
+	}
+	if loaded, err := LoadProfile(path); err != nil || loaded.Agents["oracle"].Model != "openai/o3" {
+		t.Fatalf("expected the rewritten profile, got %v %v", loaded, err)
+	}
+}
+
+func TestRootConfigMarshalKeepsKeyOrder(t *testing.T) {
+	var cfg RootConfig
+	if err := cfg.UnmarshalJSON([]byte(`{"zeta":1,"agents":{"oracle":{"variant":"high","model":"o3"},"explore":{}},"alpha":2}`)); err != nil {
+		t.Fatalf("UnmarshalJSON: %v", err)
+	}
+	if _, err := SetAgentModel(&cfg, "build", "gpt-4.1"); err != nil {
+		t.Fatalf("SetAgentModel: %v", err)
+	}
+	data, err := cfg.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	want := `{"zeta":1,"agents":{"oracle":{"variant":"high","model":"o3"},"explore":{},"build":{"model":"gpt-4.1"}},"alpha":2}`
+	if string(data) != want {
+		t.Fatalf("unexpected order:\n%s\nwant:\n%s", data, want)
+	}
+}
@@ -0,0 +1,3 @@
+// Package schema loads the JSON schemas profiles reference in $schema and
+// validates profiles against them.
+package schema
@@ -0,0 +1,148 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"moirai/internal/profile"
+	"moirai/internal/util"
+)
+
+// Options control how profiles are validated.
+type Options struct {
+	// Path is a schema path or URL used instead of the profiles' $schema.
+	Path string
+}
+
+// Result is the outcome of validating one profile.
+type Result struct {
+	Profile string `json:"profile"`
+	// Schema is the $schema reference or override path that was used.
+	Schema string `json:"schema,omitempty"`
+	Origin string `json:"origin,omitempty"`
+	// Skipped explains why the profile could not be validated.
+	Skipped string  `json:"skipped,omitempty"`
+	Errors  []Error `json:"errors"`
+}
+
+// Valid reports whether no validation errors were found. Skipped profiles
+// count as valid.
+func (r Result) Valid() bool {
+	return len(r.Errors) == 0
+}
+
+// Err describes the validation errors as a single error, or returns nil when
+// the profile is valid.
+func (r Result) Err() error {
+	if r.Valid() {
+		return nil
+	}
+	details := make([]string, 0, len(r.Errors))
+	for _, err := range r.Errors {
+		details = append(details, err.String())
+	}
+	return fmt.Errorf("profile %q does not match its schema: %s", r.Profile, strings.Join(details, "; "))
+}
+
+// Validator validates the profiles of one config dir, loading each
+// referenced schema once.
+type Validator struct {
+	dir     string
+	opts    Options
+	schemas map[string]loadedSchema
+}
+
+type loadedSchema struct {
+	schema  *Schema
+	origin  string
+	skipped string
+}
+
+// NewValidator returns a Validator for the profiles in dir.
+func NewValidator(dir string, opts Options) *Validator {
+	return &Validator{dir: dir, opts: opts, schemas: make(map[string]loadedSchema)}
+}
+
+// ValidateProfile validates the named profile in dir.
+func ValidateProfile(dir, name string, opts Options) (Result, error) {
+	return NewValidator(dir, opts).Profile(name)
+}
+
+// ValidateProfileData validates the named profile as if its file held data.
+func ValidateProfileData(dir, name string, data []byte, opts Options) (Result, error) {
+	return NewValidator(dir, opts).Data(name, data)
+}
+
+// Profile validates the named profile with its parents merged in, since the
+// merged config is what oh-my-opencode reads.
+func (v *Validator) Profile(name string) (Result, error) {
+	resolved, err := profile.ResolveProfile(v.dir, name)
+	if err != nil {
+		return Result{}, err
+	}
+	return v.validate(resolved)
+}
+
+// Data validates the named profile as if its file held data.
+func (v *Validator) Data(name string, data []byte) (Result, error) {
+	resolved, err := profile.ResolveProfileData(v.dir, name, data)
+	if err != nil {
+		return Result{}, err
+	}
+	return v.validate(resolved)
+}
+
+func (v *Validator) validate(resolved *profile.Resolved) (Result, error) {
+	result := Result{Profile: resolved.Name, Errors: make([]Error, 0)}
+	ref := v.opts.Path
+	if ref == "" {
+		ref = resolved.Config.Schema
+	}
+	if ref == "" {
+		result.Skipped = "no $schema"
+		return result, nil
+	}
+	result.Schema = ref
+
+	loaded := v.load(ref)
+	result.Origin = loaded.origin
+	if loaded.schema == nil {
+		result.Skipped = loaded.skipped
+		return result, nil
+	}
+	errs, err := loaded.schema.ValidateJSON(resolved.Data)
+	if err != nil {
+		return Result{}, fmt.Errorf("parse profile %q: %w", resolved.Name, err)
+	}
+	result.Errors = errs
+	return result, nil
+}
+
+func (v *Validator) load(ref string) loadedSchema {
+	if loaded, ok := v.schemas[ref]; ok {
+		return loaded
+	}
+	var loaded loadedSchema
+	data, origin, err := v.read(ref)
+	if err != nil {
+		loaded.skipped = fmt.Sprintf("schema unavailable: %v", err)
+	} else if compiled, err := Compile(data); err != nil {
+		loaded.skipped = fmt.Sprintf("schema %s is invalid: %v", ref, err)
+	} else {
+		loaded.schema = compiled
+		loaded.origin = origin
+	}
+	v.schemas[ref] = loaded
+	return loaded
+}
+
+func (v *Validator) read(ref string) ([]byte, string, error) {
+	if strings.HasPrefix(ref, "~") {
+		expanded, err := util.ExpandUser(ref)
+		if err != nil {
+			return nil, "", err
+		}
+		ref = expanded
+	}
+	return Read(v.dir, ref)
+}
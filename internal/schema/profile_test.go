@@ -0,0 +1,74 @@
+package schema
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestValidateProfile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "schema.json"), string(Bundled()))
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.base"), `{"$schema":"schema.json","agents":{"oracle":{"temperature":3}}}`)
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.child"), `{
+  // inherits the schema and the invalid temperature
+  "moirai": {"extends": ["base"]},
+  "agents": {"explore": {"mode": "sideways"}},
+}`)
+	writeFile(t, filepath.Join(dir, "oh-my-opencode.json.plain"), `{"agents":{}}`)
+
+	result, err := ValidateProfile(dir, "child", Options{})
+	if err != nil {
+		t.Fatalf("ValidateProfile: %v", err)
+	}
+	want := []Error{
+		{Pointer: "/agents/explore/mode", Message: `must be one of "subagent", "primary", "all"`},
+		{Pointer: "/agents/oracle/temperature", Message: "must be <= 2"},
+	}
+	if result.Origin != OriginFile || !reflect.DeepEqual(result.Errors, want) {
+		t.Fatalf("unexpected result %#v", result)
+	}
+	if result.Err() == nil {
+		t.Fatal("expected error for invalid profile")
+	}
+
+	result, err = ValidateProfileData(dir, "child", []byte(`{"moirai":{"extends":["base"]},"agents":{"oracle":{"temperature":1}}}`), Options{})
+	if err != nil || !result.Valid() {
+		t.Fatalf("expected unsaved data to be valid, got %#v %v", result, err)
+	}
+
+	result, err = ValidateProfile(dir, "plain", Options{})
+	if err != nil || result.Skipped == "" || !result.Valid() {
+		t.Fatalf("expected profile without $schema to be skipped, got %#v %v", result, err)
+	}
+	result, err = ValidateProfile(dir, "plain", Options{Path: filepath.Join(dir, "schema.json")})
+	if err != nil || result.Skipped != "" || !result.Valid() {
+		t.Fatalf("expected override schema to be used, got %#v %v", result, err)
+	}
+}
+
+func TestReadFallsBackToBundledSchema(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "offline", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	data, origin, err := Read(dir, server.URL+"/assets/"+BundledName)
+	if err != nil || origin != OriginBundled || string(data) != string(Bundled()) {
+		t.Fatalf("expected bundled schema, got %q %v", origin, err)
+	}
+	if _, _, err := Read(dir, server.URL+"/other.json"); err == nil {
+		t.Fatal("expected error for unknown unreachable schema")
+	}
+}
@@ -0,0 +1,120 @@
+package schema
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"moirai/internal/app"
+	"moirai/internal/profile"
+)
+
+// Where a schema was loaded from.
+const (
+	OriginFile    = "file"
+	OriginCache   = "cache"
+	OriginRemote  = "remote"
+	OriginBundled = "bundled"
+)
+
+// BundledName is the file name of the oh-my-opencode schema shipped with
+// moirai. References ending in this name fall back to the bundled copy when
+// neither the network nor the cache can provide the schema.
+const BundledName = "oh-my-opencode.schema.json"
+
+const (
+	cacheDirName   = "schemas"
+	cacheMaxAge    = 7 * 24 * time.Hour
+	maxSchemaBytes = 4 << 20
+)
+
+//go:embed bundled/oh-my-opencode.schema.json
+var bundledSchema []byte
+
+var httpClient = &http.Client{Timeout: 3 * time.Second}
+
+// CacheDir returns the directory holding downloaded schemas for a config dir.
+func CacheDir(configDir string) string {
+	return filepath.Join(app.StateDir(configDir), cacheDirName)
+}
+
+// Bundled returns the oh-my-opencode schema shipped with moirai.
+func Bundled() []byte {
+	return append([]byte(nil), bundledSchema...)
+}
+
+// Read loads a schema from a local path or file:// URL, or from an http(s)
+// URL through a cache in the state dir, and reports where it came from. A
+// stale cache is used when the schema cannot be fetched, and the bundled
+// copy when there is no cache either.
+func Read(dir, ref string) ([]byte, string, error) {
+	if !strings.HasPrefix(ref, "http://") && !strings.HasPrefix(ref, "https://") {
+		path := strings.TrimPrefix(ref, "file://")
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", err
+		}
+		return data, OriginFile, nil
+	}
+
+	sum := sha256.Sum256([]byte(ref))
+	cachePath := filepath.Join(CacheDir(dir), hex.EncodeToString(sum[:8])+".json")
+	cached, cacheErr := os.ReadFile(cachePath)
+	if cacheErr == nil {
+		if info, err := os.Stat(cachePath); err == nil && time.Since(info.ModTime()) < cacheMaxAge {
+			return cached, OriginCache, nil
+		}
+	}
+	data, err := fetch(ref)
+	if err != nil {
+		if cacheErr == nil {
+			return cached, OriginCache, nil
+		}
+		if isBundledRef(ref) {
+			return Bundled(), OriginBundled, nil
+		}
+		return nil, "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err == nil {
+		_ = profile.SaveProfileDataAtomic(cachePath, data, 0o644)
+	}
+	return data, OriginRemote, nil
+}
+
+func isBundledRef(ref string) bool {
+	if i := strings.IndexAny(ref, "?#"); i >= 0 {
+		ref = ref[:i]
+	}
+	return path.Base(ref) == BundledName
+}
+
+func fetch(url string) ([]byte, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSchemaBytes))
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("fetch %s: response is not JSON", url)
+	}
+	return data, nil
+}
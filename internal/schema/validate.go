@@ -0,0 +1,548 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxRefDepth bounds $ref chains so recursive schemas cannot loop forever.
+const maxRefDepth = 32
+
+// Error is a validation failure at a JSON pointer in the instance.
+type Error struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+func (e Error) String() string {
+	pointer := e.Pointer
+	if pointer == "" {
+		pointer = "(root)"
+	}
+	return pointer + ": " + e.Message
+}
+
+// Schema is a compiled JSON Schema. It supports the validation keywords of
+// drafts 4 through 2020-12 with local "#..." references; "format" and
+// references to other documents are not checked.
+type Schema struct {
+	root     any
+	patterns map[string]*regexp.Regexp
+}
+
+// Compile parses a JSON Schema document.
+func Compile(data []byte) (*Schema, error) {
+	var root any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("parse schema: %w", err)
+	}
+	switch root.(type) {
+	case map[string]any, bool:
+	default:
+		return nil, fmt.Errorf("parse schema: expected an object or boolean")
+	}
+	s := &Schema{root: root, patterns: make(map[string]*regexp.Regexp)}
+	s.compilePatterns(root)
+	return s, nil
+}
+
+// ValidateJSON validates a JSON document against the schema.
+func (s *Schema) ValidateJSON(data []byte) ([]Error, error) {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return s.Validate(value), nil
+}
+
+// Validate checks a decoded JSON value and returns its errors sorted by
+// pointer.
+func (s *Schema) Validate(value any) []Error {
+	errs := s.validate(s.root, value, "", 0)
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Pointer < errs[j].Pointer
+	})
+	out := make([]Error, 0, len(errs))
+	seen := make(map[Error]struct{})
+	for _, err := range errs {
+		if _, ok := seen[err]; ok {
+			continue
+		}
+		seen[err] = struct{}{}
+		out = append(out, err)
+	}
+	return out
+}
+
+// compilePatterns compiles every pattern and patternProperties key up front.
+// Patterns Go cannot compile are skipped during validation.
+func (s *Schema) compilePatterns(node any) {
+	switch typed := node.(type) {
+	case map[string]any:
+		if pattern, ok := typed["pattern"].(string); ok {
+			s.compilePattern(pattern)
+		}
+		if props, ok := typed["patternProperties"].(map[string]any); ok {
+			for pattern := range props {
+				s.compilePattern(pattern)
+			}
+		}
+		for _, child := range typed {
+			s.compilePatterns(child)
+		}
+	case []any:
+		for _, child := range typed {
+			s.compilePatterns(child)
+		}
+	}
+}
+
+func (s *Schema) compilePattern(pattern string) {
+	if _, ok := s.patterns[pattern]; ok {
+		return
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		re = nil
+	}
+	s.patterns[pattern] = re
+}
+
+func (s *Schema) validate(node, value any, ptr string, depth int) []Error {
+	switch typed := node.(type) {
+	case bool:
+		if !typed {
+			return []Error{{Pointer: ptr, Message: "value is not allowed"}}
+		}
+		return nil
+	case map[string]any:
+		return s.validateObjectSchema(typed, value, ptr, depth)
+	default:
+		return nil
+	}
+}
+
+func (s *Schema) validateObjectSchema(node map[string]any, value any, ptr string, depth int) []Error {
+	var errs []Error
+	if ref, ok := node["$ref"].(string); ok {
+		if depth >= maxRefDepth {
+			return []Error{{Pointer: ptr, Message: fmt.Sprintf("schema reference %s nests too deeply", ref)}}
+		}
+		if target, ok := s.resolve(ref); ok {
+			errs = append(errs, s.validate(target, value, ptr, depth+1)...)
+		}
+	}
+
+	if types, ok := schemaTypes(node["type"]); ok && !matchesType(value, types) {
+		return append(errs, Error{Pointer: ptr, Message: fmt.Sprintf("expected %s, got %s", strings.Join(types, " or "), typeName(value))})
+	}
+	if allowed, ok := node["enum"].([]any); ok && !containsValue(allowed, value) {
+		errs = append(errs, Error{Pointer: ptr, Message: "must be one of " + formatValues(allowed)})
+	}
+	if want, ok := node["const"]; ok && !equalValues(want, value) {
+		errs = append(errs, Error{Pointer: ptr, Message: "must be " + formatValue(want)})
+	}
+
+	switch typed := value.(type) {
+	case map[string]any:
+		errs = append(errs, s.validateObject(node, typed, ptr, depth)...)
+	case []any:
+		errs = append(errs, s.validateArray(node, typed, ptr, depth)...)
+	case string:
+		errs = append(errs, s.validateString(node, typed, ptr)...)
+	case float64:
+		errs = append(errs, validateNumber(node, typed, ptr)...)
+	}
+
+	errs = append(errs, s.validateCombinators(node, value, ptr, depth)...)
+	return errs
+}
+
+func (s *Schema) validateObject(node, value map[string]any, ptr string, depth int) []Error {
+	var errs []Error
+	if required, ok := node["required"].([]any); ok {
+		for _, item := range required {
+			name, ok := item.(string)
+			if !ok {
+				continue
+			}
+			if _, ok := value[name]; !ok {
+				errs = append(errs, Error{Pointer: ptr, Message: fmt.Sprintf("missing required property %q", name)})
+			}
+		}
+	}
+	if limit, ok := number(node["minProperties"]); ok && float64(len(value)) < limit {
+		errs = append(errs, Error{Pointer: ptr, Message: fmt.Sprintf("must have at least %s properties", formatNumber(limit))})
+	}
+	if limit, ok := number(node["maxProperties"]); ok && float64(len(value)) > limit {
+		errs = append(errs, Error{Pointer: ptr, Message: fmt.Sprintf("must have at most %s properties", formatNumber(limit))})
+	}
+
+	properties, _ := node["properties"].(map[string]any)
+	patternProperties, _ := node["patternProperties"].(map[string]any)
+	additional, hasAdditional := node["additionalProperties"]
+	names, hasNames := node["propertyNames"]
+
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		child := value[key]
+		childPtr := ptr + "/" + escapePointer(key)
+		if hasNames {
+			for _, err := range s.validate(names, key, childPtr, depth) {
+				err.Pointer = childPtr
+				err.Message = "property name " + err.Message
+				errs = append(errs, err)
+			}
+		}
+		matched := false
+		if schema, ok := properties[key]; ok {
+			matched = true
+			errs = append(errs, s.validate(schema, child, childPtr, depth)...)
+		}
+		for pattern, schema := range patternProperties {
+			if re := s.patterns[pattern]; re != nil && re.MatchString(key) {
+				matched = true
+				errs = append(errs, s.validate(schema, child, childPtr, depth)...)
+			}
+		}
+		if matched || !hasAdditional {
+			continue
+		}
+		if allowed, ok := additional.(bool); ok && !allowed {
+			errs = append(errs, Error{Pointer: childPtr, Message: fmt.Sprintf("property %q is not allowed", key)})
+			continue
+		}
+		errs = append(errs, s.validate(additional, child, childPtr, depth)...)
+	}
+	return errs
+}
+
+func (s *Schema) validateArray(node map[string]any, value []any, ptr string, depth int) []Error {
+	var errs []Error
+	if limit, ok := number(node["minItems"]); ok && float64(len(value)) < limit {
+		errs = append(errs, Error{Pointer: ptr, Message: fmt.Sprintf("must have at least %s items", formatNumber(limit))})
+	}
+	if limit, ok := number(node["maxItems"]); ok && float64(len(value)) > limit {
+		errs = append(errs, Error{Pointer: ptr, Message: fmt.Sprintf("must have at most %s items", formatNumber(limit))})
+	}
+	if unique, _ := node["uniqueItems"].(bool); unique {
+		for i := range value {
+			for j := 0; j < i; j++ {
+				if equalValues(value[i], value[j]) {
+					errs = append(errs, Error{Pointer: ptr + "/" + strconv.Itoa(i), Message: fmt.Sprintf("duplicates item %d", j)})
+					break
+				}
+			}
+		}
+	}
+
+	// Draft 2020-12 spells positional items prefixItems and the rest items;
+	// earlier drafts use an items array followed by additionalItems.
+	prefix, _ := node["prefixItems"].([]any)
+	rest, hasRest := node["items"]
+	if list, ok := rest.([]any); ok {
+		prefix = list
+		rest, hasRest = node["additionalItems"]
+	}
+	for i, item := range value {
+		itemPtr := ptr + "/" + strconv.Itoa(i)
+		if i < len(prefix) {
+			errs = append(errs, s.validate(prefix[i], item, itemPtr, depth)...)
+			continue
+		}
+		if hasRest {
+			errs = append(errs, s.validate(rest, item, itemPtr, depth)...)
+		}
+	}
+
+	if contains, ok := node["contains"]; ok {
+		found := false
+		for i, item := range value {
+			if len(s.validate(contains, item, ptr+"/"+strconv.Itoa(i), depth)) == 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, Error{Pointer: ptr, Message: "does not contain a matching item"})
+		}
+	}
+	return errs
+}
+
+func (s *Schema) validateString(node map[string]any, value, ptr string) []Error {
+	var errs []Error
+	length := float64(utf8.RuneCountInString(value))
+	if limit, ok := number(node["minLength"]); ok && length < limit {
+		errs = append(errs, Error{Pointer: ptr, Message: fmt.Sprintf("must be at least %s characters", formatNumber(limit))})
+	}
+	if limit, ok := number(node["maxLength"]); ok && length > limit {
+		errs = append(errs, Error{Pointer: ptr, Message: fmt.Sprintf("must be at most %s characters", formatNumber(limit))})
+	}
+	if pattern, ok := node["pattern"].(string); ok {
+		if re := s.patterns[pattern]; re != nil && !re.MatchString(value) {
+			errs = append(errs, Error{Pointer: ptr, Message: fmt.Sprintf("must match pattern %q", pattern)})
+		}
+	}
+	return errs
+}
+
+func validateNumber(node map[string]any, value float64, ptr string) []Error {
+	var errs []Error
+	// Draft 4 marks the bounds exclusive with booleans; later drafts give
+	// the exclusive bounds as numbers.
+	exclusiveMin, _ := node["exclusiveMinimum"].(bool)
+	exclusiveMax, _ := node["exclusiveMaximum"].(bool)
+	if limit, ok := number(node["minimum"]); ok {
+		if exclusiveMin && value <= limit {
+			errs = append(errs, Error{Pointer: ptr, Message: "must be > " + formatNumber(limit)})
+		} else if value < limit {
+			errs = append(errs, Error{Pointer: ptr, Message: "must be >= " + formatNumber(limit)})
+		}
+	}
+	if limit, ok := number(node["maximum"]); ok {
+		if exclusiveMax && value >= limit {
+			errs = append(errs, Error{Pointer: ptr, Message: "must be < " + formatNumber(limit)})
+		} else if value > limit {
+			errs = append(errs, Error{Pointer: ptr, Message: "must be <= " + formatNumber(limit)})
+		}
+	}
+	if limit, ok := number(node["exclusiveMinimum"]); ok && value <= limit {
+		errs = append(errs, Error{Pointer: ptr, Message: "must be > " + formatNumber(limit)})
+	}
+	if limit, ok := number(node["exclusiveMaximum"]); ok && value >= limit {
+		errs = append(errs, Error{Pointer: ptr, Message: "must be < " + formatNumber(limit)})
+	}
+	if divisor, ok := number(node["multipleOf"]); ok && divisor > 0 {
+		quotient := value / divisor
+		if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			errs = append(errs, Error{Pointer: ptr, Message: "must be a multiple of " + formatNumber(divisor)})
+		}
+	}
+	return errs
+}
+
+func (s *Schema) validateCombinators(node map[string]any, value any, ptr string, depth int) []Error {
+	var errs []Error
+	if list, ok := node["allOf"].([]any); ok {
+		for _, schema := range list {
+			errs = append(errs, s.validate(schema, value, ptr, depth)...)
+		}
+	}
+	if list, ok := node["anyOf"].([]any); ok {
+		branches := s.branches(list, value, ptr, depth)
+		if !anyValid(branches) {
+			errs = append(errs, bestBranch(branches, value, ptr)...)
+		}
+	}
+	if list, ok := node["oneOf"].([]any); ok {
+		branches := s.branches(list, value, ptr, depth)
+		valid := 0
+		for _, branch := range branches {
+			if len(branch) == 0 {
+				valid++
+			}
+		}
+		switch {
+		case valid == 0:
+			errs = append(errs, bestBranch(branches, value, ptr)...)
+		case valid > 1:
+			errs = append(errs, Error{Pointer: ptr, Message: fmt.Sprintf("matches %d alternatives, expected exactly one", valid)})
+		}
+	}
+	if schema, ok := node["not"]; ok && len(s.validate(schema, value, ptr, depth)) == 0 {
+		errs = append(errs, Error{Pointer: ptr, Message: "matches a schema it must not match"})
+	}
+	if condition, ok := node["if"]; ok {
+		if len(s.validate(condition, value, ptr, depth)) == 0 {
+			if then, ok := node["then"]; ok {
+				errs = append(errs, s.validate(then, value, ptr, depth)...)
+			}
+		} else if otherwise, ok := node["else"]; ok {
+			errs = append(errs, s.validate(otherwise, value, ptr, depth)...)
+		}
+	}
+	return errs
+}
+
+func (s *Schema) branches(list []any, value any, ptr string, depth int) [][]Error {
+	branches := make([][]Error, 0, len(list))
+	for _, schema := range list {
+		branches = append(branches, s.validate(schema, value, ptr, depth))
+	}
+	return branches
+}
+
+func anyValid(branches [][]Error) bool {
+	for _, branch := range branches {
+		if len(branch) == 0 {
+			return true
+		}
+	}
+	return len(branches) == 0
+}
+
+// bestBranch reports why no alternative matched. When every alternative
+// rejects the value itself, a single summary is returned; otherwise the
+// errors of the alternative that got furthest into the value are used.
+func bestBranch(branches [][]Error, value any, ptr string) []Error {
+	var best []Error
+	types := make([]string, 0)
+	shallow := true
+	for _, branch := range branches {
+		deep := false
+		for _, err := range branch {
+			if err.Pointer != ptr {
+				deep = true
+			} else if strings.HasPrefix(err.Message, "expected ") && strings.Contains(err.Message, ", got ") {
+				types = append(types, strings.TrimPrefix(err.Message[:strings.LastIndex(err.Message, ", got ")], "expected "))
+			}
+		}
+		if deep {
+			shallow = false
+			if best == nil || len(branch) < len(best) {
+				best = branch
+			}
+		}
+	}
+	if !shallow {
+		return best
+	}
+	if len(types) == len(branches) {
+		return []Error{{Pointer: ptr, Message: fmt.Sprintf("expected %s, got %s", strings.Join(types, " or "), typeName(value))}}
+	}
+	return []Error{{Pointer: ptr, Message: "does not match any allowed alternative"}}
+}
+
+// resolve looks up a local reference such as "#/definitions/Agent".
+func (s *Schema) resolve(ref string) (any, bool) {
+	if ref == "#" {
+		return s.root, true
+	}
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, false
+	}
+	current := s.root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+		switch typed := current.(type) {
+		case map[string]any:
+			next, ok := typed[part]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(typed) {
+				return nil, false
+			}
+			current = typed[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func schemaTypes(raw any) ([]string, bool) {
+	switch typed := raw.(type) {
+	case string:
+		return []string{typed}, true
+	case []any:
+		types := make([]string, 0, len(typed))
+		for _, item := range typed {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types, len(types) > 0
+	default:
+		return nil, false
+	}
+}
+
+func matchesType(value any, types []string) bool {
+	actual := typeName(value)
+	for _, name := range types {
+		if name == actual || (name == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func typeName(value any) string {
+	switch typed := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case float64:
+		if typed == math.Trunc(typed) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func containsValue(list []any, value any) bool {
+	for _, item := range list {
+		if equalValues(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func equalValues(a, b any) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func number(raw any) (float64, bool) {
+	value, ok := raw.(float64)
+	return value, ok
+}
+
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatValue(value any) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+func formatValues(values []any) string {
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		parts = append(parts, formatValue(value))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,102 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	s, err := Compile([]byte(`{
+  "type": "object",
+  "required": ["name"],
+  "properties": {
+    "name": {"type": "string", "minLength": 1},
+    "mode": {"enum": ["a", "b"]},
+    "level": {"type": "integer", "minimum": 0, "exclusiveMaximum": 10},
+    "tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true},
+    "color": {"type": "string", "pattern": "^#[0-9a-f]{6}$"},
+    "value": {"anyOf": [{"type": "string"}, {"type": "boolean"}]},
+    "nested": {"$ref": "#/definitions/Nested"}
+  },
+  "patternProperties": {"^x-": {"type": "number"}},
+  "additionalProperties": false,
+  "definitions": {
+    "Nested": {"type": "object", "additionalProperties": {"type": "boolean"}}
+  }
+}`))
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+
+	valid := `{"name":"n","mode":"a","level":3,"tags":["x","y"],"color":"#00ff00","value":true,"nested":{"a/b":true},"x-rate":1.5}`
+	errs, err := s.ValidateJSON([]byte(valid))
+	if err != nil || len(errs) != 0 {
+		t.Fatalf("expected valid document, got %v %v", errs, err)
+	}
+
+	errs, err = s.ValidateJSON([]byte(`{"mode":"c","level":10.5,"tags":["x","x"],"color":"red","value":1,"nested":{"a/b~c":"yes"},"x-rate":"fast","extra":1}`))
+	if err != nil {
+		t.Fatalf("ValidateJSON: %v", err)
+	}
+	want := []Error{
+		{Pointer: "", Message: `missing required property "name"`},
+		{Pointer: "/color", Message: `must match pattern "^#[0-9a-f]{6}$"`},
+		{Pointer: "/extra", Message: `property "extra" is not allowed`},
+		{Pointer: "/level", Message: "expected integer, got number"},
+		{Pointer: "/mode", Message: `must be one of "a", "b"`},
+		{Pointer: "/nested/a~1b~0c", Message: "expected boolean, got string"},
+		{Pointer: "/tags/1", Message: "duplicates item 0"},
+		{Pointer: "/value", Message: "expected string or boolean, got integer"},
+		{Pointer: "/x-rate", Message: "expected number, got string"},
+	}
+	if !reflect.DeepEqual(errs, want) {
+		t.Fatalf("unexpected errors:\n%v\nwant:\n%v", errs, want)
+	}
+	if errs[0].String() != `(root): missing required property "name"` {
+		t.Fatalf("unexpected root error text %q", errs[0].String())
+	}
+}
+
+func TestValidateCombinators(t *testing.T) {
+	s, err := Compile([]byte(`{
+  "oneOf": [
+    {"type": "object", "properties": {"kind": {"const": "a"}, "size": {"type": "number", "maximum": 5}}, "required": ["kind"]},
+    {"type": "object", "properties": {"kind": {"const": "b"}}, "required": ["kind"]}
+  ],
+  "if": {"properties": {"kind": {"const": "b"}}},
+  "then": {"required": ["extra"]}
+}`))
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	tests := []struct {
+		doc  string
+		want []Error
+	}{
+		{doc: `{"kind":"a","size":1}`, want: []Error{}},
+		{doc: `{"kind":"a","size":9}`, want: []Error{{Pointer: "/size", Message: "must be <= 5"}}},
+		{doc: `{"kind":"b"}`, want: []Error{{Pointer: "", Message: `missing required property "extra"`}}},
+	}
+	for _, tc := range tests {
+		errs, err := s.ValidateJSON([]byte(tc.doc))
+		if err != nil {
+			t.Fatalf("ValidateJSON: %v", err)
+		}
+		if !reflect.DeepEqual(errs, tc.want) {
+			t.Fatalf("%s: unexpected errors %v, want %v", tc.doc, errs, tc.want)
+		}
+	}
+}
+
+func TestCompileRejectsInvalidSchema(t *testing.T) {
+	if _, err := Compile([]byte(`[1]`)); err == nil {
+		t.Fatal("expected error for array schema")
+	}
+	s, err := Compile([]byte(`false`))
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	if errs := s.Validate(map[string]any{}); len(errs) != 1 {
+		t.Fatalf("expected false schema to reject everything, got %v", errs)
+	}
+}
@@ -0,0 +1,3 @@
+// Package secrets finds API keys, tokens and passwords in profile values and
+// redacts them from output.
+package secrets
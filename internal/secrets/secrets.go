@@ -0,0 +1,274 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"moirai/internal/jsonc"
+	"moirai/internal/profile"
+)
+
+// Placeholder replaces redacted values.
+const Placeholder = "<redacted>"
+
+// Finding is a secret found at a JSON path. The value itself is never
+// exported.
+type Finding struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+	value  string
+}
+
+// secretKeyParts match anywhere in a normalized key name.
+var secretKeyParts = []string{"apikey", "secret", "password", "passwd", "credential", "privatekey", "accesskey", "authorization"}
+
+type tokenPattern struct {
+	kind    string
+	pattern *regexp.Regexp
+}
+
+var tokenPatterns = []tokenPattern{
+	{kind: "github token", pattern: regexp.MustCompile(`^(gh[pousr]_[A-Za-z0-9]{30,}|github_pat_[A-Za-z0-9_]{22,})$`)},
+	{kind: "api key", pattern: regexp.MustCompile(`^sk-[A-Za-z0-9_-]{20,}$`)},
+	{kind: "aws access key", pattern: regexp.MustCompile(`^(AKIA|ASIA)[0-9A-Z]{16}$`)},
+	{kind: "slack token", pattern: regexp.MustCompile(`^xox[abprs]-[A-Za-z0-9-]{10,}$`)},
+	{kind: "google api key", pattern: regexp.MustCompile(`^AIza[0-9A-Za-z_-]{35}$`)},
+	{kind: "jwt", pattern: regexp.MustCompile(`^eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}$`)},
+	{kind: "bearer token", pattern: regexp.MustCompile(`^(?i:bearer)\s+\S{16,}$`)},
+}
+
+// referencePattern matches values that point at a secret instead of holding
+// it, such as {env:OPENAI_API_KEY} or ${TOKEN}.
+var referencePattern = regexp.MustCompile(`^(\{(env|file):[^}]+\}|\$\{?[A-Za-z_][A-Za-z0-9_]*\}?)$`)
+
+// IsSecretKey reports whether an object key name suggests a secret value.
+func IsSecretKey(key string) bool {
+	normalized := strings.ToLower(strings.NewReplacer("_", "", "-", "", ".", "", " ", "").Replace(key))
+	for _, part := range secretKeyParts {
+		if strings.Contains(normalized, part) {
+			return true
+		}
+	}
+	// "accessToken" and "token" are secrets; "maxTokens" is not.
+	return strings.HasSuffix(normalized, "token")
+}
+
+// TokenKind reports whether value looks like a credential by its shape.
+func TokenKind(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	for _, p := range tokenPatterns {
+		if p.pattern.MatchString(value) {
+			return p.kind, true
+		}
+	}
+	return "", false
+}
+
+func isReference(value string) bool {
+	return referencePattern.MatchString(strings.TrimSpace(value))
+}
+
+// classify returns why a string value is a secret, or "" when it is not.
+func classify(value string, underSecretKey bool) string {
+	if strings.TrimSpace(value) == "" || value == Placeholder || isReference(value) {
+		return ""
+	}
+	if kind, ok := TokenKind(value); ok {
+		return "looks like a " + kind
+	}
+	if underSecretKey {
+		return "secret key name"
+	}
+	return ""
+}
+
+// Scan returns the secrets in a decoded JSON value, sorted by path.
+func Scan(value any) []Finding {
+	var findings []Finding
+	walk(value, "", false, func(path string, text string, underSecretKey bool) {
+		if reason := classify(text, underSecretKey); reason != "" {
+			findings = append(findings, Finding{Path: path, Reason: reason, value: text})
+		}
+	})
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Path < findings[j].Path })
+	return findings
+}
+
+// ScanJSON decodes data and returns the secrets it contains.
+func ScanJSON(data []byte) ([]Finding, error) {
+	value, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return Scan(value), nil
+}
+
+func walk(value any, path string, underSecretKey bool, visit func(path, text string, underSecretKey bool)) {
+	switch typed := value.(type) {
+	case map[string]any:
+		for key, child := range typed {
+			walk(child, profile.JoinObjectPath(path, key), underSecretKey || IsSecretKey(key), visit)
+		}
+	case []any:
+		for i, child := range typed {
+			walk(child, fmt.Sprintf("%s[%d]", path, i), underSecretKey, visit)
+		}
+	case string:
+		visit(path, typed, underSecretKey)
+	}
+}
+
+// Redact returns a copy of a decoded JSON value with secrets replaced by
+// Placeholder.
+func Redact(value any) any {
+	return redact(value, false)
+}
+
+func redact(value any, underSecretKey bool) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(typed))
+		for key, child := range typed {
+			out[key] = redact(child, underSecretKey || IsSecretKey(key))
+		}
+		return out
+	case []any:
+		out := make([]any, len(typed))
+		for i, child := range typed {
+			out[i] = redact(child, underSecretKey)
+		}
+		return out
+	case string:
+		if classify(typed, underSecretKey) != "" {
+			return Placeholder
+		}
+	}
+	return value
+}
+
+// RedactJSON replaces secrets in a JSON document and reports how many were
+// redacted. Only the secret values themselves are rewritten, at their own
+// location, so keys and equal non-secret values stay intact and comments,
+// key order and formatting are kept.
+func RedactJSON(data []byte) ([]byte, int, error) {
+	value, err := decode(data)
+	if err != nil {
+		return data, 0, err
+	}
+	findings := Scan(value)
+	if len(findings) == 0 {
+		return data, 0, nil
+	}
+	redacted, err := encode(Redact(value))
+	if err != nil {
+		return nil, 0, err
+	}
+	out, err := jsonc.Update(data, redacted)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, len(findings), nil
+}
+
+// RedactChanges returns changes with secret values replaced by Placeholder.
+// Values below a key that names a secret are redacted entirely.
+func RedactChanges(changes []profile.Change) []profile.Change {
+	out := make([]profile.Change, len(changes))
+	for i, change := range changes {
+		underSecretKey := false
+		for _, segment := range pathKeys(change.Path) {
+			if IsSecretKey(segment) {
+				underSecretKey = true
+				break
+			}
+		}
+		change.Old = redactRaw(change.Old, underSecretKey)
+		change.New = redactRaw(change.New, underSecretKey)
+		out[i] = change
+	}
+	return out
+}
+
+func redactRaw(raw json.RawMessage, underSecretKey bool) json.RawMessage {
+	if len(raw) == 0 {
+		return raw
+	}
+	value, err := decode(raw)
+	if err != nil {
+		return raw
+	}
+	redacted, err := encode(redact(value, underSecretKey))
+	if err != nil {
+		return raw
+	}
+	if bytes.Equal(redacted, raw) {
+		return raw
+	}
+	return redacted
+}
+
+// encode marshals value without escaping <, > and &, matching how profiles
+// are usually written.
+func encode(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func decode(data []byte) (any, error) {
+	data, err := jsonc.Standardize(data)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// pathKeys returns the object keys in a change path such as
+// agents.oracle["api key"][0].
+func pathKeys(path string) []string {
+	var keys []string
+	for len(path) > 0 {
+		switch {
+		case path[0] == '.':
+			path = path[1:]
+		case strings.HasPrefix(path, `["`):
+			quoted, err := strconv.QuotedPrefix(path[1:])
+			if err != nil {
+				return keys
+			}
+			if key, err := strconv.Unquote(quoted); err == nil {
+				keys = append(keys, key)
+			}
+			path = strings.TrimPrefix(path[1+len(quoted):], "]")
+		case path[0] == '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return keys
+			}
+			path = path[end+1:]
+		default:
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			keys = append(keys, path[:end])
+			path = path[end:]
+		}
+	}
+	return keys
+}
@@ -1,9 +1,21 @@
 package tui
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"moirai/internal/app"
 	"moirai/internal/backup"
+	"moirai/internal/gitstore"
+	"moirai/internal/journal"
+	"moirai/internal/lifecycle"
 	"moirai/internal/link"
+	"moirai/internal/lock"
 	"moirai/internal/profile"
+	"moirai/internal/schema"
+	"moirai/internal/secrets"
 )
 
 type modelActions struct {
@@ -14,37 +26,178 @@ type modelActions struct {
 	diffBetweenProfiles   func(dir, profileA, profileB string) (string, error)
 	loadProfile           func(path string) (*profile.RootConfig, error)
 	saveProfile           func(path string, cfg *profile.RootConfig) error
-	backupProfile         func(dir, profileName string) (string, error)
-	applyAutofill         func(cfg *profile.RootConfig, knownAgents []string, preset profile.Preset) bool
+	validateProfile       func(dir, profileName string, cfg *profile.RootConfig) ([]schema.Error, error)
+	backupProfile         func(dir, profileName, reason string) (string, error)
+	applyAutofill         func(cfg *profile.RootConfig, changes []profile.AgentChange) bool
 	loadModels            func() []string
+	cachedModels          func() []string
+	fingerprintProfile    func(path string) (profile.Fingerprint, error)
+	lockConfig            func(dir string, timeout time.Duration) (func(), error)
+	recordOperation       func(dir string, entry journal.Entry) error
+	adoptActive           func(dir, name string) error
+	declineAdopt          func(dir string) error
+	discoverProfiles      func(dir string) ([]profile.ProfileInfo, error)
 }
 
 func defaultActions() modelActions {
 	return modelActions{
-		applyProfile:          link.ApplyProfile,
-		listProfileBackups:    backup.ListProfileBackups,
+		applyProfile:          applyProfileWith(app.AppConfig{}),
+		listProfileBackups:    listProfileBackupsWith(app.AppConfig{}),
 		activeProfile:         link.ActiveProfile,
-		diffAgainstLastBackup: diffAgainstLastBackup,
-		diffBetweenProfiles:   profile.DiffProfiles,
+		diffAgainstLastBackup: diffAgainstLastBackupWith(app.AppConfig{}),
+		diffBetweenProfiles:   diffBetweenProfiles,
 		loadProfile:           profile.LoadProfile,
 		saveProfile:           profile.SaveProfileAtomic,
-		backupProfile:         backup.BackupProfile,
-		applyAutofill:         profile.ApplyAutofill,
+		validateProfile:       validateProfileWith(""),
+		backupProfile:         backupProfileWith(app.AppConfig{}),
+		applyAutofill:         profile.ApplyAgentChanges,
 		loadModels:            loadModelList,
+		cachedModels:          loadCachedModelList,
+		fingerprintProfile:    profile.FileFingerprint,
+		lockConfig:            lockConfigDir,
+		recordOperation:       recordOperationWith(app.AppConfig{}),
+		adoptActive:           adoptActiveWith(app.AppConfig{}),
+		declineAdopt:          lifecycle.DeclineAdopt,
+		discoverProfiles:      profile.DiscoverProfiles,
 	}
 }
 
-func diffAgainstLastBackup(dir, profileName string) (string, bool, error) {
-	backupName, ok, err := backup.LatestProfileBackup(dir, profileName)
+func lockConfigDir(dir string, timeout time.Duration) (func(), error) {
+	held, err := lock.Acquire(dir, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		_ = held.Release()
+	}, nil
+}
+
+// configIn returns config, or the defaults of dir for the zero config the
+// default actions are built with.
+func configIn(config app.AppConfig, dir string) app.AppConfig {
+	if config.ConfigDir == "" {
+		config.ConfigDir = dir
+	}
+	return config
+}
+
+// errCommit reports an operation that completed but could not be committed
+// to the git store.
+var errCommit = errors.New("git store commit failed")
+
+// commitOperation commits the journaled operation entry to the git store
+// when config enables it.
+func commitOperation(config app.AppConfig, entry journal.Entry) error {
+	if !config.Git.Enabled || entry.Op == "" {
+		return nil
+	}
+	change := gitstore.Change{Op: entry.Op, Profile: entry.Profile, Journal: entry.ID}
+	if _, err := gitstore.Commit(config.ConfigDir, config.Git, change); err != nil {
+		return fmt.Errorf("%w: %w", errCommit, err)
+	}
+	return nil
+}
+
+// applyProfileWith returns an applyProfile action that honors the
+// strictReferences, backupDir and git settings of config.
+func applyProfileWith(config app.AppConfig) func(dir, profileName string) error {
+	return func(dir, profileName string) error {
+		config := configIn(config, dir)
+		res, err := link.ApplyProfileWith(dir, profileName, link.ApplyOptions{
+			Strict:    config.StrictReferences,
+			Backups:   backup.NewStore(config),
+			Retention: config.BackupRetention,
+		})
+		if err != nil {
+			return err
+		}
+		return commitOperation(config, res.Entry)
+	}
+}
+
+func listProfileBackupsWith(config app.AppConfig) func(dir, profileName string) ([]string, error) {
+	return func(dir, profileName string) ([]string, error) {
+		return backup.ListProfileBackups(backup.NewStore(configIn(config, dir)), profileName)
+	}
+}
+
+func backupProfileWith(config app.AppConfig) func(dir, profileName, reason string) (string, error) {
+	return func(dir, profileName, reason string) (string, error) {
+		entry, err := backup.BackupProfileWith(backup.NewStore(configIn(config, dir)), profileName, backup.Options{Reason: reason, Retention: config.BackupRetention})
+		return entry.Path, err
+	}
+}
+
+// recordOperationWith returns a recordOperation action that journals a write
+// made by the TUI and commits it to the git store of config. The write
+// already happened, so a journal error only loses the ability to undo it.
+func recordOperationWith(config app.AppConfig) func(dir string, entry journal.Entry) error {
+	return func(dir string, entry journal.Entry) error {
+		recorded, err := journal.Record(dir, entry)
+		if err != nil {
+			return err
+		}
+		return commitOperation(configIn(config, dir), recorded)
+	}
+}
+
+func adoptActiveWith(config app.AppConfig) func(dir, name string) error {
+	return func(dir, name string) error {
+		config := configIn(config, dir)
+		res, err := lifecycle.AdoptActive(config, name)
+		if err != nil {
+			return err
+		}
+		return commitOperation(config, res.Entry)
+	}
+}
+
+// validateProfileWith returns a validateProfile action that checks unsaved
+// profiles against schemaPath, or their own $schema when it is empty.
+func validateProfileWith(schemaPath string) func(dir, profileName string, cfg *profile.RootConfig) ([]schema.Error, error) {
+	return func(dir, profileName string, cfg *profile.RootConfig) ([]schema.Error, error) {
+		data, err := json.Marshal(cfg)
+		if err != nil {
+			return nil, err
+		}
+		result, err := schema.ValidateProfileData(dir, profileName, data, schema.Options{Path: schemaPath})
+		if err != nil {
+			return nil, err
+		}
+		return result.Errors, nil
+	}
+}
+
+func diffAgainstLastBackupWith(config app.AppConfig) func(dir, profileName string) (string, bool, error) {
+	return func(dir, profileName string) (string, bool, error) {
+		return diffAgainstLastBackup(backup.NewStore(configIn(config, dir)), profileName)
+	}
+}
+
+func diffAgainstLastBackup(store backup.Store, profileName string) (string, bool, error) {
+	dir := store.ConfigDir
+	backupName, ok, err := backup.LatestProfileBackup(store, profileName)
 	if err != nil {
 		return "", false, err
 	}
 	if !ok {
 		return "", false, nil
 	}
-	diff, err := profile.DiffProfileAgainstFile(dir, profileName, backupName)
+	backupPath, err := backup.Locate(store, backupName)
+	if err != nil {
+		return "", true, err
+	}
+	changes, err := profile.DiffProfileAgainstFile(dir, profileName, backupPath)
 	if err != nil {
 		return "", true, err
 	}
-	return diff, true, nil
+	return profile.FormatDiff(backupName, profileName, secrets.RedactChanges(changes), true), true, nil
+}
+
+func diffBetweenProfiles(dir, profileA, profileB string) (string, error) {
+	changes, err := profile.DiffProfiles(dir, profileA, profileB)
+	if err != nil {
+		return "", err
+	}
+	return profile.FormatDiff(profileA, profileB, secrets.RedactChanges(changes), true), nil
 }
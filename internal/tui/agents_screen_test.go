@@ -3,8 +3,14 @@ package tui
 import (
 	"strings"
 	"testing"
+	"time"
 
+	"moirai/internal/app"
+	"moirai/internal/catalog"
+	"moirai/internal/journal"
+	"moirai/internal/presets"
 	"moirai/internal/profile"
+	"moirai/internal/schema"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -66,7 +72,7 @@ func TestAgentsScreenFlagsMissingModels(t *testing.T) {
 			"oracle": {Model: "gpt-4o-mini"},
 		},
 	}
-	entries := collectAgentEntries(cfg, profile.KnownAgents())
+	entries := collectAgentEntries(cfg, catalog.Builtin())
 
 	var prometheus agentEntry
 	foundPrometheus := false
@@ -109,7 +115,7 @@ func TestModelPickerSelectionUpdatesConfigAndMarksDirty(t *testing.T) {
 	actions.loadProfile = func(_ string) (*profile.RootConfig, error) {
 		return cfg, nil
 	}
-	actions.backupProfile = func(_, _ string) (string, error) { backupCalls++; return "/config/backup", nil }
+	actions.backupProfile = func(_, _, _ string) (string, error) { backupCalls++; return "/config/backup", nil }
 	actions.saveProfile = func(_ string, _ *profile.RootConfig) error { saveCalls++; return nil }
 	actions.loadModels = func() []string {
 		return []string{"gpt-4o-mini", "gpt-4o"}
@@ -164,10 +170,10 @@ func TestAgentsSaveBacksUpWritesAndClearsDirty(t *testing.T) {
 	var backupCalls, saveCalls int
 	actions := stubActions()
 	actions.loadProfile = func(_ string) (*profile.RootConfig, error) { return cfg, nil }
-	actions.backupProfile = func(dir, profileName string) (string, error) {
+	actions.backupProfile = func(dir, profileName, reason string) (string, error) {
 		backupCalls++
-		if dir != "/config" || profileName != "alpha" {
-			t.Fatalf("unexpected backup args: %q %q", dir, profileName)
+		if dir != "/config" || profileName != "alpha" || reason != journal.OpSave {
+			t.Fatalf("unexpected backup args: %q %q %q", dir, profileName, reason)
 		}
 		return "/config/backup", nil
 	}
@@ -182,6 +188,11 @@ func TestAgentsSaveBacksUpWritesAndClearsDirty(t *testing.T) {
 		return nil
 	}
 	actions.loadModels = func() []string { return []string{"gpt-4o-mini"} }
+	var recorded []journal.Entry
+	actions.recordOperation = func(_ string, entry journal.Entry) error {
+		recorded = append(recorded, entry)
+		return nil
+	}
 
 	m := newModelWithActions("/config", false, profiles, "", false, actions)
 	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
@@ -226,6 +237,55 @@ func TestAgentsSaveBacksUpWritesAndClearsDirty(t *testing.T) {
 	if m.status.Message != "Saved" || m.status.Kind != statusKindSuccess {
 		t.Fatalf("expected saved status, got kind=%v msg=%q", m.status.Kind, m.status.Message)
 	}
+	if len(recorded) != 1 || recorded[0].Op != journal.OpSave || recorded[0].Profile != "alpha" || recorded[0].Backup != "/config/backup" {
+		t.Fatalf("expected save journaled, got %#v", recorded)
+	}
+}
+
+func TestAgentsSaveConfirmsSchemaErrors(t *testing.T) {
+	profiles := []profile.ProfileInfo{
+		{Name: "alpha", Path: "/config/oh-my-opencode.json.alpha"},
+	}
+	cfg := &profile.RootConfig{}
+
+	var saveCalls int
+	actions := stubActions()
+	actions.loadProfile = func(_ string) (*profile.RootConfig, error) { return cfg, nil }
+	actions.saveProfile = func(_ string, _ *profile.RootConfig) error { saveCalls++; return nil }
+	actions.validateProfile = func(dir, profileName string, _ *profile.RootConfig) ([]schema.Error, error) {
+		if dir != "/config" || profileName != "alpha" {
+			t.Fatalf("unexpected validate args: %q %q", dir, profileName)
+		}
+		return []schema.Error{{Pointer: "/agents/sisyphus/temperature", Message: "must be <= 2"}}, nil
+	}
+	actions.loadModels = func() []string { return []string{"gpt-4o-mini"} }
+
+	m := newModelWithActions("/config", false, profiles, "", false, actions)
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	updated, _ = updated.(model).Update(cmd())
+	m = updated.(model)
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(model)
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(model)
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+	updated, cmd = updated.(model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	updated, _ = updated.(model).Update(cmd())
+	m = updated.(model)
+	if saveCalls != 0 || !m.agentsDirty {
+		t.Fatalf("expected invalid profile not to be saved, got %d saves", saveCalls)
+	}
+	if !m.confirm.Open || len(m.confirm.Details) != 1 || m.confirm.Details[0] != "/agents/sisyphus/temperature: must be <= 2" {
+		t.Fatalf("expected schema errors in confirm, got %#v", m.confirm)
+	}
+
+	updated, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	updated, _ = updated.(model).Update(cmd())
+	m = updated.(model)
+	if saveCalls != 1 || m.agentsDirty {
+		t.Fatalf("expected save anyway to write, got %d saves dirty=%v", saveCalls, m.agentsDirty)
+	}
 }
 
 func TestAgentsAutofillDisabledShowsMessageAndDoesNotWrite(t *testing.T) {
@@ -237,9 +297,9 @@ func TestAgentsAutofillDisabledShowsMessageAndDoesNotWrite(t *testing.T) {
 	var backupCalls, saveCalls, autofillCalls int
 	actions := stubActions()
 	actions.loadProfile = func(_ string) (*profile.RootConfig, error) { return cfg, nil }
-	actions.backupProfile = func(_, _ string) (string, error) { backupCalls++; return "", nil }
+	actions.backupProfile = func(_, _, _ string) (string, error) { backupCalls++; return "", nil }
 	actions.saveProfile = func(_ string, _ *profile.RootConfig) error { saveCalls++; return nil }
-	actions.applyAutofill = func(_ *profile.RootConfig, _ []string, _ profile.Preset) bool {
+	actions.applyAutofill = func(_ *profile.RootConfig, _ []profile.AgentChange) bool {
 		autofillCalls++
 		return false
 	}
@@ -279,11 +339,11 @@ func TestAgentsAutofillEnabledFillsAndSaves(t *testing.T) {
 	var backupCalls, saveCalls, autofillCalls int
 	actions := stubActions()
 	actions.loadProfile = func(_ string) (*profile.RootConfig, error) { return cfg, nil }
-	actions.backupProfile = func(_, _ string) (string, error) { backupCalls++; return "", nil }
+	actions.backupProfile = func(_, _, _ string) (string, error) { backupCalls++; return "", nil }
 	actions.saveProfile = func(_ string, _ *profile.RootConfig) error { saveCalls++; return nil }
-	actions.applyAutofill = func(cfg *profile.RootConfig, knownAgents []string, preset profile.Preset) bool {
+	actions.applyAutofill = func(cfg *profile.RootConfig, changes []profile.AgentChange) bool {
 		autofillCalls++
-		return profile.ApplyAutofill(cfg, knownAgents, preset)
+		return profile.ApplyAgentChanges(cfg, changes)
 	}
 
 	m := newModelWithActions("/config", true, profiles, "", false, actions)
@@ -328,3 +388,208 @@ func TestAgentsAutofillEnabledFillsAndSaves(t *testing.T) {
 		t.Fatalf("expected sisyphus to be filled")
 	}
 }
+
+func TestAgentsAutofillUsesConfiguredPreset(t *testing.T) {
+	profiles := []profile.ProfileInfo{
+		{Name: "alpha", Path: "/config/oh-my-opencode.json.alpha"},
+	}
+	cfg := &profile.RootConfig{Agents: map[string]profile.AgentConfig{}}
+	actions := stubActions()
+	actions.loadProfile = func(_ string) (*profile.RootConfig, error) { return cfg, nil }
+
+	set, err := presets.Load(app.AppConfig{
+		ConfigDir: t.TempDir(),
+		Presets: map[string]app.PresetSpec{
+			"team": {Default: "gpt-4.1", Agents: map[string]string{"oracle": "o3"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("presets.Load: %v", err)
+	}
+	m := newModelWithActions("/config", true, profiles, "", false, actions)
+	m.presets = set
+	m.presetName = "team"
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	updated, _ = updated.(model).Update(cmd())
+	updated, _ = updated.(model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	m = updated.(model)
+	if !strings.Contains(m.confirm.Prompt, "preset 'team'") {
+		t.Fatalf("expected prompt to name the preset, got %q", m.confirm.Prompt)
+	}
+	updated, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	updated.(model).Update(cmd())
+
+	if cfg.Agents["oracle"].Model != "o3" || cfg.Agents["atlas"].Model != "gpt-4.1" {
+		t.Fatalf("unexpected autofill result %#v", cfg.Agents)
+	}
+}
+
+func TestAgentsAutofillUpgradeModeShowsPlan(t *testing.T) {
+	profiles := []profile.ProfileInfo{
+		{Name: "alpha", Path: "/config/oh-my-opencode.json.alpha"},
+	}
+	cfg := &profile.RootConfig{Agents: map[string]profile.AgentConfig{
+		"oracle":  {Model: "retired"},
+		"explore": {Model: "gpt-4o-mini"},
+	}}
+	var saveCalls int
+	actions := stubActions()
+	actions.loadProfile = func(_ string) (*profile.RootConfig, error) { return cfg, nil }
+	actions.saveProfile = func(_ string, _ *profile.RootConfig) error { saveCalls++; return nil }
+
+	m := newModelWithActions("/config", true, profiles, "", false, actions)
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	updated, _ = updated.(model).Update(cmd())
+	for i := 0; i < 2; i++ {
+		updated, _ = updated.(model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("m")})
+	}
+	m = updated.(model)
+	if m.autofillMode != profile.AutofillUpgrade || m.status.Message != "Autofill mode: upgrade" {
+		t.Fatalf("expected upgrade mode, got %q (%q)", m.autofillMode, m.status.Message)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	m = updated.(model)
+	if !m.confirm.Open || len(m.confirm.Details) != 1 || m.confirm.Details[0] != "oracle: retired -> gpt-4o-mini" {
+		t.Fatalf("unexpected plan %#v", m.confirm)
+	}
+	if !strings.Contains(m.View(), "oracle: retired -> gpt-4o-mini") {
+		t.Fatalf("expected plan in confirm modal:\n%s", m.View())
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	m = updated.(model)
+	if saveCalls != 0 || cfg.Agents["oracle"].Model != "retired" {
+		t.Fatalf("expected cancel to leave the profile alone")
+	}
+}
+
+func TestAgentsSaveDetectsExternalChange(t *testing.T) {
+	profiles := []profile.ProfileInfo{
+		{Name: "alpha", Path: "/config/oh-my-opencode.json.alpha"},
+	}
+	cfg := &profile.RootConfig{Agents: map[string]profile.AgentConfig{"oracle": {Model: "a"}}}
+	onDisk := "v1"
+
+	var saveCalls, lockCalls int
+	actions := stubActions()
+	actions.loadProfile = func(_ string) (*profile.RootConfig, error) {
+		if onDisk == "v1" {
+			return cfg, nil
+		}
+		return &profile.RootConfig{Agents: map[string]profile.AgentConfig{"oracle": {Model: "external"}}}, nil
+	}
+	actions.fingerprintProfile = func(_ string) (profile.Fingerprint, error) {
+		return profile.Fingerprint{Hash: onDisk}, nil
+	}
+	actions.lockConfig = func(_ string, _ time.Duration) (func(), error) {
+		lockCalls++
+		return func() {}, nil
+	}
+	actions.saveProfile = func(_ string, _ *profile.RootConfig) error {
+		saveCalls++
+		onDisk = "v3"
+		return nil
+	}
+
+	m := newModelWithActions("/config", false, profiles, "", false, actions)
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	updated, _ = updated.(model).Update(cmd())
+	m = updated.(model)
+	if m.agentsFingerprint.Hash != "v1" {
+		t.Fatalf("expected fingerprint from load, got %q", m.agentsFingerprint.Hash)
+	}
+
+	// Another process rewrites the profile while we edit it.
+	onDisk = "v2"
+	m.agentsDirty = true
+	save := func(m model) model {
+		updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("s")})
+		updated, cmd := updated.(model).Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+		updated, _ = updated.(model).Update(cmd())
+		return updated.(model)
+	}
+	m = save(m)
+	if !m.agentsConflict {
+		t.Fatalf("expected conflict prompt")
+	}
+	if saveCalls != 0 {
+		t.Fatalf("expected no save on conflict, got %d", saveCalls)
+	}
+	if !strings.Contains(m.View(), "changed on disk since it was loaded") {
+		t.Fatalf("expected conflict modal in view")
+	}
+
+	updated, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	updated, _ = updated.(model).Update(cmd())
+	m = updated.(model)
+	if m.screen != screenDiff || m.diffMode != diffModeUnsaved {
+		t.Fatalf("expected unsaved diff, got screen=%v mode=%v", m.screen, m.diffMode)
+	}
+	if !strings.Contains(stripANSI(m.diffContent), "~ agents.oracle.model: external -> a") {
+		t.Fatalf("unexpected diff content %q", m.diffContent)
+	}
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updated.(model)
+	if m.screen != screenAgents || !m.agentsDirty {
+		t.Fatalf("expected back on dirty agents screen, got screen=%v dirty=%v", m.screen, m.agentsDirty)
+	}
+
+	m = save(m)
+	if !m.agentsConflict {
+		t.Fatalf("expected conflict prompt again")
+	}
+	updated, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("o")})
+	updated, _ = updated.(model).Update(cmd())
+	m = updated.(model)
+	if saveCalls != 1 || m.agentsDirty || m.agentsConflict {
+		t.Fatalf("expected overwrite to save, got saves=%d dirty=%v conflict=%v", saveCalls, m.agentsDirty, m.agentsConflict)
+	}
+	if m.agentsFingerprint.Hash != "v3" {
+		t.Fatalf("expected fingerprint refreshed after save, got %q", m.agentsFingerprint.Hash)
+	}
+	if lockCalls != 3 {
+		t.Fatalf("expected every save attempt to take the lock, got %d", lockCalls)
+	}
+
+	onDisk = "v4"
+	m.agentsDirty = true
+	m = save(m)
+	updated, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	updated, _ = updated.(model).Update(cmd())
+	m = updated.(model)
+	if m.agentsConflict || m.agentsDirty || m.agentsFingerprint.Hash != "v4" {
+		t.Fatalf("expected reload to discard edits, got conflict=%v dirty=%v fingerprint=%q", m.agentsConflict, m.agentsDirty, m.agentsFingerprint.Hash)
+	}
+}
+
+func TestAgentsScreenShowsCatalogMetadata(t *testing.T) {
+	profiles := []profile.ProfileInfo{
+		{Name: "alpha", Path: "/config/oh-my-opencode.json.alpha"},
+	}
+	cfg := &profile.RootConfig{Agents: map[string]profile.AgentConfig{"typo": {Model: "x"}}}
+	actions := stubActions()
+	actions.loadProfile = func(_ string) (*profile.RootConfig, error) { return cfg, nil }
+
+	m := newModelWithActions("/config", false, profiles, "", false, actions)
+	m.catalog = catalog.Catalog{Agents: []catalog.Agent{
+		{Name: "oracle", Description: "Consultant", Required: true, ModelClass: "reasoning"},
+		{Name: "helper", Description: "Optional helper"},
+	}}
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	updated, _ = updated.(model).Update(cmd())
+	m = updated.(model)
+
+	view := stripANSI(m.View())
+	for _, want := range []string{"oracle*: (missing)", "helper: (unset)", "typo: x", "oracle: Consultant (reasoning model, required)"} {
+		if !strings.Contains(view, want) {
+			t.Fatalf("expected %q in view:\n%s", want, view)
+		}
+	}
+
+	m.moveAgentsSelection(2)
+	if view := stripANSI(m.View()); !strings.Contains(view, "typo: not in the agent catalog") {
+		t.Fatalf("expected custom agent details:\n%s", view)
+	}
+}
@@ -1,10 +1,16 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"moirai/internal/app"
+	"moirai/internal/catalog"
+	"moirai/internal/presets"
 	"moirai/internal/profile"
+	"moirai/internal/schema"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -24,11 +30,20 @@ type diffMode int
 const (
 	diffModeLastBackup diffMode = iota
 	diffModeActiveProfile
+	diffModeUnsaved
 )
 
 type model struct {
-	configDir       string
-	enableAutofill  bool
+	configDir      string
+	enableAutofill bool
+	lockTimeout    time.Duration
+	catalog        catalog.Catalog
+	presets        presets.Set
+	presetName     string
+	// presetsErr is set when the configured presets failed to load; the
+	// autofill action reports it instead of silently using the built-ins.
+	presetsErr      error
+	autofillMode    profile.AutofillMode
 	profiles        []profile.ProfileInfo
 	profilesVisible []profile.ProfileInfo
 	activeName      string
@@ -66,6 +81,10 @@ type model struct {
 	agentsEntries  []agentEntry
 	agentsSelected int
 	agentsDirty    bool
+	// agentsFingerprint is the profile file state when it was loaded; saves
+	// compare against it to avoid overwriting external edits.
+	agentsFingerprint profile.Fingerprint
+	agentsConflict    bool
 
 	modelSearch      string
 	modelAll         []string
@@ -79,6 +98,11 @@ type applyResultMsg struct {
 	err     error
 }
 
+type adoptResultMsg struct {
+	profile string
+	err     error
+}
+
 type backupsResultMsg struct {
 	profile string
 	backups []string
@@ -95,20 +119,27 @@ type diffResultMsg struct {
 }
 
 type agentsLoadMsg struct {
-	profile profile.ProfileInfo
-	cfg     *profile.RootConfig
-	err     error
+	profile     profile.ProfileInfo
+	cfg         *profile.RootConfig
+	fingerprint profile.Fingerprint
+	err         error
 }
 
 type agentsSaveMsg struct {
-	err error
+	fingerprint profile.Fingerprint
+	// invalid lists schema errors that stopped the save; force is the
+	// setting to retry with when the user saves anyway.
+	invalid []schema.Error
+	force   bool
+	err     error
 }
 
 type agentsAutofillMsg struct {
-	filled  int
-	changed bool
-	saved   bool
-	err     error
+	filled      int
+	changed     bool
+	saved       bool
+	fingerprint profile.Fingerprint
+	err         error
 }
 
 type ModelsRefreshedMsg struct {
@@ -141,6 +172,11 @@ func newModelWithActions(configDir string, enableAutofill bool, profiles []profi
 	m := model{
 		configDir:       configDir,
 		enableAutofill:  enableAutofill,
+		lockTimeout:     app.DefaultLockTimeout,
+		catalog:         catalog.Builtin(),
+		presets:         presets.Builtin(),
+		presetName:      app.DefaultPresetName,
+		autofillMode:    profile.AutofillMissing,
 		profiles:        profiles,
 		profilesVisible: append([]profile.ProfileInfo(nil), profiles...),
 		activeName:      activeName,
@@ -179,6 +215,9 @@ func normalizeActions(actions modelActions) modelActions {
 	if actions.saveProfile == nil {
 		actions.saveProfile = defaults.saveProfile
 	}
+	if actions.validateProfile == nil {
+		actions.validateProfile = defaults.validateProfile
+	}
 	if actions.backupProfile == nil {
 		actions.backupProfile = defaults.backupProfile
 	}
@@ -188,6 +227,27 @@ func normalizeActions(actions modelActions) modelActions {
 	if actions.loadModels == nil {
 		actions.loadModels = defaults.loadModels
 	}
+	if actions.cachedModels == nil {
+		actions.cachedModels = defaults.cachedModels
+	}
+	if actions.fingerprintProfile == nil {
+		actions.fingerprintProfile = defaults.fingerprintProfile
+	}
+	if actions.lockConfig == nil {
+		actions.lockConfig = defaults.lockConfig
+	}
+	if actions.recordOperation == nil {
+		actions.recordOperation = defaults.recordOperation
+	}
+	if actions.adoptActive == nil {
+		actions.adoptActive = defaults.adoptActive
+	}
+	if actions.declineAdopt == nil {
+		actions.declineAdopt = defaults.declineAdopt
+	}
+	if actions.discoverProfiles == nil {
+		actions.discoverProfiles = defaults.discoverProfiles
+	}
 	return actions
 }
 
@@ -203,6 +263,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.resizeViewport()
 	case applyResultMsg:
 		return m.handleApplyResult(msg)
+	case adoptResultMsg:
+		return m.handleAdoptResult(msg)
 	case backupsResultMsg:
 		return m.handleBackupsResult(msg)
 	case diffResultMsg:
@@ -245,6 +307,8 @@ func (m model) View() string {
 	body = strings.TrimRight(body, "\n")
 	if m.confirm.Open {
 		body += "\n\n" + m.renderConfirmModal()
+	} else if m.agentsConflict {
+		body += "\n\n" + m.renderConflictModal()
 	} else if m.helpOpen {
 		body += "\n\n" + m.renderHelpModal()
 	}
@@ -261,6 +325,9 @@ func (m model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	if m.confirm.Open {
 		return m.handleConfirmKey(msg)
 	}
+	if m.agentsConflict {
+		return m.handleConflictKey(msg)
+	}
 	if key == "?" {
 		m.helpOpen = !m.helpOpen
 		return m, nil
@@ -286,6 +353,9 @@ func (m model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		switch key {
 		case "esc":
 			m.screen = screenProfiles
+			if m.diffMode == diffModeUnsaved {
+				m.screen = screenAgents
+			}
 			return m, nil
 		case "j", "down":
 			m.viewport.LineDown(1)
@@ -319,6 +389,8 @@ func (m model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m.reloadAgents()
 		case "a":
 			return m.confirmAutofillAgents()
+		case "m":
+			m.cycleAutofillMode()
 		case "esc":
 			m.screen = screenProfiles
 		}
@@ -344,11 +416,49 @@ func (m model) confirmApplySelected() (tea.Model, tea.Cmd) {
 
 func (m model) applyProfile(name string) (tea.Model, tea.Cmd) {
 	return m, func() tea.Msg {
-		err := m.actions.applyProfile(m.configDir, name)
+		release, err := m.actions.lockConfig(m.configDir, m.lockTimeout)
+		if err != nil {
+			return applyResultMsg{profile: name, err: err}
+		}
+		defer release()
+		err = m.actions.applyProfile(m.configDir, name)
 		return applyResultMsg{profile: name, err: err}
 	}
 }
 
+// offerAdopt asks to turn a hand-written active config into the profile
+// name instead of showing no active profile. Answering no stops the offer
+// for good; esc only dismisses it until the next start.
+func (m *model) offerAdopt(name string) {
+	prompt := fmt.Sprintf("oh-my-opencode.json is not managed by moirai. Adopt it as profile '%s'? (y/n)", name)
+	m.openConfirm(prompt, func(m model) (tea.Model, tea.Cmd) {
+		return m.adoptActive(name)
+	})
+	m.confirm.OnNo = func(m model) (tea.Model, tea.Cmd) {
+		if err := m.actions.declineAdopt(m.configDir); err != nil {
+			m.setStatus(statusKindError, err.Error())
+		}
+		return m, nil
+	}
+	m.confirm.Details = []string{
+		fmt.Sprintf("It is backed up, copied to oh-my-opencode.json.%s and replaced by a symlink.", name),
+		"To pick another name, run: moirai adopt <name>",
+		"Answer n to stop asking, or esc to decide next time.",
+	}
+}
+
+func (m model) adoptActive(name string) (tea.Model, tea.Cmd) {
+	return m, func() tea.Msg {
+		release, err := m.actions.lockConfig(m.configDir, m.lockTimeout)
+		if err != nil {
+			return adoptResultMsg{profile: name, err: err}
+		}
+		defer release()
+		err = m.actions.adoptActive(m.configDir, name)
+		return adoptResultMsg{profile: name, err: err}
+	}
+}
+
 func (m model) openBackups() (tea.Model, tea.Cmd) {
 	name, ok := m.selectedProfile()
 	if !ok {
@@ -406,12 +516,42 @@ func (m model) openDiff(mode diffMode) (tea.Model, tea.Cmd) {
 }
 
 func (m model) handleApplyResult(msg applyResultMsg) (tea.Model, tea.Cmd) {
-	if msg.err != nil {
+	if msg.err != nil && !errors.Is(msg.err, errCommit) {
+		m.setStatus(statusKindError, msg.err.Error())
+		return m, nil
+	}
+
+	m.setDoneStatus(fmt.Sprintf("Applied: %s", msg.profile), msg.err)
+	activeName, ok, err := m.actions.activeProfile(m.configDir)
+	if err != nil {
+		m.setStatus(statusKindError, err.Error())
+		return m, nil
+	}
+	m.activeName = activeName
+	m.hasActive = ok
+	return m, nil
+}
+
+func (m model) handleAdoptResult(msg adoptResultMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil && !errors.Is(msg.err, errCommit) {
 		m.setStatus(statusKindError, msg.err.Error())
 		return m, nil
 	}
 
-	m.setStatus(statusKindSuccess, fmt.Sprintf("Applied: %s", msg.profile))
+	m.setDoneStatus(fmt.Sprintf("Adopted: %s", msg.profile), msg.err)
+	profiles, err := m.actions.discoverProfiles(m.configDir)
+	if err != nil {
+		m.setStatus(statusKindError, err.Error())
+		return m, nil
+	}
+	m.profiles = profiles
+	m.updateProfilesFilter()
+	for i, info := range m.profilesVisible {
+		if info.Name == msg.profile {
+			m.selected = i
+			break
+		}
+	}
 	activeName, ok, err := m.actions.activeProfile(m.configDir)
 	if err != nil {
 		m.setStatus(statusKindError, err.Error())
@@ -454,6 +594,9 @@ func (m model) handleDiffResult(msg diffResultMsg) (tea.Model, tea.Cmd) {
 		m.diffMessage = fmt.Sprintf("No backups found for profile: %s", msg.profile)
 		m.diffContent = ""
 	}
+	if m.diffMessage == "" && m.diffContent == "" {
+		m.diffMessage = "No differences."
+	}
 
 	m.viewport.SetContent(m.diffContent)
 	m.viewport.GotoTop()
@@ -3,8 +3,11 @@ package tui
 import (
 	"strings"
 	"testing"
+	"time"
 
+	"moirai/internal/journal"
 	"moirai/internal/profile"
+	"moirai/internal/schema"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -123,6 +126,57 @@ func TestApplyRefreshesActiveProfile(t *testing.T) {
 	}
 }
 
+func TestAdoptOfferAdoptsAndRefreshes(t *testing.T) {
+	adopted := ""
+	actions := stubActions()
+	actions.adoptActive = func(_, name string) error {
+		adopted = name
+		return nil
+	}
+	actions.discoverProfiles = func(_ string) ([]profile.ProfileInfo, error) {
+		return []profile.ProfileInfo{{Name: "alpha"}, {Name: "default"}}, nil
+	}
+	actions.activeProfile = func(_ string) (string, bool, error) {
+		return "default", true, nil
+	}
+
+	m := newModelWithActions("/config", false, []profile.ProfileInfo{{Name: "alpha"}}, "", false, actions)
+	m.offerAdopt("default")
+	if !m.confirm.Open || !strings.Contains(m.View(), "Adopt it as profile 'default'") {
+		t.Fatalf("expected the adopt prompt, got %q", m.View())
+	}
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	if cmd == nil {
+		t.Fatalf("expected adopt command after confirmation")
+	}
+	updated, _ = updated.(model).Update(cmd())
+	m = updated.(model)
+
+	if adopted != "default" {
+		t.Fatalf("expected default to be adopted, got %q", adopted)
+	}
+	if !m.hasActive || m.activeName != "default" || len(m.profiles) != 2 {
+		t.Fatalf("expected default active among 2 profiles, got %v %q %d", m.hasActive, m.activeName, len(m.profiles))
+	}
+	if name, ok := m.selectedProfile(); !ok || name != "default" {
+		t.Fatalf("expected the adopted profile selected, got %q", name)
+	}
+}
+
+func TestAdoptOfferDeclined(t *testing.T) {
+	actions := stubActions()
+	actions.adoptActive = func(_, _ string) error {
+		t.Fatal("adopt should not run when declined")
+		return nil
+	}
+	m := newModelWithActions("/config", false, nil, "", false, actions)
+	m.offerAdopt("default")
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if cmd != nil || updated.(model).confirm.Open {
+		t.Fatalf("expected the prompt to close without a command")
+	}
+}
+
 func TestBackupsScreenUsesListAndHandlesEmpty(t *testing.T) {
 	profiles := []profile.ProfileInfo{
 		{Name: "alpha"},
@@ -307,14 +361,35 @@ func stubActions() modelActions {
 		saveProfile: func(_ string, _ *profile.RootConfig) error {
 			return nil
 		},
-		backupProfile: func(_, _ string) (string, error) {
+		validateProfile: func(_, _ string, _ *profile.RootConfig) ([]schema.Error, error) {
+			return nil, nil
+		},
+		backupProfile: func(_, _, _ string) (string, error) {
 			return "", nil
 		},
-		applyAutofill: func(cfg *profile.RootConfig, knownAgents []string, preset profile.Preset) bool {
-			return profile.ApplyAutofill(cfg, knownAgents, preset)
+		applyAutofill: func(cfg *profile.RootConfig, changes []profile.AgentChange) bool {
+			return profile.ApplyAgentChanges(cfg, changes)
 		},
 		loadModels: func() []string {
 			return []string{"gpt-4o-mini"}
 		},
+		cachedModels: func() []string {
+			return []string{"gpt-4o-mini"}
+		},
+		fingerprintProfile: func(_ string) (profile.Fingerprint, error) {
+			return profile.Fingerprint{}, nil
+		},
+		lockConfig: func(_ string, _ time.Duration) (func(), error) {
+			return func() {}, nil
+		},
+		recordOperation: func(_ string, _ journal.Entry) error {
+			return nil
+		},
+		adoptActive: func(_, _ string) error {
+			return nil
+		},
+		discoverProfiles: func(_ string) ([]profile.ProfileInfo, error) {
+			return nil, nil
+		},
 	}
 }
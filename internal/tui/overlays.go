@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"fmt"
 	"strings"
 	"unicode/utf8"
 
@@ -30,6 +31,16 @@ func (m *model) setStatus(kind statusKind, msg string) {
 	}
 }
 
+// setDoneStatus reports an operation that completed. A non-nil err means
+// only its git store commit failed and is shown instead of plain success.
+func (m *model) setDoneStatus(msg string, err error) {
+	if err != nil {
+		m.setStatus(statusKindError, fmt.Sprintf("%s, but %v", msg, err))
+		return
+	}
+	m.setStatus(statusKindSuccess, msg)
+}
+
 func (m *model) clearStatusForKey(key string) {
 	if !m.status.ClearOnNextKey || m.status.Message == "" {
 		return
@@ -142,7 +153,12 @@ func statusTextStyle(kind statusKind) textStyle {
 type confirmState struct {
 	Open   bool
 	Prompt string
-	OnYes  func(model) (tea.Model, tea.Cmd)
+	// Details are extra lines shown below the prompt, such as a change plan.
+	Details []string
+	OnYes   func(model) (tea.Model, tea.Cmd)
+	// OnNo, when set, runs when the prompt is answered with n; esc only
+	// closes it.
+	OnNo func(model) (tea.Model, tea.Cmd)
 }
 
 func (m *model) openConfirm(prompt string, onYes func(model) (tea.Model, tea.Cmd)) {
@@ -162,7 +178,14 @@ func (m model) handleConfirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 		return onYes(m)
-	case "n", "N", "esc":
+	case "n", "N":
+		onNo := m.confirm.OnNo
+		m.confirm = confirmState{}
+		if onNo == nil {
+			return m, nil
+		}
+		return onNo(m)
+	case "esc":
 		m.confirm = confirmState{}
 		return m, nil
 	}
@@ -170,11 +193,12 @@ func (m model) handleConfirmKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) renderConfirmModal() string {
-	lines := []string{
-		m.confirm.Prompt,
-		"",
-		"y yes · n no · esc cancel",
+	lines := []string{m.confirm.Prompt}
+	if len(m.confirm.Details) > 0 {
+		lines = append(lines, "")
+		lines = append(lines, m.confirm.Details...)
 	}
+	lines = append(lines, "", "y yes · n no · esc cancel")
 	return renderBox("Confirm", lines, m.width)
 }
 
@@ -220,7 +244,8 @@ func (m model) helpLines() []string {
 			"enter pick model",
 			"s save (confirm when dirty)",
 			"r reload",
-			"a autofill (confirm)",
+			"a autofill (shows plan, confirm)",
+			"m cycle autofill mode",
 			"esc back",
 			"q quit",
 			"? help",
@@ -259,6 +284,9 @@ func (m model) statusHints() string {
 	if m.confirm.Open {
 		return "y yes · n no · esc cancel · q quit"
 	}
+	if m.agentsConflict {
+		return "r reload · o overwrite · d diff · esc cancel · q quit"
+	}
 	if m.helpOpen {
 		return "esc close · q quit"
 	}
@@ -268,7 +296,7 @@ func (m model) statusHints() string {
 	case screenDiff:
 		return "j/k scroll · pgup/pgdown page · a/d diff mode · esc back · ? help · q quit"
 	case screenAgents:
-		return "j/k move · enter models · s save · r reload · a autofill · esc back · ? help · q quit"
+		return "j/k move · enter models · s save · r reload · a autofill · m mode · esc back · ? help · q quit"
 	case screenModels:
 		return "type search · ctrl+u clear · j/k move · pgup/pgdown page · enter select · R refresh · esc cancel · ? help · q quit"
 	default:
@@ -2,7 +2,10 @@ package tui
 
 import (
 	"moirai/internal/app"
+	"moirai/internal/catalog"
+	"moirai/internal/lifecycle"
 	"moirai/internal/link"
+	"moirai/internal/presets"
 	"moirai/internal/profile"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -32,5 +35,31 @@ func loadModel(config app.AppConfig) (model, error) {
 	if err != nil {
 		return model{}, err
 	}
-	return newModel(config.ConfigDir, config.EnableAutofill, profiles, activeName, ok), nil
+	m := newModel(config.ConfigDir, config.EnableAutofill, profiles, activeName, ok)
+	m.lockTimeout = config.LockTimeout
+	m.actions.applyProfile = applyProfileWith(config)
+	m.actions.listProfileBackups = listProfileBackupsWith(config)
+	m.actions.diffAgainstLastBackup = diffAgainstLastBackupWith(config)
+	m.actions.backupProfile = backupProfileWith(config)
+	m.actions.adoptActive = adoptActiveWith(config)
+	m.actions.recordOperation = recordOperationWith(config)
+	if config.SchemaPath != "" {
+		m.actions.validateProfile = validateProfileWith(config.SchemaPath)
+	}
+	m.catalog = catalog.Load(config)
+	m.presetName = config.DefaultPreset
+	if set, err := presets.Load(config); err != nil {
+		m.presetsErr = err
+	} else {
+		m.presets = set
+	}
+	// A hand-written active config is only reported as "(none)"; offer to
+	// turn it into a profile unless the user declined before. A failed check
+	// just skips the offer.
+	if unmanaged, err := lifecycle.UnmanagedActive(config.ConfigDir); err == nil && unmanaged && !lifecycle.AdoptDeclined(config.ConfigDir) {
+		if name, err := lifecycle.SuggestAdoptName(config.ConfigDir); err == nil {
+			m.offerAdopt(name)
+		}
+	}
+	return m, nil
 }
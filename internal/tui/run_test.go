@@ -3,6 +3,7 @@ package tui
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"moirai/internal/app"
@@ -37,6 +38,73 @@ func TestLoadModelUsesProfilesAndActive(t *testing.T) {
 	}
 }
 
+func TestLoadModelOffersAdoptingUnmanagedActive(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "oh-my-opencode.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("write active: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "oh-my-opencode.json.default"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("write default: %v", err)
+	}
+
+	m, err := loadModel(app.AppConfig{ConfigDir: dir})
+	if err != nil {
+		t.Fatalf("loadModel: %v", err)
+	}
+	if !m.confirm.Open || !strings.Contains(m.confirm.Prompt, "'default-2'") {
+		t.Fatalf("expected an adopt prompt for a free name, got %+v", m.confirm)
+	}
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	updated, _ = updated.(model).Update(cmd())
+	m = updated.(model)
+	if !m.hasActive || m.activeName != "default-2" {
+		t.Fatalf("expected default-2 active, got %v %q (%s)", m.hasActive, m.activeName, m.status.Message)
+	}
+	if info, err := os.Lstat(filepath.Join(dir, "oh-my-opencode.json")); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("expected the active config to be a symlink, got %v %v", info, err)
+	}
+}
+
+func TestLoadModelStopsOfferingDeclinedAdopt(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "oh-my-opencode.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("write active: %v", err)
+	}
+
+	// Dismissing the prompt leaves it for the next start.
+	m, err := loadModel(app.AppConfig{ConfigDir: dir})
+	if err != nil {
+		t.Fatalf("loadModel: %v", err)
+	}
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if updated.(model).confirm.Open {
+		t.Fatal("expected esc to close the adopt prompt")
+	}
+	m, err = loadModel(app.AppConfig{ConfigDir: dir})
+	if err != nil {
+		t.Fatalf("loadModel: %v", err)
+	}
+	if !m.confirm.Open {
+		t.Fatal("expected the adopt prompt again after esc")
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if status := updated.(model).status; status.Kind == statusKindError {
+		t.Fatalf("decline failed: %s", status.Message)
+	}
+	m, err = loadModel(app.AppConfig{ConfigDir: dir})
+	if err != nil {
+		t.Fatalf("loadModel: %v", err)
+	}
+	if m.confirm.Open {
+		t.Fatalf("expected no adopt prompt after declining, got %+v", m.confirm)
+	}
+	if info, err := os.Lstat(filepath.Join(dir, "oh-my-opencode.json")); err != nil || !info.Mode().IsRegular() {
+		t.Fatalf("expected the active config left alone, got %v %v", info, err)
+	}
+}
+
 func TestRunInvokesProgramRunner(t *testing.T) {
 	origRunner := programRunner
 	t.Cleanup(func() {
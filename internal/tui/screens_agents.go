@@ -1,11 +1,16 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
+	"path/filepath"
 	"sort"
 	"strings"
 
+	"moirai/internal/catalog"
+	"moirai/internal/journal"
 	"moirai/internal/profile"
+	"moirai/internal/secrets"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -14,6 +19,11 @@ type agentEntry struct {
 	Name    string
 	Model   string
 	Missing bool
+	// Catalog metadata; InCatalog is false for agents only found in the profile.
+	InCatalog   bool
+	Required    bool
+	Description string
+	ModelClass  string
 }
 
 func (m model) viewAgents() string {
@@ -31,9 +41,10 @@ func (m model) viewAgents() string {
 		if m.height > 0 {
 			// Header lines here:
 			//   Profile, optional "Unsaved changes", blank, "Agents:"
-			headerLines := 3
+			// plus the blank line and description of the selected agent.
+			headerLines := 5
 			if m.agentsDirty {
-				headerLines = 4
+				headerLines = 6
 			}
 			// Reserve title art + blank separator + status bar.
 			pageSize = m.height - (titleArtHeight()+2) - headerLines
@@ -49,9 +60,16 @@ func (m model) viewAgents() string {
 				prefix = "> "
 			}
 			name := entry.Name
+			if entry.Required {
+				name += "*"
+			}
 			modelLabel := entry.Model
 			if strings.TrimSpace(modelLabel) == "" {
-				modelLabel = missingStyle.Render("(missing)")
+				if entry.Required {
+					modelLabel = missingStyle.Render("(missing)")
+				} else {
+					modelLabel = hintStyle.Render("(unset)")
+				}
 			}
 			line := fmt.Sprintf("%s%s: %s", prefix, name, modelLabel)
 			if i == m.agentsSelected {
@@ -59,20 +77,43 @@ func (m model) viewAgents() string {
 			}
 			fmt.Fprintln(&b, line)
 		}
+		if entry, ok := m.selectedAgent(); ok {
+			b.WriteString("\n")
+			b.WriteString(hintStyle.Render(agentDetails(entry)))
+			b.WriteString("\n")
+		}
 	}
 	return b.String()
 }
 
+// agentDetails describes the selected agent using its catalog metadata.
+func agentDetails(entry agentEntry) string {
+	if !entry.InCatalog {
+		return fmt.Sprintf("%s: not in the agent catalog", entry.Name)
+	}
+	details := make([]string, 0, 2)
+	if entry.ModelClass != "" {
+		details = append(details, entry.ModelClass+" model")
+	}
+	if entry.Required {
+		details = append(details, "required")
+	} else {
+		details = append(details, "optional")
+	}
+	text := entry.Name
+	if entry.Description != "" {
+		text += ": " + entry.Description
+	}
+	return text + " (" + strings.Join(details, ", ") + ")"
+}
+
 func (m model) openAgents() (tea.Model, tea.Cmd) {
 	info, ok := m.selectedProfileInfo()
 	if !ok {
 		m.setStatus(statusKindError, "No profiles available.")
 		return m, nil
 	}
-	return m, func() tea.Msg {
-		cfg, err := m.actions.loadProfile(info.Path)
-		return agentsLoadMsg{profile: info, cfg: cfg, err: err}
-	}
+	return m, m.loadAgentsCmd(info)
 }
 
 func (m model) reloadAgents() (tea.Model, tea.Cmd) {
@@ -80,9 +121,94 @@ func (m model) reloadAgents() (tea.Model, tea.Cmd) {
 		m.setStatus(statusKindError, "No profile loaded.")
 		return m, nil
 	}
-	return m, func() tea.Msg {
-		cfg, err := m.actions.loadProfile(m.agentsProfile.Path)
-		return agentsLoadMsg{profile: m.agentsProfile, cfg: cfg, err: err}
+	return m, m.loadAgentsCmd(m.agentsProfile)
+}
+
+func (m model) loadAgentsCmd(info profile.ProfileInfo) tea.Cmd {
+	return func() tea.Msg {
+		// Fingerprint before reading so an edit racing the load shows up as
+		// a conflict on save instead of being overwritten.
+		fingerprint, err := m.actions.fingerprintProfile(info.Path)
+		if err != nil {
+			return agentsLoadMsg{profile: info, err: err}
+		}
+		cfg, err := m.actions.loadProfile(info.Path)
+		return agentsLoadMsg{profile: info, cfg: cfg, fingerprint: fingerprint, err: err}
+	}
+}
+
+// errProfileChanged reports that the profile file was modified by someone
+// else after the agents screen loaded it.
+var errProfileChanged = errors.New("profile changed on disk since it was loaded")
+
+// persistAgents backs up the profile and writes cfg while holding the config
+// dir lock, journaling the write as op. Unless force is set, it refuses to
+// overwrite a profile whose content changed since it was loaded. It returns
+// the new fingerprint, together with an error wrapping errCommit when
+// the save stands but its git store commit failed.
+func (m model) persistAgents(cfg *profile.RootConfig, force bool, op string) (profile.Fingerprint, error) {
+	release, err := m.actions.lockConfig(m.configDir, m.lockTimeout)
+	if err != nil {
+		return profile.Fingerprint{}, err
+	}
+	defer release()
+
+	if !force && !m.agentsFingerprint.IsZero() {
+		current, err := m.actions.fingerprintProfile(m.agentsProfile.Path)
+		if err != nil {
+			return profile.Fingerprint{}, err
+		}
+		if current.Changed(m.agentsFingerprint) {
+			return profile.Fingerprint{}, errProfileChanged
+		}
+	}
+	beforeHash, err := journal.HashFile(m.agentsProfile.Path)
+	if err != nil {
+		return profile.Fingerprint{}, err
+	}
+	backupPath, err := m.actions.backupProfile(m.configDir, m.agentsProfile.Name, op)
+	if err != nil {
+		return profile.Fingerprint{}, err
+	}
+	if err := m.actions.saveProfile(m.agentsProfile.Path, cfg); err != nil {
+		return profile.Fingerprint{}, err
+	}
+	afterHash, _ := journal.HashFile(m.agentsProfile.Path)
+	var commitErr error
+	if err := m.actions.recordOperation(m.configDir, journal.Entry{
+		Op:         op,
+		Profile:    m.agentsProfile.Name,
+		File:       filepath.Base(m.agentsProfile.Path),
+		BeforeHash: beforeHash,
+		AfterHash:  afterHash,
+		Backup:     backupPath,
+	}); errors.Is(err, errCommit) {
+		commitErr = err
+	}
+	fingerprint, err := m.actions.fingerprintProfile(m.agentsProfile.Path)
+	if err != nil {
+		// The save succeeded; without a fingerprint the next save simply
+		// skips the conflict check.
+		return profile.Fingerprint{}, commitErr
+	}
+	return fingerprint, commitErr
+}
+
+// saveAgentsCmd saves the edited profile. With validate set, a profile that
+// does not match its schema is not saved and the errors are reported so the
+// user can decide.
+func (m model) saveAgentsCmd(force, validate bool) tea.Cmd {
+	cfg := m.agentsConfig
+	return func() tea.Msg {
+		if validate {
+			// A schema that cannot be checked does not block the save.
+			invalid, err := m.actions.validateProfile(m.configDir, m.agentsProfile.Name, cfg)
+			if err == nil && len(invalid) > 0 {
+				return agentsSaveMsg{invalid: invalid, force: force}
+			}
+		}
+		fingerprint, err := m.persistAgents(cfg, force, journal.OpSave)
+		return agentsSaveMsg{fingerprint: fingerprint, err: err}
 	}
 }
 
@@ -95,16 +221,7 @@ func (m model) saveAgents() (tea.Model, tea.Cmd) {
 		m.setStatus(statusKindError, "No profile loaded.")
 		return m, nil
 	}
-	cfg := m.agentsConfig
-	return m, func() tea.Msg {
-		if _, err := m.actions.backupProfile(m.configDir, m.agentsProfile.Name); err != nil {
-			return agentsSaveMsg{err: err}
-		}
-		if err := m.actions.saveProfile(m.agentsProfile.Path, cfg); err != nil {
-			return agentsSaveMsg{err: err}
-		}
-		return agentsSaveMsg{}
-	}
+	return m, m.saveAgentsCmd(false, true)
 }
 
 func (m model) confirmSaveAgents() (tea.Model, tea.Cmd) {
@@ -121,6 +238,68 @@ func (m model) confirmSaveAgents() (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// autofillModes is the order the agents screen cycles through with "m".
+var autofillModes = []profile.AutofillMode{
+	profile.AutofillMissing,
+	profile.AutofillOverwrite,
+	profile.AutofillUpgrade,
+	profile.AutofillOnly,
+}
+
+func (m *model) cycleAutofillMode() {
+	next := autofillModes[0]
+	for i, mode := range autofillModes {
+		if mode == m.autofillMode {
+			next = autofillModes[(i+1)%len(autofillModes)]
+			break
+		}
+	}
+	m.autofillMode = next
+	m.setStatus(statusKindInfo, fmt.Sprintf("Autofill mode: %s", autofillModeLabel(next)))
+}
+
+func autofillModeLabel(mode profile.AutofillMode) string {
+	if mode == profile.AutofillOnly {
+		return "only selected agent"
+	}
+	return string(mode)
+}
+
+// autofillOptions builds the options for the current mode; only applies to
+// the selected agent and upgrade checks the cached model list.
+func (m model) autofillOptions() (profile.AutofillOptions, error) {
+	opts := profile.AutofillOptions{Mode: m.autofillMode}
+	switch m.autofillMode {
+	case profile.AutofillOnly:
+		agent, ok := m.selectedAgent()
+		if !ok {
+			return opts, fmt.Errorf("no agent selected")
+		}
+		opts.Agents = []string{agent.Name}
+	case profile.AutofillUpgrade:
+		opts.Models = m.actions.cachedModels()
+	}
+	return opts, nil
+}
+
+// planAutofill resolves the default preset and lists the changes the current
+// autofill mode would make.
+func (m model) planAutofill() (profile.Preset, []profile.AgentChange, error) {
+	preset, err := m.autofillPreset()
+	if err != nil {
+		return profile.Preset{}, nil, fmt.Errorf("Autofill preset unavailable: %v", err)
+	}
+	opts, err := m.autofillOptions()
+	if err != nil {
+		return profile.Preset{}, nil, err
+	}
+	changes, err := profile.PlanAutofill(m.agentsConfig, m.catalog.Required(), preset, opts)
+	if err != nil {
+		return profile.Preset{}, nil, err
+	}
+	return preset, changes, nil
+}
+
 func (m model) autofillAgents() (tea.Model, tea.Cmd) {
 	if !m.enableAutofill {
 		m.setStatus(statusKindError, "Autofill disabled. Run with --enable-autofill.")
@@ -130,33 +309,37 @@ func (m model) autofillAgents() (tea.Model, tea.Cmd) {
 		m.setStatus(statusKindError, "No profile loaded.")
 		return m, nil
 	}
-	preset, ok := profile.PresetByName("openai")
-	if !ok {
-		m.setStatus(statusKindError, "Autofill preset unavailable.")
+	_, changes, err := m.planAutofill()
+	if err != nil {
+		m.setStatus(statusKindError, err.Error())
 		return m, nil
 	}
-	known := profile.KnownAgents()
 	return m, func() tea.Msg {
-		before := len(profile.MissingAgents(m.agentsConfig, known))
-		changed := m.actions.applyAutofill(m.agentsConfig, known, preset)
-		after := len(profile.MissingAgents(m.agentsConfig, known))
-		filled := before - after
-		if filled < 0 {
-			filled = 0
-		}
-		if !changed {
-			return agentsAutofillMsg{filled: filled, changed: false, saved: false}
-		}
-		if _, err := m.actions.backupProfile(m.configDir, m.agentsProfile.Name); err != nil {
-			return agentsAutofillMsg{filled: filled, changed: true, saved: false, err: err}
+		if !m.actions.applyAutofill(m.agentsConfig, changes) {
+			return agentsAutofillMsg{filled: 0, changed: false, saved: false}
 		}
-		if err := m.actions.saveProfile(m.agentsProfile.Path, m.agentsConfig); err != nil {
+		filled := len(changes)
+		fingerprint, err := m.persistAgents(m.agentsConfig, false, journal.OpAutofill)
+		if err != nil && !errors.Is(err, errCommit) {
 			return agentsAutofillMsg{filled: filled, changed: true, saved: false, err: err}
 		}
-		return agentsAutofillMsg{filled: filled, changed: true, saved: true}
+		return agentsAutofillMsg{filled: filled, changed: true, saved: true, fingerprint: fingerprint, err: err}
 	}
 }
 
+// autofillPreset resolves the configured default preset.
+func (m model) autofillPreset() (profile.Preset, error) {
+	if m.presetsErr != nil {
+		return profile.Preset{}, m.presetsErr
+	}
+	resolved, err := m.presets.Resolve(m.presetName)
+	if err != nil {
+		return profile.Preset{}, err
+	}
+	return resolved.Preset, nil
+}
+
+// confirmAutofillAgents shows the per-agent change plan before writing.
 func (m model) confirmAutofillAgents() (tea.Model, tea.Cmd) {
 	if !m.enableAutofill {
 		return m.autofillAgents()
@@ -164,14 +347,29 @@ func (m model) confirmAutofillAgents() (tea.Model, tea.Cmd) {
 	if m.agentsProfile.Name == "" || m.agentsProfile.Path == "" {
 		return m.autofillAgents()
 	}
-	if _, ok := profile.PresetByName("openai"); !ok {
+	preset, changes, err := m.planAutofill()
+	if err != nil {
 		return m.autofillAgents()
 	}
-	presetName := "openai"
-	prompt := fmt.Sprintf("Autofill missing agents using preset '%s' and save? (y/n)", presetName)
+	if len(changes) == 0 {
+		if m.autofillMode == profile.AutofillMissing {
+			m.setStatus(statusKindInfo, "No missing models to autofill.")
+		} else {
+			m.setStatus(statusKindInfo, fmt.Sprintf("Nothing to change in %s mode.", autofillModeLabel(m.autofillMode)))
+		}
+		return m, nil
+	}
+	prompt := fmt.Sprintf("Autofill (%s) using preset '%s' and save? (y/n)", autofillModeLabel(m.autofillMode), preset.Name)
 	m.openConfirm(prompt, func(m model) (tea.Model, tea.Cmd) {
 		return m.autofillAgents()
 	})
+	for _, change := range changes {
+		from := change.From
+		if from == "" {
+			from = "(unset)"
+		}
+		m.confirm.Details = append(m.confirm.Details, fmt.Sprintf("%s: %s -> %s", change.Agent, from, change.To))
+	}
 	return m, nil
 }
 
@@ -206,7 +404,8 @@ func (m model) handleAgentsLoad(msg agentsLoadMsg) (tea.Model, tea.Cmd) {
 	m.screen = screenAgents
 	m.agentsProfile = msg.profile
 	m.agentsConfig = msg.cfg
-	m.agentsEntries = collectAgentEntries(msg.cfg, profile.KnownAgents())
+	m.agentsFingerprint = msg.fingerprint
+	m.agentsEntries = collectAgentEntries(msg.cfg, m.catalog)
 	if len(m.agentsEntries) == 0 {
 		m.agentsSelected = -1
 	} else {
@@ -217,21 +416,56 @@ func (m model) handleAgentsLoad(msg agentsLoadMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m model) handleAgentsSave(msg agentsSaveMsg) (tea.Model, tea.Cmd) {
-	if msg.err != nil {
+	if errors.Is(msg.err, errProfileChanged) {
+		m.agentsDirty = true
+		m.openConflict()
+		return m, nil
+	}
+	if msg.err != nil && !errors.Is(msg.err, errCommit) {
 		m.setStatus(statusKindError, msg.err.Error())
 		return m, nil
 	}
+	if len(msg.invalid) > 0 {
+		m.openInvalidSave(msg)
+		return m, nil
+	}
 	m.agentsDirty = false
-	m.setStatus(statusKindSuccess, "Saved")
+	m.agentsFingerprint = msg.fingerprint
+	m.setDoneStatus("Saved", msg.err)
 	return m, nil
 }
 
+// maxInvalidDetails caps the schema errors listed in the save dialog.
+const maxInvalidDetails = 8
+
+func (m *model) openInvalidSave(msg agentsSaveMsg) {
+	prompt := fmt.Sprintf("'%s' does not match its schema. Save anyway? (y/n)", m.agentsProfile.Name)
+	force := msg.force
+	m.openConfirm(prompt, func(m model) (tea.Model, tea.Cmd) {
+		return m, m.saveAgentsCmd(force, false)
+	})
+	for i, schemaErr := range msg.invalid {
+		if i == maxInvalidDetails {
+			m.confirm.Details = append(m.confirm.Details, fmt.Sprintf("... and %d more", len(msg.invalid)-i))
+			break
+		}
+		m.confirm.Details = append(m.confirm.Details, schemaErr.String())
+	}
+	m.setStatus(statusKindError, fmt.Sprintf("Not saved: %d schema errors", len(msg.invalid)))
+}
+
 func (m model) handleAgentsAutofill(msg agentsAutofillMsg) (tea.Model, tea.Cmd) {
-	if msg.err != nil {
+	if errors.Is(msg.err, errProfileChanged) {
+		m.agentsDirty = true
+		m.agentsEntries = collectAgentEntries(m.agentsConfig, m.catalog)
+		m.openConflict()
+		return m, nil
+	}
+	if msg.err != nil && !errors.Is(msg.err, errCommit) {
 		m.setStatus(statusKindError, msg.err.Error())
 		if msg.changed {
 			m.agentsDirty = true
-			m.agentsEntries = collectAgentEntries(m.agentsConfig, profile.KnownAgents())
+			m.agentsEntries = collectAgentEntries(m.agentsConfig, m.catalog)
 		}
 		return m, nil
 	}
@@ -239,12 +473,71 @@ func (m model) handleAgentsAutofill(msg agentsAutofillMsg) (tea.Model, tea.Cmd)
 		m.setStatus(statusKindInfo, "No missing models to autofill.")
 		return m, nil
 	}
-	m.agentsEntries = collectAgentEntries(m.agentsConfig, profile.KnownAgents())
+	m.agentsEntries = collectAgentEntries(m.agentsConfig, m.catalog)
 	m.agentsDirty = !msg.saved
-	m.setStatus(statusKindSuccess, fmt.Sprintf("Autofilled %d agents", msg.filled))
+	if msg.saved {
+		m.agentsFingerprint = msg.fingerprint
+	}
+	m.setDoneStatus(fmt.Sprintf("Autofilled %d agents", msg.filled), msg.err)
+	return m, nil
+}
+
+func (m *model) openConflict() {
+	m.agentsConflict = true
+	m.setStatus(statusKindError, fmt.Sprintf("'%s' changed on disk.", m.agentsProfile.Name))
+}
+
+func (m model) handleConflictKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "r":
+		m.agentsConflict = false
+		return m.reloadAgents()
+	case "o":
+		m.agentsConflict = false
+		m.setStatus(statusKindInfo, "Saving...")
+		return m, m.saveAgentsCmd(true, true)
+	case "d":
+		m.agentsConflict = false
+		return m, m.diffUnsavedCmd()
+	case "esc":
+		m.agentsConflict = false
+		return m, nil
+	}
 	return m, nil
 }
 
+func (m model) renderConflictModal() string {
+	lines := []string{
+		fmt.Sprintf("'%s' changed on disk since it was loaded.", m.agentsProfile.Name),
+		"",
+		"r reload (discard changes) · o overwrite · d diff · esc cancel",
+	}
+	return renderBox("Conflict", lines, m.width)
+}
+
+// diffUnsavedCmd compares the profile on disk with the unsaved edits.
+func (m model) diffUnsavedCmd() tea.Cmd {
+	name := m.agentsProfile.Name
+	path := m.agentsProfile.Path
+	cfg := m.agentsConfig
+	return func() tea.Msg {
+		onDisk, err := m.actions.loadProfile(path)
+		if err != nil {
+			return diffResultMsg{mode: diffModeUnsaved, profile: name, err: err}
+		}
+		changes, err := profile.DiffConfigs(onDisk, cfg)
+		if err != nil {
+			return diffResultMsg{mode: diffModeUnsaved, profile: name, err: err}
+		}
+		return diffResultMsg{
+			mode:    diffModeUnsaved,
+			profile: name,
+			against: "on disk",
+			diff:    profile.FormatDiff("on disk", "unsaved", secrets.RedactChanges(changes), true),
+		}
+	}
+}
+
 func (m *model) moveAgentsSelection(delta int) {
 	if len(m.agentsEntries) == 0 {
 		return
@@ -263,7 +556,7 @@ func (m *model) updateAgentsEntries() {
 	if m.agentsSelected >= 0 && m.agentsSelected < len(m.agentsEntries) {
 		selectedName = m.agentsEntries[m.agentsSelected].Name
 	}
-	m.agentsEntries = collectAgentEntries(m.agentsConfig, profile.KnownAgents())
+	m.agentsEntries = collectAgentEntries(m.agentsConfig, m.catalog)
 	if len(m.agentsEntries) == 0 {
 		m.agentsSelected = -1
 		return
@@ -288,21 +581,25 @@ func (m model) selectedAgent() (agentEntry, bool) {
 	return m.agentsEntries[m.agentsSelected], true
 }
 
-func collectAgentEntries(cfg *profile.RootConfig, knownAgents []string) []agentEntry {
-	entries := make([]agentEntry, 0, len(knownAgents))
-	seen := make(map[string]struct{}, len(knownAgents))
-	for _, name := range knownAgents {
-		seen[name] = struct{}{}
+func collectAgentEntries(cfg *profile.RootConfig, agents catalog.Catalog) []agentEntry {
+	entries := make([]agentEntry, 0, len(agents.Agents))
+	seen := make(map[string]struct{}, len(agents.Agents))
+	for _, agent := range agents.Agents {
+		seen[agent.Name] = struct{}{}
 		model := ""
 		if cfg != nil && cfg.Agents != nil {
-			if entry, ok := cfg.Agents[name]; ok {
+			if entry, ok := cfg.Agents[agent.Name]; ok {
 				model = entry.Model
 			}
 		}
 		entries = append(entries, agentEntry{
-			Name:    name,
-			Model:   model,
-			Missing: strings.TrimSpace(model) == "",
+			Name:        agent.Name,
+			Model:       model,
+			Missing:     strings.TrimSpace(model) == "",
+			InCatalog:   true,
+			Required:    agent.Required,
+			Description: agent.Description,
+			ModelClass:  agent.ModelClass,
 		})
 	}
 	custom := make([]string, 0)
@@ -15,6 +15,8 @@ func (m model) viewDiff() string {
 		} else {
 			title = fmt.Sprintf("Diff: %s vs active", m.diffProfile)
 		}
+	case diffModeUnsaved:
+		title = fmt.Sprintf("Diff: %s on disk vs unsaved changes", m.diffProfile)
 	default:
 		title = fmt.Sprintf("Diff: %s vs last-backup", m.diffProfile)
 	}
@@ -134,20 +134,8 @@ func (m model) selectModel() (tea.Model, tea.Cmd) {
 		m.setStatus(statusKindError, "No profile loaded.")
 		return m, nil
 	}
-	cfg := m.agentsConfig
-	profileName := m.agentsProfile.Name
-	profilePath := m.agentsProfile.Path
-	configDir := m.configDir
 	m.setStatus(statusKindInfo, "Saving...")
-	return m, func() tea.Msg {
-		if _, err := m.actions.backupProfile(configDir, profileName); err != nil {
-			return agentsSaveMsg{err: err}
-		}
-		if err := m.actions.saveProfile(profilePath, cfg); err != nil {
-			return agentsSaveMsg{err: err}
-		}
-		return agentsSaveMsg{}
-	}
+	return m, m.saveAgentsCmd(false, true)
 }
 
 func (m *model) updateModelFilter() {
@@ -234,6 +222,20 @@ func loadModelList() []string {
 	}
 }
 
+// loadCachedModelList returns the cached opencode model list without the
+// built-in fallback, or nil when no cache exists.
+func loadCachedModelList() []string {
+	configHome, err := resolveConfigHome()
+	if err != nil {
+		return nil
+	}
+	models, ok, err := modelsCache.LoadCachedModels(configHome)
+	if err != nil || !ok {
+		return nil
+	}
+	return models
+}
+
 func defaultModelList() []string {
 	return []string{
 		"gpt-4o-mini",
@@ -35,9 +35,17 @@ func ListDir(dir string) ([]os.DirEntry, error) {
 	return os.ReadDir(dir)
 }
 
+// TimestampLayout is the time layout used for backup file suffixes.
+const TimestampLayout = "20060102-150405"
+
 // Timestamp returns a local time string formatted as YYYYMMDD-HHMMSS.
 func Timestamp() string {
-	return time.Now().Format("20060102-150405")
+	return time.Now().Format(TimestampLayout)
+}
+
+// ParseTimestamp parses a YYYYMMDD-HHMMSS string in local time.
+func ParseTimestamp(value string) (time.Time, error) {
+	return time.ParseInLocation(TimestampLayout, value, time.Local)
 }
 
 // CopyFileAtomic copies src to dst using a temp file in dst's directory.
@@ -71,11 +79,27 @@ func CopyFileAtomic(src, dst string) error {
 		_ = tempFile.Close()
 		return err
 	}
+	if err := tempFile.Sync(); err != nil {
+		_ = tempFile.Close()
+		return err
+	}
 	if err := tempFile.Close(); err != nil {
 		return err
 	}
 	if err := os.Rename(tempName, dst); err != nil {
 		return err
 	}
+	SyncDir(dir)
 	return nil
 }
+
+// SyncDir flushes directory metadata (such as a rename) to disk. It is best
+// effort because not every platform supports syncing directories.
+func SyncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}